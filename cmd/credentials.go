@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage upstream credentials used to fetch restricted data from a source",
+}
+
+var credentialsSetCmd = &cobra.Command{
+	Use:   "set <source>",
+	Short: "Store credentials for a source (by name or id)",
+	Long: "Store credentials for an upstream source, encrypted with the key in secrets.key_file.\n" +
+		"Use --username (password read from stdin when --password is omitted) for Digest\n" +
+		"/queryauth access, or --eida-token-file for an EIDA authentication token.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username, _ := cmd.Flags().GetString("username")
+		password, _ := cmd.Flags().GetString("password")
+		tokenFile, _ := cmd.Flags().GetString("eida-token-file")
+
+		cred := &models.SourceCredentials{Username: username, Password: password}
+		if tokenFile != "" {
+			data, err := os.ReadFile(tokenFile)
+			if err != nil {
+				return fmt.Errorf("read token file: %w", err)
+			}
+			cred.EIDAToken = string(data)
+		}
+		if username != "" && password == "" {
+			fmt.Fprint(os.Stderr, "Password: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read password: %w", err)
+			}
+			cred.Password = strings.TrimRight(line, "\r\n")
+		}
+		if (cred.Username == "" || cred.Password == "") && cred.EIDAToken == "" {
+			return fmt.Errorf("either --username/--password or --eida-token-file is required")
+		}
+
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		src, err := findSource(db, args[0])
+		if err != nil {
+			return err
+		}
+		box := openSecrets()
+		if box == nil {
+			return store.ErrNoSecretKey
+		}
+		if err := store.NewCredentialStore(db, box).Set(src.ID, cred); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Credentials saved for source:", src.Name)
+		return nil
+	},
+}
+
+var credentialsClearCmd = &cobra.Command{
+	Use:   "clear <source>",
+	Short: "Remove stored credentials for a source (by name or id)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		src, err := findSource(db, args[0])
+		if err != nil {
+			return err
+		}
+		if err := store.NewCredentialStore(db, nil).Delete(src.ID); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Credentials removed for source:", src.Name)
+		return nil
+	},
+}
+
+// findSource resolves a source by numeric id or case-insensitive name.
+func findSource(db *database.DB, ref string) (*models.Source, error) {
+	srcStore := store.NewSourceStore(db)
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		if src, err := srcStore.Get(id); err == nil {
+			return src, nil
+		}
+	}
+	sources, err := srcStore.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		if strings.EqualFold(sources[i].Name, ref) {
+			return &sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("source %q not found", ref)
+}
+
+func init() {
+	credentialsSetCmd.Flags().String("username", "", "username for Digest /queryauth access")
+	credentialsSetCmd.Flags().String("password", "", "password (read from stdin when omitted)")
+	credentialsSetCmd.Flags().String("eida-token-file", "", "path to an EIDA authentication token file")
+
+	credentialsCmd.AddCommand(credentialsSetCmd, credentialsClearCmd)
+	rootCmd.AddCommand(credentialsCmd)
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/database"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Database maintenance",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Show, apply or revert schema migrations",
+	Long: "Schema migrations are tracked in the schema_migrations table. fdsn serve applies\n" +
+		"pending migrations on start; these commands manage them explicitly.",
+}
+
+var dbMigrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they are applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		status, err := database.Status(db)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	},
+}
+
+var dbMigrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetInt("to")
+
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		done, err := database.MigrateUp(db, to)
+		for _, m := range done {
+			fmt.Fprintf(os.Stderr, "Applied %03d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(os.Stderr, "Database is up to date")
+		}
+		return err
+	},
+}
+
+var dbMigrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the most recent migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, _ := cmd.Flags().GetInt("steps")
+		if steps < 1 {
+			return fmt.Errorf("--steps must be at least 1")
+		}
+
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		done, err := database.MigrateDown(db, steps)
+		for _, m := range done {
+			fmt.Fprintf(os.Stderr, "Reverted %03d_%s\n", m.Version, m.Name)
+		}
+		return err
+	},
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup <path>",
+	Short: "Write a consistent copy of the SQLite database",
+	Long: "Copy the database to a new file using SQLite's online backup API. It is safe to\n" +
+		"run while fdsn serve is running. For PostgreSQL use pg_dump.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if err := database.Backup(db, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Backed up database to %s\n", args[0])
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Replace the SQLite database with a backup",
+	Long: "Check the backup's integrity, copy it over the configured database and apply any\n" +
+		"migrations newer than the backup. Everything imported since the backup is lost.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if err := database.Restore(db, args[0]); err != nil {
+			return err
+		}
+		if err := database.Migrate(db); err != nil {
+			return fmt.Errorf("database migrate: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Restored database from %s\n", args[0])
+		return nil
+	},
+}
+
+var dbVacuumCmd = &cobra.Command{
+	Use:   "vacuum",
+	Short: "Reclaim free space and refresh query planner statistics",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		return database.Vacuum(db)
+	},
+}
+
+var dbIntegrityCheckCmd = &cobra.Command{
+	Use:   "integrity-check",
+	Short: "Check the SQLite database for corruption and broken references",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDBUnmigrated()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		problems, err := database.IntegrityCheck(db)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems found", len(problems))
+		}
+		fmt.Println("ok")
+		return nil
+	},
+}
+
+func init() {
+	dbMigrateUpCmd.Flags().Int("to", 0, "apply migrations up to this version (default all)")
+	dbMigrateDownCmd.Flags().Int("steps", 1, "number of migrations to revert")
+
+	dbMigrateCmd.AddCommand(dbMigrateStatusCmd, dbMigrateUpCmd, dbMigrateDownCmd)
+	dbCmd.AddCommand(dbMigrateCmd, dbBackupCmd, dbRestoreCmd, dbVacuumCmd, dbIntegrityCheckCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover <network>...",
+	Short: "Find the data centres serving networks and add them as sources",
+	Long: "Look up each network code with the EIDA routing service and the IRIS fedcatalog\n" +
+		"(discovery.eida_routing_url, discovery.fedcatalog_url) and create a source for\n" +
+		"every data centre that is not registered yet.",
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		d := discovery.NewDiscoverer(newDiscoveryClient(), store.NewSourceStore(db))
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NETWORK\tSOURCE\tBASE URL\tVIA\tSTATUS")
+		for _, network := range args {
+			found, err := d.Discover(network)
+			if err != nil {
+				_ = tw.Flush()
+				return fmt.Errorf("discover %s: %w", network, err)
+			}
+			if len(found) == 0 {
+				fmt.Fprintf(tw, "%s\t-\t-\t-\tnot found\n", network)
+			}
+			for _, s := range found {
+				status := "existing"
+				if s.Created {
+					status = "created"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", network, s.Name, s.BaseURL, s.Via, status)
+			}
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/joescharf/fdsn/internal/extract"
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var eventCmd = &cobra.Command{
+	Use:   "event",
+	Short: "Import and list seismic events",
+}
+
+var eventImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import events from a source's FDSN event service",
+	Long: "Import events as QuakeML, with their origins and magnitudes, from a source's\n" +
+		"FDSN event service. Events imported before are updated in place. Use\n" +
+		"--format text for services without QuakeML; it carries one origin and\n" +
+		"magnitude per event.",
+	Example: "  fdsn event import --source Earthscope --start 2024-01-01 --minmag 6\n" +
+		"  fdsn event import --source Earthscope --eventid 11793085 --arrivals",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, _ := cmd.Flags().GetString("source")
+		if ref == "" {
+			return fmt.Errorf("--source is required")
+		}
+		q := fdsnclient.EventQuery{}
+		q.StartTime, _ = cmd.Flags().GetString("start")
+		q.EndTime, _ = cmd.Flags().GetString("end")
+		q.MinMag, _ = cmd.Flags().GetString("minmag")
+		q.MaxMag, _ = cmd.Flags().GetString("maxmag")
+		q.MinLat, _ = cmd.Flags().GetString("minlat")
+		q.MaxLat, _ = cmd.Flags().GetString("maxlat")
+		q.MinLon, _ = cmd.Flags().GetString("minlon")
+		q.MaxLon, _ = cmd.Flags().GetString("maxlon")
+		q.Lat, _ = cmd.Flags().GetString("lat")
+		q.Lon, _ = cmd.Flags().GetString("lon")
+		q.MaxRadius, _ = cmd.Flags().GetString("maxradius")
+		q.EventID, _ = cmd.Flags().GetString("eventid")
+		q.Catalog, _ = cmd.Flags().GetString("catalog")
+		q.Limit, _ = cmd.Flags().GetString("limit")
+		q.Format, _ = cmd.Flags().GetString("format")
+		q.IncludeArrivals, _ = cmd.Flags().GetBool("arrivals")
+		if q.Format != "xml" && q.Format != "text" {
+			return fmt.Errorf("--format must be xml or text")
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		src, err := findSource(db, ref)
+		if err != nil {
+			return err
+		}
+		im := importer.NewEventImporter(store.NewSourceStore(db), store.NewEventStore(db))
+		res, err := im.Import(importer.EventRequest{SourceID: src.ID, Query: q})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d events fetched, %d new, %d updated\n", res.SourceName, res.Fetched, res.Inserted, res.Updated)
+		return nil
+	},
+}
+
+var eventListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List imported events, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q := store.EventQuery{}
+		q.Limit, _ = cmd.Flags().GetInt("limit")
+		q.OrderBy, _ = cmd.Flags().GetString("orderby")
+		if v, _ := cmd.Flags().GetFloat64("minmag"); cmd.Flags().Changed("minmag") {
+			q.MinMagnitude = &v
+		}
+		for _, f := range []struct {
+			name string
+			dst  **time.Time
+		}{{"start", &q.StartTime}, {"end", &q.EndTime}} {
+			s, _ := cmd.Flags().GetString(f.name)
+			if s == "" {
+				continue
+			}
+			t, err := parseEventTime(s)
+			if err != nil {
+				return fmt.Errorf("--%s: %w", f.name, err)
+			}
+			*f.dst = &t
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if ref, _ := cmd.Flags().GetString("source"); ref != "" {
+			src, err := findSource(db, ref)
+			if err != nil {
+				return err
+			}
+			q.SourceID = src.ID
+		}
+		page, err := store.NewEventStore(db).ListEvents(q)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTIME\tLATITUDE\tLONGITUDE\tDEPTH KM\tMAGNITUDE\tREGION\tSOURCE")
+		for _, ev := range page.Events {
+			depth, mag := "-", "-"
+			if ev.DepthKM != nil {
+				depth = strconv.FormatFloat(*ev.DepthKM, 'f', 1, 64)
+			}
+			if ev.Magnitude != nil {
+				mag = strconv.FormatFloat(*ev.Magnitude, 'f', 1, 64) + " " + ev.MagnitudeType
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%.3f\t%.3f\t%s\t%s\t%s\t%s\n", ev.ID, ev.Time.UTC().Format("2006-01-02 15:04:05"),
+				ev.Latitude, ev.Longitude, depth, mag, ev.Description, ev.SourceName)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if int64(len(page.Events)) < page.Total {
+			fmt.Fprintf(os.Stderr, "showing %d of %d events\n", len(page.Events), page.Total)
+		}
+		return nil
+	},
+}
+
+var eventRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove an imported event with its origins, magnitudes and picks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid event id %q", args[0])
+		}
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if err := store.NewEventStore(db).DeleteEvent(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "removed event %d\n", id)
+		return nil
+	},
+}
+
+var eventExtractCmd = &cobra.Command{
+	Use:   "extract <id>",
+	Short: "Fetch the waveforms of the stations around an event into a bundle",
+	Long: "Fetch the waveforms of the imported stations within a distance range of an\n" +
+		"event, one request per channel, and package them as a zip with their\n" +
+		"StationXML. With --window phases (the default) each window runs from\n" +
+		"--before seconds ahead of the IASP91 first P arrival to --after seconds\n" +
+		"past the first S; with --window offsets it runs from --before seconds\n" +
+		"ahead of the origin time to --after seconds past it. The bundle is kept\n" +
+		"in extract.dir and listed in the web UI.",
+	Example: "  fdsn event extract 42 --maxradius 30 --channel BH?,HH? --output event42.zip\n" +
+		"  fdsn event extract 42 --window offsets --before 0 --after 3600 --dry-run",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid event id %q", args[0])
+		}
+		p := extract.Params{}
+		p.MinRadius, _ = cmd.Flags().GetFloat64("minradius")
+		p.MaxRadius, _ = cmd.Flags().GetFloat64("maxradius")
+		p.Channel, _ = cmd.Flags().GetString("channel")
+		p.Window, _ = cmd.Flags().GetString("window")
+		p.Before, _ = cmd.Flags().GetFloat64("before")
+		p.After, _ = cmd.Flags().GetFloat64("after")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		output, _ := cmd.Flags().GetString("output")
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+		waves, err := openWaveCache()
+		if err != nil {
+			return err
+		}
+		ex := newExtractor(db, openSecrets(), waves, viper.GetString("stationxml.schema_version"))
+
+		if dryRun {
+			x, err := ex.Plan(id, p)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "NETWORK\tSTATION\tLOCATION\tCHANNEL\tDISTANCE\tSTART\tEND")
+			for _, r := range x.Requests {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.2f\t%s\t%s\n", r.NetworkCode, r.StationCode, r.LocationCode, r.ChannelCode,
+					r.DistanceDeg, r.StartTime.UTC().Format("2006-01-02 15:04:05"), r.EndTime.UTC().Format("2006-01-02 15:04:05"))
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%d requests (dry run)\n", len(x.Requests))
+			return nil
+		}
+
+		x, err := ex.Create(id, p)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "extraction %d: fetching %d channels\n", x.ID, len(x.Requests))
+		if err := ex.Run(x); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "extraction %d: %d fetched, %d without data, %d failed\n",
+			x.ID, x.DoneCount, x.NoDataCount, x.FailedCount)
+		path := ex.BundlePath(x.ID)
+		if output != "" {
+			if err := copyFile(path, output); err != nil {
+				return err
+			}
+			path = output
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s (%d bytes)\n", path, x.Bytes)
+		return nil
+	},
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// parseEventTime accepts a date or an RFC 3339 time, in UTC unless a zone
+// is given.
+func parseEventTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want YYYY-MM-DD or RFC 3339", s)
+}
+
+func init() {
+	eventImportCmd.Flags().String("source", "", "source name or id")
+	eventImportCmd.Flags().String("start", "", "events at or after this time")
+	eventImportCmd.Flags().String("end", "", "events at or before this time")
+	eventImportCmd.Flags().String("minmag", "", "minimum magnitude")
+	eventImportCmd.Flags().String("maxmag", "", "maximum magnitude")
+	eventImportCmd.Flags().String("minlat", "", "minimum latitude")
+	eventImportCmd.Flags().String("maxlat", "", "maximum latitude")
+	eventImportCmd.Flags().String("minlon", "", "minimum longitude")
+	eventImportCmd.Flags().String("maxlon", "", "maximum longitude")
+	eventImportCmd.Flags().String("lat", "", "latitude of the --maxradius centre")
+	eventImportCmd.Flags().String("lon", "", "longitude of the --maxradius centre")
+	eventImportCmd.Flags().String("maxradius", "", "maximum distance in degrees from --lat/--lon")
+	eventImportCmd.Flags().String("eventid", "", "upstream event identifier")
+	eventImportCmd.Flags().String("catalog", "", "upstream catalog name")
+	eventImportCmd.Flags().String("limit", "", "maximum number of events to fetch")
+	eventImportCmd.Flags().String("format", "xml", "upstream format: xml (QuakeML) or text")
+	eventImportCmd.Flags().Bool("arrivals", false, "also import picks and arrivals (QuakeML only)")
+
+	eventListCmd.Flags().String("source", "", "only list events from this source (name or id)")
+	eventListCmd.Flags().String("start", "", "events at or after this time")
+	eventListCmd.Flags().String("end", "", "events at or before this time")
+	eventListCmd.Flags().Float64("minmag", 0, "minimum magnitude")
+	eventListCmd.Flags().String("orderby", "time", "time, time-asc, magnitude or magnitude-asc")
+	eventListCmd.Flags().Int("limit", 50, "maximum number of events to list (0 for all)")
+
+	eventExtractCmd.Flags().Float64("minradius", 0, "minimum distance in degrees from the epicentre")
+	eventExtractCmd.Flags().Float64("maxradius", extract.DefaultMaxRadius, "maximum distance in degrees from the epicentre")
+	eventExtractCmd.Flags().String("channel", extract.DefaultChannel, "channel codes, comma-separated, wildcards allowed")
+	eventExtractCmd.Flags().String("window", extract.WindowPhases, "window mode: phases or offsets")
+	eventExtractCmd.Flags().Float64("before", extract.DefaultBefore, "seconds before the P arrival (or origin time)")
+	eventExtractCmd.Flags().Float64("after", extract.DefaultAfter, "seconds after the S arrival (or origin time)")
+	eventExtractCmd.Flags().Bool("dry-run", false, "list the requests without fetching anything")
+	eventExtractCmd.Flags().StringP("output", "o", "", "also copy the bundle to this file")
+
+	eventCmd.AddCommand(eventImportCmd, eventListCmd, eventRemoveCmd, eventExtractCmd)
+	rootCmd.AddCommand(eventCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/joescharf/fdsn/internal/export"
+	"github.com/joescharf/fdsn/internal/fdsnserver"
+)
+
+// exportFormats maps export command formats to station service formats.
+var exportFormats = map[string]string{
+	"stationxml":   "xml",
+	"text":         "text",
+	export.GeoJSON: export.GeoJSON,
+	export.KML:     export.KML,
+	export.CSV:     export.CSV,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export stationxml|text|geojson|kml|csv",
+	Short: "Export the station catalog",
+	Long: "Write the local catalog in one of the station service formats, without starting\n" +
+		"the server. The output is what /fdsnws/station/1/query returns for the same\n" +
+		"parameters. Restricted data is left out unless --include-restricted is given.",
+	Example: "  fdsn export stationxml --level channel -o catalog.xml\n" +
+		"  fdsn export geojson --net IU,II > stations.geojson",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"stationxml", "text", export.GeoJSON, export.KML, export.CSV},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, ok := exportFormats[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown format %q: use stationxml, text, geojson, kml or csv", args[0])
+		}
+		q := url.Values{"format": {format}}
+		for _, name := range []string{"net", "sta", "loc", "cha", "level", "starttime", "endtime"} {
+			if v, _ := cmd.Flags().GetString(name); v != "" {
+				q.Set(name, v)
+			}
+		}
+		schemaVersion, _ := cmd.Flags().GetString("schema-version")
+		if schemaVersion == "" {
+			schemaVersion = viper.GetString("stationxml.schema_version")
+		}
+		restricted, _ := cmd.Flags().GetBool("include-restricted")
+		output, _ := cmd.Flags().GetString("output")
+
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		var w io.Writer = os.Stdout
+		var f *os.File
+		if output != "" && output != "-" {
+			if f, err = os.Create(output); err != nil {
+				return err
+			}
+			w = f
+		}
+		err = fdsnserver.QueryStations(db, w, q, schemaVersion, restricted)
+		if f != nil {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(output)
+			}
+		}
+		if errors.Is(err, fdsnserver.ErrNoData) {
+			return fmt.Errorf("nothing to export: %w", err)
+		}
+		return err
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("net", "", "network codes, comma-separated, wildcards allowed")
+	exportCmd.Flags().String("sta", "", "station codes")
+	exportCmd.Flags().String("loc", "", "location codes")
+	exportCmd.Flags().String("cha", "", "channel codes")
+	exportCmd.Flags().String("level", "", "network, station, channel or response (default station)")
+	exportCmd.Flags().String("starttime", "", "only epochs active after this time")
+	exportCmd.Flags().String("endtime", "", "only epochs active before this time")
+	exportCmd.Flags().String("schema-version", "", "StationXML version, 1.1 or 1.2 (default stationxml.schema_version)")
+	exportCmd.Flags().Bool("include-restricted", false, "include restricted networks, stations and channels")
+	exportCmd.Flags().StringP("output", "o", "", "write to this file instead of standard output")
+
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import station metadata from a source",
+	Long: "Import channels, their StationXML restrictions and metadata, and availability\n" +
+		"from a source. Without --source the network is imported from the data centre\n" +
+		"found to serve it by discovery, which registers it as a source if needed.",
+	Example: "  fdsn import --source Earthscope --net IU --sta ANMO --cha 'BH?'\n" +
+		"  fdsn import --net CH",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, _ := cmd.Flags().GetString("source")
+		req := importer.Request{}
+		req.Network, _ = cmd.Flags().GetString("net")
+		req.Station, _ = cmd.Flags().GetString("sta")
+		req.Location, _ = cmd.Flags().GetString("loc")
+		req.Channel, _ = cmd.Flags().GetString("cha")
+		if ref == "" && req.Network == "" {
+			return fmt.Errorf("--source or --net is required")
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if ref != "" {
+			src, err := findSource(db, ref)
+			if err != nil {
+				return err
+			}
+			req.SourceID = src.ID
+		}
+		res, err := newImporter(db).Import(req)
+		if err != nil {
+			return err
+		}
+		printImportResults([]importResult{{Network: req.Network, Result: res}})
+		return nil
+	},
+}
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Re-import imported networks from their sources",
+	Long: "Re-import every network already in the catalog from the source it came from,\n" +
+		"updating channels, restrictions and availability. Narrow the refresh with\n" +
+		"--source and --net, or refresh everything with --all.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, _ := cmd.Flags().GetString("source")
+		nets, _ := cmd.Flags().GetStringSlice("net")
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (ref != "" || len(nets) > 0) {
+			return fmt.Errorf("use --source and/or --net, or --all")
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		var sourceID int64
+		if ref != "" {
+			src, err := findSource(db, ref)
+			if err != nil {
+				return err
+			}
+			sourceID = src.ID
+		}
+
+		imp := newImporter(db)
+		targets, err := imp.Targets()
+		if err != nil {
+			return err
+		}
+		var results []importResult
+		var failed int
+		for _, t := range targets {
+			if sourceID != 0 && t.SourceID != sourceID {
+				continue
+			}
+			if len(nets) > 0 && !containsFold(nets, t.NetworkCode) {
+				continue
+			}
+			res, err := imp.Import(importer.Request{SourceID: t.SourceID, Network: t.NetworkCode})
+			if err != nil {
+				failed++
+				res = &importer.Result{SourceID: t.SourceID, SourceName: t.SourceName}
+			}
+			results = append(results, importResult{Network: t.NetworkCode, Result: res, Err: err})
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing to refresh")
+			return nil
+		}
+		printImportResults(results)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d networks failed to refresh", failed, len(results))
+		}
+		return nil
+	},
+}
+
+// importResult is one row of import or refresh output.
+type importResult struct {
+	Network string
+	Result  *importer.Result
+	Err     error
+}
+
+func printImportResults(results []importResult) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tNETWORK\tCHANNELS\tAVAILABILITY\tSTATUS")
+	for _, r := range results {
+		network := r.Network
+		if network == "" {
+			network = "*"
+		}
+		status := "ok"
+		if r.Err != nil {
+			status = r.Err.Error()
+		} else if r.Result.AvailabilityError != "" {
+			status = r.Result.AvailabilityError
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d (%s)\t%s\n", r.Result.SourceName, network,
+			r.Result.Imported, r.Result.AvailabilityCount, r.Result.AvailabilityStatus, status)
+	}
+	_ = tw.Flush()
+}
+
+// newImporter returns an importer writing to db, discovering sources with
+// the configured lookup services.
+func newImporter(db *database.DB) *importer.Importer {
+	sources := store.NewSourceStore(db)
+	return importer.New(sources, store.NewStationStore(db), store.NewAvailabilityStore(db),
+		store.NewCredentialStore(db, openSecrets()), discovery.NewDiscoverer(newDiscoveryClient(), sources))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	importCmd.Flags().String("source", "", "source name or id (default: discover the source serving --net)")
+	importCmd.Flags().String("net", "", "network codes, comma-separated, wildcards allowed")
+	importCmd.Flags().String("sta", "", "station codes")
+	importCmd.Flags().String("loc", "", "location codes")
+	importCmd.Flags().String("cha", "", "channel codes")
+
+	refreshCmd.Flags().String("source", "", "only refresh networks from this source (name or id)")
+	refreshCmd.Flags().StringSlice("net", nil, "only refresh these networks")
+	refreshCmd.Flags().Bool("all", false, "refresh every imported network")
+
+	rootCmd.AddCommand(importCmd, refreshCmd)
+}
@@ -1,23 +1,33 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/joescharf/fdsn/internal/api"
+	"github.com/joescharf/fdsn/internal/catalog"
 	"github.com/joescharf/fdsn/internal/config"
 	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/extract"
+	"github.com/joescharf/fdsn/internal/fdsnserver"
+	"github.com/joescharf/fdsn/internal/health"
+	"github.com/joescharf/fdsn/internal/metrics"
 	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/secrets"
 	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/wavecache"
 )
 
 var serveCmd = &cobra.Command{
@@ -25,34 +35,62 @@ var serveCmd = &cobra.Command{
 	Short: "Start the FDSN portal server",
 	Long:  "Start the HTTP server that serves the FDSN portal UI and API endpoints.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dbPath := viper.GetString("db.path")
 		port := viper.GetInt("server.port")
-
-		// Ensure database directory exists
-		if err := config.EnsureDir(filepath.Dir(dbPath)); err != nil {
-			return fmt.Errorf("create db directory: %w", err)
+		schemaVersion := viper.GetString("stationxml.schema_version")
+		if !fdsnserver.SupportedSchemaVersion(schemaVersion) {
+			return fmt.Errorf("stationxml.schema_version %q is not supported: use 1.1 or 1.2", schemaVersion)
 		}
 
-		// Open database
-		db, err := database.New(dbPath)
+		db, err := openDB()
 		if err != nil {
-			return fmt.Errorf("database init: %w", err)
+			return err
 		}
 		defer func() { _ = db.Close() }()
-		log.Info().Str("path", dbPath).Msg("database opened")
 
-		// Run migrations
-		if err := database.Migrate(db); err != nil {
-			return fmt.Errorf("database migrate: %w", err)
+		// Reconcile sources with the config
+		if err := reconcileSources(db); err != nil {
+			log.Warn().Err(err).Msg("source reconciliation failed")
+		}
+		if path := viper.GetString("catalog.spec"); path != "" {
+			spec, err := catalog.Load(path)
+			if err != nil {
+				return fmt.Errorf("catalog spec: %w", err)
+			}
+			go runCatalogSync(db, spec, path, viper.GetBool("catalog.prune"))
+		}
+
+		box := openSecrets()
+		checker := health.NewChecker(store.NewSourceStore(db), store.NewHealthStore(db), store.NewCredentialStore(db, box))
+		if interval := viper.GetDuration("health.interval"); interval > 0 {
+			go checker.Run(context.Background(), interval)
+		}
+
+		if interval := viper.GetDuration("backup.interval"); interval > 0 {
+			if db.DriverName() != database.DriverSQLite {
+				log.Warn().Msg("backup.interval ignored: scheduled backups are only supported for SQLite")
+			} else {
+				backups := &database.Backups{DB: db, Dir: backupDir(), Keep: viper.GetInt("backup.keep")}
+				go backups.Run(context.Background(), interval)
+			}
+		}
+
+		waves, err := openWaveCache()
+		if err != nil {
+			return err
+		}
+
+		extractor := newExtractor(db, box, waves, schemaVersion)
+		if err := extractor.Recover(); err != nil {
+			log.Warn().Err(err).Msg("extraction recovery failed")
 		}
 
-		// Seed sources from config into database
-		if err := seedSources(db); err != nil {
-			log.Warn().Err(err).Msg("source seeding failed")
+		serveMetrics := viper.GetBool("metrics.enabled")
+		if serveMetrics {
+			registerMetrics(db, waves)
 		}
 
 		// Build router
-		handler, err := api.NewRouter(db)
+		handler, err := api.NewRouter(db, box, checker, newDiscoveryClient(), schemaVersion, waves, extractor, serveMetrics)
 		if err != nil {
 			return fmt.Errorf("router init: %w", err)
 		}
@@ -81,45 +119,212 @@ func init() {
 	_ = viper.BindPFlag("server.no_browser", serveCmd.Flags().Lookup("no-browser"))
 }
 
-// seedSources reads sources from viper config and inserts any that are not
-// already present in the database (matched by name).
-func seedSources(db *sqlx.DB) error {
-	srcStore := store.NewSourceStore(db)
+// openDB opens the configured database, creating its directory if needed,
+// and applies any pending migrations.
+func openDB() (*database.DB, error) {
+	db, err := openDBUnmigrated()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("database migrate: %w", err)
+	}
+	return db, nil
+}
 
-	existing, err := srcStore.List()
+// openDBUnmigrated opens the configured database without touching its schema.
+func openDBUnmigrated() (*database.DB, error) {
+	if driver := viper.GetString("db.driver"); driver != database.DriverSQLite {
+		db, err := database.Open(driver, viper.GetString("db.dsn"))
+		if err != nil {
+			return nil, fmt.Errorf("database init: %w", err)
+		}
+		log.Info().Str("driver", driver).Msg("database opened")
+		return db, nil
+	}
+
+	dbPath := viper.GetString("db.path")
+
+	// Ensure database directory exists
+	if err := config.EnsureDir(filepath.Dir(dbPath)); err != nil {
+		return nil, fmt.Errorf("create db directory: %w", err)
+	}
+
+	db, err := database.New(dbPath)
 	if err != nil {
-		return fmt.Errorf("list existing sources: %w", err)
+		return nil, fmt.Errorf("database init: %w", err)
+	}
+	log.Info().Str("path", dbPath).Msg("database opened")
+	return db, nil
+}
+
+// backupDir returns where scheduled backups are written: backup.dir, or a
+// "backups" directory next to the database file.
+func backupDir() string {
+	if dir := viper.GetString("backup.dir"); dir != "" {
+		return dir
 	}
+	return filepath.Join(filepath.Dir(viper.GetString("db.path")), "backups")
+}
 
-	existingNames := make(map[string]bool, len(existing))
-	for _, s := range existing {
-		existingNames[s.Name] = true
+// openWaveCache opens the waveform cache, or returns nil if
+// waveform_cache.max_mb is 0.
+func openWaveCache() (*wavecache.Cache, error) {
+	maxMB := viper.GetInt64("waveform_cache.max_mb")
+	if maxMB <= 0 {
+		return nil, nil
+	}
+	dir := viper.GetString("waveform_cache.dir")
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(viper.GetString("db.path")), "waveforms")
+	}
+	c, err := wavecache.Open(dir, maxMB<<20, viper.GetDuration("waveform_cache.min_age"))
+	if err != nil {
+		return nil, err
 	}
+	s := c.Stats()
+	log.Info().Str("dir", dir).Int("segments", s.Segments).Int64("bytes", s.SizeBytes).Msg("waveform cache opened")
+	return c, nil
+}
+
+// newExtractor returns the event waveform extractor, writing bundles to
+// extract.dir, or an "extractions" directory next to the database file.
+func newExtractor(db *database.DB, box *secrets.Box, waves *wavecache.Cache, schemaVersion string) *extract.Extractor {
+	dir := viper.GetString("extract.dir")
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(viper.GetString("db.path")), "extractions")
+	}
+	return extract.New(db, store.NewCredentialStore(db, box), waves, dir, schemaVersion)
+}
 
-	// Read sources from viper config
-	var cfgSources []struct {
-		Name        string `mapstructure:"name"`
-		BaseURL     string `mapstructure:"base_url"`
-		Description string `mapstructure:"description"`
+// registerMetrics adds the database pools, the waveform cache and the
+// catalog counts to the Prometheus metrics.
+func registerMetrics(db *database.DB, waves *wavecache.Cache) {
+	metrics.RegisterDB(db.DB.DB, "writer")
+	if db.Reader != db.DB {
+		metrics.RegisterDB(db.Reader.DB, "reader")
 	}
+	if waves != nil {
+		metrics.Registry.MustRegister(waves)
+	}
+	metrics.RegisterCatalog(store.NewStatsStore(db).GetStats)
+}
+
+// openSecrets loads (or creates) the key used to encrypt stored upstream
+// credentials. Failure is not fatal: the server runs without credential
+// support and logs a warning.
+func openSecrets() *secrets.Box {
+	path := viper.GetString("secrets.key_file")
+	box, err := secrets.LoadOrCreateKeyFile(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("credential encryption unavailable; upstream requests will be anonymous")
+		return nil
+	}
+	return box
+}
+
+// newDiscoveryClient returns a client for the configured EIDA routing and
+// fedcatalog services.
+func newDiscoveryClient() *discovery.Client {
+	return discovery.New(viper.GetString("discovery.eida_routing_url"), viper.GetString("discovery.fedcatalog_url"))
+}
+
+// configSource is an entry of the sources config key. Enabled is nil when
+// not set, which creates the source enabled and otherwise keeps its current
+// flag.
+type configSource struct {
+	Name        string `mapstructure:"name"`
+	BaseURL     string `mapstructure:"base_url"`
+	Description string `mapstructure:"description"`
+	Enabled     *bool  `mapstructure:"enabled"`
+}
+
+// reconcileSources makes the database match the sources config key. Config
+// sources are matched to existing sources by name (case-insensitively):
+// missing ones are created, and existing ones take the configured URL,
+// description and, if set, enabled flag and are marked as managed by config,
+// which makes them read-only in the API apart from the enabled flag. A
+// managed source that is no longer configured is handed back to the UI, and
+// also disabled if source_config.disable_removed is set.
+//
+// Only sources already managed by config are adopted, and unmarked ones
+// with the configured base URL, which earlier versions seeded from the
+// config without marking them. Any other source of the same name was
+// created elsewhere: it is left alone and the config entry is skipped with
+// a warning.
+func reconcileSources(db *database.DB) error {
+	srcStore := store.NewSourceStore(db)
+
+	existing, err := srcStore.List()
+	if err != nil {
+		return fmt.Errorf("list existing sources: %w", err)
+	}
+
+	var cfgSources []configSource
 	if err := viper.UnmarshalKey("sources", &cfgSources); err != nil {
 		return fmt.Errorf("unmarshal sources config: %w", err)
 	}
 
+	configured := make(map[string]bool, len(cfgSources))
 	for _, cs := range cfgSources {
-		if existingNames[cs.Name] {
-			continue
+		if cs.Name == "" || cs.BaseURL == "" {
+			return fmt.Errorf("sources config: name and base_url are required")
+		}
+		key := strings.ToLower(cs.Name)
+		if configured[key] {
+			return fmt.Errorf("sources config: %s is listed more than once", cs.Name)
 		}
-		src := &models.Source{
+		configured[key] = true
+
+		want := models.Source{
 			Name:        cs.Name,
-			BaseURL:     cs.BaseURL,
+			BaseURL:     strings.TrimRight(cs.BaseURL, "/"),
 			Description: cs.Description,
-			Enabled:     true,
+			Enabled:     cs.Enabled == nil || *cs.Enabled,
+			ManagedBy:   models.ManagedByConfig,
+		}
+		i := slices.IndexFunc(existing, func(s models.Source) bool { return strings.EqualFold(s.Name, cs.Name) })
+		if i < 0 {
+			if err := srcStore.Create(&want); err != nil {
+				return fmt.Errorf("create source %q: %w", cs.Name, err)
+			}
+			log.Info().Str("name", cs.Name).Str("url", want.BaseURL).Msg("seeded source from config")
+			continue
+		}
+		src := existing[i]
+		if src.ManagedBy != models.ManagedByConfig && !strings.EqualFold(strings.TrimRight(src.BaseURL, "/"), want.BaseURL) {
+			log.Warn().Str("name", cs.Name).Str("existing_url", src.BaseURL).Str("config_url", want.BaseURL).
+				Msg("source config clashes with a source managed in the UI; skipping it")
+			continue
+		}
+		if cs.Enabled == nil {
+			want.Enabled = src.Enabled
+		}
+		if src.BaseURL == want.BaseURL && src.Description == want.Description &&
+			src.Enabled == want.Enabled && src.ManagedBy == want.ManagedBy {
+			continue
+		}
+		want.ID, want.Name, want.CreatedAt = src.ID, src.Name, src.CreatedAt
+		if err := srcStore.Update(&want); err != nil {
+			return fmt.Errorf("update source %q: %w", src.Name, err)
+		}
+		log.Info().Str("name", src.Name).Str("url", want.BaseURL).Bool("enabled", want.Enabled).Msg("updated source from config")
+	}
+
+	disable := viper.GetBool("source_config.disable_removed")
+	for _, src := range existing {
+		if src.ManagedBy != models.ManagedByConfig || configured[strings.ToLower(src.Name)] {
+			continue
+		}
+		src.ManagedBy = ""
+		if disable {
+			src.Enabled = false
 		}
-		if err := srcStore.Create(src); err != nil {
-			return fmt.Errorf("create source %q: %w", cs.Name, err)
+		if err := srcStore.Update(&src); err != nil {
+			return fmt.Errorf("release source %q: %w", src.Name, err)
 		}
-		log.Info().Str("name", cs.Name).Str("url", cs.BaseURL).Msg("seeded source from config")
+		log.Info().Str("name", src.Name).Bool("enabled", src.Enabled).Msg("source removed from config; now managed in the UI")
 	}
 
 	return nil
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
 	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
 	"github.com/joescharf/fdsn/internal/store"
 )
 
@@ -36,7 +41,7 @@ func TestBrowserCmd(t *testing.T) {
 	}
 }
 
-func TestSeedSources_EmptyDB(t *testing.T) {
+func TestReconcileSources_EmptyDB(t *testing.T) {
 	// Set up a temp database
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.db")
@@ -66,8 +71,8 @@ func TestSeedSources_EmptyDB(t *testing.T) {
 		},
 	})
 
-	if err := seedSources(db); err != nil {
-		t.Fatalf("seedSources: %v", err)
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("reconcileSources: %v", err)
 	}
 
 	// Verify sources were created
@@ -89,7 +94,7 @@ func TestSeedSources_EmptyDB(t *testing.T) {
 	}
 }
 
-func TestSeedSources_NoDuplicates(t *testing.T) {
+func TestReconcileSources_NoDuplicates(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.db")
 
@@ -113,13 +118,13 @@ func TestSeedSources_NoDuplicates(t *testing.T) {
 	})
 
 	// Seed once
-	if err := seedSources(db); err != nil {
-		t.Fatalf("first seedSources: %v", err)
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("first reconcileSources: %v", err)
 	}
 
 	// Seed again — should not duplicate
-	if err := seedSources(db); err != nil {
-		t.Fatalf("second seedSources: %v", err)
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("second reconcileSources: %v", err)
 	}
 
 	srcStore := store.NewSourceStore(db)
@@ -133,7 +138,7 @@ func TestSeedSources_NoDuplicates(t *testing.T) {
 	}
 }
 
-func TestSeedSources_NoConfig(t *testing.T) {
+func TestReconcileSources_NoConfig(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.db")
 
@@ -150,8 +155,8 @@ func TestSeedSources_NoConfig(t *testing.T) {
 	// Reset viper — no sources configured
 	viper.Reset()
 
-	if err := seedSources(db); err != nil {
-		t.Fatalf("seedSources with no config: %v", err)
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("reconcileSources with no config: %v", err)
 	}
 
 	srcStore := store.NewSourceStore(db)
@@ -165,6 +170,140 @@ func TestSeedSources_NoConfig(t *testing.T) {
 	}
 }
 
+func TestReconcileSources_Changes(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("database.Migrate: %v", err)
+	}
+	srcStore := store.NewSourceStore(db)
+
+	// A source seeded from the config before sources were marked, and one
+	// from the UI
+	if err := srcStore.Create(&models.Source{Name: "Earthscope", BaseURL: "https://service.earthscope.org", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcStore.Create(&models.Source{Name: "Mine", BaseURL: "http://mine", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.Set("sources", []map[string]any{
+		{"name": "earthscope", "base_url": "https://service.earthscope.org/", "description": "Earthscope"},
+		{"name": "ORFEUS", "base_url": "https://www.orfeus-eu.org", "enabled": false},
+	})
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("reconcileSources: %v", err)
+	}
+	byName := func() map[string]models.Source {
+		sources, err := srcStore.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		m := make(map[string]models.Source)
+		for _, s := range sources {
+			m[s.Name] = s
+		}
+		return m
+	}
+	got := byName()
+	if len(got) != 3 {
+		t.Fatalf("sources = %v, want 3", got)
+	}
+	if es := got["Earthscope"]; es.BaseURL != "https://service.earthscope.org" || es.Description != "Earthscope" || es.ManagedBy != models.ManagedByConfig {
+		t.Errorf("Earthscope = %+v, want updated and managed by config", es)
+	}
+	if o := got["ORFEUS"]; o.Enabled || o.ManagedBy != models.ManagedByConfig {
+		t.Errorf("ORFEUS = %+v, want disabled and managed by config", o)
+	}
+	if m := got["Mine"]; m.ManagedBy != "" || !m.Enabled {
+		t.Errorf("Mine = %+v, want left alone", m)
+	}
+
+	// Disabled in the UI, Earthscope stays disabled while the config does
+	// not set enabled
+	es := got["Earthscope"]
+	es.Enabled = false
+	if err := srcStore.Update(&es); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removed from config, ORFEUS is released and, with disable_removed, disabled
+	viper.Set("sources", []map[string]any{{"name": "Earthscope", "base_url": "https://service.earthscope.org"}})
+	viper.Set("source_config.disable_removed", true)
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("second reconcileSources: %v", err)
+	}
+	got = byName()
+	if o := got["ORFEUS"]; o.ManagedBy != "" || o.Enabled {
+		t.Errorf("removed ORFEUS = %+v, want released and disabled", o)
+	}
+	if es := got["Earthscope"]; es.Description != "" || es.Enabled || es.ManagedBy != models.ManagedByConfig {
+		t.Errorf("Earthscope = %+v, want description cleared and still disabled", es)
+	}
+
+	// An explicit enabled flag in the config wins
+	viper.Set("sources", []map[string]any{{"name": "Earthscope", "base_url": "https://service.earthscope.org", "enabled": true}})
+	if err := reconcileSources(db); err != nil {
+		t.Fatalf("third reconcileSources: %v", err)
+	}
+	if es := byName()["Earthscope"]; !es.Enabled {
+		t.Errorf("Earthscope = %+v, want enabled by config", es)
+	}
+	if m := got["Mine"]; !m.Enabled {
+		t.Errorf("Mine = %+v, want still enabled", m)
+	}
+}
+
+func TestReconcileSources_Clash(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("database.Migrate: %v", err)
+	}
+	srcStore := store.NewSourceStore(db)
+
+	// Created in the UI under the name of a config source, with another URL
+	ui := models.Source{Name: "orfeus", BaseURL: "http://mirror.example.org", Description: "Local mirror", Enabled: true}
+	if err := srcStore.Create(&ui); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs)
+
+	viper.Reset()
+	viper.Set("sources", []map[string]any{
+		{"name": "ORFEUS", "base_url": "https://www.orfeus-eu.org", "description": "ORFEUS", "enabled": false},
+	})
+	for range 2 {
+		if err := reconcileSources(db); err != nil {
+			t.Fatalf("reconcileSources: %v", err)
+		}
+	}
+
+	sources, err := srcStore.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("sources = %+v, want only the UI source", sources)
+	}
+	if got := sources[0]; got.BaseURL != ui.BaseURL || got.Description != ui.Description || !got.Enabled || got.ManagedBy != "" {
+		t.Errorf("UI source = %+v, want left alone", got)
+	}
+	if !strings.Contains(logs.String(), `"level":"warn"`) || !strings.Contains(logs.String(), "clashes") {
+		t.Errorf("logs = %s, want a clash warning", logs.String())
+	}
+}
+
 func TestMain(m *testing.M) {
 	// Prevent tests from reading the user's real config
 	viper.Reset()
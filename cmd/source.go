@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/health"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var sourceCmd = &cobra.Command{
+	Use:   "source",
+	Short: "Manage upstream FDSN sources",
+}
+
+var sourceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sources with their catalog counts and last probe",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		sources, err := store.NewSourceStore(db).ListWithStats()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tBASE URL\tENABLED\tMANAGED BY\tNETWORKS\tSTATIONS\tSERVICES\tLAST CHECKED")
+		for _, s := range sources {
+			var services []string
+			for _, svc := range s.Services {
+				services = append(services, svc.Service)
+			}
+			checked := "never"
+			if s.LastChecked != nil {
+				checked = s.LastChecked.Local().Format("2006-01-02 15:04")
+				if s.LastError != "" {
+					checked += " (failed)"
+				}
+			}
+			managedBy := s.ManagedBy
+			if managedBy == "" {
+				managedBy = "-"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\t%d\t%d\t%s\t%s\n", s.ID, s.Name, s.BaseURL, s.Enabled, managedBy,
+				s.NetworkCount, s.StationCount, strings.Join(services, ","), checked)
+		}
+		return tw.Flush()
+	},
+}
+
+var sourceAddCmd = &cobra.Command{
+	Use:   "add <name> <base-url>",
+	Short: "Register a source and probe its services",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		description, _ := cmd.Flags().GetString("description")
+		disabled, _ := cmd.Flags().GetBool("disabled")
+		noProbe, _ := cmd.Flags().GetBool("no-probe")
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		src := &models.Source{
+			Name:        args[0],
+			BaseURL:     strings.TrimRight(args[1], "/"),
+			Description: description,
+			Enabled:     !disabled,
+		}
+		if err := store.NewSourceStore(db).Create(src); err != nil {
+			return fmt.Errorf("create source %q: %w", src.Name, err)
+		}
+		fmt.Fprintf(os.Stderr, "Source %s created (id %d)\n", src.Name, src.ID)
+		if noProbe {
+			return nil
+		}
+		return printProbe(newChecker(db).Check(src), src)
+	},
+}
+
+var sourceRemoveCmd = &cobra.Command{
+	Use:   "remove <source>",
+	Short: "Remove a source (by name or id)",
+	Long: "Remove a source together with its imported networks, stations, channels and\n" +
+		"availability, or move them to another source with --reassign-to. Use --dry-run\n" +
+		"to see what would be removed or moved.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reassign, _ := cmd.Flags().GetString("reassign-to")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		src, err := findSource(db, args[0])
+		if err != nil {
+			return err
+		}
+		if src.ManagedBy == models.ManagedByConfig {
+			return fmt.Errorf("source %s is managed by the config file: remove it from sources there", src.Name)
+		}
+		opts := store.DeleteSourceOptions{DryRun: dryRun}
+		target := ""
+		if reassign != "" {
+			to, err := findSource(db, reassign)
+			if err != nil {
+				return err
+			}
+			opts.ReassignTo, target = to.ID, to.Name
+		}
+		res, err := store.NewSourceStore(db).Delete(src.ID, opts)
+		if err != nil {
+			return fmt.Errorf("remove source %s: %w", src.Name, err)
+		}
+
+		action := "deleted"
+		if target != "" {
+			action = "moved to " + target
+		}
+		if dryRun {
+			fmt.Fprintln(os.Stderr, "Dry run: nothing was changed")
+			action = "would be " + action
+		} else {
+			fmt.Fprintln(os.Stderr, "Source removed:", src.Name)
+		}
+		fmt.Fprintf(os.Stderr, "%d networks, %d stations, %d channels, %d availability extents and %d events %s\n",
+			res.Networks, res.Stations, res.Channels, res.Availability, res.Events, action)
+		return nil
+	},
+}
+
+var sourceProbeCmd = &cobra.Command{
+	Use:   "probe [source]...",
+	Short: "Probe sources (by name or id) for their FDSN services",
+	Long:  "Probe the given sources, or every source with --all, and record the results.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			return fmt.Errorf("name sources to probe or use --all")
+		}
+
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		var sources []models.Source
+		if all {
+			if sources, err = store.NewSourceStore(db).List(); err != nil {
+				return err
+			}
+		}
+		for _, ref := range args {
+			src, err := findSource(db, ref)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, *src)
+		}
+
+		checker := newChecker(db)
+		var failed int
+		for i := range sources {
+			if err := printProbe(checker.Check(&sources[i]), &sources[i]); err != nil {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d probes failed", failed, len(sources))
+		}
+		return nil
+	},
+}
+
+// openCatalogDB opens the database and reconciles the sources with the
+// config, as fdsn serve does, so commands see the same sources as the server.
+func openCatalogDB() (*database.DB, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := reconcileSources(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// newChecker returns a health checker recording into db.
+func newChecker(db *database.DB) *health.Checker {
+	return health.NewChecker(store.NewSourceStore(db), store.NewHealthStore(db), store.NewCredentialStore(db, openSecrets()))
+}
+
+// printProbe prints a probe result, returning an error if the probe failed.
+func printProbe(res *models.ProbeResult, src *models.Source) error {
+	if res.Error != "" {
+		fmt.Fprintf(os.Stderr, "%s: probe failed: %s\n", src.Name, res.Error)
+		return fmt.Errorf("probe %s: %s", src.Name, res.Error)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s (%d ms)\n", src.Name, res.LatencyMS)
+	for _, svc := range res.Services {
+		fmt.Fprintf(tw, "  %s\t%s\n", svc.Service, svc.Version)
+	}
+	for _, service := range slices.Sorted(maps.Keys(res.ServiceErrors)) {
+		fmt.Fprintf(tw, "  %s\tfailed: %s\n", service, res.ServiceErrors[service])
+	}
+	return tw.Flush()
+}
+
+func init() {
+	sourceAddCmd.Flags().String("description", "", "source description")
+	sourceAddCmd.Flags().Bool("disabled", false, "create the source disabled")
+	sourceAddCmd.Flags().Bool("no-probe", false, "do not probe the new source")
+	sourceRemoveCmd.Flags().String("reassign-to", "", "move the catalog to this source (name or id) instead of deleting it")
+	sourceRemoveCmd.Flags().Bool("dry-run", false, "report what would be removed without changing anything")
+	sourceProbeCmd.Flags().Bool("all", false, "probe every source")
+
+	sourceCmd.AddCommand(sourceListCmd, sourceAddCmd, sourceRemoveCmd, sourceProbeCmd)
+	rootCmd.AddCommand(sourceCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/joescharf/fdsn/internal/catalog"
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [spec]",
+	Short: "Reconcile the catalog with a catalog spec file",
+	Long: "Create or update the sources listed in a YAML catalog spec and import each of\n" +
+		"their selections, refreshing channels already in the catalog. With --prune,\n" +
+		"channels no selection covers are removed, along with stations and networks\n" +
+		"left empty. The spec defaults to catalog.spec.",
+	Example: "  fdsn sync catalog.yaml --prune --dry-run\n" +
+		"  fdsn sync catalog.yaml --prune",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := viper.GetString("catalog.spec")
+		if len(args) > 0 {
+			path = args[0]
+		}
+		if path == "" {
+			return fmt.Errorf("no spec given and catalog.spec is not set")
+		}
+		opts := catalog.Options{Prune: viper.GetBool("catalog.prune")}
+		if cmd.Flags().Changed("prune") {
+			opts.Prune, _ = cmd.Flags().GetBool("prune")
+		}
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+
+		spec, err := catalog.Load(path)
+		if err != nil {
+			return err
+		}
+		db, err := openCatalogDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		report, err := newSyncer(db).Sync(spec, opts)
+		if err != nil {
+			return err
+		}
+		printSyncReport(report, opts)
+		if failed := report.Failed(); failed > 0 {
+			return fmt.Errorf("%d of %d selections failed to import", failed, len(report.Selections))
+		}
+		return nil
+	},
+}
+
+// newSyncer returns a catalog syncer writing to db.
+func newSyncer(db *database.DB) *catalog.Syncer {
+	return catalog.New(store.NewSourceStore(db), store.NewStationStore(db), newImporter(db))
+}
+
+// runCatalogSync syncs the catalog with the spec at path, logging the
+// outcome; fdsn serve runs it in the background at startup.
+func runCatalogSync(db *database.DB, spec *catalog.Spec, path string, prune bool) {
+	report, err := newSyncer(db).Sync(spec, catalog.Options{Prune: prune})
+	if err != nil {
+		log.Error().Err(err).Str("spec", path).Msg("catalog sync failed")
+		return
+	}
+	log.Info().Str("spec", path).
+		Int("selections", len(report.Selections)).
+		Int("failed", report.Failed()).
+		Int("pruned_channels", report.Pruned.Channels).
+		Msg("catalog sync complete")
+}
+
+func printSyncReport(report *catalog.Report, opts catalog.Options) {
+	verb := "Pruned"
+	if opts.DryRun {
+		verb = "Would prune"
+		fmt.Fprintln(os.Stderr, "Dry run: nothing was changed")
+	}
+	for _, s := range report.Sources {
+		if s.Action != catalog.SourceUnchanged {
+			fmt.Fprintf(os.Stderr, "Source %s %s\n", s.Name, s.Action)
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSELECTION\tACTION\tCHANNELS\tSTATUS")
+	for _, s := range report.Selections {
+		channels, status := "-", "ok"
+		if s.Result != nil {
+			channels = fmt.Sprint(s.Result.Imported)
+			if s.Result.AvailabilityError != "" {
+				status = s.Result.AvailabilityError
+			}
+		}
+		if s.Error != "" {
+			status = s.Error
+		} else if s.Action == catalog.SelectionSkipped {
+			status = "source disabled"
+		} else if opts.DryRun {
+			status = "pending"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Source, s.Selection, s.Action, channels, status)
+	}
+	_ = tw.Flush()
+
+	if opts.Prune {
+		p := report.Pruned
+		fmt.Fprintf(os.Stderr, "%s %d channels, %d stations and %d networks\n", verb, p.Channels, p.Stations, p.Networks)
+	}
+}
+
+func init() {
+	syncCmd.Flags().Bool("prune", false, "remove channels not covered by the spec (default catalog.prune)")
+	syncCmd.Flags().Bool("dry-run", false, "report what would change without changing anything")
+
+	rootCmd.AddCommand(syncCmd)
+}
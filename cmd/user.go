@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joescharf/fdsn/internal/digest"
+	"github.com/joescharf/fdsn/internal/fdsnserver"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage credentials for the FDSN /queryauth endpoints",
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Create a user or reset an existing user's password",
+	Long: "Create a user for HTTP Digest access to restricted data via /queryauth.\n" +
+		"If --password is not given the password is read from standard input.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := args[0]
+		password, _ := cmd.Flags().GetString("password")
+		networks, _ := cmd.Flags().GetString("networks")
+
+		if password == "" {
+			fmt.Fprint(os.Stderr, "Password: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+		if password == "" {
+			return fmt.Errorf("password must not be empty")
+		}
+
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		u := &models.User{
+			Username: username,
+			HA1:      digest.HA1(username, fdsnserver.Realm, password),
+			Networks: networks,
+		}
+		if err := store.NewUserStore(db).Upsert(u); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "User %s saved (networks: %s)\n", u.Username, u.Networks)
+		return nil
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List queryauth users",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		users, err := store.NewUserStore(db).List()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tNETWORKS\tUPDATED")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Username, u.Networks, u.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	},
+}
+
+var userRemoveCmd = &cobra.Command{
+	Use:   "remove <username>",
+	Short: "Remove a queryauth user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		if err := store.NewUserStore(db).Delete(args[0]); err != nil {
+			if store.IsNotFound(err) {
+				return fmt.Errorf("user %q not found", args[0])
+			}
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "User removed:", args[0])
+		return nil
+	},
+}
+
+func init() {
+	userAddCmd.Flags().String("password", "", "password (read from stdin when omitted)")
+	userAddCmd.Flags().String("networks", "*", "comma-separated network codes (wildcards allowed) the user may access")
+
+	userCmd.AddCommand(userAddCmd, userListCmd, userRemoveCmd)
+	rootCmd.AddCommand(userCmd)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+func TestDisabledSourceNotContacted(t *testing.T) {
+	// The upstream answers everything with no data and counts the requests
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("database.Migrate: %v", err)
+	}
+	src := models.Source{Name: "OFF", BaseURL: upstream.URL, Enabled: true}
+	if err := store.NewSourceStore(db).Create(&src); err != nil {
+		t.Fatal(err)
+	}
+
+	router, err := NewRouter(db, nil, nil, nil, "", nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	paths := map[string]string{
+		"explore": "/api/v1/sources/1/explore/stations?net=IU",
+		"proxy":   "/api/v1/waveforms/proxy?source_id=1&net=IU&sta=ANMO&loc=00&cha=BHZ&starttime=2024-01-01T00:00:00&endtime=2024-01-01T00:01:00",
+	}
+	get := func(path string) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for name, path := range paths {
+		if status := get(path); status == http.StatusConflict {
+			t.Errorf("%s while enabled: status %d", name, status)
+		}
+	}
+	if n := hits.Load(); n != int32(len(paths)) {
+		t.Fatalf("upstream requests while enabled = %d, want %d", n, len(paths))
+	}
+
+	db.MustExec("UPDATE sources SET enabled = FALSE WHERE id = 1")
+	hits.Store(0)
+
+	for name, path := range paths {
+		if status := get(path); status != http.StatusConflict {
+			t.Errorf("%s while disabled: status %d, want 409", name, status)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("upstream requests while disabled = %d, want 0", n)
+	}
+}
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/joescharf/fdsn/internal/extract"
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/geodesy"
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+
+	// Defaults of the event station search: stations within 10° and a
+	// window from a minute before the origin time to ten minutes after.
+	defaultEventRadius = 10.0
+	defaultEventBefore = 60 * time.Second
+	defaultEventAfter  = 600 * time.Second
+)
+
+type eventsHandler struct {
+	store        store.EventStore
+	stationStore store.StationStore
+	importer     *importer.EventImporter
+}
+
+func (h *eventsHandler) list(w http.ResponseWriter, r *http.Request) {
+	q, err := parseEventQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.store.ListEvents(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseEventQuery reads the event list filters and paging from the query
+// string. Times are RFC 3339, radii degrees and depths kilometres.
+func parseEventQuery(r *http.Request) (store.EventQuery, error) {
+	v := r.URL.Query()
+	q := store.EventQuery{
+		MagType:     v.Get("magtype"),
+		Catalog:     v.Get("catalog"),
+		OrderBy:     v.Get("orderby"),
+		Limit:       defaultEventLimit,
+		Contributor: v.Get("contributor"),
+	}
+	if s := v.Get("eventtype"); s != "" {
+		q.EventTypes = strings.Split(s, ",")
+	}
+	if q.OrderBy != "" {
+		if _, ok := store.EventOrders[q.OrderBy]; !ok {
+			return q, fmt.Errorf("orderby must be time, time-asc, magnitude or magnitude-asc")
+		}
+	}
+
+	var err error
+	intParam := func(name string) int64 {
+		s := v.Get(name)
+		if s == "" || err != nil {
+			return 0
+		}
+		n, perr := strconv.ParseInt(s, 10, 64)
+		if perr != nil || n < 0 {
+			err = fmt.Errorf("invalid %s %q", name, s)
+		}
+		return n
+	}
+	floatParam := func(name string) *float64 {
+		s := v.Get(name)
+		if s == "" || err != nil {
+			return nil
+		}
+		f, perr := strconv.ParseFloat(s, 64)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q", name, s)
+			return nil
+		}
+		return &f
+	}
+	timeParam := func(name string) *time.Time {
+		s := v.Get(name)
+		if s == "" || err != nil {
+			return nil
+		}
+		t, perr := time.Parse(time.RFC3339, s)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q: want RFC 3339", name, s)
+			return nil
+		}
+		return &t
+	}
+
+	q.SourceID = intParam("source")
+	if v.Get("limit") != "" {
+		q.Limit = int(intParam("limit"))
+	}
+	q.Offset = int(intParam("offset"))
+	q.StartTime = timeParam("starttime")
+	q.EndTime = timeParam("endtime")
+	q.MinMagnitude = floatParam("minmag")
+	q.MaxMagnitude = floatParam("maxmag")
+	q.MinDepth = floatParam("mindepth")
+	q.MaxDepth = floatParam("maxdepth")
+	q.MinLatitude = floatParam("minlat")
+	q.MaxLatitude = floatParam("maxlat")
+	q.MinLongitude = floatParam("minlon")
+	q.MaxLongitude = floatParam("maxlon")
+	q.Latitude = floatParam("lat")
+	q.Longitude = floatParam("lon")
+	q.MinRadius = floatParam("minradius")
+	q.MaxRadius = floatParam("maxradius")
+	if err != nil {
+		return q, err
+	}
+	if q.Limit < 1 || q.Limit > maxEventLimit {
+		return q, fmt.Errorf("limit must be between 1 and %d", maxEventLimit)
+	}
+	if (q.MinRadius != nil || q.MaxRadius != nil) && (q.Latitude == nil || q.Longitude == nil) {
+		return q, fmt.Errorf("minradius and maxradius require lat and lon")
+	}
+	return q, nil
+}
+
+func (h *eventsHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ev, err := h.store.GetEvent(id)
+	if errors.Is(err, store.ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ev)
+}
+
+func (h *eventsHandler) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	err = h.store.DeleteEvent(id)
+	if errors.Is(err, store.ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stations lists the stored stations operating at the event's origin time
+// within minradius..maxradius degrees of its epicentre, nearest first, each
+// with a dataselect link for the window from before to after seconds around
+// the origin time.
+func (h *eventsHandler) stations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	v := r.URL.Query()
+	maxRadius, minRadius := defaultEventRadius, 0.0
+	before, after := defaultEventBefore, defaultEventAfter
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{{"maxradius", &maxRadius}, {"minradius", &minRadius}} {
+		if s := v.Get(p.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f < 0 || f > 180 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q: want 0 to 180 degrees", p.name, s))
+				return
+			}
+			*p.dst = f
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Duration
+	}{{"before", &before}, {"after", &after}} {
+		if s := v.Get(p.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q: want seconds", p.name, s))
+				return
+			}
+			*p.dst = time.Duration(f * float64(time.Second))
+		}
+	}
+
+	ev, err := h.store.GetEvent(id)
+	if errors.Is(err, store.ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	stations, err := extract.NearbyStations(h.stationStore, ev, minRadius, maxRadius, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	start, end := ev.Time.Add(-before), ev.Time.Add(after)
+	result := make([]models.EventStation, len(stations))
+	for i, st := range stations {
+		deg := geodesy.Distance(ev.Latitude, ev.Longitude, st.Latitude, st.Longitude)
+		result[i] = models.EventStation{
+			Station:     st,
+			DistanceDeg: deg,
+			DistanceKM:  geodesy.DegreesToKM(deg),
+			Azimuth:     geodesy.Azimuth(ev.Latitude, ev.Longitude, st.Latitude, st.Longitude),
+			BackAzimuth: geodesy.Azimuth(st.Latitude, st.Longitude, ev.Latitude, ev.Longitude),
+			WindowStart: start,
+			WindowEnd:   end,
+			WaveformURL: dataselectURL(st, start, end),
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DistanceDeg < result[j].DistanceDeg })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"event":    ev,
+		"stations": result,
+	})
+}
+
+// dataselectURL returns the local dataselect query for a station's data
+// between start and end.
+func dataselectURL(st models.Station, start, end time.Time) string {
+	params := url.Values{}
+	params.Set("net", st.NetworkCode)
+	params.Set("sta", st.Code)
+	params.Set("starttime", start.UTC().Format("2006-01-02T15:04:05"))
+	params.Set("endtime", end.UTC().Format("2006-01-02T15:04:05"))
+	return "/fdsnws/dataselect/1/query?" + params.Encode()
+}
+
+// eventImportRequest selects the events to import from a source's event
+// service. Values are passed upstream as FDSN event query parameters.
+type eventImportRequest struct {
+	SourceID        int64  `json:"source_id"`
+	StartTime       string `json:"starttime"`
+	EndTime         string `json:"endtime"`
+	MinLatitude     string `json:"minlatitude"`
+	MaxLatitude     string `json:"maxlatitude"`
+	MinLongitude    string `json:"minlongitude"`
+	MaxLongitude    string `json:"maxlongitude"`
+	Latitude        string `json:"latitude"`
+	Longitude       string `json:"longitude"`
+	MinRadius       string `json:"minradius"`
+	MaxRadius       string `json:"maxradius"`
+	MinDepth        string `json:"mindepth"`
+	MaxDepth        string `json:"maxdepth"`
+	MinMagnitude    string `json:"minmagnitude"`
+	MaxMagnitude    string `json:"maxmagnitude"`
+	MagnitudeType   string `json:"magnitudetype"`
+	EventType       string `json:"eventtype"`
+	EventID         string `json:"eventid"`
+	Catalog         string `json:"catalog"`
+	Contributor     string `json:"contributor"`
+	UpdatedAfter    string `json:"updatedafter"`
+	Limit           string `json:"limit"`
+	OrderBy         string `json:"orderby"`
+	Format          string `json:"format"`
+	IncludeArrivals bool   `json:"includearrivals"`
+}
+
+func (h *eventsHandler) importEvents(w http.ResponseWriter, r *http.Request) {
+	var req eventImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	res, err := h.importer.Import(importer.EventRequest{
+		SourceID: req.SourceID,
+		Query: fdsnclient.EventQuery{
+			StartTime:       req.StartTime,
+			EndTime:         req.EndTime,
+			MinLat:          req.MinLatitude,
+			MaxLat:          req.MaxLatitude,
+			MinLon:          req.MinLongitude,
+			MaxLon:          req.MaxLongitude,
+			Lat:             req.Latitude,
+			Lon:             req.Longitude,
+			MinRadius:       req.MinRadius,
+			MaxRadius:       req.MaxRadius,
+			MinDepth:        req.MinDepth,
+			MaxDepth:        req.MaxDepth,
+			MinMag:          req.MinMagnitude,
+			MaxMag:          req.MaxMagnitude,
+			MagType:         req.MagnitudeType,
+			EventType:       req.EventType,
+			EventID:         req.EventID,
+			Catalog:         req.Catalog,
+			Contributor:     req.Contributor,
+			UpdatedAfter:    req.UpdatedAfter,
+			Limit:           req.Limit,
+			OrderBy:         req.OrderBy,
+			Format:          req.Format,
+			IncludeArrivals: req.IncludeArrivals,
+		},
+	})
+	if err != nil {
+		writeError(w, importStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
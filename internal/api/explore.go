@@ -11,6 +11,7 @@ import (
 
 type exploreHandler struct {
 	sourceStore store.SourceStore
+	credStore   store.CredentialStore
 }
 
 func (h *exploreHandler) stations(w http.ResponseWriter, r *http.Request) {
@@ -24,6 +25,10 @@ func (h *exploreHandler) stations(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "source not found")
 		return
 	}
+	if !src.Enabled {
+		writeError(w, http.StatusConflict, "source "+src.Name+" is disabled")
+		return
+	}
 
 	q := fdsnclient.StationQuery{
 		Network:  r.URL.Query().Get("net"),
@@ -36,7 +41,11 @@ func (h *exploreHandler) stations(w http.ResponseWriter, r *http.Request) {
 		MaxLon:   r.URL.Query().Get("maxlon"),
 	}
 
-	client := fdsnclient.New(src.BaseURL)
+	client, err := upstreamClient(h.credStore, src)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	stations, err := client.QueryStations(q)
 	if err != nil {
 		writeError(w, http.StatusBadGateway, err.Error())
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/joescharf/fdsn/internal/extract"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+type extractionsHandler struct {
+	store     store.ExtractionStore
+	extractor *extract.Extractor
+}
+
+// extractionRequest sets the stations and windows of an extraction; fields
+// left out take the extract package defaults. Offsets are seconds.
+type extractionRequest struct {
+	MinRadius *float64 `json:"min_radius"`
+	MaxRadius *float64 `json:"max_radius"`
+	Channel   string   `json:"channel"`
+	Window    string   `json:"window"`
+	Before    *float64 `json:"before"`
+	After     *float64 `json:"after"`
+	DryRun    bool     `json:"dry_run"`
+}
+
+// create starts an extraction of the waveforms around an event, answering
+// 202 with the pending extraction. A dry run answers 200 with the planned
+// requests and stores nothing.
+func (h *extractionsHandler) create(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req extractionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+	p := extract.DefaultParams()
+	if req.MinRadius != nil {
+		p.MinRadius = *req.MinRadius
+	}
+	if req.MaxRadius != nil {
+		p.MaxRadius = *req.MaxRadius
+	}
+	if req.Channel != "" {
+		p.Channel = req.Channel
+	}
+	if req.Window != "" {
+		p.Window = req.Window
+	}
+	if req.Before != nil {
+		p.Before = *req.Before
+	}
+	if req.After != nil {
+		p.After = *req.After
+	}
+	if err := p.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.DryRun {
+		x, err := h.extractor.Plan(eventID, p)
+		if err != nil {
+			writeError(w, extractionStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, x)
+		return
+	}
+	x, err := h.extractor.Start(eventID, p)
+	if err != nil {
+		writeError(w, extractionStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, x)
+}
+
+// extractionStatus maps extraction errors to HTTP statuses.
+func extractionStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrEventNotFound), errors.Is(err, store.ErrExtractionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, extract.ErrTooManyStations), errors.Is(err, extract.ErrNoStations):
+		return http.StatusBadRequest
+	case errors.Is(err, extract.ErrRunning), errors.Is(err, extract.ErrNotDone):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// list returns the extractions, newest first, of the event given by the
+// event query parameter or of every event.
+func (h *extractionsHandler) list(w http.ResponseWriter, r *http.Request) {
+	var eventID int64
+	if s := r.URL.Query().Get("event"); s != "" {
+		var err error
+		if eventID, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid event %q", s))
+			return
+		}
+	}
+	extractions, err := h.store.List(eventID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, extractions)
+}
+
+// get returns an extraction with the outcome of each request.
+func (h *extractionsHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	x, err := h.store.Get(id)
+	if err != nil {
+		writeError(w, extractionStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, x)
+}
+
+// bundle downloads the zip of a finished extraction.
+func (h *extractionsHandler) bundle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	path, x, err := h.extractor.Bundle(id)
+	if err != nil {
+		writeError(w, extractionStatus(err), err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-extraction-%d.zip"`, x.EventID, x.ID))
+	http.ServeFile(w, r, path)
+}
+
+// delete removes a finished extraction and its bundle; running ones answer
+// 409.
+func (h *extractionsHandler) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := h.extractor.Delete(id); err != nil {
+		writeError(w, extractionStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
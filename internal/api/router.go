@@ -6,23 +6,39 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/jmoiron/sqlx"
 	"github.com/rs/cors"
 	"github.com/rs/zerolog/log"
 
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/extract"
 	"github.com/joescharf/fdsn/internal/fdsnserver"
+	"github.com/joescharf/fdsn/internal/health"
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/metrics"
+	"github.com/joescharf/fdsn/internal/secrets"
 	"github.com/joescharf/fdsn/internal/store"
 	"github.com/joescharf/fdsn/internal/ui"
+	"github.com/joescharf/fdsn/internal/wavecache"
 )
 
 // NewRouter builds the top-level chi router with all API routes and the SPA handler.
-func NewRouter(db *sqlx.DB) (http.Handler, error) {
+// box encrypts stored upstream source credentials; with a nil box credentials
+// cannot be saved and upstream requests are anonymous. checker probes sources
+// when they are created or updated. lookup finds the data centre serving a
+// network so sources can be registered and imports routed automatically.
+// schemaVersion is the default StationXML version of the station service.
+// waves caches miniSEED fetched for dataselect and the waveform proxy; nil
+// disables caching. extractor runs event waveform extractions. serveMetrics
+// exposes the Prometheus metrics at /metrics.
+func NewRouter(db *database.DB, box *secrets.Box, checker *health.Checker, lookup *discovery.Client, schemaVersion string, waves *wavecache.Cache, extractor *extract.Extractor, serveMetrics bool) (http.Handler, error) {
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(zerologMiddleware)
+	r.Use(metrics.Middleware)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(30 * time.Second))
 
@@ -41,16 +57,24 @@ func NewRouter(db *sqlx.DB) (http.Handler, error) {
 	staStore := store.NewStationStore(db)
 	availStore := store.NewAvailabilityStore(db)
 	statsStore := store.NewStatsStore(db)
+	searchStore := store.NewSearchStore(db)
+	credStore := store.NewCredentialStore(db, box)
+	discoverer := discovery.NewDiscoverer(lookup, srcStore)
 
 	// Handlers
-	sources := &sourcesHandler{store: srcStore}
-	explore := &exploreHandler{sourceStore: srcStore}
-	imp := &importHandler{sourceStore: srcStore, stationStore: staStore, availabilityStore: availStore}
+	sources := &sourcesHandler{store: srcStore, credStore: credStore, checker: checker, discovery: discoverer}
+	explore := &exploreHandler{sourceStore: srcStore, credStore: credStore}
+	imp := &importHandler{importer: importer.New(srcStore, staStore, availStore, credStore, discoverer)}
 	stations := &stationsHandler{store: staStore, availStore: availStore}
 	networks := &networksHandler{store: staStore}
 	stats := &statsHandler{store: statsStore}
-	waveforms := &waveformsHandler{sourceStore: srcStore}
+	search := &searchHandler{store: searchStore}
+	waveforms := &waveformsHandler{sourceStore: srcStore, credStore: credStore, cache: waves}
 	avail := &availabilityHandler{store: availStore}
+	evStore := store.NewEventStore(db)
+	events := &eventsHandler{store: evStore, stationStore: staStore, importer: importer.NewEventImporter(srcStore, evStore)}
+	extractions := &extractionsHandler{store: store.NewExtractionStore(db), extractor: extractor}
+	tools := &toolsHandler{events: evStore, stations: staStore}
 
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
@@ -59,9 +83,15 @@ func NewRouter(db *sqlx.DB) (http.Handler, error) {
 		// Sources CRUD
 		r.Get("/sources", sources.list)
 		r.Post("/sources", sources.create)
+		r.Post("/sources/discover", sources.discover)
 		r.Get("/sources/{id}", sources.get)
 		r.Put("/sources/{id}", sources.update)
 		r.Delete("/sources/{id}", sources.delete)
+		r.Post("/sources/{id}/probe", sources.probe)
+
+		// Upstream credentials (write-only)
+		r.Put("/sources/{id}/credentials", sources.setCredentials)
+		r.Delete("/sources/{id}/credentials", sources.deleteCredentials)
 
 		// Explore external FDSN sources
 		r.Get("/sources/{id}/explore/stations", explore.stations)
@@ -73,27 +103,60 @@ func NewRouter(db *sqlx.DB) (http.Handler, error) {
 		// Import
 		r.Post("/import/stations", imp.importStations)
 		r.Get("/import/refresh-targets", imp.refreshTargets)
+		r.Post("/import/events", events.importEvents)
 
 		// Stations
 		r.Get("/stations", stations.list)
+		r.Get("/stations/clusters", stations.clusters)
 		r.Get("/stations/{id}", stations.get)
 		r.Delete("/stations/{id}", stations.delete)
 
 		// Availability
 		r.Get("/stations/{id}/availability", avail.getByStation)
 
+		// Events
+		r.Get("/events", events.list)
+		r.Get("/events/{id}", events.get)
+		r.Delete("/events/{id}", events.delete)
+		r.Get("/events/{id}/stations", events.stations)
+
+		// Event waveform extractions
+		r.Post("/events/{id}/extractions", extractions.create)
+		r.Get("/extractions", extractions.list)
+		r.Get("/extractions/{id}", extractions.get)
+		r.Get("/extractions/{id}/bundle", extractions.bundle)
+		r.Delete("/extractions/{id}", extractions.delete)
+
+		// Distance and travel-time calculators
+		r.Get("/tools/distance", tools.distance)
+		r.Get("/tools/traveltime", tools.travelTime)
+
 		// Networks
 		r.Get("/networks", networks.list)
 
+		// Search across networks, stations and channels
+		r.Get("/search", search.search)
+
+		// Restricted flags
+		r.Put("/networks/{id}/restricted", stations.setRestricted(store.LevelNetwork))
+		r.Put("/stations/{id}/restricted", stations.setRestricted(store.LevelStation))
+		r.Put("/channels/{id}/restricted", stations.setRestricted(store.LevelChannel))
+
 		// Waveforms
 		r.Get("/waveforms/proxy", waveforms.proxy)
+		r.Get("/waveforms/cache", waveforms.cacheStats)
+		r.Delete("/waveforms/cache", waveforms.clearCache)
 
 		// Stats
 		r.Get("/stats", stats.get)
 	})
 
+	if serveMetrics {
+		r.Handle("/metrics", metrics.Handler())
+	}
+
 	// FDSN-compliant endpoints
-	r.Mount("/fdsnws", fdsnserver.NewRouter(db))
+	r.Mount("/fdsnws", fdsnserver.NewRouter(db, credStore, schemaVersion, waves))
 
 	// SPA handler — serves embedded UI for everything else
 	spaHandler, err := ui.Handler()
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+type searchHandler struct {
+	store store.SearchStore
+}
+
+func (h *searchHandler) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	results, err := h.store.Search(q, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"query":   q,
+		"results": results,
+	})
+}
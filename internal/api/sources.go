@@ -2,16 +2,22 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/health"
 	"github.com/joescharf/fdsn/internal/models"
 	"github.com/joescharf/fdsn/internal/store"
 )
 
 type sourcesHandler struct {
-	store store.SourceStore
+	store     store.SourceStore
+	credStore store.CredentialStore
+	checker   *health.Checker
+	discovery *discovery.Discoverer
 }
 
 func (h *sourcesHandler) list(w http.ResponseWriter, r *http.Request) {
@@ -50,10 +56,13 @@ func (h *sourcesHandler) create(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name and base_url are required")
 		return
 	}
+	src.ManagedBy = ""
 	if err := h.store.Create(&src); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	probed := src
+	go h.checker.Check(&probed)
 	writeJSON(w, http.StatusCreated, src)
 }
 
@@ -63,26 +72,177 @@ func (h *sourcesHandler) update(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
+	cur, err := h.store.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
 	var src models.Source
 	if err := json.NewDecoder(r.Body).Decode(&src); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON")
 		return
 	}
 	src.ID = id
+	src.ManagedBy = ""
+	if cur.ManagedBy == models.ManagedByConfig {
+		// Only the enabled flag of a config source is changed here; the
+		// other fields may be omitted or repeated.
+		if (src.Name != "" && src.Name != cur.Name) ||
+			(src.BaseURL != "" && src.BaseURL != cur.BaseURL) ||
+			(src.Description != "" && src.Description != cur.Description) {
+			writeError(w, http.StatusConflict, "source "+cur.Name+" is managed by the config file; only enabled can be changed here")
+			return
+		}
+		src.Name, src.BaseURL, src.Description = cur.Name, cur.BaseURL, cur.Description
+		src.ManagedBy = cur.ManagedBy
+	}
 	if err := h.store.Update(&src); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	probed := src
+	go h.checker.Check(&probed)
 	writeJSON(w, http.StatusOK, src)
 }
 
+// delete removes a source with its catalog, or moves the catalog to the
+// source given by reassign_to. With dry_run=true nothing is changed and the
+// response reports what would be.
 func (h *sourcesHandler) delete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
-	if err := h.store.Delete(id); err != nil {
+	var opts store.DeleteSourceOptions
+	q := r.URL.Query()
+	if v := q.Get("dry_run"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid dry_run")
+			return
+		}
+	}
+	if v := q.Get("reassign_to"); v != "" {
+		if opts.ReassignTo, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid reassign_to")
+			return
+		}
+	}
+	if !h.deletable(w, id) {
+		return
+	}
+	res, err := h.store.Delete(id, opts)
+	if errors.Is(err, store.ErrReassign) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// deletable reports whether the source may be deleted through the API,
+// writing an error response if not: sources managed by the config file are
+// removed there.
+func (h *sourcesHandler) deletable(w http.ResponseWriter, id int64) bool {
+	src, err := h.store.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "source not found")
+		return false
+	}
+	if src.ManagedBy == models.ManagedByConfig {
+		writeError(w, http.StatusConflict, "source "+src.Name+" is managed by the config file; remove it there")
+		return false
+	}
+	return true
+}
+
+// discover looks up the data centres serving a network and registers any
+// that are not yet sources. Newly created sources are probed in the
+// background.
+func (h *sourcesHandler) discover(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Network string `json:"network"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.Network == "" {
+		writeError(w, http.StatusBadRequest, "network is required")
+		return
+	}
+	found, err := h.discovery.Discover(req.Network)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	for _, d := range found {
+		if d.Created {
+			probed := d.Source
+			go h.checker.Check(&probed)
+		}
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// probe runs a health check of the source now and returns the result.
+func (h *sourcesHandler) probe(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	src, err := h.store.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.checker.Check(src))
+}
+
+// setCredentials stores upstream credentials for a source. The body is a
+// models.SourceCredentials; either username and password or eida_token is
+// required. Secrets are never echoed back.
+func (h *sourcesHandler) setCredentials(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if _, err := h.store.Get(id); err != nil {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+	var cred models.SourceCredentials
+	if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if (cred.Username == "" || cred.Password == "") && cred.EIDAToken == "" {
+		writeError(w, http.StatusBadRequest, "username and password, or eida_token, are required")
+		return
+	}
+	if err := h.credStore.Set(id, &cred); err != nil {
+		if errors.Is(err, store.ErrNoSecretKey) {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *sourcesHandler) deleteCredentials(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := h.credStore.Delete(id); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -1,10 +1,17 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/joescharf/fdsn/internal/export"
 	"github.com/joescharf/fdsn/internal/store"
 )
 
@@ -14,26 +21,189 @@ type stationsHandler struct {
 }
 
 func (h *stationsHandler) list(w http.ResponseWriter, r *http.Request) {
-	network := r.URL.Query().Get("network")
-	station := r.URL.Query().Get("station")
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	if limit <= 0 || limit > 1000 {
-		limit = 100
+	q, err := parseStationQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeStationPage(w, r, q)
+}
+
+// writeStationPage runs q and writes the page, or 400 for an invalid sort or
+// cursor. With ?format=geojson, kml or csv it writes every matching station
+// in that format instead.
+func (h *stationsHandler) writeStationPage(w http.ResponseWriter, r *http.Request, q store.StationQuery) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		h.writeStationExport(w, format, q)
+		return
+	}
+	page, err := h.store.ListStations(q)
+	if errors.Is(err, store.ErrInvalidQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
 
-	stations, total, err := h.store.ListStations(network, station, limit, offset)
+func (h *stationsHandler) writeStationExport(w http.ResponseWriter, format string, q store.StationQuery) {
+	if !export.Supported(format) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported format %q (want json, geojson, kml or csv)", format))
+		return
+	}
+	stations, err := h.store.ListStationSummaries(q)
+	if errors.Is(err, store.ErrInvalidQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.Filename(format)+`"`)
+	_ = export.Write(w, format, stations)
+}
+
+// parseStationQuery reads the station list filters, sort and cursor from the
+// query string.
+func parseStationQuery(r *http.Request) (store.StationQuery, error) {
+	v := r.URL.Query()
+	q := store.StationQuery{
+		Network: v.Get("network"),
+		Station: v.Get("station"),
+		Channel: v.Get("channel"),
+		Sensor:  v.Get("sensor"),
+		Sort:    v.Get("sort"),
+		Cursor:  v.Get("cursor"),
+	}
+	if sort, ok := strings.CutPrefix(q.Sort, "-"); ok {
+		q.Sort, q.Desc = sort, true
+	}
+	switch v.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	var err error
+	intParam := func(name string) int64 {
+		s := v.Get(name)
+		if s == "" || err != nil {
+			return 0
+		}
+		n, perr := strconv.ParseInt(s, 10, 64)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q", name, s)
+		}
+		return n
+	}
+	floatParam := func(name string) *float64 {
+		s := v.Get(name)
+		if s == "" || err != nil {
+			return nil
+		}
+		f, perr := strconv.ParseFloat(s, 64)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q", name, s)
+			return nil
+		}
+		return &f
+	}
+
+	q.SourceID = intParam("source")
+	q.Limit = int(intParam("limit"))
+	q.MinSampleRate = floatParam("minsamplerate")
+	q.MaxSampleRate = floatParam("maxsamplerate")
+	q.MinLatitude = floatParam("minlat")
+	q.MaxLatitude = floatParam("maxlat")
+	q.MinLongitude = floatParam("minlon")
+	q.MaxLongitude = floatParam("maxlon")
+	q.Latitude = floatParam("lat")
+	q.Longitude = floatParam("lon")
+	q.MinRadius = floatParam("minradius")
+	q.MaxRadius = floatParam("maxradius")
+	if err != nil {
+		return q, err
+	}
+	if (q.MinRadius != nil || q.MaxRadius != nil) && (q.Latitude == nil || q.Longitude == nil) {
+		return q, fmt.Errorf("minradius and maxradius require lat and lon")
+	}
+	if s := v.Get("active_at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("invalid active_at %q: want RFC 3339", s)
+		}
+		q.ActiveAt = &t
+	}
+	if s := v.Get("has_availability"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid has_availability %q", s)
+		}
+		q.HasAvailability = &b
+	}
+	return q, nil
+}
+
+// clusters groups stations for the map. bbox is the viewport as
+// minlon,minlat,maxlon,maxlat (Leaflet's toBBoxString); zoom is the tile
+// zoom level.
+func (h *stationsHandler) clusters(w http.ResponseWriter, r *http.Request) {
+	var box store.GeoFilter
+	if s := r.URL.Query().Get("bbox"); s != "" {
+		parts := strings.Split(s, ",")
+		if len(parts) != 4 {
+			writeError(w, http.StatusBadRequest, "bbox must be minlon,minlat,maxlon,maxlat")
+			return
+		}
+		v := make([]float64, 4)
+		for i, p := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "bbox must be minlon,minlat,maxlon,maxlat")
+				return
+			}
+			v[i] = f
+		}
+		box = viewportBox(v[0], v[1], v[2], v[3])
+	}
+	zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "zoom is required")
+		return
+	}
 
+	clusters, err := h.store.ClusterStations(box, zoom)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"stations": stations,
-		"total":    total,
+		"zoom":     zoom,
+		"clusters": clusters,
 	})
 }
 
+// viewportBox converts a map viewport to a filter. Map longitudes run past
+// ±180 when the world wraps; a viewport wider than the world selects every
+// longitude, and one past the antimeridian is normalised to cross it.
+func viewportBox(minLon, minLat, maxLon, maxLat float64) store.GeoFilter {
+	box := store.GeoFilter{MinLatitude: &minLat, MaxLatitude: &maxLat}
+	if maxLon-minLon >= 360 {
+		return box
+	}
+	minLon = math.Mod(math.Mod(minLon+180, 360)+360, 360) - 180
+	maxLon = math.Mod(math.Mod(maxLon+180, 360)+360, 360) - 180
+	box.MinLongitude, box.MaxLongitude = &minLon, &maxLon
+	return box
+}
+
 func (h *stationsHandler) get(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -91,22 +261,13 @@ func (h *stationsHandler) listStationsBySource(w http.ResponseWriter, r *http.Re
 		writeError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
-	network := r.URL.Query().Get("network")
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	if limit <= 0 || limit > 1000 {
-		limit = 100
-	}
-
-	stations, total, err := h.store.ListStationsBySource(sourceID, network, limit, offset)
+	q, err := parseStationQuery(r)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"stations": stations,
-		"total":    total,
-	})
+	q.SourceID = sourceID
+	h.writeStationPage(w, r, q)
 }
 
 func (h *networksHandler) list(w http.ResponseWriter, r *http.Request) {
@@ -117,3 +278,27 @@ func (h *networksHandler) list(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, networks)
 }
+
+// setRestricted returns a handler that marks the network, station or channel
+// identified by {id} as restricted or open. Body: {"restricted": true}
+func (h *stationsHandler) setRestricted(level string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+		var req struct {
+			Restricted bool `json:"restricted"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if err := h.store.SetRestricted(level, id, req.Restricted); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "level": level, "restricted": req.Restricted})
+	}
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/geodesy"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/traveltime"
+)
+
+const (
+	// maxToolDistances is the most distances one traveltime request may
+	// list in distdeg.
+	maxToolDistances = 200
+	// maxToolDepth is the deepest source in km the traveltime tool accepts.
+	maxToolDepth = 800.0
+)
+
+// toolPhases are the phase names the traveltime tool can be limited to: P
+// and S stand for the first compressional and shear arrivals, whatever
+// branch they take.
+var toolPhases = []string{"P", "S", "LQ", "LR"}
+
+// toolsHandler serves the distance and travel-time calculators. They work
+// from stored coordinates alone and never contact a source.
+type toolsHandler struct {
+	events   store.EventStore
+	stations store.StationStore
+}
+
+// point is one end of a tools request: a stored event or station, or bare
+// coordinates.
+type point struct {
+	lat, lon float64
+	event    *models.Event
+	station  *models.Station
+}
+
+// toolDistance is the response of the distance tool. Azimuth is measured
+// at the event towards the station and BackAzimuth at the station towards
+// the event, both clockwise from north.
+type toolDistance struct {
+	EventID          *int64  `json:"event_id,omitempty"`
+	StationID        *int64  `json:"station_id,omitempty"`
+	NetworkCode      string  `json:"network_code,omitempty"`
+	StationCode      string  `json:"station_code,omitempty"`
+	EventLatitude    float64 `json:"event_latitude"`
+	EventLongitude   float64 `json:"event_longitude"`
+	StationLatitude  float64 `json:"station_latitude"`
+	StationLongitude float64 `json:"station_longitude"`
+	DistanceDeg      float64 `json:"distance_deg"`
+	DistanceKM       float64 `json:"distance_km"`
+	Azimuth          float64 `json:"azimuth"`
+	BackAzimuth      float64 `json:"back_azimuth"`
+}
+
+// toolArrival is a predicted arrival, with its absolute time when the
+// origin time is known.
+type toolArrival struct {
+	traveltime.Arrival
+	ArrivalTime *time.Time `json:"arrival_time,omitempty"`
+}
+
+type toolTravelTimes struct {
+	DistanceDeg float64       `json:"distance_deg"`
+	Arrivals    []toolArrival `json:"arrivals"`
+}
+
+// distance answers the distance and azimuths between an event and a
+// station, each given by id (event, station) or coordinates (evlat and
+// evlon, stalat and stalon).
+func (h *toolsHandler) distance(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	ev, err := h.eventPoint(v)
+	if err == nil && ev == nil {
+		err = badParam("event or evlat and evlon are required")
+	}
+	if err != nil {
+		writeError(w, toolStatus(err), err.Error())
+		return
+	}
+	st, err := h.stationPoint(v)
+	if err == nil && st == nil {
+		err = badParam("station or stalat and stalon are required")
+	}
+	if err != nil {
+		writeError(w, toolStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, distanceBetween(ev, st))
+}
+
+// travelTime answers the predicted P, S and surface wave arrivals in the
+// IASP91 model at the distdeg distances, or between an event and a station
+// given as for distance. The source depth is evdepth, else the event's.
+// Arrivals carry absolute times when the event is a stored one.
+func (h *toolsHandler) travelTime(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	phases, err := parsePhases(v.Get("phases"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ev, err := h.eventPoint(v)
+	if err != nil {
+		writeError(w, toolStatus(err), err.Error())
+		return
+	}
+	st, err := h.stationPoint(v)
+	if err != nil {
+		writeError(w, toolStatus(err), err.Error())
+		return
+	}
+
+	depth := 0.0
+	if ev != nil && ev.event != nil && ev.event.DepthKM != nil {
+		depth = max(*ev.event.DepthKM, 0)
+	}
+	if s := v.Get("evdepth"); s != "" {
+		if depth, err = strconv.ParseFloat(s, 64); err != nil || depth < 0 || depth > maxToolDepth {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid evdepth %q: want 0 to %g km", s, maxToolDepth))
+			return
+		}
+	}
+
+	resp := map[string]any{"model": "iasp91", "depth_km": depth}
+	var distances []float64
+	switch {
+	case v.Get("distdeg") != "":
+		if st != nil {
+			writeError(w, http.StatusBadRequest, "distdeg cannot be combined with a station")
+			return
+		}
+		if distances, err = parseDistances(v.Get("distdeg")); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case ev != nil && st != nil:
+		d := distanceBetween(ev, st)
+		resp["distance"] = d
+		distances = []float64{d.DistanceDeg}
+	default:
+		writeError(w, http.StatusBadRequest, "distdeg, or an event and a station, are required")
+		return
+	}
+
+	var origin *time.Time
+	if ev != nil && ev.event != nil {
+		origin = &ev.event.Time
+		resp["origin_time"] = ev.event.Time
+	}
+	times := make([]toolTravelTimes, len(distances))
+	for i, d := range distances {
+		times[i] = toolTravelTimes{DistanceDeg: d, Arrivals: arrivals(d, depth, phases, origin)}
+	}
+	resp["travel_times"] = times
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// arrivals returns the arrivals of the given phases at distance degrees,
+// earliest first.
+func arrivals(distance, depth float64, phases map[string]bool, origin *time.Time) []toolArrival {
+	var found []traveltime.Arrival
+	if phases["P"] {
+		if a, ok := traveltime.FirstP(distance, depth); ok {
+			found = append(found, a)
+		}
+	}
+	if phases["S"] {
+		if a, ok := traveltime.FirstS(distance, depth); ok {
+			found = append(found, a)
+		}
+	}
+	for _, a := range traveltime.Surface(distance) {
+		if phases[a.Phase] {
+			found = append(found, a)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Time < found[j].Time })
+
+	out := make([]toolArrival, len(found))
+	for i, a := range found {
+		out[i] = toolArrival{Arrival: a}
+		if origin != nil {
+			t := origin.Add(time.Duration(a.Time * float64(time.Second))).UTC()
+			out[i].ArrivalTime = &t
+		}
+	}
+	return out
+}
+
+func distanceBetween(ev, st *point) toolDistance {
+	deg := geodesy.Distance(ev.lat, ev.lon, st.lat, st.lon)
+	d := toolDistance{
+		EventLatitude:    ev.lat,
+		EventLongitude:   ev.lon,
+		StationLatitude:  st.lat,
+		StationLongitude: st.lon,
+		DistanceDeg:      deg,
+		DistanceKM:       geodesy.DegreesToKM(deg),
+		Azimuth:          geodesy.Azimuth(ev.lat, ev.lon, st.lat, st.lon),
+		BackAzimuth:      geodesy.Azimuth(st.lat, st.lon, ev.lat, ev.lon),
+	}
+	if ev.event != nil {
+		d.EventID = &ev.event.ID
+	}
+	if st.station != nil {
+		d.StationID = &st.station.ID
+		d.NetworkCode, d.StationCode = st.station.NetworkCode, st.station.Code
+	}
+	return d
+}
+
+// eventPoint reads the event of a tools request, returning nil if none is
+// given.
+func (h *toolsHandler) eventPoint(v url.Values) (*point, error) {
+	p, id, err := parsePoint(v, "event", "evlat", "evlon")
+	if err != nil || id == 0 {
+		return p, err
+	}
+	ev, err := h.events.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	return &point{lat: ev.Latitude, lon: ev.Longitude, event: ev}, nil
+}
+
+// stationPoint reads the station of a tools request, returning nil if none
+// is given.
+func (h *toolsHandler) stationPoint(v url.Values) (*point, error) {
+	p, id, err := parsePoint(v, "station", "stalat", "stalon")
+	if err != nil || id == 0 {
+		return p, err
+	}
+	st, err := h.stations.GetStation(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &point{lat: st.Latitude, lon: st.Longitude, station: &st.Station}, nil
+}
+
+var errStationNotFound = errors.New("station not found")
+
+// parsePoint reads either the id parameter or the lat and lon pair. It
+// returns the id to look up, or the point given by coordinates, or neither.
+func parsePoint(v url.Values, idName, latName, lonName string) (*point, int64, error) {
+	idStr, latStr, lonStr := v.Get(idName), v.Get(latName), v.Get(lonName)
+	if idStr != "" {
+		if latStr != "" || lonStr != "" {
+			return nil, 0, badParam(fmt.Sprintf("%s cannot be combined with %s and %s", idName, latName, lonName))
+		}
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, 0, badParam(fmt.Sprintf("invalid %s %q", idName, idStr))
+		}
+		return nil, id, nil
+	}
+	if latStr == "" && lonStr == "" {
+		return nil, 0, nil
+	}
+	if latStr == "" || lonStr == "" {
+		return nil, 0, badParam(fmt.Sprintf("%s and %s must be given together", latName, lonName))
+	}
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, 0, badParam(fmt.Sprintf("invalid %s %q: want -90 to 90 degrees", latName, latStr))
+	}
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, 0, badParam(fmt.Sprintf("invalid %s %q: want -180 to 180 degrees", lonName, lonStr))
+	}
+	return &point{lat: lat, lon: lon}, 0, nil
+}
+
+// parseDistances reads a comma-separated list of distances in degrees.
+func parseDistances(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) > maxToolDistances {
+		return nil, fmt.Errorf("at most %d distances may be given", maxToolDistances)
+	}
+	distances := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		d, err := strconv.ParseFloat(p, 64)
+		if err != nil || d < 0 || d > 180 {
+			return nil, fmt.Errorf("invalid distdeg %q: want 0 to 180 degrees", p)
+		}
+		distances = append(distances, d)
+	}
+	return distances, nil
+}
+
+// parsePhases reads a comma-separated list of toolPhases; empty means all.
+func parsePhases(s string) (map[string]bool, error) {
+	phases := map[string]bool{}
+	if s == "" {
+		for _, p := range toolPhases {
+			phases[p] = true
+		}
+		return phases, nil
+	}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		found := false
+		for _, known := range toolPhases {
+			found = found || p == known
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown phase %q: want %s", p, strings.Join(toolPhases, ", "))
+		}
+		phases[p] = true
+	}
+	return phases, nil
+}
+
+// toolStatus maps the errors of tools requests to HTTP statuses.
+func toolStatus(err error) int {
+	var bad badParam
+	switch {
+	case errors.As(err, &bad):
+		return http.StatusBadRequest
+	case errors.Is(err, store.ErrEventNotFound), errors.Is(err, errStationNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// badParam is an invalid or missing tools request parameter.
+type badParam string
+
+func (e badParam) Error() string { return string(e) }
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// upstreamClient returns an FDSN client for src carrying the source's stored
+// credentials, so restricted data is fetched via /queryauth when possible.
+func upstreamClient(creds store.CredentialStore, src *models.Source) (*fdsnclient.Client, error) {
+	cred, err := creds.Get(src.ID)
+	if err != nil {
+		return nil, err
+	}
+	return fdsnclient.New(src.BaseURL).WithSource(src.Name).WithCredentials(cred), nil
+}
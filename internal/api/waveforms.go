@@ -1,18 +1,23 @@
 package api
 
 import (
-	"io"
+	"bytes"
 	"net/http"
 
 	"github.com/joescharf/fdsn/internal/fdsnclient"
 	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/wavecache"
 )
 
 type waveformsHandler struct {
 	sourceStore store.SourceStore
+	credStore   store.CredentialStore
+	cache       *wavecache.Cache
 }
 
-// proxy streams miniSEED data from an external FDSN source.
+// proxy streams miniSEED data from an external FDSN source, answering from
+// the waveform cache where it can. An empty loc selects the empty location
+// code.
 // Query params: source_id, net, sta, loc, cha, starttime, endtime
 func (h *waveformsHandler) proxy(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -27,34 +32,56 @@ func (h *waveformsHandler) proxy(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "source not found")
 		return
 	}
+	if !src.Enabled {
+		writeError(w, http.StatusConflict, "source "+src.Name+" is disabled")
+		return
+	}
 
-	net := q.Get("net")
-	sta := q.Get("sta")
-	loc := q.Get("loc")
-	cha := q.Get("cha")
-	starttime := q.Get("starttime")
-	endtime := q.Get("endtime")
-
-	if net == "" || sta == "" || cha == "" || starttime == "" || endtime == "" {
+	sr := fdsnclient.StreamRequest{
+		Network:   q.Get("net"),
+		Station:   q.Get("sta"),
+		Location:  q.Get("loc"),
+		Channel:   q.Get("cha"),
+		StartTime: q.Get("starttime"),
+		EndTime:   q.Get("endtime"),
+	}
+	if sr.Network == "" || sr.Station == "" || sr.Channel == "" || sr.StartTime == "" || sr.EndTime == "" {
 		writeError(w, http.StatusBadRequest, "net, sta, cha, starttime, endtime are required")
 		return
 	}
 
-	client := fdsnclient.New(src.BaseURL)
-	body, err := client.FetchMiniSEED(net, sta, loc, cha, starttime, endtime)
+	client, err := upstreamClient(h.credStore, src)
 	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	if err := h.cache.Fetch(src.ID, []fdsnclient.StreamRequest{sr}, client.FetchMiniSEEDBulk, &buf); err != nil {
 		writeError(w, http.StatusBadGateway, err.Error())
 		return
 	}
-	if body == nil {
+	if buf.Len() == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	defer body.Close()
 
 	w.Header().Set("Content-Type", "application/vnd.fdsn.mseed")
 	w.Header().Set("Content-Disposition", "inline")
-	_, _ = io.Copy(w, body)
+	_, _ = buf.WriteTo(w)
+}
+
+// cacheStats reports waveform cache hits, misses and size.
+func (h *waveformsHandler) cacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.cache.Stats())
+}
+
+// clearCache empties the waveform cache.
+func (h *waveformsHandler) clearCache(w http.ResponseWriter, r *http.Request) {
+	if err := h.cache.Clear(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func parseInt64(s string) int64 {
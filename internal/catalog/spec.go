@@ -0,0 +1,210 @@
+// Package catalog reconciles the database with a declarative catalog spec: a
+// YAML file listing sources and the channels to import from each, so a
+// portal's catalog can be kept in version control and rebuilt from it.
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Spec is a catalog spec file.
+type Spec struct {
+	Sources []SourceSpec `yaml:"sources"`
+}
+
+// SourceSpec is a source and the selections imported from it. Enabled
+// defaults to true.
+type SourceSpec struct {
+	Name        string      `yaml:"name"`
+	BaseURL     string      `yaml:"base_url"`
+	Description string      `yaml:"description"`
+	Enabled     *bool       `yaml:"enabled"`
+	Selections  []Selection `yaml:"selections"`
+}
+
+// Selection picks channels from a source using station service parameters:
+// comma-separated codes with ? and * wildcards, "--" for an empty location,
+// and an optional time window limiting the channel epochs.
+type Selection struct {
+	Network   string `yaml:"net"`
+	Station   string `yaml:"sta"`
+	Location  string `yaml:"loc"`
+	Channel   string `yaml:"cha"`
+	StartTime string `yaml:"starttime"`
+	EndTime   string `yaml:"endtime"`
+
+	start, end *time.Time
+}
+
+// Load reads and validates the spec at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Parse decodes and validates a spec. Unknown keys are rejected so typos do
+// not silently widen a selection.
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+func (s *Spec) validate() error {
+	seen := make(map[string]bool, len(s.Sources))
+	for i := range s.Sources {
+		src := &s.Sources[i]
+		if src.Name == "" {
+			return fmt.Errorf("source %d: name is required", i+1)
+		}
+		key := strings.ToLower(src.Name)
+		if seen[key] {
+			return fmt.Errorf("source %s: listed more than once", src.Name)
+		}
+		seen[key] = true
+		if src.BaseURL == "" {
+			return fmt.Errorf("source %s: base_url is required", src.Name)
+		}
+		src.BaseURL = strings.TrimRight(src.BaseURL, "/")
+		for j := range src.Selections {
+			if err := src.Selections[j].validate(); err != nil {
+				return fmt.Errorf("source %s, selection %d: %w", src.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (sel *Selection) validate() error {
+	if sel.Network == "" {
+		return errors.New("net is required")
+	}
+	for _, codes := range []string{sel.Network, sel.Station, sel.Location, sel.Channel} {
+		for _, code := range splitCodes(codes) {
+			if _, err := path.Match(code, ""); err != nil {
+				return fmt.Errorf("invalid code pattern %q", code)
+			}
+		}
+	}
+	var err error
+	if sel.start, err = parseTime(sel.StartTime); err != nil {
+		return err
+	}
+	if sel.end, err = parseTime(sel.EndTime); err != nil {
+		return err
+	}
+	if sel.start != nil && sel.end != nil && !sel.end.After(*sel.start) {
+		return errors.New("endtime must be after starttime")
+	}
+	return nil
+}
+
+// String formats the selection as NET.STA.LOC.CHA with its time window.
+func (sel Selection) String() string {
+	codes := make([]string, 4)
+	for i, c := range []string{sel.Network, sel.Station, sel.Location, sel.Channel} {
+		if c == "" {
+			c = "*"
+		}
+		codes[i] = c
+	}
+	s := strings.Join(codes, ".")
+	if sel.StartTime != "" || sel.EndTime != "" {
+		s += " [" + sel.StartTime + ", " + sel.EndTime + "]"
+	}
+	return s
+}
+
+// Matches reports whether the selection covers the channel: its codes match
+// and, if the selection has a time window, its epoch overlaps it.
+func (sel Selection) Matches(network, station, location, channel string, start, end *time.Time) bool {
+	if location == "" {
+		location = "--"
+	}
+	if !matchCodes(sel.Network, network) || !matchCodes(sel.Station, station) ||
+		!matchCodes(sel.Location, location) || !matchCodes(sel.Channel, channel) {
+		return false
+	}
+	if sel.start != nil && end != nil && end.Before(*sel.start) {
+		return false
+	}
+	if sel.end != nil && start != nil && start.After(*sel.end) {
+		return false
+	}
+	return true
+}
+
+// window returns the selection's time window as station service parameters.
+func (sel Selection) window() (start, end string) {
+	const layout = "2006-01-02T15:04:05"
+	if sel.start != nil {
+		start = sel.start.Format(layout)
+	}
+	if sel.end != nil {
+		end = sel.end.Format(layout)
+	}
+	return start, end
+}
+
+// matchCodes matches code against a comma-separated pattern list; an empty
+// list matches everything.
+func matchCodes(patterns, code string) bool {
+	if patterns == "" {
+		return true
+	}
+	for _, p := range splitCodes(patterns) {
+		if ok, _ := path.Match(strings.ToUpper(p), strings.ToUpper(code)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCodes(s string) []string {
+	if s == "" {
+		return nil
+	}
+	codes := strings.Split(s, ",")
+	for i, c := range codes {
+		codes[i] = strings.TrimSpace(c)
+	}
+	return codes
+}
+
+// parseTime reads a selection time: a date or an ISO 8601 date-time, UTC if
+// no zone is given.
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, f := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(f, s); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q", s)
+}
@@ -0,0 +1,108 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const specYAML = `
+sources:
+  - name: Earthscope
+    base_url: https://service.earthscope.org/
+    selections:
+      - net: IU
+        sta: ANMO,COLA
+        loc: 00
+        cha: BH?
+      - net: II
+        starttime: 2020-01-01
+  - name: Local
+    base_url: http://localhost:9000
+    enabled: false
+`
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(specYAML))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(spec.Sources) != 2 {
+		t.Fatalf("sources = %d, want 2", len(spec.Sources))
+	}
+	es := spec.Sources[0]
+	if es.BaseURL != "https://service.earthscope.org" || es.Enabled != nil {
+		t.Errorf("source = %+v", es)
+	}
+	if got := es.Selections[0].String(); got != "IU.ANMO,COLA.00.BH?" {
+		t.Errorf("selection = %s", got)
+	}
+	if start, end := es.Selections[1].window(); start != "2020-01-01T00:00:00" || end != "" {
+		t.Errorf("window = %q, %q", start, end)
+	}
+	if local := spec.Sources[1]; local.Enabled == nil || *local.Enabled {
+		t.Errorf("enabled = %v, want false", local.Enabled)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		yaml, want string
+	}{
+		{"sources:\n  - base_url: http://a\n", "name is required"},
+		{"sources:\n  - name: A\n", "base_url is required"},
+		{"sources:\n  - name: A\n    base_url: http://a\n  - name: a\n    base_url: http://b\n", "listed more than once"},
+		{"sources:\n  - name: A\n    base_url: http://a\n    selections:\n      - sta: ANMO\n", "net is required"},
+		{"sources:\n  - name: A\n    base_url: http://a\n    selections:\n      - net: IU\n        starttime: yesterday\n", "invalid time"},
+		{"sources:\n  - name: A\n    base_url: http://a\n    selections:\n      - net: IU\n        starttime: 2024-01-01\n        endtime: 2023-01-01\n", "endtime must be after"},
+		{"sources:\n  - name: A\n    base_url: http://a\n    selections:\n      - net: '[IU'\n", "invalid code pattern"},
+		{"sources:\n  - name: A\n    url: http://a\n", "field url not found"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.yaml)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.yaml, err, tt.want)
+		}
+	}
+}
+
+func TestSelectionMatches(t *testing.T) {
+	spec, err := Parse([]byte(`
+sources:
+  - name: A
+    base_url: http://a
+    selections:
+      - net: IU
+        sta: AN*
+        loc: "--,10"
+        cha: BH?
+        starttime: 2020-01-01
+        endtime: 2021-01-01
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	sel := spec.Sources[0].Selections[0]
+	at := func(s string) *time.Time {
+		tm, _ := time.Parse("2006-01-02", s)
+		return &tm
+	}
+	tests := []struct {
+		net, sta, loc, cha string
+		start, end         *time.Time
+		want               bool
+	}{
+		{"IU", "ANMO", "", "BHZ", nil, nil, true},
+		{"iu", "anmo", "10", "bhz", nil, nil, true},
+		{"IU", "ANMO", "00", "BHZ", nil, nil, false},
+		{"IU", "COLA", "", "BHZ", nil, nil, false},
+		{"IU", "ANMO", "", "LHZ", nil, nil, false},
+		{"IU", "ANMO", "", "BHZ", at("2010-01-01"), at("2019-01-01"), false},
+		{"IU", "ANMO", "", "BHZ", at("2010-01-01"), at("2020-06-01"), true},
+		{"IU", "ANMO", "", "BHZ", at("2022-01-01"), nil, false},
+	}
+	for _, tt := range tests {
+		if got := sel.Matches(tt.net, tt.sta, tt.loc, tt.cha, tt.start, tt.end); got != tt.want {
+			t.Errorf("Matches(%s.%s.%s.%s, %v, %v) = %t, want %t", tt.net, tt.sta, tt.loc, tt.cha, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// Source actions in a Report.
+const (
+	SourceCreated   = "created"
+	SourceUpdated   = "updated"
+	SourceUnchanged = "unchanged"
+	// SourceManaged is reported for a source the spec would change but that
+	// is managed by the config file, which takes precedence.
+	SourceManaged = "managed by config"
+)
+
+// Selection actions in a Report: a selection is added if no stored channel
+// of its source matched it before the sync, and refreshed otherwise.
+// Selections of disabled sources are skipped, keeping their channels.
+const (
+	SelectionAdded     = "added"
+	SelectionRefreshed = "refreshed"
+	SelectionSkipped   = "skipped"
+)
+
+// Options controls a sync.
+type Options struct {
+	// Prune removes stored channels not covered by any selection, with the
+	// stations and networks left empty. Sources are never removed, but the
+	// catalog of a source missing from the spec is pruned entirely.
+	Prune bool
+	// DryRun reports what a sync would do without changing anything or
+	// contacting the sources. Channels that a selection would newly import
+	// are not known, so only existing channels are counted.
+	DryRun bool
+}
+
+// SourceChange is what a sync did to a source row.
+type SourceChange struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// SelectionResult is the outcome of importing one selection.
+type SelectionResult struct {
+	Source    string           `json:"source"`
+	Selection string           `json:"selection"`
+	Action    string           `json:"action"`
+	Result    *importer.Result `json:"result,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Report summarises a sync.
+type Report struct {
+	Sources    []SourceChange     `json:"sources"`
+	Selections []SelectionResult  `json:"selections"`
+	Pruned     models.PruneResult `json:"pruned"`
+}
+
+// Failed returns the number of selections that failed to import.
+func (r *Report) Failed() int {
+	var n int
+	for _, s := range r.Selections {
+		if s.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Syncer reconciles the source and station stores with a spec.
+type Syncer struct {
+	sources  store.SourceStore
+	stations store.StationStore
+	importer *importer.Importer
+}
+
+// New returns a Syncer importing through imp.
+func New(sources store.SourceStore, stations store.StationStore, imp *importer.Importer) *Syncer {
+	return &Syncer{sources: sources, stations: stations, importer: imp}
+}
+
+// Sync makes the database match spec: sources are created or updated by
+// name (case-insensitively), every selection is imported and, with
+// opts.Prune, channels outside the spec are removed. A selection that fails
+// to import is recorded in the report and does not stop the sync; an error
+// is only returned if the database cannot be read or written.
+func (s *Syncer) Sync(spec *Spec, opts Options) (*Report, error) {
+	existing, err := s.sources.List()
+	if err != nil {
+		return nil, fmt.Errorf("list sources: %w", err)
+	}
+	byName := make(map[string]models.Source, len(existing))
+	for _, src := range existing {
+		byName[strings.ToLower(src.Name)] = src
+	}
+	epochs, err := s.stations.ListChannelEpochs()
+	if err != nil {
+		return nil, fmt.Errorf("list channels: %w", err)
+	}
+
+	report := &Report{}
+	specIDs := make(map[int64]*SourceSpec, len(spec.Sources))
+	for i := range spec.Sources {
+		ss := &spec.Sources[i]
+		src, action, err := s.applySource(ss, byName, opts.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		report.Sources = append(report.Sources, SourceChange{Name: ss.Name, Action: action})
+		if src.ID != 0 {
+			specIDs[src.ID] = ss
+		}
+
+		for _, sel := range ss.Selections {
+			res := SelectionResult{Source: ss.Name, Selection: sel.String(), Action: SelectionAdded}
+			for _, ep := range epochs {
+				if ep.SourceID == src.ID && sel.Matches(ep.Network, ep.Station, ep.Location, ep.Channel, ep.StartTime, ep.EndTime) {
+					res.Action = SelectionRefreshed
+					break
+				}
+			}
+			if !src.Enabled {
+				res.Action = SelectionSkipped
+			} else if !opts.DryRun {
+				req := importer.Request{
+					SourceID: src.ID,
+					Network:  sel.Network,
+					Station:  sel.Station,
+					Location: sel.Location,
+					Channel:  sel.Channel,
+				}
+				req.StartTime, req.EndTime = sel.window()
+				imported, err := s.importer.Import(req)
+				if err != nil {
+					log.Warn().Err(err).Str("source", ss.Name).Str("selection", res.Selection).Msg("catalog sync import failed")
+					res.Error = err.Error()
+				}
+				res.Result = imported
+			}
+			report.Selections = append(report.Selections, res)
+		}
+	}
+
+	if opts.Prune {
+		if !opts.DryRun {
+			// Include the channels the imports just added
+			if epochs, err = s.stations.ListChannelEpochs(); err != nil {
+				return nil, fmt.Errorf("list channels: %w", err)
+			}
+		}
+		stale := staleChannels(epochs, specIDs)
+		if opts.DryRun {
+			report.Pruned = countPrune(epochs, stale)
+		} else if len(stale) > 0 {
+			ids := make([]int64, 0, len(stale))
+			for id := range stale {
+				ids = append(ids, id)
+			}
+			pruned, err := s.stations.DeleteChannels(ids)
+			if err != nil {
+				return nil, fmt.Errorf("prune channels: %w", err)
+			}
+			report.Pruned = *pruned
+		}
+	}
+	return report, nil
+}
+
+// applySource creates or updates the source row for ss, leaving sources
+// managed by the config file as they are. In a dry run a missing source is
+// returned with a zero ID.
+func (s *Syncer) applySource(ss *SourceSpec, byName map[string]models.Source, dryRun bool) (models.Source, string, error) {
+	enabled := ss.Enabled == nil || *ss.Enabled
+	src, ok := byName[strings.ToLower(ss.Name)]
+	if !ok {
+		src = models.Source{Name: ss.Name, BaseURL: ss.BaseURL, Description: ss.Description, Enabled: enabled}
+		if !dryRun {
+			if err := s.sources.Create(&src); err != nil {
+				return src, "", fmt.Errorf("create source %q: %w", ss.Name, err)
+			}
+			log.Info().Str("name", ss.Name).Str("url", ss.BaseURL).Msg("created source from catalog spec")
+		}
+		return src, SourceCreated, nil
+	}
+	if src.BaseURL == ss.BaseURL && src.Description == ss.Description && src.Enabled == enabled {
+		return src, SourceUnchanged, nil
+	}
+	if src.ManagedBy == models.ManagedByConfig {
+		log.Warn().Str("name", src.Name).Msg("catalog spec differs from the sources config, which manages this source")
+		return src, SourceManaged, nil
+	}
+	src.BaseURL, src.Description, src.Enabled = ss.BaseURL, ss.Description, enabled
+	if !dryRun {
+		if err := s.sources.Update(&src); err != nil {
+			return src, "", fmt.Errorf("update source %q: %w", ss.Name, err)
+		}
+		log.Info().Str("name", src.Name).Str("url", src.BaseURL).Msg("updated source from catalog spec")
+	}
+	return src, SourceUpdated, nil
+}
+
+// staleChannels returns the IDs of the channels not matched by a selection
+// of their source.
+func staleChannels(epochs []models.ChannelEpoch, specs map[int64]*SourceSpec) map[int64]bool {
+	stale := make(map[int64]bool)
+	for _, ep := range epochs {
+		keep := false
+		if ss := specs[ep.SourceID]; ss != nil {
+			for _, sel := range ss.Selections {
+				if sel.Matches(ep.Network, ep.Station, ep.Location, ep.Channel, ep.StartTime, ep.EndTime) {
+					keep = true
+					break
+				}
+			}
+		}
+		if !keep {
+			stale[ep.ID] = true
+		}
+	}
+	return stale
+}
+
+// countPrune counts what removing the stale channels would remove, as
+// DeleteChannels would report it.
+func countPrune(epochs []models.ChannelEpoch, stale map[int64]bool) models.PruneResult {
+	keptStations := make(map[int64]bool)
+	keptNetworks := make(map[int64]bool)
+	for _, ep := range epochs {
+		if !stale[ep.ID] {
+			keptStations[ep.StationID] = true
+			keptNetworks[ep.NetworkID] = true
+		}
+	}
+	res := models.PruneResult{Channels: len(stale)}
+	stations := make(map[int64]bool)
+	networks := make(map[int64]bool)
+	for _, ep := range epochs {
+		if !stale[ep.ID] {
+			continue
+		}
+		if !keptStations[ep.StationID] && !stations[ep.StationID] {
+			stations[ep.StationID] = true
+			res.Stations++
+		}
+		if !keptNetworks[ep.NetworkID] && !networks[ep.NetworkID] {
+			networks[ep.NetworkID] = true
+			res.Networks++
+		}
+	}
+	return res
+}
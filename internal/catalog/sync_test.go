@@ -0,0 +1,213 @@
+package catalog
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/importer"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+const channelHeader = "#Network|Station|Location|Channel|Latitude|Longitude|Elevation|Depth|Azimuth|Dip|SensorDescription|Scale|ScaleFreq|ScaleUnits|SampleRate|StartTime|EndTime\n"
+
+var upstreamChannels = map[string]string{
+	"IU": "IU|ANMO|00|BHZ|34.9|-106.4|1850|145|0|-90|STS-1|3.3e9|0.02|M/S|40|2002-11-19T21:07:00|\n" +
+		"IU|ANMO|00|BH1|34.9|-106.4|1850|145|0|0|STS-1|3.3e9|0.02|M/S|40|2002-11-19T21:07:00|\n",
+	"II": "II|PFO|00|BHZ|33.6|-116.5|1280|5|0|-90|STS-1|3.3e9|0.02|M/S|40|2006-07-13T00:00:00|\n",
+}
+
+// upstream serves channel text for the networks in upstreamChannels and
+// nothing else; requests are recorded so tests can check the parameters.
+func upstream(t *testing.T, requests *[]string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/fdsnws/station/1/query" || q.Get("format") != "text" {
+			http.NotFound(w, r)
+			return
+		}
+		*requests = append(*requests, r.URL.RawQuery)
+		rows, ok := upstreamChannels[q.Get("net")]
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, channelHeader+rows)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func setup(t *testing.T) (*Syncer, *database.DB) {
+	t.Helper()
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sources, stations := store.NewSourceStore(db), store.NewStationStore(db)
+	imp := importer.New(sources, stations, nil, store.NewCredentialStore(db, nil), nil)
+	return New(sources, stations, imp), db
+}
+
+func mustParse(t *testing.T, yaml string) *Spec {
+	t.Helper()
+	spec, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return spec
+}
+
+func count(t *testing.T, db *database.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM "+table); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSync(t *testing.T) {
+	syncer, db := setup(t)
+	var requests []string
+	url := upstream(t, &requests)
+
+	spec := mustParse(t, fmt.Sprintf(`
+sources:
+  - name: Upstream
+    base_url: %s
+    selections:
+      - net: IU
+        starttime: 2020-01-01
+      - net: II
+`, url))
+	report, err := syncer.Sync(spec, Options{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if report.Sources[0].Action != SourceCreated || report.Failed() != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	for _, sel := range report.Selections {
+		if sel.Action != SelectionAdded {
+			t.Errorf("%s action = %s, want added", sel.Selection, sel.Action)
+		}
+	}
+	if n := count(t, db, "channels"); n != 3 {
+		t.Errorf("channels = %d, want 3", n)
+	}
+	if !strings.Contains(requests[0], "starttime=2020-01-01T00%3A00%3A00") {
+		t.Errorf("time window not sent upstream: %s", requests[0])
+	}
+
+	// A second sync refreshes, and picks up a changed description
+	spec.Sources[0].Description = "changed"
+	if report, err = syncer.Sync(spec, Options{}); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if report.Sources[0].Action != SourceUpdated {
+		t.Errorf("source action = %s, want updated", report.Sources[0].Action)
+	}
+	for _, sel := range report.Selections {
+		if sel.Action != SelectionRefreshed || sel.Result.Imported == 0 {
+			t.Errorf("%s = %s, %+v; want refreshed", sel.Selection, sel.Action, sel.Result)
+		}
+	}
+	if n := count(t, db, "channels"); n != 3 {
+		t.Errorf("channels after refresh = %d, want 3", n)
+	}
+}
+
+func TestSyncPrune(t *testing.T) {
+	syncer, db := setup(t)
+	var requests []string
+	url := upstream(t, &requests)
+
+	full := mustParse(t, fmt.Sprintf("sources:\n  - name: Upstream\n    base_url: %s\n    selections:\n      - net: IU\n      - net: II\n", url))
+	if _, err := syncer.Sync(full, Options{}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	// A source outside the spec keeps its row but loses its catalog
+	other := &models.Source{Name: "Other", BaseURL: "http://other", Enabled: true}
+	if err := store.NewSourceStore(db).Create(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.NewStationStore(db).ImportStations(other.ID, []models.ImportChannel{
+		{NetworkCode: "XX", StationCode: "TMP1", ChannelCode: "HHZ"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop II and narrow IU to BHZ
+	narrow := mustParse(t, fmt.Sprintf("sources:\n  - name: Upstream\n    base_url: %s\n    selections:\n      - net: IU\n        cha: BHZ\n", url))
+
+	requests = nil
+	report, err := syncer.Sync(narrow, Options{Prune: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := models.PruneResult{Channels: 3, Stations: 2, Networks: 2}
+	if report.Pruned != want || len(requests) != 0 {
+		t.Errorf("dry run pruned %+v with %d requests, want %+v and none", report.Pruned, len(requests), want)
+	}
+	if n := count(t, db, "channels"); n != 4 {
+		t.Errorf("dry run changed channels: %d, want 4", n)
+	}
+
+	if report, err = syncer.Sync(narrow, Options{Prune: true}); err != nil {
+		t.Fatalf("Sync prune: %v", err)
+	}
+	if report.Pruned != want {
+		t.Errorf("pruned %+v, want %+v", report.Pruned, want)
+	}
+	var codes []string
+	if err := db.Select(&codes, "SELECT code FROM channels ORDER BY code"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(codes, ",") != "BHZ" {
+		t.Errorf("channels left = %v, want [BHZ]", codes)
+	}
+	if n := count(t, db, "networks"); n != 1 {
+		t.Errorf("networks = %d, want 1", n)
+	}
+	if n := count(t, db, "sources"); n != 2 {
+		t.Errorf("sources = %d, want 2", n)
+	}
+}
+
+func TestSyncDisabledSource(t *testing.T) {
+	syncer, db := setup(t)
+	var requests []string
+	url := upstream(t, &requests)
+
+	spec := mustParse(t, fmt.Sprintf("sources:\n  - name: Upstream\n    base_url: %s\n    selections:\n      - net: IU\n", url))
+	if _, err := syncer.Sync(spec, Options{}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// Disabling the source skips its selections but keeps, and does not
+	// prune, its channels
+	disabled := false
+	spec.Sources[0].Enabled = &disabled
+	requests = nil
+	report, err := syncer.Sync(spec, Options{Prune: true})
+	if err != nil {
+		t.Fatalf("Sync disabled: %v", err)
+	}
+	if sel := report.Selections[0]; sel.Action != SelectionSkipped || sel.Error != "" || len(requests) != 0 {
+		t.Errorf("selection = %+v with %d requests, want skipped and none", sel, len(requests))
+	}
+	if n := count(t, db, "channels"); n != 2 {
+		t.Errorf("channels = %d, want 2", n)
+	}
+}
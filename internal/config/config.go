@@ -34,6 +34,16 @@ func DefaultConfigFile() (string, error) {
 	return filepath.Join(dir, "config.yaml"), nil
 }
 
+// DefaultSecretKeyPath returns the default credential encryption key path
+// inside ConfigDir.
+func DefaultSecretKeyPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secret.key"), nil
+}
+
 // EnsureDir creates a directory (and parents) with mode 0o700 if it doesn't exist.
 func EnsureDir(dir string) error {
 	return os.MkdirAll(dir, 0o700)
@@ -49,6 +59,10 @@ func SetDefaults() {
 	// Server
 	viper.SetDefault("server.port", 8080)
 
+	// Database backend: "sqlite" uses db.path, "postgres" connects to db.dsn
+	viper.SetDefault("db.driver", "sqlite")
+	viper.SetDefault("db.dsn", "")
+
 	// Database — prefer ~/.config/fdsn/fdsn.db, fall back to ./fdsn.db
 	dbPath, err := DefaultDBPath()
 	if err != nil {
@@ -56,13 +70,61 @@ func SetDefaults() {
 	}
 	viper.SetDefault("db.path", dbPath)
 
+	// Encryption key for stored upstream source credentials
+	keyPath, err := DefaultSecretKeyPath()
+	if err != nil {
+		keyPath = "./secret.key"
+	}
+	viper.SetDefault("secrets.key_file", keyPath)
+
+	// Scheduled SQLite backups; 0 disables them. An empty dir means a
+	// "backups" directory next to db.path.
+	viper.SetDefault("backup.interval", "0")
+	viper.SetDefault("backup.dir", "")
+	viper.SetDefault("backup.keep", 7)
+
+	// Source health probes; 0 disables the schedule
+	viper.SetDefault("health.interval", "1h")
+
+	// Services used to find the data centre that serves a network; an empty
+	// URL disables that lookup
+	viper.SetDefault("discovery.eida_routing_url", "https://www.orfeus-eu.org/eidaws/routing/1")
+	viper.SetDefault("discovery.fedcatalog_url", "https://service.iris.edu/irisws/fedcatalog/1")
+
+	// Catalog spec file that fdsn serve syncs the catalog with at startup;
+	// empty disables it. prune also removes channels the spec does not cover.
+	viper.SetDefault("catalog.spec", "")
+	viper.SetDefault("catalog.prune", false)
+
+	// StationXML schema version written by the station service when a
+	// request has no schemaversion parameter: "1.1" or "1.2"
+	viper.SetDefault("stationxml.schema_version", "1.1")
+
+	// On-disk cache of miniSEED fetched for dataselect and the waveform
+	// proxy; a max_mb of 0 disables it. An empty dir means a "waveforms"
+	// directory next to db.path. Data younger than min_age is not cached.
+	viper.SetDefault("waveform_cache.max_mb", 1024)
+	viper.SetDefault("waveform_cache.dir", "")
+	viper.SetDefault("waveform_cache.min_age", "1h")
+
+	// Zip bundles of event waveform extractions. An empty dir means an
+	// "extractions" directory next to db.path.
+	viper.SetDefault("extract.dir", "")
+
+	// Prometheus metrics at /metrics
+	viper.SetDefault("metrics.enabled", true)
+
 	// Logging
 	viper.SetDefault("log.level", "info")
 
 	// Server browser behavior
 	viper.SetDefault("server.no_browser", false)
 
-	// Preset FDSN sources
+	// Sources removed from the sources key are handed back to the UI; with
+	// disable_removed they are also disabled
+	viper.SetDefault("source_config.disable_removed", false)
+
+	// Preset FDSN sources, managed by config
 	viper.SetDefault("sources", []map[string]string{
 		{
 			"name":        "Earthscope",
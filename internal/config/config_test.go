@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -93,6 +94,33 @@ func TestSetDefaults(t *testing.T) {
 		t.Errorf("SetDefaults() db.path = %q, want %q", dbPath, expectedDB)
 	}
 
+	expectedKey, err := DefaultSecretKeyPath()
+	if err != nil {
+		expectedKey = "./secret.key"
+	}
+	if keyFile := viper.GetString("secrets.key_file"); keyFile != expectedKey {
+		t.Errorf("SetDefaults() secrets.key_file = %q, want %q", keyFile, expectedKey)
+	}
+
+	if interval := viper.GetDuration("health.interval"); interval != time.Hour {
+		t.Errorf("SetDefaults() health.interval = %v, want 1h", interval)
+	}
+
+	if u := viper.GetString("discovery.fedcatalog_url"); u != "https://service.iris.edu/irisws/fedcatalog/1" {
+		t.Errorf("SetDefaults() discovery.fedcatalog_url = %q", u)
+	}
+
+	if driver := viper.GetString("db.driver"); driver != "sqlite" {
+		t.Errorf("SetDefaults() db.driver = %q, want sqlite", driver)
+	}
+
+	if interval := viper.GetDuration("backup.interval"); interval != 0 {
+		t.Errorf("SetDefaults() backup.interval = %v, want 0 (disabled)", interval)
+	}
+	if keep := viper.GetInt("backup.keep"); keep != 7 {
+		t.Errorf("SetDefaults() backup.keep = %d, want 7", keep)
+	}
+
 	if port := viper.GetInt("server.port"); port != 8080 {
 		t.Errorf("SetDefaults() server.port = %d, want 8080", port)
 	}
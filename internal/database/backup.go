@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"modernc.org/sqlite"
+)
+
+// ErrSQLiteOnly is returned by maintenance operations that only apply to
+// SQLite; PostgreSQL databases are backed up and checked with its own tools.
+var ErrSQLiteOnly = errors.New("only supported for SQLite databases (use pg_dump/pg_restore for PostgreSQL)")
+
+// Backup writes a consistent copy of the database to path with SQLite's
+// online backup API. The copy is taken from a read-only connection in one
+// read transaction, so it runs alongside the server without blocking writes.
+// path must not exist; the copy is written to a temporary file first so a
+// failed backup never leaves a partial file.
+func Backup(db *DB, path string) error {
+	if db.DriverName() != DriverSQLite {
+		return ErrSQLiteOnly
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	err := withSQLiteConn(db.Reader, func(c sqliteConn) error {
+		b, err := c.NewBackup("file:" + tmp)
+		if err != nil {
+			return err
+		}
+		return copyAll(b)
+	})
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the contents of the database with the backup at path,
+// using the online backup API on the writer connection. The backup is
+// integrity-checked first. Run Migrate afterwards: the backup may predate
+// the current schema.
+func Restore(db *DB, path string) error {
+	if db.DriverName() != DriverSQLite {
+		return ErrSQLiteOnly
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	src, err := sqlx.Open(DriverSQLite, fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	problems, err := integrityCheck(src)
+	_ = src.Close()
+	if err != nil {
+		return fmt.Errorf("check backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup %s is corrupt: %s", path, problems[0])
+	}
+
+	err = withSQLiteConn(db.DB, func(c sqliteConn) error {
+		b, err := c.NewRestore(fmt.Sprintf("file:%s?mode=ro", path))
+		if err != nil {
+			return err
+		}
+		return copyAll(b)
+	})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// sqliteConn is the part of the modernc.org/sqlite connection that exposes
+// the online backup API.
+type sqliteConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// withSQLiteConn runs fn on a driver connection taken from db.
+func withSQLiteConn(db *sqlx.DB, fn func(sqliteConn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(dc any) error {
+		c, ok := dc.(sqliteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", dc)
+		}
+		return fn(c)
+	})
+}
+
+// copyAll copies every page in one step and releases b.
+func copyAll(b *sqlite.Backup) error {
+	if _, err := b.Step(-1); err != nil {
+		_ = b.Finish()
+		return err
+	}
+	return b.Finish()
+}
+
+// Vacuum rebuilds the database to reclaim free space and refreshes the
+// planner statistics.
+func Vacuum(db *DB) error {
+	if db.DriverName() == DriverPostgres {
+		_, err := db.Exec("VACUUM ANALYZE")
+		return err
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return err
+	}
+	_, err := db.Exec("ANALYZE")
+	return err
+}
+
+// IntegrityCheck runs SQLite's integrity and foreign key checks and returns
+// the problems found, or none if the database is healthy.
+func IntegrityCheck(db *DB) ([]string, error) {
+	if db.DriverName() != DriverSQLite {
+		return nil, ErrSQLiteOnly
+	}
+	return integrityCheck(db.Reader)
+}
+
+func integrityCheck(db *sqlx.DB) ([]string, error) {
+	var results []string
+	if err := db.Select(&results, "PRAGMA integrity_check"); err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, r := range results {
+		if r != "ok" {
+			problems = append(problems, r)
+		}
+	}
+
+	var fks []struct {
+		Table  string `db:"table"`
+		RowID  *int64 `db:"rowid"`
+		Parent string `db:"parent"`
+		FKID   int    `db:"fkid"`
+	}
+	if err := db.Select(&fks, "PRAGMA foreign_key_check"); err != nil {
+		return nil, err
+	}
+	for _, fk := range fks {
+		row := "?"
+		if fk.RowID != nil {
+			row = fmt.Sprint(*fk.RowID)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %s references a missing %s row", fk.Table, row, fk.Parent))
+	}
+	return problems, nil
+}
+
+// Scheduled backups are named after the time they were taken in UTC, e.g.
+// fdsn-20260101T030000Z.db.
+const (
+	backupPrefix = "fdsn-"
+	backupSuffix = ".db"
+	backupLayout = "20060102T150405Z"
+)
+
+// Backups writes timestamped backups to Dir, keeping the newest Keep of them
+// (all of them if Keep is 0).
+type Backups struct {
+	DB   *DB
+	Dir  string
+	Keep int
+}
+
+// Create writes a new backup and prunes old ones. It returns the backup path.
+func (b *Backups) Create() (string, error) {
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return "", err
+	}
+	name := backupPrefix + time.Now().UTC().Format(backupLayout) + backupSuffix
+	path := filepath.Join(b.Dir, name)
+	if err := Backup(b.DB, path); err != nil {
+		return "", err
+	}
+	return path, b.prune()
+}
+
+// List returns the paths of the backups in Dir, oldest first.
+func (b *Backups) List() ([]string, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, backupPrefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, backupSuffix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(backupLayout, stamp); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(b.Dir, name))
+	}
+	sort.Strings(paths) // the timestamp layout sorts chronologically
+	return paths, nil
+}
+
+// prune removes all but the newest Keep backups.
+func (b *Backups) prune() error {
+	if b.Keep <= 0 {
+		return nil
+	}
+	paths, err := b.List()
+	if err != nil {
+		return err
+	}
+	for len(paths) > b.Keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		log.Info().Str("path", paths[0]).Msg("removed old backup")
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// Run creates a backup every interval until ctx is cancelled. Failures are
+// logged and retried at the next tick.
+func (b *Backups) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := b.Create()
+			if err != nil {
+				log.Error().Err(err).Str("dir", b.Dir).Msg("scheduled backup failed")
+				continue
+			}
+			log.Info().Str("path", path).Msg("database backed up")
+		}
+	}
+}
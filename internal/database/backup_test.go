@@ -0,0 +1,117 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
+
+func countSources(t *testing.T, db *DB) int {
+	t.Helper()
+	var n int
+	if err := db.Reader.Get(&n, "SELECT COUNT(*) FROM sources"); err != nil {
+		t.Fatalf("count sources: %v", err)
+	}
+	return n
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	tmp := t.TempDir()
+	db := newTestDB(t, filepath.Join(tmp, "fdsn.db"))
+	if _, err := db.Exec("INSERT INTO sources (name, base_url) VALUES ('a', 'http://a')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	backup := filepath.Join(tmp, "backup.db")
+	if err := Backup(db, backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := Backup(db, backup); err == nil {
+		t.Error("expected an error when the backup file exists")
+	}
+
+	if _, err := db.Exec("DELETE FROM sources"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := Restore(db, backup); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if n := countSources(t, db); n != 1 {
+		t.Errorf("sources after restore = %d, want 1", n)
+	}
+	if problems, err := IntegrityCheck(db); err != nil || len(problems) > 0 {
+		t.Errorf("IntegrityCheck = %v, %v", problems, err)
+	}
+	if err := Vacuum(db); err != nil {
+		t.Errorf("Vacuum: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmp, "junk.db"), []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(db, filepath.Join(tmp, "junk.db")); err == nil {
+		t.Error("expected an error restoring a corrupt backup")
+	}
+	if n := countSources(t, db); n != 1 {
+		t.Errorf("sources after failed restore = %d, want 1", n)
+	}
+}
+
+func TestIntegrityCheckForeignKeys(t *testing.T) {
+	db := newTestDB(t, t.TempDir()+"/fdsn.db")
+	if _, err := db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO networks (source_id, code) VALUES (42, 'XX')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	problems, err := IntegrityCheck(db)
+	if err != nil || len(problems) != 1 {
+		t.Errorf("IntegrityCheck = %v, %v; want one orphaned network", problems, err)
+	}
+}
+
+func TestBackupsRetention(t *testing.T) {
+	tmp := t.TempDir()
+	db := newTestDB(t, filepath.Join(tmp, "fdsn.db"))
+	dir := filepath.Join(tmp, "backups")
+	b := &Backups{DB: db, Dir: dir, Keep: 2}
+
+	// Older backups, plus a file that is not one and must be left alone
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"fdsn-20200101T000000Z.db", "fdsn-20210101T000000Z.db", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := b.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	paths, err := b.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != filepath.Join(dir, "fdsn-20210101T000000Z.db") || paths[1] != path {
+		t.Errorf("backups = %v, want the 2021 backup and %s", paths, path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
@@ -1,54 +1,81 @@
 package database
 
 import (
+	"errors"
 	"fmt"
-	"io/fs"
-	"sort"
-	"strings"
+	"runtime"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/rs/zerolog/log"
 	_ "modernc.org/sqlite"
 )
 
-// New opens (or creates) the SQLite database at dbPath and returns a sqlx.DB.
-func New(dbPath string) (*sqlx.DB, error) {
-	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_foreign_keys=on&_busy_timeout=5000", dbPath)
-	db, err := sqlx.Open("sqlite", dsn)
+// Supported values of the db.driver setting, also used as the sqlx driver
+// name of the connections returned by New and NewPostgres.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DB is an open database. The embedded sqlx.DB is the writer and must be used
+// for anything that modifies data; Reader serves read-only queries. For SQLite
+// the writer is a single connection and Reader a separate pool of read-only
+// connections, which WAL mode lets run while a write transaction is open. For
+// PostgreSQL both are the same pool.
+type DB struct {
+	*sqlx.DB
+	Reader *sqlx.DB
+}
+
+// Close closes the writer and, if separate, the reader pool.
+func (db *DB) Close() error {
+	err := db.DB.Close()
+	if db.Reader != db.DB {
+		err = errors.Join(err, db.Reader.Close())
+	}
+	return err
+}
+
+// Open connects to the database for driver: a file path for SQLite or a
+// connection string for PostgreSQL.
+func Open(driver, dsn string) (*DB, error) {
+	switch driver {
+	case DriverSQLite, "":
+		return New(dsn)
+	case DriverPostgres:
+		return NewPostgres(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q (want %s or %s)", driver, DriverSQLite, DriverPostgres)
+	}
+}
+
+// sqlitePragmas are applied to every SQLite connection.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+// New opens (or creates) the SQLite database at dbPath in WAL mode, with a
+// single writer connection and a pool of read-only connections.
+func New(dbPath string) (*DB, error) {
+	w, err := sqlx.Open(DriverSQLite, fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&%s", dbPath, sqlitePragmas))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
-	db.SetMaxOpenConns(1) // SQLite serialises writes; one conn avoids lock contention
-	if err := db.Ping(); err != nil {
+	w.SetMaxOpenConns(1) // SQLite serialises writes; one conn avoids lock contention
+	if err := w.Ping(); err != nil {
+		_ = w.Close()
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
-	return db, nil
-}
 
-// Migrate runs all embedded SQL migration files in lexicographic order.
-func Migrate(db *sqlx.DB) error {
-	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	// The writer has created the file and switched it to WAL, so readers can
+	// open it read-only.
+	r, err := sqlx.Open(DriverSQLite, fmt.Sprintf("file:%s?mode=ro&_pragma=query_only(1)&%s", dbPath, sqlitePragmas))
 	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+		_ = w.Close()
+		return nil, fmt.Errorf("open database reader: %w", err)
 	}
-
-	// Sort by name to ensure deterministic ordering
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
-			continue
-		}
-		data, err := fs.ReadFile(migrationsFS, "migrations/"+e.Name())
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", e.Name(), err)
-		}
-		log.Info().Str("file", e.Name()).Msg("running migration")
-		if _, err := db.Exec(string(data)); err != nil {
-			return fmt.Errorf("exec migration %s: %w", e.Name(), err)
-		}
+	r.SetMaxOpenConns(max(4, runtime.NumCPU()))
+	if err := r.Ping(); err != nil {
+		_ = w.Close()
+		_ = r.Close()
+		return nil, fmt.Errorf("ping database reader: %w", err)
 	}
-	return nil
+	return &DB{DB: w, Reader: r}, nil
 }
@@ -33,3 +33,189 @@ func TestNewAndMigrate(t *testing.T) {
 		}
 	}
 }
+
+func TestNewReaderWriterSplit(t *testing.T) {
+	db, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var mode string
+	var fk, timeout int
+	if err := db.Get(&mode, "PRAGMA journal_mode"); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v; want wal", mode, err)
+	}
+	if err := db.Reader.Get(&fk, "PRAGMA foreign_keys"); err != nil || fk != 1 {
+		t.Errorf("reader foreign_keys = %d, %v; want 1", fk, err)
+	}
+	if err := db.Reader.Get(&timeout, "PRAGMA busy_timeout"); err != nil || timeout != 5000 {
+		t.Errorf("reader busy_timeout = %d, %v; want 5000", timeout, err)
+	}
+
+	if _, err := db.Reader.Exec("INSERT INTO users (username, ha1) VALUES ('a', 'b')"); err == nil {
+		t.Error("expected the reader to reject writes")
+	}
+
+	// Reads proceed while a write transaction is open and see only
+	// committed data.
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("Beginx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec("INSERT INTO sources (name, base_url) VALUES ('s', 'http://s')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	var n int
+	if err := db.Reader.Get(&n, "SELECT COUNT(*) FROM sources"); err != nil || n != 0 {
+		t.Errorf("read during write = %d, %v; want 0, nil", n, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := db.Reader.Get(&n, "SELECT COUNT(*) FROM sources"); err != nil || n != 1 {
+		t.Errorf("read after commit = %d, %v; want 1, nil", n, err)
+	}
+}
+
+func TestMigrateTracksVersions(t *testing.T) {
+	db, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	// A second run applies nothing; before tracking, 003 ran on every start
+	if done, err := MigrateUp(db, 0); err != nil || len(done) != 0 {
+		t.Fatalf("second MigrateUp = %d migrations, %v; want none", len(done), err)
+	}
+
+	status, err := Status(db)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not applied", s.Version, s.Name)
+		}
+	}
+
+	last := status[len(status)-1]
+	done, err := MigrateDown(db, 1)
+	if err != nil || len(done) != 1 || done[0].Version != last.Version {
+		t.Fatalf("MigrateDown = %+v, %v", done, err)
+	}
+	status, _ = Status(db)
+	if status[len(status)-1].AppliedAt != nil {
+		t.Error("expected latest migration to be pending after MigrateDown")
+	}
+	if done, err := MigrateUp(db, 0); err != nil || len(done) != 1 {
+		t.Fatalf("re-apply = %d migrations, %v; want 1", len(done), err)
+	}
+}
+
+func TestMigrateDownAll(t *testing.T) {
+	db, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := MigrateUp(db, 2); err != nil {
+		t.Fatalf("MigrateUp(2): %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	migrations, _ := Migrations(DriverSQLite)
+	if _, err := MigrateDown(db, len(migrations)); err != nil {
+		t.Fatalf("MigrateDown all: %v", err)
+	}
+	var tables int
+	db.Get(&tables, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')")
+	if tables != 0 {
+		t.Errorf("expected no tables after reverting everything, have %d", tables)
+	}
+}
+
+func TestMigrateBaselinesLegacyDatabase(t *testing.T) {
+	db, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	// Simulate the untracked runner: every up file executed, nothing recorded
+	migrations, err := Migrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	for _, m := range migrations[:legacyBaseline] {
+		if _, err := db.Exec(m.Up); err != nil {
+			t.Fatalf("legacy exec %d: %v", m.Version, err)
+		}
+	}
+	db.Exec("INSERT INTO sources (name, base_url, description) VALUES ('kept', 'http://x', '')")
+
+	done, err := MigrateUp(db, 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(done) != len(migrations)-legacyBaseline || done[0].Version != legacyBaseline+1 {
+		t.Errorf("applied %+v, want versions after the baseline", done)
+	}
+	var n int
+	db.Get(&n, "SELECT COUNT(*) FROM sources WHERE name = 'kept'")
+	if n != 1 {
+		t.Error("existing data lost while baselining")
+	}
+}
+
+func TestMigrateKeepsCatalogAcrossRebuilds(t *testing.T) {
+	db, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	db.MustExec("INSERT INTO sources (name, base_url, description) VALUES ('src', 'http://x', '')")
+	db.MustExec("INSERT INTO networks (source_id, code) VALUES (1, 'IU')")
+	db.MustExec("INSERT INTO stations (network_id, code, latitude, longitude, elevation) VALUES (1, 'ANMO', 34.9, -106.5, 1850)")
+	db.MustExec("INSERT INTO channels (station_id, location_code, code) VALUES (1, '00', 'BHZ')")
+	channels := func() int {
+		var n int
+		db.Get(&n, "SELECT COUNT(*) FROM channels")
+		return n
+	}
+
+	// Reverting and re-applying 003 rebuilds stations and networks, which
+	// must not cascade to their children
+	migrations, _ := Migrations(DriverSQLite)
+	if _, err := MigrateDown(db, len(migrations)-2); err != nil {
+		t.Fatalf("MigrateDown to 002: %v", err)
+	}
+	if n := channels(); n != 1 {
+		t.Errorf("channels after MigrateDown = %d, want 1", n)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if n := channels(); n != 1 {
+		t.Errorf("channels after Migrate = %d, want 1", n)
+	}
+	var fk int
+	db.Get(&fk, "PRAGMA foreign_keys")
+	if fk != 1 {
+		t.Error("foreign keys left disabled after a rebuild")
+	}
+}
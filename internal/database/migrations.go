@@ -1,6 +1,358 @@
 package database
 
-import "embed"
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-//go:embed migrations/*.sql
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// SQLite migrations live in migrations/, PostgreSQL ones in
+// migrations/postgres/. The two share version numbers; a dialect that needs
+// no change for a version simply has no file for it.
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql
 var migrationsFS embed.FS
+
+// migrationsDir returns the embedded directory holding driver's migrations.
+func migrationsDir(driver string) string {
+	if driver == DriverPostgres {
+		return "migrations/postgres"
+	}
+	return "migrations"
+}
+
+// legacyBaseline is the last migration every database created before
+// migrations were tracked is known to have applied. Such databases are
+// baselined at this version; later migrations use IF NOT EXISTS, so they are
+// safe to apply on top of a schema that already has them. Only SQLite
+// databases predate tracking.
+const legacyBaseline = 3
+
+// Migration is one embedded schema change. Files are named
+// NNN_name.sql, with an optional NNN_name.down.sql that reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// noForeignKeys marks a SQLite migration file that rebuilds tables other
+// tables reference. Dropping such a table with foreign keys enforced would
+// cascade to the rows that reference it, so the file runs with them off.
+const noForeignKeys = "-- migrate: foreign_keys off"
+
+// rebuildsTables reports whether script carries the noForeignKeys marker.
+func rebuildsTables(script string) bool {
+	for line := range strings.Lines(script) {
+		if strings.TrimSpace(line) == noForeignKeys {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations for driver ordered by version.
+func Migrations(driver string) ([]Migration, error) {
+	dir := migrationsDir(driver)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		file := e.Name()
+		if e.IsDir() || !strings.HasSuffix(file, ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(file, ".sql")
+		down := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".down")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", file)
+		}
+		data, err := fs.ReadFile(migrationsFS, dir+"/"+file)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", file, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d used by %q and %q", version, m.Name, name)
+		}
+		if down {
+			m.Down = string(data)
+		} else {
+			m.Up = string(data)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrate applies all pending migrations.
+func Migrate(db *DB) error {
+	_, err := MigrateUp(db, 0)
+	return err
+}
+
+// MigrateUp applies pending migrations up to and including version target
+// (0 means all), each once and in its own transaction. It returns the
+// migrations applied.
+func MigrateUp(db *DB, target int) ([]Migration, error) {
+	migrations, err := Migrations(db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if target > 0 && m.Version > target {
+			break
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("applying migration")
+		if err := runMigration(db, m.Up, func(tx *sqlx.Tx) error {
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC())
+			return err
+		}); err != nil {
+			return done, fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first. It
+// stops with an error at a migration that has no down file.
+func MigrateDown(db *DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations(db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %03d_%s cannot be reverted: no down file", m.Version, m.Name)
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("reverting migration")
+		if err := runMigration(db, m.Down, func(tx *sqlx.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+			return err
+		}); err != nil {
+			return done, fmt.Errorf("revert migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Status lists every embedded migration with the time it was applied, or
+// nil if it is pending.
+func Status(db *DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := appliedVersions(db); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := db.Select(&rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		appliedAt[r.Version] = r.AppliedAt
+	}
+
+	out := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		out[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if t, ok := appliedAt[m.Version]; ok {
+			out[i].AppliedAt = &t
+		}
+	}
+	return out, nil
+}
+
+// runMigration executes script and record in one transaction. A SQLite
+// script marked noForeignKeys runs with foreign keys off, which can only be
+// switched outside a transaction, and must leave no dangling references.
+func runMigration(db *DB, script string, record func(*sqlx.Tx) error) error {
+	if db.DriverName() == DriverPostgres || !rebuildsTables(script) {
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+		if _, err := tx.Exec(script); err != nil {
+			return err
+		}
+		if err := record(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON") }()
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	var violations int
+	if err := tx.Get(&violations, "SELECT COUNT(*) FROM pragma_foreign_key_check"); err != nil {
+		return err
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d foreign key violations", violations)
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appliedVersions creates schema_migrations if needed and returns the
+// versions recorded in it. A database that predates it is baselined at
+// legacyBaseline first.
+func appliedVersions(db *DB) (map[int]bool, error) {
+	tracked, err := tableExists(db, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		if err := createMigrationsTable(db); err != nil {
+			return nil, err
+		}
+	}
+
+	var versions []int
+	if err := db.Select(&versions, "SELECT version FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// createMigrationsTable creates schema_migrations. If the database already
+// has the core tables it was migrated by the untracked runner, and the
+// migrations up to legacyBaseline are recorded without being run again.
+func createMigrationsTable(db *DB) error {
+	legacy := false
+	if db.DriverName() != DriverPostgres {
+		var err error
+		if legacy, err = tableExists(db, "sources"); err != nil {
+			return err
+		}
+	}
+	migrations, err := Migrations(db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`CREATE TABLE schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	if legacy {
+		now := time.Now().UTC()
+		for _, m := range migrations {
+			if m.Version > legacyBaseline {
+				break
+			}
+			if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, now); err != nil {
+				return err
+			}
+		}
+		log.Info().Int("version", legacyBaseline).Msg("baselined existing database")
+	}
+	return tx.Commit()
+}
+
+// tableExists reports whether the named table exists.
+func tableExists(db *DB, name string) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	if db.DriverName() == DriverPostgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	var n int
+	if err := db.Get(&n, query, name); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,2 @@
+-- 002_availability_unique.down.sql: Drop the availability upsert index
+DROP INDEX IF EXISTS idx_availability_channel_earliest;
@@ -0,0 +1,66 @@
+-- 003_dedup_stations.down.sql: Restore start_time in the UNIQUE constraints
+--
+-- Rebuilds networks, stations and channels, so it runs without foreign key
+-- enforcement, which would otherwise cascade the drops to their children.
+-- migrate: foreign_keys off
+--
+-- Removed duplicates are not restored; only the table definitions revert.
+
+CREATE TABLE stations_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network_id INTEGER REFERENCES networks(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    elevation REAL NOT NULL,
+    site_name TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(network_id, code, start_time)
+);
+
+INSERT INTO stations_old SELECT * FROM stations;
+DROP TABLE stations;
+ALTER TABLE stations_old RENAME TO stations;
+
+CREATE TABLE networks_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER REFERENCES sources(id),
+    code TEXT NOT NULL,
+    description TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(source_id, code, start_time)
+);
+
+INSERT INTO networks_old SELECT * FROM networks;
+DROP TABLE networks;
+ALTER TABLE networks_old RENAME TO networks;
+
+CREATE TABLE channels_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    station_id INTEGER REFERENCES stations(id) ON DELETE CASCADE,
+    location_code TEXT DEFAULT '',
+    code TEXT NOT NULL,
+    latitude REAL,
+    longitude REAL,
+    elevation REAL,
+    depth REAL,
+    azimuth REAL,
+    dip REAL,
+    sensor_description TEXT,
+    scale REAL,
+    scale_freq REAL,
+    scale_units TEXT,
+    sample_rate REAL,
+    start_time DATETIME,
+    end_time DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(station_id, location_code, code, start_time)
+);
+
+INSERT INTO channels_old SELECT * FROM channels;
+DROP TABLE channels;
+ALTER TABLE channels_old RENAME TO channels;
@@ -1,5 +1,9 @@
 -- 003_dedup_stations.sql: Deduplicate stations/networks and tighten UNIQUE constraints
 -- Remove start_time from uniqueness since it causes duplicates on re-import
+--
+-- Rebuilds networks, stations and channels, so it runs without foreign key
+-- enforcement, which would otherwise cascade the drops to their children.
+-- migrate: foreign_keys off
 
 -- Step 1: Reassign channels from duplicate stations to the surviving (lowest id) station
 UPDATE channels
@@ -0,0 +1,3 @@
+-- 004_restricted_users.down.sql: Drop restriction markers and queryauth users
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS restrictions;
@@ -0,0 +1,27 @@
+-- 004_restricted_users.sql: Restricted-data markers and FDSN queryauth users
+--
+-- A row in restrictions marks a network, station or channel as restricted.
+-- Restriction is inherited: a channel is restricted if it, its station or its
+-- network has a row. origin records whether the flag came from the upstream
+-- StationXML restrictedStatus ('upstream') or was set by hand ('manual');
+-- re-imports only ever clear 'upstream' markers.
+
+CREATE TABLE IF NOT EXISTS restrictions (
+    level TEXT NOT NULL CHECK (level IN ('network', 'station', 'channel')),
+    ref_id INTEGER NOT NULL,
+    origin TEXT NOT NULL DEFAULT 'manual' CHECK (origin IN ('upstream', 'manual')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (level, ref_id)
+);
+
+-- users holds credentials for /queryauth. ha1 is MD5(username:realm:password);
+-- networks is a comma-separated list of network codes (wildcards allowed)
+-- whose restricted data the user may access.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE,
+    ha1 TEXT NOT NULL,
+    networks TEXT NOT NULL DEFAULT '*',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
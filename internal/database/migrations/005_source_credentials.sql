@@ -0,0 +1,12 @@
+-- 005_source_credentials.sql: Encrypted upstream credentials per source
+--
+-- password and eida_token hold values sealed by internal/secrets with the
+-- local key file; they are never stored in plain text.
+
+CREATE TABLE IF NOT EXISTS source_credentials (
+    source_id INTEGER PRIMARY KEY REFERENCES sources(id) ON DELETE CASCADE,
+    username TEXT NOT NULL DEFAULT '',
+    password TEXT NOT NULL DEFAULT '',
+    eida_token TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- 006_source_health.down.sql: Drop source health and service capabilities
+DROP TABLE IF EXISTS source_health;
+DROP TABLE IF EXISTS source_services;
@@ -0,0 +1,23 @@
+-- 006_source_health.sql: Source health probes and service capabilities
+--
+-- source_services lists the FDSN services found on a source by the last
+-- successful probe; parameters is a comma-separated list from its WADL.
+-- source_health keeps the latest probe outcome for each source.
+
+CREATE TABLE IF NOT EXISTS source_services (
+    source_id INTEGER NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    service TEXT NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    parameters TEXT NOT NULL DEFAULT '',
+    checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_id, service)
+);
+
+CREATE TABLE IF NOT EXISTS source_health (
+    source_id INTEGER PRIMARY KEY REFERENCES sources(id) ON DELETE CASCADE,
+    latency_ms INTEGER,
+    last_checked DATETIME,
+    last_success DATETIME,
+    last_failure DATETIME,
+    last_error TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,11 @@
+-- 008_search.down.sql: Drop the search index and its triggers
+DROP TRIGGER IF EXISTS networks_search_insert;
+DROP TRIGGER IF EXISTS networks_search_update;
+DROP TRIGGER IF EXISTS networks_search_delete;
+DROP TRIGGER IF EXISTS stations_search_insert;
+DROP TRIGGER IF EXISTS stations_search_update;
+DROP TRIGGER IF EXISTS stations_search_delete;
+DROP TRIGGER IF EXISTS channels_search_insert;
+DROP TRIGGER IF EXISTS channels_search_update;
+DROP TRIGGER IF EXISTS channels_search_delete;
+DROP TABLE IF EXISTS search_index;
@@ -0,0 +1,87 @@
+-- 008_search.sql: Full-text search over networks, stations and channels
+--
+-- search_index is an FTS5 table with the trigram tokenizer, so it matches
+-- substrings and tolerates typos (a query shares most of its trigrams with a
+-- misspelt word). Each row is a network, station or channel; the rowid is
+-- the row's id * 4 plus 1, 2 or 3 for its kind so triggers can find it.
+-- Triggers keep it in step with the base tables, including rows removed by
+-- cascading deletes.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+    code,
+    label,
+    kind UNINDEXED,
+    ref_id UNINDEXED,
+    station_id UNINDEXED,
+    network UNINDEXED,
+    station UNINDEXED,
+    location UNINDEXED,
+    tokenize = 'trigram'
+);
+
+CREATE TRIGGER IF NOT EXISTS networks_search_insert AFTER INSERT ON networks BEGIN
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    VALUES (new.id * 4 + 1, new.code, COALESCE(new.description, ''), 'network', new.id, NULL, new.code, '', '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS networks_search_update AFTER UPDATE OF description ON networks
+WHEN old.description IS NOT new.description BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    VALUES (new.id * 4 + 1, new.code, COALESCE(new.description, ''), 'network', new.id, NULL, new.code, '', '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS networks_search_delete AFTER DELETE ON networks BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS stations_search_insert AFTER INSERT ON stations BEGIN
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    SELECT new.id * 4 + 2, new.code, COALESCE(new.site_name, ''), 'station', new.id, new.id, n.code, new.code, ''
+    FROM networks n WHERE n.id = new.network_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS stations_search_update AFTER UPDATE OF site_name ON stations
+WHEN old.site_name IS NOT new.site_name BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    SELECT new.id * 4 + 2, new.code, COALESCE(new.site_name, ''), 'station', new.id, new.id, n.code, new.code, ''
+    FROM networks n WHERE n.id = new.network_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS stations_search_delete AFTER DELETE ON stations BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS channels_search_insert AFTER INSERT ON channels BEGIN
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    SELECT new.id * 4 + 3, new.code, COALESCE(new.sensor_description, ''), 'channel', new.id, new.station_id,
+           n.code, s.code, COALESCE(new.location_code, '')
+    FROM stations s JOIN networks n ON n.id = s.network_id WHERE s.id = new.station_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS channels_search_update AFTER UPDATE OF sensor_description ON channels
+WHEN old.sensor_description IS NOT new.sensor_description BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+    INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+    SELECT new.id * 4 + 3, new.code, COALESCE(new.sensor_description, ''), 'channel', new.id, new.station_id,
+           n.code, s.code, COALESCE(new.location_code, '')
+    FROM stations s JOIN networks n ON n.id = s.network_id WHERE s.id = new.station_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS channels_search_delete AFTER DELETE ON channels BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+END;
+
+-- Index existing rows
+INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+SELECT id * 4 + 1, code, COALESCE(description, ''), 'network', id, NULL, code, '', '' FROM networks;
+
+INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+SELECT s.id * 4 + 2, s.code, COALESCE(s.site_name, ''), 'station', s.id, s.id, n.code, s.code, ''
+FROM stations s JOIN networks n ON n.id = s.network_id;
+
+INSERT INTO search_index (rowid, code, label, kind, ref_id, station_id, network, station, location)
+SELECT c.id * 4 + 3, c.code, COALESCE(c.sensor_description, ''), 'channel', c.id, c.station_id,
+       n.code, s.code, COALESCE(c.location_code, '')
+FROM channels c JOIN stations s ON s.id = c.station_id JOIN networks n ON n.id = s.network_id;
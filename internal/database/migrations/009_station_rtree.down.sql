@@ -0,0 +1,5 @@
+-- 009_station_rtree.down.sql: Drop the station spatial index and its triggers
+DROP TRIGGER IF EXISTS stations_rtree_insert;
+DROP TRIGGER IF EXISTS stations_rtree_update;
+DROP TRIGGER IF EXISTS stations_rtree_delete;
+DROP TABLE IF EXISTS station_rtree;
@@ -0,0 +1,33 @@
+-- 009_station_rtree.sql: R*Tree spatial index over station positions
+--
+-- station_rtree holds each station as a degenerate box keyed by station id,
+-- so bounding box and radius queries look up candidates instead of scanning
+-- stations. R*Tree coordinates are 32-bit floats rounded outwards, so
+-- queries still compare the exact stations.latitude/longitude afterwards.
+-- Triggers keep it in step with stations, including rows removed by
+-- cascading deletes. PostgreSQL uses station_locations (007) instead.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS station_rtree USING rtree(
+    id,
+    min_lat, max_lat,
+    min_lon, max_lon
+);
+
+CREATE TRIGGER IF NOT EXISTS stations_rtree_insert AFTER INSERT ON stations BEGIN
+    INSERT INTO station_rtree (id, min_lat, max_lat, min_lon, max_lon)
+    VALUES (new.id, new.latitude, new.latitude, new.longitude, new.longitude);
+END;
+
+CREATE TRIGGER IF NOT EXISTS stations_rtree_update AFTER UPDATE OF latitude, longitude ON stations BEGIN
+    UPDATE station_rtree
+    SET min_lat = new.latitude, max_lat = new.latitude, min_lon = new.longitude, max_lon = new.longitude
+    WHERE id = new.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS stations_rtree_delete AFTER DELETE ON stations BEGIN
+    DELETE FROM station_rtree WHERE id = old.id;
+END;
+
+INSERT INTO station_rtree (id, min_lat, max_lat, min_lon, max_lon)
+SELECT id, latitude, latitude, longitude, longitude FROM stations
+WHERE id NOT IN (SELECT id FROM station_rtree);
@@ -0,0 +1,16 @@
+-- 010_stationxml_12.sql: StationXML 1.2 node metadata
+--
+-- fdsn_source_id is the FDSN Source Identifier (the sourceID attribute),
+-- identifiers holds Identifier elements such as DOIs as a JSON array, and
+-- water_level is the station or channel WaterLevel in metres.
+
+ALTER TABLE networks ADD COLUMN fdsn_source_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE networks ADD COLUMN identifiers TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE stations ADD COLUMN fdsn_source_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE stations ADD COLUMN identifiers TEXT NOT NULL DEFAULT '';
+ALTER TABLE stations ADD COLUMN water_level REAL;
+
+ALTER TABLE channels ADD COLUMN fdsn_source_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN identifiers TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN water_level REAL;
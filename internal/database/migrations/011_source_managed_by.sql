@@ -0,0 +1,7 @@
+-- 011_source_managed_by.sql: Sources owned by the config file
+--
+-- managed_by is 'config' for sources reconciled from the sources config key,
+-- which the API does not let users edit or delete, and empty for sources
+-- created in the web UI, the API or the CLI.
+
+ALTER TABLE sources ADD COLUMN managed_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,98 @@
+-- 012_events.sql: Seismic events imported from upstream event services
+--
+-- An event keeps the QuakeML publicID it had upstream and is unique per
+-- source. Its time, position, depth and magnitude are copied from the
+-- preferred origin and magnitude so queries need no joins. Depths are in
+-- kilometres, as in the FDSN event service parameters and text format.
+-- Arrivals belong to an origin and refer to picks by publicID.
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    public_id TEXT NOT NULL,
+    event_type TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    preferred_origin_id TEXT NOT NULL DEFAULT '',
+    preferred_magnitude_id TEXT NOT NULL DEFAULT '',
+    time DATETIME NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    depth_km REAL,
+    magnitude REAL,
+    magnitude_type TEXT NOT NULL DEFAULT '',
+    magnitude_author TEXT NOT NULL DEFAULT '',
+    author TEXT NOT NULL DEFAULT '',
+    catalog TEXT NOT NULL DEFAULT '',
+    contributor TEXT NOT NULL DEFAULT '',
+    contributor_id TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(source_id, public_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_time ON events(time);
+CREATE INDEX IF NOT EXISTS idx_events_magnitude ON events(magnitude);
+
+CREATE TABLE IF NOT EXISTS origins (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    public_id TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    depth_km REAL,
+    depth_type TEXT NOT NULL DEFAULT '',
+    method_id TEXT NOT NULL DEFAULT '',
+    earth_model_id TEXT NOT NULL DEFAULT '',
+    evaluation_mode TEXT NOT NULL DEFAULT '',
+    evaluation_status TEXT NOT NULL DEFAULT '',
+    author TEXT NOT NULL DEFAULT '',
+    agency_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_origins_event ON origins(event_id);
+
+CREATE TABLE IF NOT EXISTS magnitudes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    public_id TEXT NOT NULL,
+    mag REAL NOT NULL,
+    magnitude_type TEXT NOT NULL DEFAULT '',
+    origin_public_id TEXT NOT NULL DEFAULT '',
+    station_count INTEGER,
+    author TEXT NOT NULL DEFAULT '',
+    agency_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_magnitudes_event ON magnitudes(event_id);
+
+CREATE TABLE IF NOT EXISTS picks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    public_id TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    network_code TEXT NOT NULL DEFAULT '',
+    station_code TEXT NOT NULL DEFAULT '',
+    location_code TEXT NOT NULL DEFAULT '',
+    channel_code TEXT NOT NULL DEFAULT '',
+    phase_hint TEXT NOT NULL DEFAULT '',
+    polarity TEXT NOT NULL DEFAULT '',
+    onset TEXT NOT NULL DEFAULT '',
+    evaluation_mode TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_picks_event ON picks(event_id);
+
+CREATE TABLE IF NOT EXISTS arrivals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    origin_id INTEGER NOT NULL REFERENCES origins(id) ON DELETE CASCADE,
+    public_id TEXT NOT NULL,
+    pick_public_id TEXT NOT NULL,
+    phase TEXT NOT NULL,
+    distance REAL,
+    azimuth REAL,
+    time_residual REAL,
+    time_weight REAL
+);
+
+CREATE INDEX IF NOT EXISTS idx_arrivals_origin ON arrivals(origin_id);
@@ -0,0 +1,3 @@
+-- 013_extractions.down.sql: Drop waveform extraction jobs
+DROP TABLE IF EXISTS extraction_requests;
+DROP TABLE IF EXISTS extractions;
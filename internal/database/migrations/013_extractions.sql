@@ -0,0 +1,46 @@
+-- 013_extractions.sql: Waveform extraction jobs for events
+--
+-- An extraction fetches the waveforms of the stations around an event, one
+-- request per channel, and packages them as a zip bundle with their
+-- StationXML. The window of each request starts before the predicted first
+-- P arrival and ends after the first S ("phases") or is a fixed offset
+-- from the origin time ("offsets"); offsets are stored in seconds. Requests
+-- keep their codes so they survive the station being deleted.
+
+CREATE TABLE IF NOT EXISTS extractions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    window_mode TEXT NOT NULL,
+    before_seconds REAL NOT NULL,
+    after_seconds REAL NOT NULL,
+    channel TEXT NOT NULL DEFAULT '',
+    min_radius REAL NOT NULL DEFAULT 0,
+    max_radius REAL NOT NULL,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_extractions_event ON extractions(event_id);
+
+CREATE TABLE IF NOT EXISTS extraction_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    extraction_id INTEGER NOT NULL REFERENCES extractions(id) ON DELETE CASCADE,
+    source_id INTEGER NOT NULL,
+    network_code TEXT NOT NULL,
+    station_code TEXT NOT NULL,
+    location_code TEXT NOT NULL DEFAULT '',
+    channel_code TEXT NOT NULL,
+    distance_deg REAL NOT NULL,
+    p_arrival REAL,
+    s_arrival REAL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    bytes INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_extraction_requests_extraction ON extraction_requests(extraction_id);
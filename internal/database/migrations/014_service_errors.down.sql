@@ -0,0 +1,2 @@
+-- 014_service_errors.down.sql: Drop per-service probe errors
+ALTER TABLE source_services DROP COLUMN last_error;
@@ -0,0 +1,6 @@
+-- 014_service_errors.sql: Per-service probe errors
+--
+-- last_error holds the error of the last probe of a service that failed to
+-- answer. The service stays listed until a probe finds it absent.
+
+ALTER TABLE source_services ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
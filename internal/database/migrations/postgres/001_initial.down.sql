@@ -0,0 +1,7 @@
+-- 001_initial.down.sql: Drop the core schema
+
+DROP TABLE IF EXISTS availability;
+DROP TABLE IF EXISTS channels;
+DROP TABLE IF EXISTS stations;
+DROP TABLE IF EXISTS networks;
+DROP TABLE IF EXISTS sources;
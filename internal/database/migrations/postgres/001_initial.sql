@@ -0,0 +1,69 @@
+-- 001_initial.sql: Core schema for FDSN station metadata (PostgreSQL)
+--
+-- Matches the SQLite schema after 003_dedup_stations: networks, stations and
+-- channels are unique without start_time.
+
+CREATE TABLE IF NOT EXISTS sources (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    base_url TEXT NOT NULL,
+    description TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS networks (
+    id BIGSERIAL PRIMARY KEY,
+    source_id BIGINT REFERENCES sources(id),
+    code TEXT NOT NULL,
+    description TEXT,
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS stations (
+    id BIGSERIAL PRIMARY KEY,
+    network_id BIGINT REFERENCES networks(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    elevation DOUBLE PRECISION NOT NULL,
+    site_name TEXT,
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (network_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS channels (
+    id BIGSERIAL PRIMARY KEY,
+    station_id BIGINT REFERENCES stations(id) ON DELETE CASCADE,
+    location_code TEXT DEFAULT '',
+    code TEXT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    elevation DOUBLE PRECISION,
+    depth DOUBLE PRECISION,
+    azimuth DOUBLE PRECISION,
+    dip DOUBLE PRECISION,
+    sensor_description TEXT,
+    scale DOUBLE PRECISION,
+    scale_freq DOUBLE PRECISION,
+    scale_units TEXT,
+    sample_rate DOUBLE PRECISION,
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (station_id, location_code, code)
+);
+
+CREATE TABLE IF NOT EXISTS availability (
+    id BIGSERIAL PRIMARY KEY,
+    channel_id BIGINT REFERENCES channels(id) ON DELETE CASCADE,
+    earliest TIMESTAMPTZ NOT NULL,
+    latest TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
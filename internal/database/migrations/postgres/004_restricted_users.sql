@@ -0,0 +1,20 @@
+-- 004_restricted_users.sql: Restricted-data markers and FDSN queryauth users
+--
+-- See the SQLite migration of the same version for the column semantics.
+
+CREATE TABLE IF NOT EXISTS restrictions (
+    level TEXT NOT NULL CHECK (level IN ('network', 'station', 'channel')),
+    ref_id BIGINT NOT NULL,
+    origin TEXT NOT NULL DEFAULT 'manual' CHECK (origin IN ('upstream', 'manual')),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (level, ref_id)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    ha1 TEXT NOT NULL,
+    networks TEXT NOT NULL DEFAULT '*',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
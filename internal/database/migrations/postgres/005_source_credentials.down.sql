@@ -0,0 +1,2 @@
+-- 005_source_credentials.down.sql: Drop stored upstream credentials
+DROP TABLE IF EXISTS source_credentials;
@@ -0,0 +1,9 @@
+-- 005_source_credentials.sql: Encrypted upstream credentials per source
+
+CREATE TABLE IF NOT EXISTS source_credentials (
+    source_id BIGINT PRIMARY KEY REFERENCES sources(id) ON DELETE CASCADE,
+    username TEXT NOT NULL DEFAULT '',
+    password TEXT NOT NULL DEFAULT '',
+    eida_token TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,19 @@
+-- 006_source_health.sql: Source health probes and service capabilities
+
+CREATE TABLE IF NOT EXISTS source_services (
+    source_id BIGINT NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    service TEXT NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    parameters TEXT NOT NULL DEFAULT '',
+    checked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_id, service)
+);
+
+CREATE TABLE IF NOT EXISTS source_health (
+    source_id BIGINT PRIMARY KEY REFERENCES sources(id) ON DELETE CASCADE,
+    latency_ms BIGINT,
+    last_checked TIMESTAMPTZ,
+    last_success TIMESTAMPTZ,
+    last_failure TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT ''
+);
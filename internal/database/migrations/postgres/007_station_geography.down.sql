@@ -0,0 +1,5 @@
+-- 007_station_geography.down.sql: Drop station geography (the extension is kept)
+
+DROP TRIGGER IF EXISTS stations_sync_location ON stations;
+DROP FUNCTION IF EXISTS sync_station_location();
+DROP TABLE IF EXISTS station_locations;
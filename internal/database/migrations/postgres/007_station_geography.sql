@@ -0,0 +1,33 @@
+-- 007_station_geography.sql: PostGIS locations for stations (PostgreSQL only)
+--
+-- station_locations holds a geography point per station, kept in step with
+-- stations.latitude/longitude by a trigger, so spatial queries can use the
+-- GiST index. It is a side table so stations keeps the same columns as on
+-- SQLite. Creating the extension needs a role allowed to do so.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+CREATE TABLE IF NOT EXISTS station_locations (
+    station_id BIGINT PRIMARY KEY REFERENCES stations(id) ON DELETE CASCADE,
+    location geography(Point, 4326) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_station_locations_location ON station_locations USING GIST (location);
+
+CREATE OR REPLACE FUNCTION sync_station_location() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO station_locations (station_id, location)
+    VALUES (NEW.id, ST_SetSRID(ST_MakePoint(NEW.longitude, NEW.latitude), 4326)::geography)
+    ON CONFLICT (station_id) DO UPDATE SET location = excluded.location;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stations_sync_location ON stations;
+CREATE TRIGGER stations_sync_location
+    AFTER INSERT OR UPDATE OF latitude, longitude ON stations
+    FOR EACH ROW EXECUTE FUNCTION sync_station_location();
+
+INSERT INTO station_locations (station_id, location)
+SELECT id, ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography FROM stations
+ON CONFLICT (station_id) DO NOTHING;
@@ -0,0 +1,11 @@
+-- 008_search.down.sql: Drop the search index and its triggers
+DROP TRIGGER IF EXISTS networks_search_sync ON networks;
+DROP TRIGGER IF EXISTS networks_search_update ON networks;
+DROP TRIGGER IF EXISTS stations_search_sync ON stations;
+DROP TRIGGER IF EXISTS stations_search_update ON stations;
+DROP TRIGGER IF EXISTS channels_search_sync ON channels;
+DROP TRIGGER IF EXISTS channels_search_update ON channels;
+DROP FUNCTION IF EXISTS search_index_networks();
+DROP FUNCTION IF EXISTS search_index_stations();
+DROP FUNCTION IF EXISTS search_index_channels();
+DROP TABLE IF EXISTS search_index;
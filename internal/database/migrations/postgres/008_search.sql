@@ -0,0 +1,101 @@
+-- 008_search.sql: Trigram search over networks, stations and channels
+--
+-- search_index has one row per network, station or channel, kept in step by
+-- triggers; its id is the row's id * 4 plus 1, 2 or 3 for its kind, as on
+-- SQLite. A pg_trgm GIN index makes the word-similarity search typo-tolerant.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS search_index (
+    id BIGINT PRIMARY KEY,
+    code TEXT NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    ref_id BIGINT NOT NULL,
+    station_id BIGINT,
+    network TEXT NOT NULL,
+    station TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_index_text ON search_index
+    USING GIN (lower(code || ' ' || label) gin_trgm_ops);
+
+CREATE OR REPLACE FUNCTION search_index_networks() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        DELETE FROM search_index WHERE id = OLD.id * 4 + 1;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+        VALUES (NEW.id * 4 + 1, NEW.code, COALESCE(NEW.description, ''), 'network', NEW.id, NULL, NEW.code, '', '');
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION search_index_stations() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        DELETE FROM search_index WHERE id = OLD.id * 4 + 2;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+        SELECT NEW.id * 4 + 2, NEW.code, COALESCE(NEW.site_name, ''), 'station', NEW.id, NEW.id, n.code, NEW.code, ''
+        FROM networks n WHERE n.id = NEW.network_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION search_index_channels() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        DELETE FROM search_index WHERE id = OLD.id * 4 + 3;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+        SELECT NEW.id * 4 + 3, NEW.code, COALESCE(NEW.sensor_description, ''), 'channel', NEW.id, NEW.station_id,
+               n.code, s.code, COALESCE(NEW.location_code, '')
+        FROM stations s JOIN networks n ON n.id = s.network_id WHERE s.id = NEW.station_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS networks_search_sync ON networks;
+CREATE TRIGGER networks_search_sync AFTER INSERT OR DELETE ON networks
+    FOR EACH ROW EXECUTE FUNCTION search_index_networks();
+DROP TRIGGER IF EXISTS networks_search_update ON networks;
+CREATE TRIGGER networks_search_update AFTER UPDATE OF description ON networks
+    FOR EACH ROW WHEN (OLD.description IS DISTINCT FROM NEW.description) EXECUTE FUNCTION search_index_networks();
+
+DROP TRIGGER IF EXISTS stations_search_sync ON stations;
+CREATE TRIGGER stations_search_sync AFTER INSERT OR DELETE ON stations
+    FOR EACH ROW EXECUTE FUNCTION search_index_stations();
+DROP TRIGGER IF EXISTS stations_search_update ON stations;
+CREATE TRIGGER stations_search_update AFTER UPDATE OF site_name ON stations
+    FOR EACH ROW WHEN (OLD.site_name IS DISTINCT FROM NEW.site_name) EXECUTE FUNCTION search_index_stations();
+
+DROP TRIGGER IF EXISTS channels_search_sync ON channels;
+CREATE TRIGGER channels_search_sync AFTER INSERT OR DELETE ON channels
+    FOR EACH ROW EXECUTE FUNCTION search_index_channels();
+DROP TRIGGER IF EXISTS channels_search_update ON channels;
+CREATE TRIGGER channels_search_update AFTER UPDATE OF sensor_description ON channels
+    FOR EACH ROW WHEN (OLD.sensor_description IS DISTINCT FROM NEW.sensor_description) EXECUTE FUNCTION search_index_channels();
+
+-- Index existing rows
+INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+SELECT id * 4 + 1, code, COALESCE(description, ''), 'network', id, NULL, code, '', '' FROM networks
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+SELECT s.id * 4 + 2, s.code, COALESCE(s.site_name, ''), 'station', s.id, s.id, n.code, s.code, ''
+FROM stations s JOIN networks n ON n.id = s.network_id
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO search_index (id, code, label, kind, ref_id, station_id, network, station, location)
+SELECT c.id * 4 + 3, c.code, COALESCE(c.sensor_description, ''), 'channel', c.id, c.station_id,
+       n.code, s.code, COALESCE(c.location_code, '')
+FROM channels c JOIN stations s ON s.id = c.station_id JOIN networks n ON n.id = s.network_id
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- 009_station_rtree.down.sql: Drop the planar station location index
+
+DROP INDEX IF EXISTS idx_station_locations_geom;
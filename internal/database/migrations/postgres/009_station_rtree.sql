@@ -0,0 +1,7 @@
+-- 009_station_rtree.sql: Planar index for station bounding box queries
+--
+-- The geography index from 007 serves radius queries. Bounding boxes are
+-- latitude/longitude rectangles, which geography boxes only approximate, so
+-- they use an index on the planar geometry instead.
+
+CREATE INDEX IF NOT EXISTS idx_station_locations_geom ON station_locations USING GIST ((location::geometry));
@@ -0,0 +1,11 @@
+-- 010_stationxml_12.down.sql: Drop StationXML 1.2 node metadata
+ALTER TABLE channels DROP COLUMN water_level;
+ALTER TABLE channels DROP COLUMN identifiers;
+ALTER TABLE channels DROP COLUMN fdsn_source_id;
+
+ALTER TABLE stations DROP COLUMN water_level;
+ALTER TABLE stations DROP COLUMN identifiers;
+ALTER TABLE stations DROP COLUMN fdsn_source_id;
+
+ALTER TABLE networks DROP COLUMN identifiers;
+ALTER TABLE networks DROP COLUMN fdsn_source_id;
@@ -0,0 +1,2 @@
+-- 011_source_managed_by.down.sql: Drop the source ownership marker
+ALTER TABLE sources DROP COLUMN managed_by;
@@ -0,0 +1,6 @@
+-- 012_events.down.sql: Drop imported events
+DROP TABLE IF EXISTS arrivals;
+DROP TABLE IF EXISTS picks;
+DROP TABLE IF EXISTS magnitudes;
+DROP TABLE IF EXISTS origins;
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"SELECT '?', \"a?\" FROM t WHERE c = ?", "SELECT '?', \"a?\" FROM t WHERE c = $1"},
+		{"SELECT 'it''s ?' WHERE x = ?", "SELECT 'it''s ?' WHERE x = $1"},
+		{"-- why?\nSELECT ?", "-- why?\nSELECT $1"},
+		{"SELECT /* a? */ ? /* b /* nested? */ ? */ + ?", "SELECT /* a? */ $1 /* b /* nested? */ ? */ + $2"},
+		{"SELECT ? /* unterminated ?", "SELECT $1 /* unterminated ?"},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.in); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if _, err := Open("oracle", "x"); err == nil {
+		t.Error("expected an error for an unsupported driver")
+	}
+}
+
+// openTestPostgres connects to the PostgreSQL server in FDSN_TEST_POSTGRES_DSN
+// using a fresh schema that is dropped when the test ends. The test is
+// skipped if the variable is not set.
+func openTestPostgres(t *testing.T) *DB {
+	t.Helper()
+	dsn := os.Getenv("FDSN_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("FDSN_TEST_POSTGRES_DSN not set")
+	}
+	admin, err := NewPostgres(dsn)
+	if err != nil {
+		t.Fatalf("NewPostgres: %v", err)
+	}
+	schema := fmt.Sprintf("fdsn_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	if !strings.Contains(dsn, "://") {
+		sep = " "
+	}
+	param := "search_path=" + schema + ",public"
+	if sep == " " {
+		param = "search_path='" + schema + ",public'"
+	}
+	db, err := NewPostgres(dsn + sep + param)
+	if err != nil {
+		t.Fatalf("NewPostgres(schema): %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	db := openTestPostgres(t)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if done, err := MigrateUp(db, 0); err != nil || len(done) != 0 {
+		t.Fatalf("second MigrateUp = %d migrations, %v; want none", len(done), err)
+	}
+
+	var stationID int64
+	if err := db.Get(&stationID, `WITH s AS (INSERT INTO sources (name, base_url) VALUES ('a', 'http://a') RETURNING id),
+		n AS (INSERT INTO networks (source_id, code) SELECT id, 'XX' FROM s RETURNING id)
+		INSERT INTO stations (network_id, code, latitude, longitude, elevation)
+		SELECT id, 'STA', ?, ?, 0 FROM n RETURNING id`, 46.5, 7.25); err != nil {
+		t.Fatalf("insert station: %v", err)
+	}
+	var lat float64
+	if err := db.Get(&lat, "SELECT ST_Y(location::geometry) FROM station_locations WHERE station_id = ?", stationID); err != nil || lat != 46.5 {
+		t.Errorf("station location latitude = %v, %v; want 46.5", lat, err)
+	}
+
+	migrations, _ := Migrations(DriverPostgres)
+	if _, err := MigrateDown(db, len(migrations)); err != nil {
+		t.Fatalf("MigrateDown all: %v", err)
+	}
+}
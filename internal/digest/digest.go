@@ -0,0 +1,218 @@
+// Package digest implements the subset of HTTP Digest authentication
+// (RFC 2617, MD5 with qop=auth) used by FDSN web services for /queryauth.
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HA1 returns the hex-encoded MD5 of "username:realm:password". Credential
+// stores keep this value instead of the plain-text password.
+func HA1(username, realm, password string) string {
+	return md5Hex(username + ":" + realm + ":" + password)
+}
+
+// ParseHeader parses the parameters of a Digest challenge or authorization
+// header, e.g. `Digest realm="FDSN", nonce="abc", qop=auth`.
+// It returns nil if the header does not use the Digest scheme.
+func ParseHeader(h string) map[string]string {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(h), " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return nil
+	}
+
+	params := make(map[string]string)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var val string
+		if strings.HasPrefix(after, `"`) {
+			// Quoted string; honour backslash escapes
+			var b strings.Builder
+			i := 1
+			for ; i < len(after); i++ {
+				c := after[i]
+				if c == '\\' && i+1 < len(after) {
+					i++
+					b.WriteByte(after[i])
+					continue
+				}
+				if c == '"' {
+					break
+				}
+				b.WriteByte(c)
+			}
+			val = b.String()
+			if i < len(after) {
+				i++
+			}
+			rest = after[i:]
+		} else {
+			val, rest, _ = strings.Cut(after, ",")
+			val = strings.TrimSpace(val)
+		}
+		params[key] = val
+	}
+	return params
+}
+
+// Server verifies Digest credentials against a lookup of stored HA1 values.
+// Nonces are stateless: each embeds its issue time and an HMAC so any
+// instance holding the same secret can validate it.
+type Server struct {
+	Realm    string
+	NonceTTL time.Duration
+
+	// Lookup returns the stored HA1 for username, or false if unknown.
+	Lookup func(username string) (ha1 string, ok bool)
+
+	secret []byte
+}
+
+// NewServer creates a Server with a random nonce secret and a five minute
+// nonce lifetime.
+func NewServer(realm string, lookup func(username string) (string, bool)) *Server {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return &Server{
+		Realm:    realm,
+		NonceTTL: 5 * time.Minute,
+		Lookup:   lookup,
+		secret:   secret,
+	}
+}
+
+// Challenge writes a 401 response carrying a fresh WWW-Authenticate header.
+// stale tells the client its credentials were fine but the nonce expired.
+func (s *Server) Challenge(w http.ResponseWriter, stale bool) {
+	h := fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=MD5, nonce="%s", opaque="%s"`,
+		s.Realm, s.newNonce(time.Now()), md5Hex(s.Realm))
+	if stale {
+		h += ", stale=true"
+	}
+	w.Header().Set("WWW-Authenticate", h)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// Authenticate checks the request's Authorization header. It returns the
+// authenticated username, or ok=false. stale is true when the response was
+// otherwise valid but computed with an expired nonce.
+func (s *Server) Authenticate(r *http.Request) (username string, ok, stale bool) {
+	p := ParseHeader(r.Header.Get("Authorization"))
+	if p == nil {
+		return "", false, false
+	}
+	username = p["username"]
+	if username == "" || p["realm"] != s.Realm || p["response"] == "" {
+		return "", false, false
+	}
+	if alg := p["algorithm"]; alg != "" && !strings.EqualFold(alg, "MD5") {
+		return "", false, false
+	}
+	if uri := p["uri"]; uri != r.URL.RequestURI() && uri != r.URL.Path {
+		return "", false, false
+	}
+
+	ha1, found := s.Lookup(username)
+	if !found {
+		return "", false, false
+	}
+
+	ha2 := md5Hex(r.Method + ":" + p["uri"])
+	var expected string
+	switch p["qop"] {
+	case "auth":
+		expected = md5Hex(strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], "auth", ha2}, ":"))
+	case "":
+		expected = md5Hex(ha1 + ":" + p["nonce"] + ":" + ha2)
+	default:
+		return "", false, false
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(p["response"])) != 1 {
+		return "", false, false
+	}
+
+	valid, expired := s.checkNonce(p["nonce"], time.Now())
+	if !valid {
+		return "", false, false
+	}
+	if expired {
+		return "", false, true
+	}
+	return username, true, false
+}
+
+// Authorization builds the Authorization header value answering challenge
+// (the parsed WWW-Authenticate parameters) for a request. nc is the nonce
+// count for this nonce, starting at 1; cnonce is a client-chosen random value.
+func Authorization(challenge map[string]string, method, uri, username, password string, nc int, cnonce string) string {
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	ha1 := HA1(username, realm, password)
+	ha2 := md5Hex(method + ":" + uri)
+
+	qopAuth := false
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qopAuth = true
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=MD5`,
+		username, realm, nonce, uri)
+	if qopAuth {
+		ncs := fmt.Sprintf("%08x", nc)
+		resp := md5Hex(strings.Join([]string{ha1, nonce, ncs, cnonce, "auth", ha2}, ":"))
+		fmt.Fprintf(&b, `, qop=auth, nc=%s, cnonce="%s", response="%s"`, ncs, cnonce, resp)
+	} else {
+		fmt.Fprintf(&b, `, response="%s"`, md5Hex(ha1+":"+nonce+":"+ha2))
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		fmt.Fprintf(&b, `, opaque="%s"`, opaque)
+	}
+	return b.String()
+}
+
+// newNonce encodes the issue time followed by an HMAC of it.
+func (s *Server) newNonce(now time.Time) string {
+	buf := make([]byte, 8, 8+sha256.Size)
+	binary.BigEndian.PutUint64(buf, uint64(now.UnixNano()))
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(buf)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(buf))
+}
+
+func (s *Server) checkNonce(nonce string, now time.Time) (valid, expired bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(raw) != 8+sha256.Size {
+		return false, false
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(raw[:8])
+	if !hmac.Equal(mac.Sum(nil), raw[8:]) {
+		return false, false
+	}
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(raw[:8])))
+	return true, now.Sub(issued) > s.NonceTTL
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
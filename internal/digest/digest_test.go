@@ -0,0 +1,137 @@
+package digest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHA1(t *testing.T) {
+	// Example from RFC 2617 section 3.5
+	got := HA1("Mufasa", "testrealm@host.com", "Circle Of Life")
+	if got != "939e7578ed9e3c518a452acee763bce9" {
+		t.Errorf("HA1 = %s", got)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	p := ParseHeader(`Digest username="Mufasa", realm="testrealm@host.com", qop=auth, nc=00000001, opaque="5ccc\"069"`)
+	if p == nil {
+		t.Fatal("ParseHeader returned nil")
+	}
+	want := map[string]string{
+		"username": "Mufasa",
+		"realm":    "testrealm@host.com",
+		"qop":      "auth",
+		"nc":       "00000001",
+		"opaque":   `5ccc"069`,
+	}
+	for k, v := range want {
+		if p[k] != v {
+			t.Errorf("%s: got %q, want %q", k, p[k], v)
+		}
+	}
+
+	if ParseHeader("Basic dXNlcjpwYXNz") != nil {
+		t.Error("expected nil for Basic scheme")
+	}
+}
+
+// authorize builds a client Authorization header for the given challenge.
+func authorize(t *testing.T, challenge, method, uri, user, pass string) string {
+	t.Helper()
+	c := ParseHeader(challenge)
+	if c == nil {
+		t.Fatalf("bad challenge %q", challenge)
+	}
+	ha1 := HA1(user, c["realm"], pass)
+	ha2 := md5Hex(method + ":" + uri)
+	resp := md5Hex(strings.Join([]string{ha1, c["nonce"], "00000001", "0a4f113b", "auth", ha2}, ":"))
+	return `Digest username="` + user + `", realm="` + c["realm"] + `", nonce="` + c["nonce"] +
+		`", uri="` + uri + `", qop=auth, nc=00000001, cnonce="0a4f113b", response="` + resp +
+		`", opaque="` + c["opaque"] + `"`
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	s := NewServer("FDSN", func(u string) (string, bool) {
+		if u == "alice" {
+			return HA1("alice", "FDSN", "secret"), true
+		}
+		return "", false
+	})
+
+	rec := httptest.NewRecorder()
+	s.Challenge(rec, false)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("challenge status = %d", rec.Code)
+	}
+	challenge := rec.Header().Get("WWW-Authenticate")
+
+	uri := "/fdsnws/station/1/queryauth?net=XX"
+	req := httptest.NewRequest("GET", uri, nil)
+	req.Header.Set("Authorization", authorize(t, challenge, "GET", uri, "alice", "secret"))
+	user, ok, stale := s.Authenticate(req)
+	if !ok || stale || user != "alice" {
+		t.Fatalf("Authenticate = %q, %v, %v", user, ok, stale)
+	}
+
+	req.Header.Set("Authorization", authorize(t, challenge, "GET", uri, "alice", "wrong"))
+	if _, ok, _ := s.Authenticate(req); ok {
+		t.Error("expected wrong password to fail")
+	}
+
+	req.Header.Set("Authorization", authorize(t, challenge, "GET", uri, "bob", "secret"))
+	if _, ok, _ := s.Authenticate(req); ok {
+		t.Error("expected unknown user to fail")
+	}
+}
+
+func TestServerStaleNonce(t *testing.T) {
+	s := NewServer("FDSN", func(u string) (string, bool) {
+		return HA1("alice", "FDSN", "secret"), true
+	})
+	old := s.newNonce(time.Now().Add(-time.Hour))
+	challenge := `Digest realm="FDSN", qop="auth", nonce="` + old + `", opaque="x"`
+
+	req := httptest.NewRequest("GET", "/q", nil)
+	req.Header.Set("Authorization", authorize(t, challenge, "GET", "/q", "alice", "secret"))
+	_, ok, stale := s.Authenticate(req)
+	if ok || !stale {
+		t.Errorf("expected stale nonce, got ok=%v stale=%v", ok, stale)
+	}
+
+	// A forged nonce is rejected outright
+	challenge = `Digest realm="FDSN", qop="auth", nonce="Zm9yZ2Vk", opaque="x"`
+	req.Header.Set("Authorization", authorize(t, challenge, "GET", "/q", "alice", "secret"))
+	if _, ok, stale := s.Authenticate(req); ok || stale {
+		t.Error("expected forged nonce to be rejected")
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	s := NewServer("FDSN", func(u string) (string, bool) {
+		return HA1("alice", "FDSN", "secret"), u == "alice"
+	})
+	rec := httptest.NewRecorder()
+	s.Challenge(rec, false)
+	challenge := ParseHeader(rec.Header().Get("WWW-Authenticate"))
+
+	uri := "/fdsnws/dataselect/1/queryauth?net=XX"
+	req := httptest.NewRequest("GET", uri, nil)
+	req.Header.Set("Authorization", Authorization(challenge, "GET", uri, "alice", "secret", 1, "abc"))
+	if user, ok, _ := s.Authenticate(req); !ok || user != "alice" {
+		t.Errorf("qop=auth: Authenticate = %q, %v", user, ok)
+	}
+
+	// Legacy RFC 2069 challenge without qop
+	delete(challenge, "qop")
+	req.Header.Set("Authorization", Authorization(challenge, "GET", uri, "alice", "secret", 1, "abc"))
+	if p := ParseHeader(req.Header.Get("Authorization")); p["qop"] != "" || p["nc"] != "" {
+		t.Errorf("unexpected qop fields without qop challenge: %v", p)
+	}
+	if user, ok, _ := s.Authenticate(req); !ok || user != "alice" {
+		t.Errorf("no qop: Authenticate = %q, %v", user, ok)
+	}
+}
@@ -0,0 +1,273 @@
+// Package discovery finds the data centres that serve a network, using the
+// EIDA routing service and the IRIS fedcatalog, and registers them as sources
+// so imports can be routed without knowing the node's URL.
+package discovery
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+const userAgent = "FDSN-Client/1.0"
+
+// Lookup services that can be queried.
+const (
+	ViaEIDARouting = "eida-routing"
+	ViaFedcatalog  = "fedcatalog"
+)
+
+// DataCenter is a data centre reported to serve a network.
+type DataCenter struct {
+	Name    string // empty when the lookup service does not name it
+	BaseURL string // scheme and host of the FDSN web services
+	Via     string
+}
+
+// Client queries the EIDA routing service and the IRIS fedcatalog. An empty
+// URL disables that lookup.
+type Client struct {
+	EIDARoutingURL string
+	FedcatalogURL  string
+	HTTPClient     *http.Client
+}
+
+// New returns a Client for the given service base URLs (for example
+// https://www.orfeus-eu.org/eidaws/routing/1).
+func New(eidaRoutingURL, fedcatalogURL string) *Client {
+	return &Client{
+		EIDARoutingURL: strings.TrimRight(eidaRoutingURL, "/"),
+		FedcatalogURL:  strings.TrimRight(fedcatalogURL, "/"),
+		HTTPClient:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Lookup returns the data centres serving the FDSN station service for
+// network, EIDA routing answers first. Data centres reported by both
+// services are merged, taking the name from the fedcatalog. An error is
+// returned only if every enabled lookup failed.
+func (c *Client) Lookup(network string) ([]DataCenter, error) {
+	if network == "" || strings.ContainsAny(network, "*?,") {
+		return nil, fmt.Errorf("a single network code is required")
+	}
+
+	var out []DataCenter
+	index := make(map[string]int)
+	var errs []error
+	tried := 0
+	merge := func(dcs []DataCenter, err error) {
+		tried++
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		for _, dc := range dcs {
+			key := Key(dc.BaseURL)
+			if i, ok := index[key]; ok {
+				if out[i].Name == "" {
+					out[i].Name = dc.Name
+				}
+				continue
+			}
+			index[key] = len(out)
+			out = append(out, dc)
+		}
+	}
+	if c.EIDARoutingURL != "" {
+		merge(c.eidaRouting(network))
+	}
+	if c.FedcatalogURL != "" {
+		merge(c.fedcatalog(network))
+	}
+	if tried == 0 {
+		return nil, errors.New("no discovery service configured")
+	}
+	if len(errs) == tried {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		log.Warn().Err(err).Str("network", network).Msg("discovery lookup failed")
+	}
+	return out, nil
+}
+
+// eidaRouting asks the EIDA routing service which node serves network.
+func (c *Client) eidaRouting(network string) ([]DataCenter, error) {
+	v := url.Values{"network": {network}, "service": {"station"}, "format": {"json"}}
+	body, err := c.get(c.EIDARoutingURL + "/query?" + v.Encode())
+	if err != nil || body == nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var routes []struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(body).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("decode EIDA routing response: %w", err)
+	}
+	var out []DataCenter
+	for _, r := range routes {
+		if base := serviceBase(r.URL); base != "" {
+			out = append(out, DataCenter{BaseURL: base, Via: ViaEIDARouting})
+		}
+	}
+	return out, nil
+}
+
+// fedcatalog asks the IRIS fedcatalog which data centres serve network. Only
+// the DATACENTER and STATIONSERVICE header lines of the request-format
+// response are used.
+func (c *Client) fedcatalog(network string) ([]DataCenter, error) {
+	v := url.Values{"net": {network}, "targetservice": {"station"}, "format": {"request"}}
+	body, err := c.get(c.FedcatalogURL + "/query?" + v.Encode())
+	if err != nil || body == nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var out []DataCenter
+	var name string
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "DATACENTER":
+			name, _, _ = strings.Cut(value, ",")
+		case "STATIONSERVICE":
+			if base := serviceBase(value); base != "" {
+				out = append(out, DataCenter{Name: name, BaseURL: base, Via: ViaFedcatalog})
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read fedcatalog response: %w", err)
+	}
+	return out, nil
+}
+
+// get fetches rawURL. A 204 (nothing found) yields nil, nil.
+func (c *Client) get(rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", rawURL, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: status %d: %s", rawURL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
+
+// serviceBase strips the /fdsnws/... path from a service URL, leaving the
+// base URL sources are configured with.
+func serviceBase(serviceURL string) string {
+	i := strings.Index(serviceURL, "/fdsnws/")
+	if i <= 0 {
+		return ""
+	}
+	return serviceURL[:i]
+}
+
+// Key normalises a base URL for comparison, ignoring the scheme, case, a
+// leading "www." and trailing slashes.
+func Key(baseURL string) string {
+	k := strings.ToLower(strings.TrimRight(baseURL, "/"))
+	if i := strings.Index(k, "://"); i >= 0 {
+		k = k[i+3:]
+	}
+	return strings.TrimPrefix(k, "www.")
+}
+
+// Discoverer looks up data centres and makes sure each has a source row.
+type Discoverer struct {
+	client  *Client
+	sources store.SourceStore
+}
+
+// NewDiscoverer returns a Discoverer that registers sources in sources.
+func NewDiscoverer(client *Client, sources store.SourceStore) *Discoverer {
+	return &Discoverer{client: client, sources: sources}
+}
+
+// Discover returns a source for every data centre serving network, in lookup
+// order, creating sources that do not exist yet. Existing sources are
+// matched by base URL and returned even if disabled.
+func (d *Discoverer) Discover(network string) ([]models.DiscoveredSource, error) {
+	network = strings.ToUpper(strings.TrimSpace(network))
+	dcs, err := d.client.Lookup(network)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := d.sources.List()
+	if err != nil {
+		return nil, fmt.Errorf("list sources: %w", err)
+	}
+	byKey := make(map[string]models.Source, len(existing))
+	names := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		byKey[Key(s.BaseURL)] = s
+		names[strings.ToLower(s.Name)] = true
+	}
+
+	out := make([]models.DiscoveredSource, 0, len(dcs))
+	for _, dc := range dcs {
+		if s, ok := byKey[Key(dc.BaseURL)]; ok {
+			out = append(out, models.DiscoveredSource{Source: s, Via: dc.Via})
+			continue
+		}
+		src := models.Source{
+			Name:        sourceName(dc, names),
+			BaseURL:     dc.BaseURL,
+			Description: fmt.Sprintf("Discovered via %s for network %s", dc.Via, network),
+			Enabled:     true,
+		}
+		if err := d.sources.Create(&src); err != nil {
+			return nil, fmt.Errorf("create source for %s: %w", dc.BaseURL, err)
+		}
+		log.Info().Str("name", src.Name).Str("url", src.BaseURL).Str("network", network).Msg("discovered source")
+		byKey[Key(src.BaseURL)] = src
+		names[strings.ToLower(src.Name)] = true
+		out = append(out, models.DiscoveredSource{Source: src, Via: dc.Via, Created: true})
+	}
+	return out, nil
+}
+
+// sourceName picks an unused name for a new source: the data centre's name
+// if known, otherwise its host.
+func sourceName(dc DataCenter, taken map[string]bool) string {
+	host := Key(dc.BaseURL)
+	name := dc.Name
+	if name == "" {
+		name = host
+	}
+	if taken[strings.ToLower(name)] {
+		name = name + " (" + host + ")"
+	}
+	return name
+}
@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+func lookupServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/eidaws/routing/1/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("network") != "CH" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"url": "http://eida.ethz.ch/fdsnws/station/1/query", "name": "station",
+			"params": [{"net": "CH", "sta": "*", "loc": "*", "cha": "*", "start": "1980-01-01T00:00:00", "end": ""}]}]`)
+	})
+	mux.HandleFunc("/irisws/fedcatalog/1/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("net") != "CH" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = io.WriteString(w, "#FederatorVersion: 1.0\n\n"+
+			"DATACENTER=ETH,http://www.seismo.ethz.ch\n"+
+			"STATIONSERVICE=https://eida.ethz.ch/fdsnws/station/1/\n"+
+			"DATASELECTSERVICE=https://eida.ethz.ch/fdsnws/dataselect/1/\n"+
+			"CH ACB -- HHZ 2010-01-01T00:00:00 2599-12-31T23:59:59\n\n"+
+			"DATACENTER=IRISDMC,http://ds.iris.edu\n"+
+			"STATIONSERVICE=http://service.iris.edu/fdsnws/station/1/\n"+
+			"CH DAVOX -- LHZ 2002-09-01T00:00:00 2599-12-31T23:59:59\n")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLookup(t *testing.T) {
+	srv := lookupServer(t)
+	c := New(srv.URL+"/eidaws/routing/1", srv.URL+"/irisws/fedcatalog/1")
+
+	dcs, err := c.Lookup("CH")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	want := []DataCenter{
+		{Name: "ETH", BaseURL: "http://eida.ethz.ch", Via: ViaEIDARouting},
+		{Name: "IRISDMC", BaseURL: "http://service.iris.edu", Via: ViaFedcatalog},
+	}
+	if len(dcs) != len(want) {
+		t.Fatalf("got %+v, want %+v", dcs, want)
+	}
+	for i := range want {
+		if dcs[i] != want[i] {
+			t.Errorf("dcs[%d] = %+v, want %+v", i, dcs[i], want[i])
+		}
+	}
+
+	if dcs, err := c.Lookup("ZZ"); err != nil || len(dcs) != 0 {
+		t.Errorf("Lookup(ZZ) = %+v, %v; want nothing", dcs, err)
+	}
+	if _, err := c.Lookup("C*"); err == nil {
+		t.Error("expected an error for a wildcard network")
+	}
+
+	broken := New("http://127.0.0.1:1/routing", "")
+	if _, err := broken.Lookup("CH"); err == nil {
+		t.Error("expected an error when every lookup fails")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	srv := lookupServer(t)
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	sources := store.NewSourceStore(db)
+	if err := sources.Create(&models.Source{Name: "Earthscope", BaseURL: "https://service.iris.edu", Enabled: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	d := NewDiscoverer(New(srv.URL+"/eidaws/routing/1", srv.URL+"/irisws/fedcatalog/1"), sources)
+	found, err := d.Discover("ch")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 sources, got %+v", found)
+	}
+	if !found[0].Created || found[0].Name != "ETH" || found[0].BaseURL != "http://eida.ethz.ch" {
+		t.Errorf("expected ETH to be created, got %+v", found[0])
+	}
+	if found[1].Created || found[1].Name != "Earthscope" {
+		t.Errorf("expected existing Earthscope source to be reused, got %+v", found[1])
+	}
+
+	// A second lookup finds the created source instead of adding another
+	found, err = d.Discover("CH")
+	if err != nil {
+		t.Fatalf("Discover again: %v", err)
+	}
+	all, _ := sources.List()
+	if len(all) != 2 || found[0].Created {
+		t.Errorf("expected no new sources, have %d", len(all))
+	}
+}
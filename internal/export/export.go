@@ -0,0 +1,255 @@
+// Package export writes station lists as GeoJSON, KML and CSV for GIS tools
+// such as QGIS and Google Earth.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// Export formats.
+const (
+	GeoJSON = "geojson"
+	KML     = "kml"
+	CSV     = "csv"
+)
+
+// Supported reports whether format is one of the export formats.
+func Supported(format string) bool {
+	return format == GeoJSON || format == KML || format == CSV
+}
+
+// ContentType returns the media type for format.
+func ContentType(format string) string {
+	switch format {
+	case GeoJSON:
+		return "application/geo+json"
+	case KML:
+		return "application/vnd.google-earth.kml+xml"
+	case CSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Filename suggests a download name for an export in format.
+func Filename(format string) string {
+	return "stations." + format
+}
+
+// Write writes stations to w in format.
+func Write(w io.Writer, format string, stations []models.StationSummary) error {
+	switch format {
+	case GeoJSON:
+		return writeGeoJSON(w, stations)
+	case KML:
+		return writeKML(w, stations)
+	case CSV:
+		return writeCSV(w, stations)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Geometry   point          `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type point struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func writeGeoJSON(w io.Writer, stations []models.StationSummary) error {
+	fc := featureCollection{Type: "FeatureCollection", Features: make([]feature, 0, len(stations))}
+	for _, s := range stations {
+		channels := s.Channels
+		if channels == nil {
+			channels = []string{}
+		}
+		fc.Features = append(fc.Features, feature{
+			Type:     "Feature",
+			ID:       s.Network + "." + s.Station,
+			Geometry: point{Type: "Point", Coordinates: []float64{s.Longitude, s.Latitude}},
+			Properties: map[string]any{
+				"network":             s.Network,
+				"network_description": s.NetworkDescription,
+				"station":             s.Station,
+				"site_name":           s.SiteName,
+				"elevation":           s.Elevation,
+				"start_time":          timePtr(s.StartTime),
+				"end_time":            timePtr(s.EndTime),
+				"channels":            channels,
+				"availability_start":  s.AvailabilityStart,
+				"availability_end":    s.AvailabilityEnd,
+				"restricted":          s.Restricted,
+				"data_center":         s.DataCenter,
+			},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
+
+type kmlDoc struct {
+	XMLName  xml.Name `xml:"kml"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Document struct {
+		Name    string      `xml:"name"`
+		Folders []kmlFolder `xml:"Folder"`
+	} `xml:"Document"`
+}
+
+type kmlFolder struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description,omitempty"`
+	TimeSpan     *kmlSpan  `xml:"TimeSpan,omitempty"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Point        struct {
+		Coordinates string `xml:"coordinates"`
+	} `xml:"Point"`
+}
+
+type kmlSpan struct {
+	Begin string `xml:"begin,omitempty"`
+	End   string `xml:"end,omitempty"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+// writeKML writes one folder per network, so Google Earth can toggle
+// networks, with each station's epoch as a TimeSpan for the time slider.
+func writeKML(w io.Writer, stations []models.StationSummary) error {
+	var doc kmlDoc
+	doc.XMLNS = "http://www.opengis.net/kml/2.2"
+	doc.Document.Name = "Stations"
+	folders := make(map[string]int)
+	for _, s := range stations {
+		i, ok := folders[s.Network]
+		if !ok {
+			i = len(doc.Document.Folders)
+			folders[s.Network] = i
+			doc.Document.Folders = append(doc.Document.Folders, kmlFolder{Name: s.Network, Description: s.NetworkDescription})
+		}
+		pm := kmlPlacemark{Name: s.Network + "." + s.Station, Description: s.SiteName}
+		if s.StartTime != nil || s.EndTime != nil {
+			pm.TimeSpan = &kmlSpan{Begin: formatTime(s.StartTime), End: formatTime(s.EndTime)}
+		}
+		for _, f := range csvFields(s)[2:] {
+			if f.value != "" {
+				pm.ExtendedData = append(pm.ExtendedData, kmlData{Name: f.name, Value: f.value})
+			}
+		}
+		pm.Point.Coordinates = fmt.Sprintf("%s,%s,%s", formatFloat(s.Longitude), formatFloat(s.Latitude), formatFloat(s.Elevation))
+		doc.Document.Folders[i].Placemarks = append(doc.Document.Folders[i].Placemarks, pm)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type field struct{ name, value string }
+
+// csvFields is a station as named columns, in CSV column order.
+func csvFields(s models.StationSummary) []field {
+	return []field{
+		{"network", s.Network},
+		{"station", s.Station},
+		{"latitude", formatFloat(s.Latitude)},
+		{"longitude", formatFloat(s.Longitude)},
+		{"elevation", formatFloat(s.Elevation)},
+		{"site_name", s.SiteName},
+		{"start_time", formatTime(s.StartTime)},
+		{"end_time", formatTime(s.EndTime)},
+		{"channels", strings.Join(s.Channels, " ")},
+		{"availability_start", deref(s.AvailabilityStart)},
+		{"availability_end", deref(s.AvailabilityEnd)},
+		{"restricted", strconv.FormatBool(s.Restricted)},
+		{"data_center", s.DataCenter},
+		{"network_description", s.NetworkDescription},
+	}
+}
+
+// writeCSV writes a header row and one row per station. The latitude and
+// longitude column names are picked up by QGIS's delimited text import.
+func writeCSV(w io.Writer, stations []models.StationSummary) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, f := range csvFields(models.StationSummary{}) {
+		header = append(header, f.name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range stations {
+		fields := csvFields(s)
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			row[i] = f.value
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func timePtr(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := formatTime(t)
+	return &s
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
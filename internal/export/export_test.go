@@ -0,0 +1,115 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func testStations() []models.StationSummary {
+	start := time.Date(1989, 8, 29, 0, 0, 0, 0, time.UTC)
+	earliest, latest := "2020-01-01T00:00:00Z", "2021-01-01T00:00:00Z"
+	return []models.StationSummary{
+		{Network: "IU", NetworkDescription: "Global Seismograph Network", Station: "ANMO",
+			Latitude: 34.9459, Longitude: -106.4572, Elevation: 1820, SiteName: "Albuquerque, New Mexico",
+			StartTime: &start, Channels: []string{"00.BHZ", "10.BHZ"},
+			AvailabilityStart: &earliest, AvailabilityEnd: &latest, DataCenter: "IRIS"},
+		{Network: "NZ", Station: "WEL", Latitude: -41.28, Longitude: 174.77, Restricted: true},
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, GeoJSON, testStations()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			ID       string `json:"id"`
+			Geometry struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 2 {
+		t.Fatalf("got %s with %d features", fc.Type, len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.ID != "IU.ANMO" || f.Geometry.Coordinates[0] != -106.4572 || f.Geometry.Coordinates[1] != 34.9459 {
+		t.Errorf("feature = %s at %v, want IU.ANMO at lon, lat", f.ID, f.Geometry.Coordinates)
+	}
+	if f.Properties["start_time"] != "1989-08-29T00:00:00Z" || f.Properties["availability_end"] != "2021-01-01T00:00:00Z" {
+		t.Errorf("properties = %v", f.Properties)
+	}
+	if ch, _ := f.Properties["channels"].([]any); len(ch) != 2 {
+		t.Errorf("channels = %v, want 2", f.Properties["channels"])
+	}
+	if fc.Features[1].Properties["end_time"] != nil || fc.Features[1].Properties["restricted"] != true {
+		t.Errorf("second feature properties = %v", fc.Features[1].Properties)
+	}
+}
+
+func TestKML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, KML, testStations()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var doc kmlDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if len(doc.Document.Folders) != 2 || doc.Document.Folders[0].Name != "IU" {
+		t.Fatalf("folders = %+v, want one per network", doc.Document.Folders)
+	}
+	pm := doc.Document.Folders[0].Placemarks[0]
+	if pm.Name != "IU.ANMO" || pm.Point.Coordinates != "-106.4572,34.9459,1820" {
+		t.Errorf("placemark = %s at %s", pm.Name, pm.Point.Coordinates)
+	}
+	if pm.TimeSpan == nil || pm.TimeSpan.Begin != "1989-08-29T00:00:00Z" || pm.TimeSpan.End != "" {
+		t.Errorf("time span = %+v", pm.TimeSpan)
+	}
+	if doc.Document.Folders[1].Placemarks[0].TimeSpan != nil {
+		t.Error("expected no time span without epochs")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, testStations()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want header and 2", len(rows))
+	}
+	if strings.Join(rows[0][:4], ",") != "network,station,latitude,longitude" {
+		t.Errorf("header = %v", rows[0])
+	}
+	// The site name contains a comma and must be quoted, not split
+	if len(rows[1]) != len(rows[0]) || rows[1][5] != "Albuquerque, New Mexico" || rows[1][8] != "00.BHZ 10.BHZ" {
+		t.Errorf("row = %v", rows[1])
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if Supported("shp") {
+		t.Error("shp should not be supported")
+	}
+	if err := Write(&bytes.Buffer{}, "shp", nil); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,379 @@
+// Package extract builds waveform bundles for events. An extraction picks
+// the stored stations within a distance range of an event, makes one
+// request per matching channel with a window set by the predicted P and S
+// arrivals or by fixed offsets from the origin time, fetches the miniSEED
+// through the waveform cache and packages it as a zip with the stations'
+// StationXML.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/geodesy"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/traveltime"
+	"github.com/joescharf/fdsn/internal/wavecache"
+)
+
+// Window modes.
+const (
+	// WindowPhases windows each channel from Before seconds ahead of the
+	// predicted first P arrival to After seconds past the first S.
+	WindowPhases = "phases"
+	// WindowOffsets windows every channel from Before seconds ahead of the
+	// origin time to After seconds past it.
+	WindowOffsets = "offsets"
+)
+
+// Parameter defaults.
+const (
+	DefaultChannel   = "BH?"
+	DefaultMaxRadius = 10.0
+	DefaultBefore    = 60.0
+	DefaultAfter     = 600.0
+)
+
+// MaxStations is the most stations one extraction may fetch.
+const MaxStations = 500
+
+// concurrency is the number of requests fetched at once.
+const concurrency = 4
+
+var (
+	// ErrTooManyStations is returned when more than MaxStations stations
+	// match an extraction.
+	ErrTooManyStations = errors.New("too many stations")
+	// ErrNoStations is returned when no stored station matches an
+	// extraction.
+	ErrNoStations = errors.New("no matching stations")
+	// ErrRunning is returned when deleting an extraction that has not
+	// finished.
+	ErrRunning = errors.New("extraction is still running")
+	// ErrNotDone is returned for the bundle of an unfinished or failed
+	// extraction.
+	ErrNotDone = errors.New("extraction has no bundle")
+)
+
+// Params selects the stations and windows of an extraction. Radii are
+// degrees and offsets seconds; Channel is a comma-separated list of channel
+// code patterns with * and ? wildcards.
+type Params struct {
+	MinRadius float64
+	MaxRadius float64
+	Channel   string
+	Window    string
+	Before    float64
+	After     float64
+}
+
+// DefaultParams returns the parameters of an extraction when none are
+// given: BH? channels within 10° windowed on the P and S arrivals.
+func DefaultParams() Params {
+	return Params{
+		MaxRadius: DefaultMaxRadius,
+		Channel:   DefaultChannel,
+		Window:    WindowPhases,
+		Before:    DefaultBefore,
+		After:     DefaultAfter,
+	}
+}
+
+// Validate checks p.
+func (p Params) Validate() error {
+	switch {
+	case p.Window != WindowPhases && p.Window != WindowOffsets:
+		return fmt.Errorf("window must be %s or %s", WindowPhases, WindowOffsets)
+	case p.MinRadius < 0 || p.MaxRadius <= 0 || p.MaxRadius > 180 || p.MinRadius >= p.MaxRadius:
+		return fmt.Errorf("radii must satisfy 0 <= minradius < maxradius <= 180")
+	case p.Before < 0 || p.After < 0:
+		return fmt.Errorf("before and after must not be negative")
+	case p.Window == WindowOffsets && p.Before+p.After == 0:
+		return fmt.Errorf("before and after must not both be zero")
+	case strings.TrimSpace(p.Channel) == "":
+		return fmt.Errorf("channel is required")
+	}
+	for _, c := range splitCodes(p.Channel) {
+		if _, err := path.Match(c, ""); err != nil {
+			return fmt.Errorf("invalid channel pattern %q", c)
+		}
+	}
+	return nil
+}
+
+// Extractor plans, runs and keeps event waveform extractions. Bundles are
+// written to its directory as extraction-<id>.zip.
+type Extractor struct {
+	db            *database.DB
+	events        store.EventStore
+	stations      store.StationStore
+	sources       store.SourceStore
+	creds         store.CredentialStore
+	clients       *fdsnclient.Pool
+	extractions   store.ExtractionStore
+	cache         *wavecache.Cache
+	dir           string
+	schemaVersion string
+
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+// New returns an Extractor writing bundles to dir. creds authenticates
+// upstream requests, cache (which may be nil) serves them where it can and
+// schemaVersion is the StationXML version of the bundles.
+func New(db *database.DB, creds store.CredentialStore, cache *wavecache.Cache, dir, schemaVersion string) *Extractor {
+	return &Extractor{
+		db:            db,
+		events:        store.NewEventStore(db),
+		stations:      store.NewStationStore(db),
+		sources:       store.NewSourceStore(db),
+		creds:         creds,
+		clients:       fdsnclient.NewPool(),
+		extractions:   store.NewExtractionStore(db),
+		cache:         cache,
+		dir:           dir,
+		schemaVersion: schemaVersion,
+		running:       map[int64]bool{},
+	}
+}
+
+// Recover fails the extractions a previous process left unfinished and
+// removes bundles whose extraction is gone, e.g. with its event.
+func (e *Extractor) Recover() error {
+	n, err := e.extractions.FailInterrupted()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Warn().Int64("count", n).Msg("failed extractions interrupted by a restart")
+	}
+	bundles, err := filepath.Glob(filepath.Join(e.dir, "extraction-*.zip"))
+	if err != nil {
+		return err
+	}
+	for _, b := range bundles {
+		var id int64
+		if _, err := fmt.Sscanf(filepath.Base(b), "extraction-%d.zip", &id); err != nil {
+			continue
+		}
+		if _, err := e.extractions.Get(id); errors.Is(err, store.ErrExtractionNotFound) {
+			if err := os.Remove(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BundlePath returns where the bundle of extraction id is written.
+func (e *Extractor) BundlePath(id int64) string {
+	return filepath.Join(e.dir, fmt.Sprintf("extraction-%d.zip", id))
+}
+
+// Bundle returns the bundle path of a finished extraction.
+func (e *Extractor) Bundle(id int64) (string, *models.Extraction, error) {
+	x, err := e.extractions.Get(id)
+	if err != nil {
+		return "", nil, err
+	}
+	if x.Status != models.ExtractionDone {
+		return "", x, ErrNotDone
+	}
+	return e.BundlePath(id), x, nil
+}
+
+// Plan returns the extraction p makes for an event, with its requests,
+// without storing it. Stations of disabled sources are skipped.
+func (e *Extractor) Plan(eventID int64, p Params) (*models.Extraction, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	ev, err := e.events.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := e.sources.List()
+	if err != nil {
+		return nil, err
+	}
+	enabled := map[int64]bool{}
+	for _, s := range sources {
+		enabled[s.ID] = s.Enabled
+	}
+
+	// A single pattern narrows the station search; lists are matched below
+	channel := ""
+	if patterns := splitCodes(p.Channel); len(patterns) == 1 {
+		channel = patterns[0]
+	}
+	nearby, err := NearbyStations(e.stations, ev, p.MinRadius, p.MaxRadius, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &models.Extraction{
+		EventID:          ev.ID,
+		Window:           p.Window,
+		Before:           p.Before,
+		After:            p.After,
+		Channel:          p.Channel,
+		MinRadius:        p.MinRadius,
+		MaxRadius:        p.MaxRadius,
+		EventDescription: ev.Description,
+		Requests:         []models.ExtractionRequest{},
+	}
+	depth := 0.0
+	if ev.DepthKM != nil {
+		depth = *ev.DepthKM
+	}
+	stations := 0
+	for _, st := range nearby {
+		if !enabled[st.SourceID] {
+			continue
+		}
+		detail, err := e.stations.GetStation(st.ID)
+		if err != nil {
+			return nil, fmt.Errorf("station %s.%s: %w", st.NetworkCode, st.Code, err)
+		}
+		dist := geodesy.Distance(ev.Latitude, ev.Longitude, st.Latitude, st.Longitude)
+		start, end, pTime, sTime := window(ev.Time, dist, depth, p)
+		matched := false
+		for _, ch := range detail.Channels {
+			if !matchCodes(p.Channel, ch.Code) || !activeAt(ch, ev.Time) {
+				continue
+			}
+			matched = true
+			x.Requests = append(x.Requests, models.ExtractionRequest{
+				SourceID:     st.SourceID,
+				NetworkCode:  st.NetworkCode,
+				StationCode:  st.Code,
+				LocationCode: ch.LocationCode,
+				ChannelCode:  ch.Code,
+				DistanceDeg:  dist,
+				PArrival:     pTime,
+				SArrival:     sTime,
+				StartTime:    start,
+				EndTime:      end,
+				Status:       models.RequestPending,
+			})
+		}
+		if matched {
+			stations++
+		}
+	}
+	if stations > MaxStations {
+		return nil, fmt.Errorf("%w: %d stations match, at most %d may be extracted at once; narrow the radius or channels",
+			ErrTooManyStations, stations, MaxStations)
+	}
+	sort.SliceStable(x.Requests, func(i, j int) bool {
+		a, b := x.Requests[i], x.Requests[j]
+		if a.DistanceDeg != b.DistanceDeg {
+			return a.DistanceDeg < b.DistanceDeg
+		}
+		return requestName(a) < requestName(b)
+	})
+	x.RequestCount = len(x.Requests)
+	return x, nil
+}
+
+// window returns the request window at dist degrees from an event at
+// origin and depth km, and the predicted first P and S arrivals in seconds
+// after the origin time. With no predicted S the window ends After seconds
+// past the P arrival.
+func window(origin time.Time, dist, depth float64, p Params) (start, end time.Time, pTime, sTime *float64) {
+	if a, ok := traveltime.FirstP(dist, depth); ok {
+		pTime = &a.Time
+	}
+	if a, ok := traveltime.FirstS(dist, depth); ok {
+		sTime = &a.Time
+	}
+	from, to := 0.0, 0.0
+	if p.Window == WindowPhases {
+		if pTime != nil {
+			from, to = *pTime, *pTime
+		}
+		if sTime != nil {
+			to = *sTime
+		}
+	}
+	start = origin.Add(seconds(from - p.Before))
+	end = origin.Add(seconds(to + p.After))
+	return start, end, pTime, sTime
+}
+
+// NearbyStations pages through the stations within minRadius..maxRadius
+// degrees of an event that operated at its origin time, with a channel
+// matching the channel pattern if one is given.
+func NearbyStations(stations store.StationStore, ev *models.Event, minRadius, maxRadius float64, channel string) ([]models.Station, error) {
+	q := store.StationQuery{
+		Channel: channel,
+		GeoFilter: store.GeoFilter{
+			Latitude:  &ev.Latitude,
+			Longitude: &ev.Longitude,
+			MaxRadius: &maxRadius,
+		},
+		ActiveAt: &ev.Time,
+		Limit:    store.MaxStationLimit,
+	}
+	if minRadius > 0 {
+		q.MinRadius = &minRadius
+	}
+	var result []models.Station
+	for {
+		page, err := stations.ListStations(q)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page.Stations...)
+		if page.NextCursor == "" {
+			return result, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func activeAt(ch models.Channel, t time.Time) bool {
+	return (ch.StartTime == nil || !ch.StartTime.After(t)) && (ch.EndTime == nil || !ch.EndTime.Before(t))
+}
+
+// matchCodes matches code against a comma-separated pattern list.
+func matchCodes(patterns, code string) bool {
+	for _, p := range splitCodes(patterns) {
+		if ok, _ := path.Match(strings.ToUpper(p), strings.ToUpper(code)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCodes(s string) []string {
+	var codes []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+// requestName is the NET.STA.LOC.CHA name of a request's stream.
+func requestName(r models.ExtractionRequest) string {
+	return r.NetworkCode + "." + r.StationCode + "." + r.LocationCode + "." + r.ChannelCode
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
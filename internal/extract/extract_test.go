@@ -0,0 +1,278 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/stationxml"
+	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/traveltime"
+)
+
+var origin = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// setup stores an event at 0°N 0°E and three IU stations: NEAR, 5° east
+// with BHZ, BHN and HHZ channels, FAR, 50° east, and OLD, 3° east, closed
+// before the event. The source's dataselect service is served by handler.
+func setup(t *testing.T, handler http.HandlerFunc) (*Extractor, int64) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	src := &models.Source{Name: "TEST", BaseURL: srv.URL, Enabled: true}
+	if err := store.NewSourceStore(db).Create(src); err != nil {
+		t.Fatal(err)
+	}
+	closed := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ch := func(sta string, lon float64, loc, cha string, end *time.Time) models.ImportChannel {
+		return models.ImportChannel{NetworkCode: "IU", StationCode: sta, Longitude: lon, LocationCode: loc,
+			ChannelCode: cha, ChanLongitude: lon, SampleRate: 40, Scale: 6e8, ScaleFreq: 1, ScaleUnits: "M/S",
+			StationEndTime: end, ChanEndTime: end}
+	}
+	err = store.NewStationStore(db).ImportStations(src.ID, []models.ImportChannel{
+		ch("NEAR", 5, "00", "BHZ", nil),
+		ch("NEAR", 5, "00", "BHN", nil),
+		ch("NEAR", 5, "00", "HHZ", nil),
+		ch("FAR", 50, "", "BHZ", nil),
+		ch("OLD", 3, "", "BHZ", &closed),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := 10.0
+	if _, _, err := store.NewEventStore(db).ImportEvents(src.ID, []models.Event{{
+		PublicID: "quakeml:test/event/1", Description: "TEST REGION", Time: origin, DepthKM: &depth,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	return New(db, store.NewCredentialStore(db, nil), nil, t.TempDir(), ""), 1
+}
+
+// dataselect answers BHZ requests with fake records and anything else with
+// no data.
+func dataselect(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !strings.Contains(string(body), " BHZ ") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	_, _ = w.Write([]byte("records for " + string(body)))
+}
+
+func TestPlan(t *testing.T) {
+	e, eventID := setup(t, dataselect)
+
+	x, err := e.Plan(eventID, DefaultParams())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(x.Requests) != 2 || x.Requests[0].ChannelCode != "BHN" || x.Requests[1].ChannelCode != "BHZ" {
+		t.Fatalf("requests = %+v, want NEAR BHN and BHZ", x.Requests)
+	}
+	r := x.Requests[0]
+	p, _ := traveltime.FirstP(5, 10)
+	s, _ := traveltime.FirstS(5, 10)
+	if math.Abs(r.DistanceDeg-5) > 1e-9 || r.PArrival == nil || *r.PArrival != p.Time || r.SArrival == nil {
+		t.Errorf("request = %+v", r)
+	}
+	wantStart := origin.Add(seconds(p.Time - DefaultBefore))
+	wantEnd := origin.Add(seconds(s.Time + DefaultAfter))
+	if !r.StartTime.Equal(wantStart) || !r.EndTime.Equal(wantEnd) {
+		t.Errorf("window = %s – %s, want %s – %s", r.StartTime, r.EndTime, wantStart, wantEnd)
+	}
+
+	// Offsets, a channel list and a radius reaching FAR
+	x, err = e.Plan(eventID, Params{MaxRadius: 60, Channel: "HHZ, BHZ", Window: WindowOffsets, Before: 30, After: 120})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(x.Requests) != 3 || x.Requests[2].StationCode != "FAR" {
+		t.Fatalf("requests = %+v, want NEAR BHZ and HHZ and FAR BHZ", x.Requests)
+	}
+	if r := x.Requests[2]; !r.StartTime.Equal(origin.Add(-30*time.Second)) || !r.EndTime.Equal(origin.Add(2*time.Minute)) {
+		t.Errorf("offset window = %s – %s", r.StartTime, r.EndTime)
+	}
+
+	if _, err := e.Plan(eventID, Params{MaxRadius: 10, Channel: "BH?", Window: "arrivals"}); err == nil {
+		t.Error("unknown window accepted")
+	}
+	if _, err := e.Plan(99, DefaultParams()); !errors.Is(err, store.ErrEventNotFound) {
+		t.Errorf("Plan for unknown event: %v", err)
+	}
+	if _, err := e.Create(eventID, Params{MaxRadius: 1, Channel: "BH?", Window: WindowPhases}); !errors.Is(err, ErrNoStations) {
+		t.Errorf("Create without stations: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	e, eventID := setup(t, dataselect)
+
+	x, err := e.Create(eventID, DefaultParams())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := e.Delete(x.ID); !errors.Is(err, ErrRunning) {
+		t.Errorf("Delete before running: %v", err)
+	}
+	if err := e.Run(x); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got, err := e.extractions.Get(x.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.ExtractionDone || got.DoneCount != 1 || got.NoDataCount != 1 || got.Bytes == 0 {
+		t.Errorf("extraction = %+v", got)
+	}
+
+	path, _, err := e.Bundle(x.ID)
+	if err != nil {
+		t.Fatalf("Bundle: %v", err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	var names []string
+	var stationXML []byte
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "stationxml/IU.NEAR.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stationXML, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	zr.Close()
+	want := []string{"mseed/IU.NEAR.00.BHZ.mseed", "stationxml/IU.NEAR.xml", "manifest.json"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("bundle = %v, want %v", names, want)
+	}
+
+	// Only the channel with data, with its response
+	problems, err := stationxml.Validate(bytes.NewReader(stationXML))
+	if err != nil || len(problems) > 0 {
+		t.Errorf("bundle StationXML: %v %v", err, problems)
+	}
+	doc := string(stationXML)
+	for _, want := range []string{`<Channel code="BHZ" locationCode="00"`, "<InstrumentSensitivity>", "<Value>6e+08</Value>", "<Name>M/S</Name>"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("bundle StationXML lacks %s:\n%s", want, doc)
+		}
+	}
+	if strings.Contains(doc, `code="BHN"`) || strings.Contains(doc, `code="HHZ"`) {
+		t.Errorf("bundle StationXML has channels without data:\n%s", doc)
+	}
+
+	if err := e.Delete(x.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("bundle left after delete: %v", err)
+	}
+}
+
+func TestRunStationXMLChannels(t *testing.T) {
+	// Data for NEAR 00.HHZ and 10.BHZ only
+	e, eventID := setup(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), " 00 HHZ ") && !strings.Contains(string(body), " 10 BHZ ") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte("records for " + string(body)))
+	})
+	second := func(cha string) models.ImportChannel {
+		return models.ImportChannel{NetworkCode: "IU", StationCode: "NEAR", Longitude: 5, LocationCode: "10",
+			ChannelCode: cha, ChanLongitude: 5, SampleRate: 40}
+	}
+	if err := e.stations.ImportStations(1, []models.ImportChannel{second("BHZ"), second("HHZ")}); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := e.Create(eventID, Params{MaxRadius: 10, Channel: "BHZ,HHZ", Window: WindowOffsets, Before: 30, After: 120})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(x.Requests) != 4 {
+		t.Fatalf("requests = %+v, want NEAR 00 and 10 BHZ and HHZ", x.Requests)
+	}
+	if err := e.Run(x); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	path, _, err := e.Bundle(x.ID)
+	if err != nil {
+		t.Fatalf("Bundle: %v", err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer zr.Close()
+	rc, err := zr.Open("stationxml/IU.NEAR.xml")
+	if err != nil {
+		t.Fatalf("bundle StationXML: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+
+	if problems, err := stationxml.Validate(bytes.NewReader(data)); err != nil || len(problems) > 0 {
+		t.Errorf("bundle StationXML: %v %v", err, problems)
+	}
+	doc := string(data)
+	for _, want := range []string{`<Channel code="HHZ" locationCode="00"`, `<Channel code="BHZ" locationCode="10"`} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("bundle StationXML lacks %s:\n%s", want, doc)
+		}
+	}
+	for _, other := range []string{`<Channel code="BHZ" locationCode="00"`, `<Channel code="HHZ" locationCode="10"`} {
+		if strings.Contains(doc, other) {
+			t.Errorf("bundle StationXML has %s, which was not fetched:\n%s", other, doc)
+		}
+	}
+}
+
+func TestRunFails(t *testing.T) {
+	e, eventID := setup(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	})
+
+	x, err := e.Create(eventID, DefaultParams())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := e.Run(x); err == nil {
+		t.Fatal("Run succeeded with every request failing")
+	}
+	got, _ := e.extractions.Get(x.ID)
+	if got.Status != models.ExtractionFailed || got.FailedCount != 2 || !strings.Contains(got.Error, "every request failed") {
+		t.Errorf("extraction = %+v", got)
+	}
+	if _, _, err := e.Bundle(x.ID); !errors.Is(err, ErrNotDone) {
+		t.Errorf("Bundle of failed extraction: %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/fdsnserver"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// Start plans and stores an extraction for an event and runs it in the
+// background, returning it pending.
+func (e *Extractor) Start(eventID int64, p Params) (*models.Extraction, error) {
+	x, err := e.Create(eventID, p)
+	if err != nil {
+		return nil, err
+	}
+	// The run updates its copy as it goes
+	job := *x
+	job.Requests = slices.Clone(x.Requests)
+	go func() {
+		if err := e.Run(&job); err != nil {
+			log.Error().Err(err).Int64("extraction", job.ID).Msg("extraction failed")
+		}
+	}()
+	return x, nil
+}
+
+// Create plans and stores a pending extraction for an event, to be run
+// with Run.
+func (e *Extractor) Create(eventID int64, p Params) (*models.Extraction, error) {
+	x, err := e.Plan(eventID, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(x.Requests) == 0 {
+		return nil, fmt.Errorf("%w: no %s channels of enabled sources within %g° of the event", ErrNoStations, p.Channel, p.MaxRadius)
+	}
+	if err := e.extractions.Create(x); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.running[x.ID] = true
+	e.mu.Unlock()
+	return x, nil
+}
+
+// Delete removes a finished extraction and its bundle.
+func (e *Extractor) Delete(id int64) error {
+	x, err := e.extractions.Get(id)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	running := e.running[id]
+	e.mu.Unlock()
+	if running || x.Status == models.ExtractionPending || x.Status == models.ExtractionRunning {
+		return ErrRunning
+	}
+	if err := os.Remove(e.BundlePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return e.extractions.Delete(id)
+}
+
+// fdsnTime is the time format of FDSN request parameters.
+const fdsnTime = "2006-01-02T15:04:05.000"
+
+// fetched is the outcome of one request.
+type fetched struct {
+	req  *models.ExtractionRequest
+	data []byte
+}
+
+// Run fetches the requests of a stored extraction and writes its bundle:
+// the miniSEED of each channel as mseed/NET.STA.LOC.CHA.mseed, the
+// StationXML of each station at response level as stationxml/NET.STA.xml
+// and the extraction with the outcome of every request as manifest.json.
+// The extraction fails if no request could be fetched; requests the
+// sources answered without data do not count as failures.
+func (e *Extractor) Run(x *models.Extraction) error {
+	defer func() {
+		e.mu.Lock()
+		delete(e.running, x.ID)
+		e.mu.Unlock()
+	}()
+	x.Status = models.ExtractionRunning
+	if err := e.extractions.SetStatus(x.ID, x.Status, 0, ""); err != nil {
+		return err
+	}
+	size, err := e.run(x)
+	x.Bytes = size
+	x.Status = models.ExtractionDone
+	if err != nil {
+		x.Status, x.Error = models.ExtractionFailed, err.Error()
+		_ = os.Remove(e.BundlePath(x.ID))
+	}
+	if serr := e.extractions.SetStatus(x.ID, x.Status, x.Bytes, x.Error); serr != nil && err == nil {
+		err = serr
+	}
+	log.Info().Int64("extraction", x.ID).Str("status", x.Status).Int("requests", len(x.Requests)).
+		Int64("bytes", x.Bytes).Msg("extraction finished")
+	return err
+}
+
+func (e *Extractor) run(x *models.Extraction) (int64, error) {
+	ev, err := e.events.GetEvent(x.EventID)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return 0, fmt.Errorf("create extraction directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(e.dir, fmt.Sprintf("extraction-%d-*.tmp", x.ID))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	err = e.writeBundle(tmp, ev, x)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmp.Name(), e.BundlePath(x.ID))
+}
+
+// writeBundle fetches the requests of x and writes them to w as a zip with
+// their StationXML and the manifest.
+func (e *Extractor) writeBundle(w io.Writer, ev *models.Event, x *models.Extraction) error {
+	zw := zip.NewWriter(w)
+
+	// Fetch concurrently; the zip is written here as results arrive
+	var werr error
+	for res := range e.fetchAll(x.Requests) {
+		if werr != nil || len(res.data) == 0 {
+			continue
+		}
+		werr = writeEntry(zw, "mseed/"+requestName(*res.req)+".mseed", res.data)
+	}
+	if werr != nil {
+		return werr
+	}
+
+	x.DoneCount, x.NoDataCount, x.FailedCount = 0, 0, 0
+	var firstErr string
+	for i := range x.Requests {
+		r := &x.Requests[i]
+		switch r.Status {
+		case models.RequestDone:
+			x.DoneCount++
+		case models.RequestNoData:
+			x.NoDataCount++
+		case models.RequestFailed:
+			x.FailedCount++
+			if firstErr == "" {
+				firstErr = requestName(*r) + ": " + r.Error
+			}
+		}
+		if err := e.extractions.UpdateRequest(r); err != nil {
+			return err
+		}
+	}
+	if x.FailedCount == len(x.Requests) {
+		return fmt.Errorf("every request failed, e.g. %s", firstErr)
+	}
+
+	if err := e.writeStationXML(zw, x); err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(map[string]any{"event": ev, "extraction": x}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// fetchAll fetches reqs concurrently through the waveform cache, recording
+// each outcome on its request, and sends the results on the returned
+// channel, which is closed when all are done.
+func (e *Extractor) fetchAll(reqs []models.ExtractionRequest) <-chan fetched {
+	clients := map[int64]*fdsnclient.Client{}
+	clientErrs := map[int64]error{}
+	for _, r := range reqs {
+		if _, ok := clients[r.SourceID]; ok || clientErrs[r.SourceID] != nil {
+			continue
+		}
+		src, err := e.sources.Get(r.SourceID)
+		if err != nil {
+			clientErrs[r.SourceID] = fmt.Errorf("source %d: %w", r.SourceID, err)
+			continue
+		}
+		cred, err := e.creds.Get(src.ID)
+		if err != nil {
+			clientErrs[r.SourceID] = err
+			continue
+		}
+		clients[r.SourceID] = e.clients.Get(src, cred)
+	}
+
+	jobs := make(chan *models.ExtractionRequest)
+	results := make(chan fetched)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				data, err := e.fetch(clients[r.SourceID], clientErrs[r.SourceID], r)
+				switch {
+				case err != nil:
+					r.Status, r.Error = models.RequestFailed, err.Error()
+				case len(data) == 0:
+					r.Status = models.RequestNoData
+				default:
+					r.Status, r.Bytes = models.RequestDone, int64(len(data))
+				}
+				results <- fetched{req: r, data: data}
+			}
+		}()
+	}
+	go func() {
+		for i := range reqs {
+			jobs <- &reqs[i]
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (e *Extractor) fetch(client *fdsnclient.Client, clientErr error, r *models.ExtractionRequest) ([]byte, error) {
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	sr := fdsnclient.StreamRequest{
+		Network:   r.NetworkCode,
+		Station:   r.StationCode,
+		Location:  r.LocationCode,
+		Channel:   r.ChannelCode,
+		StartTime: r.StartTime.UTC().Format(fdsnTime),
+		EndTime:   r.EndTime.UTC().Format(fdsnTime),
+	}
+	var buf bytes.Buffer
+	if err := e.cache.Fetch(r.SourceID, []fdsnclient.StreamRequest{sr}, client.FetchMiniSEEDBulk, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeStationXML adds the response-level StationXML of each station with
+// data, limited to the channels with data and the epochs overlapping their
+// windows. A channel's response is its overall sensitivity, when known.
+// The station service selects every combination of the location and channel
+// codes asked for, so channels that were not fetched are dropped after.
+func (e *Extractor) writeStationXML(zw *zip.Writer, x *models.Extraction) error {
+	type selection struct {
+		net, sta   string
+		locs, chas []string
+		fetched    map[string]bool // LOC.CHA
+		start, end time.Time
+	}
+	stations := map[string]*selection{}
+	for _, r := range x.Requests {
+		if r.Status != models.RequestDone {
+			continue
+		}
+		key := r.NetworkCode + "." + r.StationCode
+		sel, ok := stations[key]
+		if !ok {
+			sel = &selection{net: r.NetworkCode, sta: r.StationCode, fetched: map[string]bool{}, start: r.StartTime, end: r.EndTime}
+			stations[key] = sel
+		}
+		sel.fetched[r.LocationCode+"."+r.ChannelCode] = true
+		loc := r.LocationCode
+		if loc == "" {
+			loc = "--"
+		}
+		if !slices.Contains(sel.locs, loc) {
+			sel.locs = append(sel.locs, loc)
+		}
+		if !slices.Contains(sel.chas, r.ChannelCode) {
+			sel.chas = append(sel.chas, r.ChannelCode)
+		}
+		if r.StartTime.Before(sel.start) {
+			sel.start = r.StartTime
+		}
+		if r.EndTime.After(sel.end) {
+			sel.end = r.EndTime
+		}
+	}
+	keys := make([]string, 0, len(stations))
+	for k := range stations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sel := stations[key]
+		q := url.Values{}
+		q.Set("net", sel.net)
+		q.Set("sta", sel.sta)
+		q.Set("loc", strings.Join(sel.locs, ","))
+		q.Set("cha", strings.Join(sel.chas, ","))
+		q.Set("level", "response")
+		q.Set("starttime", sel.start.UTC().Format(fdsnTime))
+		q.Set("endtime", sel.end.UTC().Format(fdsnTime))
+		var buf bytes.Buffer
+		err := fdsnserver.QueryStations(e.db, &buf, q, e.schemaVersion, true)
+		if errors.Is(err, fdsnserver.ErrNoData) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("stationxml for %s: %w", key, err)
+		}
+		doc, err := onlyChannels(buf.Bytes(), sel.fetched)
+		if err != nil {
+			return fmt.Errorf("stationxml for %s: %w", key, err)
+		}
+		if err := writeEntry(zw, "stationxml/"+key+".xml", doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onlyChannels rewrites a StationXML document keeping only the channels
+// whose LOC.CHA is in keep.
+func onlyChannels(doc []byte, keep map[string]bool) ([]byte, error) {
+	var sx models.FDSNStationXML
+	if err := xml.Unmarshal(doc, &sx); err != nil {
+		return nil, err
+	}
+	for i := range sx.Networks {
+		for j := range sx.Networks[i].Stations {
+			sta := &sx.Networks[i].Stations[j]
+			sta.Channels = slices.DeleteFunc(sta.Channels, func(c models.XMLChannel) bool {
+				return !keep[c.LocationCode+"."+c.Code]
+			})
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(sx); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeEntry(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,146 @@
+package fdsnclient
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/joescharf/fdsn/internal/digest"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// eidaAuthPath is where EIDA nodes exchange a token for dataselect credentials.
+const eidaAuthPath = "/fdsnws/dataselect/1/auth"
+
+// WithCredentials configures c from stored source credentials and returns it.
+// A nil cred leaves the client anonymous.
+func (c *Client) WithCredentials(cred *models.SourceCredentials) *Client {
+	if cred != nil {
+		c.Username = cred.Username
+		c.Password = cred.Password
+		c.EIDAToken = cred.EIDAToken
+	}
+	return c
+}
+
+// credentialsFor returns the Digest credentials to use for path. Explicit
+// username/password apply to every service; an EIDA token only yields
+// credentials for dataselect, the only service EIDA protects with it.
+func (c *Client) credentialsFor(path string) (user, pass string, fromToken bool, err error) {
+	if c.Username != "" {
+		return c.Username, c.Password, false, nil
+	}
+	if c.EIDAToken != "" && strings.HasPrefix(path, "/fdsnws/dataselect/") {
+		user, pass, err = c.eidaCredentials()
+		return user, pass, true, err
+	}
+	return "", "", false, nil
+}
+
+// authPath rewrites an FDSN query path to its authenticated method:
+// /query becomes /queryauth and /extent becomes /extentauth.
+func authPath(path string) string {
+	p, query, hasQuery := strings.Cut(path, "?")
+	if !strings.HasSuffix(p, "/query") && !strings.HasSuffix(p, "/extent") {
+		return path
+	}
+	p += "auth"
+	if hasQuery {
+		p += "?" + query
+	}
+	return p
+}
+
+// do sends a request, answering a Digest challenge once when user is set.
+func (c *Client) do(method, url string, body []byte, user, pass string) (*http.Response, error) {
+	req, err := newRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || user == "" {
+		return resp, err
+	}
+	challenge := digest.ParseHeader(resp.Header.Get("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	req, err = newRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization",
+		digest.Authorization(challenge, method, req.URL.RequestURI(), user, pass, 1, newCnonce()))
+	return c.HTTPClient.Do(req)
+}
+
+func newRequest(method, url string, body []byte) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	return req, nil
+}
+
+// eidaCredentials exchanges the EIDA token for temporary dataselect
+// credentials, caching them until resetEIDA is called.
+func (c *Client) eidaCredentials() (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.eidaUser != "" {
+		return c.eidaUser, c.eidaPass, nil
+	}
+
+	url := c.BaseURL + eidaAuthPath
+	req, err := http.NewRequest("POST", url, strings.NewReader(c.EIDAToken))
+	if err != nil {
+		return "", "", fmt.Errorf("POST %s: %w", url, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("POST %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("POST %s: status %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	user, pass, ok := strings.Cut(strings.TrimSpace(string(body)), ":")
+	if !ok || user == "" {
+		return "", "", fmt.Errorf("POST %s: unexpected token exchange response", url)
+	}
+	c.eidaUser, c.eidaPass = user, pass
+	return user, pass, nil
+}
+
+func (c *Client) resetEIDA() {
+	c.mu.Lock()
+	c.eidaUser, c.eidaPass = "", ""
+	c.mu.Unlock()
+}
+
+func newCnonce() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
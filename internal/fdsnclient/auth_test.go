@@ -0,0 +1,133 @@
+package fdsnclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/digest"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// authServer serves dataselect /query anonymously and /queryauth behind Digest
+// for the given user. An EIDA /auth endpoint hands out that same user.
+func authServer(t *testing.T, user, pass, token string) (*httptest.Server, *int) {
+	t.Helper()
+	logins := 0
+	ds := digest.NewServer("FDSN", func(u string) (string, bool) {
+		return digest.HA1(user, "FDSN", pass), u == user
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/dataselect/1/query", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "open")
+	})
+	mux.HandleFunc("/fdsnws/dataselect/1/queryauth", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok, stale := ds.Authenticate(r); !ok {
+			ds.Challenge(w, stale)
+			return
+		}
+		_, _ = io.WriteString(w, "restricted")
+	})
+	mux.HandleFunc("/fdsnws/dataselect/1/auth", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || string(body) != token {
+			http.Error(w, "bad token", http.StatusForbidden)
+			return
+		}
+		logins++
+		_, _ = io.WriteString(w, user+":"+pass)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &logins
+}
+
+func fetch(t *testing.T, c *Client) string {
+	t.Helper()
+	body, err := c.FetchMiniSEED("XX", "STA", "", "HHZ", "2024-01-01", "2024-01-02")
+	if err != nil {
+		t.Fatalf("FetchMiniSEED: %v", err)
+	}
+	defer body.Close()
+	b, _ := io.ReadAll(body)
+	return string(b)
+}
+
+func TestClientDigestQueryAuth(t *testing.T) {
+	srv, _ := authServer(t, "alice", "secret", "")
+
+	if got := fetch(t, New(srv.URL)); got != "open" {
+		t.Errorf("anonymous: got %q, want open", got)
+	}
+
+	c := New(srv.URL)
+	c.Username, c.Password = "alice", "secret"
+	if got := fetch(t, c); got != "restricted" {
+		t.Errorf("authenticated: got %q, want restricted", got)
+	}
+
+	c.Password = "wrong"
+	if _, err := c.FetchMiniSEED("XX", "STA", "", "HHZ", "2024-01-01", "2024-01-02"); err == nil {
+		t.Error("expected wrong password to fail")
+	}
+}
+
+func TestClientEIDAToken(t *testing.T) {
+	srv, logins := authServer(t, "tmp-user", "tmp-pass", "TOKEN")
+
+	c := New(srv.URL)
+	c.EIDAToken = "TOKEN"
+	for i := 0; i < 2; i++ {
+		if got := fetch(t, c); got != "restricted" {
+			t.Errorf("fetch %d: got %q, want restricted", i, got)
+		}
+	}
+	if *logins != 1 {
+		t.Errorf("token exchanged %d times, want 1", *logins)
+	}
+
+	c = New(srv.URL)
+	c.EIDAToken = "BAD"
+	if _, err := c.FetchMiniSEED("XX", "STA", "", "HHZ", "2024-01-01", "2024-01-02"); err == nil {
+		t.Error("expected rejected token to fail")
+	}
+}
+
+func TestAuthPath(t *testing.T) {
+	tests := map[string]string{
+		"/fdsnws/station/1/query?net=IU":       "/fdsnws/station/1/queryauth?net=IU",
+		"/fdsnws/availability/1/extent?net=IU": "/fdsnws/availability/1/extentauth?net=IU",
+		"/fdsnws/dataselect/1/query":           "/fdsnws/dataselect/1/queryauth",
+		"/fdsnws/station/1/version":            "/fdsnws/station/1/version",
+	}
+	for in, want := range tests {
+		if got := authPath(in); got != want {
+			t.Errorf("authPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPoolReusesEIDALogin(t *testing.T) {
+	srv, logins := authServer(t, "tmp-user", "tmp-pass", "TOKEN")
+	p := NewPool()
+	src := &models.Source{ID: 1, Name: "EIDA", BaseURL: srv.URL}
+	cred := &models.SourceCredentials{EIDAToken: "TOKEN"}
+
+	for i := 0; i < 2; i++ {
+		if got := fetch(t, p.Get(src, cred)); got != "restricted" {
+			t.Errorf("fetch %d: got %q, want restricted", i, got)
+		}
+	}
+	if *logins != 1 {
+		t.Errorf("token exchanged %d times, want 1", *logins)
+	}
+
+	// Changed credentials replace the client
+	if p.Get(src, nil) == p.Get(src, cred) {
+		t.Error("expected a new client after the credentials changed")
+	}
+	if got := fetch(t, p.Get(src, nil)); got != "open" {
+		t.Errorf("anonymous: got %q, want open", got)
+	}
+}
@@ -5,13 +5,33 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/joescharf/fdsn/internal/metrics"
 )
 
 // Client talks to an external FDSN web-service endpoint.
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
+
+	// Source names the source in upstream metrics; the host of BaseURL is
+	// used when it is empty.
+	Source string
+
+	// Username and Password, when set, are sent with HTTP Digest and queries
+	// are made against the authenticated /queryauth (or /extentauth) methods.
+	Username string
+	Password string
+
+	// EIDAToken holds the contents of an EIDA authentication token file. It
+	// is exchanged for temporary dataselect credentials on first use.
+	EIDAToken string
+
+	mu       sync.Mutex
+	eidaUser string
+	eidaPass string
 }
 
 // New creates an FDSN client for the given base URL.
@@ -24,35 +44,90 @@ func New(baseURL string) *Client {
 	}
 }
 
+// WithSource sets the source name c reports in metrics and returns c.
+func (c *Client) WithSource(name string) *Client {
+	c.Source = name
+	return c
+}
+
 // userAgent identifies this client to external FDSN services.
 const userAgent = "FDSN-Client/1.0"
 
 // get performs a GET request and returns the response body.
 // The caller is responsible for closing the returned ReadCloser.
+// Queries are routed to the authenticated method when credentials apply.
 func (c *Client) get(path string) (io.ReadCloser, error) {
-	url := c.BaseURL + path
-	req, err := http.NewRequest("GET", url, nil)
+	return c.request("GET", path, nil)
+}
+
+// post performs a POST request with a plain-text body, as used by the FDSN
+// bulk request format, and returns the response body like get.
+func (c *Client) post(path string, body []byte) (io.ReadCloser, error) {
+	return c.request("POST", path, body)
+}
+
+func (c *Client) request(method, path string, body []byte) (io.ReadCloser, error) {
+	user, pass, fromToken, err := c.credentialsFor(path)
 	if err != nil {
-		return nil, fmt.Errorf("GET %s: %w", url, err)
+		return nil, err
+	}
+	if user != "" {
+		path = authPath(path)
 	}
-	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := c.HTTPClient.Do(req)
+	url := c.BaseURL + path
+	start := time.Now()
+	resp, err := c.do(method, url, body, user, pass)
+	// A 404 is how services say they are not offered or, with nodata=404,
+	// that there is no data; neither is a failure of the source
+	failed := err != nil || (resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound)
+	metrics.ObserveUpstream(c.sourceName(), service(path), time.Since(start), failed)
 	if err != nil {
-		return nil, fmt.Errorf("GET %s: %w", url, err)
+		return nil, fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && fromToken {
+		// Temporary EIDA credentials expired; fetch new ones next time.
+		c.resetEIDA()
 	}
 	if resp.StatusCode == http.StatusNoContent {
 		resp.Body.Close()
 		return nil, nil // no data
 	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		msg, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("GET %s: status %d: %s", url, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%s %s: status %d: %s", method, url, resp.StatusCode, string(msg))
 	}
 	return resp.Body, nil
 }
 
+// sourceName is the source label of c's metrics.
+func (c *Client) sourceName() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	_, host, found := strings.Cut(c.BaseURL, "://")
+	if !found {
+		return "unknown"
+	}
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+// service returns the FDSN service a request path addresses, e.g. station
+// for /fdsnws/station/1/query, or the first path element of other paths.
+func service(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 1 && (parts[0] == "fdsnws" || parts[0] == "eidaws") {
+		return parts[1]
+	}
+	if parts[0] == "" {
+		return "other"
+	}
+	return parts[0]
+}
+
 // IsNotSupported returns true if the error indicates the service is not available (404 or 501).
 func IsNotSupported(err error) bool {
 	if err == nil {
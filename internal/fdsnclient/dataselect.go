@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 )
 
 // FetchMiniSEED fetches raw miniSEED data from the external FDSN dataselect endpoint.
@@ -26,3 +27,38 @@ func (c *Client) FetchMiniSEED(network, station, location, channel, starttime, e
 	}
 	return body, nil
 }
+
+// StreamRequest selects one stream and time window in an FDSN bulk (POST)
+// request. Wildcards are allowed in the codes; an empty location is sent as "--".
+type StreamRequest struct {
+	Network   string `json:"net"`
+	Station   string `json:"sta"`
+	Location  string `json:"loc"`
+	Channel   string `json:"cha"`
+	StartTime string `json:"start"`
+	EndTime   string `json:"end"`
+}
+
+// Line formats r as a line of an FDSN POST request body.
+func (r StreamRequest) Line() string {
+	loc := r.Location
+	if loc == "" {
+		loc = "--"
+	}
+	return strings.Join([]string{r.Network, r.Station, loc, r.Channel, r.StartTime, r.EndTime}, " ")
+}
+
+// FetchMiniSEEDBulk requests many streams in one dataselect POST request.
+// Returns nil (and no error) when the source has no matching data.
+func (c *Client) FetchMiniSEEDBulk(reqs []StreamRequest) (io.ReadCloser, error) {
+	var b strings.Builder
+	for _, r := range reqs {
+		b.WriteString(r.Line())
+		b.WriteByte('\n')
+	}
+	body, err := c.post("/fdsnws/dataselect/1/query", []byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("fetch miniSEED: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,50 @@
+package fdsnclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/digest"
+)
+
+func TestFetchMiniSEEDBulk(t *testing.T) {
+	ds := digest.NewServer("FDSN", func(u string) (string, bool) {
+		return digest.HA1("alice", "FDSN", "secret"), u == "alice"
+	})
+	var got string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/dataselect/1/queryauth", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok, stale := ds.Authenticate(r); !ok {
+			ds.Challenge(w, stale)
+			return
+		}
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		_, _ = io.WriteString(w, "mseed")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.Username, c.Password = "alice", "secret"
+	body, err := c.FetchMiniSEEDBulk([]StreamRequest{
+		{Network: "IU", Station: "ANMO", Location: "00", Channel: "BHZ", StartTime: "2024-01-01T00:00:00", EndTime: "2024-01-01T01:00:00"},
+		{Network: "IU", Station: "COLA", Channel: "BH?", StartTime: "2024-01-01T00:00:00", EndTime: "2024-01-01T01:00:00"},
+	})
+	if err != nil {
+		t.Fatalf("FetchMiniSEEDBulk: %v", err)
+	}
+	body.Close()
+
+	// The body must be re-sent intact after the Digest challenge
+	want := "IU ANMO 00 BHZ 2024-01-01T00:00:00 2024-01-01T01:00:00\n" +
+		"IU COLA -- BH? 2024-01-01T00:00:00 2024-01-01T01:00:00\n"
+	if got != want {
+		t.Errorf("POST body = %q, want %q", got, want)
+	}
+}
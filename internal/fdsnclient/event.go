@@ -0,0 +1,327 @@
+package fdsnclient
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// EventQuery holds query parameters for the FDSN event service.
+type EventQuery struct {
+	StartTime    string
+	EndTime      string
+	MinLat       string
+	MaxLat       string
+	MinLon       string
+	MaxLon       string
+	Lat          string
+	Lon          string
+	MinRadius    string
+	MaxRadius    string
+	MinDepth     string
+	MaxDepth     string
+	MinMag       string
+	MaxMag       string
+	MagType      string
+	EventType    string
+	EventID      string
+	Catalog      string
+	Contributor  string
+	UpdatedAfter string
+	Limit        string
+	OrderBy      string
+	Format       string // "xml" (default) or "text"
+
+	// IncludeArrivals asks for picks and arrivals, which only QuakeML
+	// carries.
+	IncludeArrivals bool
+}
+
+// QueryEvents fetches events from an external FDSN event service, as QuakeML
+// unless q.Format is "text". Events without an origin are dropped. Text rows
+// become events with a single origin and magnitude.
+func (c *Client) QueryEvents(q EventQuery) ([]models.Event, error) {
+	body, err := c.get(buildEventPath(q))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	if q.Format == "text" {
+		return parseEventText(body)
+	}
+	doc, err := parseQuakeML(body)
+	if err != nil {
+		return nil, err
+	}
+	return EventsFromQuakeML(doc), nil
+}
+
+func buildEventPath(q EventQuery) string {
+	params := url.Values{}
+	if q.Format == "text" {
+		params.Set("format", "text")
+	}
+	for _, p := range []struct{ name, value string }{
+		{"starttime", q.StartTime},
+		{"endtime", q.EndTime},
+		{"minlat", q.MinLat},
+		{"maxlat", q.MaxLat},
+		{"minlon", q.MinLon},
+		{"maxlon", q.MaxLon},
+		{"lat", q.Lat},
+		{"lon", q.Lon},
+		{"minradius", q.MinRadius},
+		{"maxradius", q.MaxRadius},
+		{"mindepth", q.MinDepth},
+		{"maxdepth", q.MaxDepth},
+		{"minmag", q.MinMag},
+		{"maxmag", q.MaxMag},
+		{"magtype", q.MagType},
+		{"eventtype", q.EventType},
+		{"eventid", q.EventID},
+		{"catalog", q.Catalog},
+		{"contributor", q.Contributor},
+		{"updatedafter", q.UpdatedAfter},
+		{"limit", q.Limit},
+		{"orderby", q.OrderBy},
+	} {
+		if p.value != "" {
+			params.Set(p.name, p.value)
+		}
+	}
+	if q.IncludeArrivals && q.Format != "text" {
+		params.Set("includearrivals", "true")
+	}
+	return "/fdsnws/event/1/query?" + params.Encode()
+}
+
+// parseQuakeML decodes the eventParameters of a QuakeML document. The root
+// element is matched by name only, since services differ in the namespace
+// prefixes they declare.
+func parseQuakeML(r io.Reader) (*models.QuakeML, error) {
+	dec := xml.NewDecoder(r)
+	var doc models.QuakeML
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("decode QuakeML: no eventParameters element")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode QuakeML: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "eventParameters" {
+			continue
+		}
+		if err := dec.DecodeElement(&doc.EventParameters, &se); err != nil {
+			return nil, fmt.Errorf("decode QuakeML: %w", err)
+		}
+		return &doc, nil
+	}
+}
+
+// EventsFromQuakeML converts the events of a QuakeML document, filling each
+// event's summary fields from its preferred origin and magnitude, or the first
+// of each when none is preferred. Events without an origin are dropped.
+func EventsFromQuakeML(doc *models.QuakeML) []models.Event {
+	var events []models.Event
+	for _, qe := range doc.EventParameters.Events {
+		if len(qe.Origins) == 0 {
+			continue
+		}
+		ev := models.Event{
+			PublicID:             qe.PublicID,
+			Type:                 qe.Type,
+			PreferredOriginID:    qe.PreferredOriginID,
+			PreferredMagnitudeID: qe.PreferredMagnitudeID,
+			Catalog:              qe.Catalog,
+			Contributor:          qe.Contributor,
+			ContributorID:        qe.ContributorID,
+		}
+		for _, d := range qe.Descriptions {
+			if ev.Description == "" || d.Type == "region name" {
+				ev.Description = strings.TrimSpace(d.Text)
+			}
+		}
+		if qe.CreationInfo != nil {
+			ev.Author = qe.CreationInfo.Author
+		}
+
+		for _, qo := range qe.Origins {
+			ev.Origins = append(ev.Origins, originFromQuakeML(qo))
+		}
+		for _, qm := range qe.Magnitudes {
+			m := models.Magnitude{
+				PublicID:     qm.PublicID,
+				Mag:          qm.Mag.Value,
+				Type:         qm.Type,
+				OriginID:     qm.OriginID,
+				StationCount: qm.StationCount,
+			}
+			if ci := qm.CreationInfo; ci != nil {
+				m.Author, m.AgencyID = ci.Author, ci.AgencyID
+			}
+			ev.Magnitudes = append(ev.Magnitudes, m)
+		}
+		for _, qp := range qe.Picks {
+			t := parseTime(qp.Time.Value)
+			if t == nil {
+				continue
+			}
+			ev.Picks = append(ev.Picks, models.Pick{
+				PublicID:       qp.PublicID,
+				Time:           *t,
+				NetworkCode:    qp.WaveformID.NetworkCode,
+				StationCode:    qp.WaveformID.StationCode,
+				LocationCode:   qp.WaveformID.LocationCode,
+				ChannelCode:    qp.WaveformID.ChannelCode,
+				PhaseHint:      qp.PhaseHint,
+				Polarity:       qp.Polarity,
+				Onset:          qp.Onset,
+				EvaluationMode: qp.EvaluationMode,
+			})
+		}
+
+		o := ev.Origins[0]
+		for _, cand := range ev.Origins {
+			if cand.PublicID == qe.PreferredOriginID {
+				o = cand
+			}
+		}
+		ev.Time, ev.Latitude, ev.Longitude, ev.DepthKM = o.Time, o.Latitude, o.Longitude, o.DepthKM
+		if ev.Author == "" {
+			ev.Author = o.Author
+		}
+		if len(ev.Magnitudes) > 0 {
+			m := ev.Magnitudes[0]
+			for _, cand := range ev.Magnitudes {
+				if cand.PublicID == qe.PreferredMagnitudeID {
+					m = cand
+				}
+			}
+			mag := m.Mag
+			ev.Magnitude, ev.MagnitudeType, ev.MagnitudeAuthor = &mag, m.Type, m.Author
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func originFromQuakeML(qo models.QMLOrigin) models.Origin {
+	o := models.Origin{
+		PublicID:         qo.PublicID,
+		Latitude:         qo.Latitude.Value,
+		Longitude:        qo.Longitude.Value,
+		DepthType:        qo.DepthType,
+		MethodID:         qo.MethodID,
+		EarthModelID:     qo.EarthModelID,
+		EvaluationMode:   qo.EvaluationMode,
+		EvaluationStatus: qo.EvaluationStatus,
+	}
+	if t := parseTime(qo.Time.Value); t != nil {
+		o.Time = *t
+	}
+	if qo.Depth != nil {
+		km := qo.Depth.Value / 1000
+		o.DepthKM = &km
+	}
+	if ci := qo.CreationInfo; ci != nil {
+		o.Author, o.AgencyID = ci.Author, ci.AgencyID
+	}
+	for _, qa := range qo.Arrivals {
+		o.Arrivals = append(o.Arrivals, models.Arrival{
+			PublicID:     qa.PublicID,
+			PickID:       qa.PickID,
+			Phase:        qa.Phase,
+			Distance:     qa.Distance,
+			Azimuth:      qa.Azimuth,
+			TimeResidual: qa.TimeResidual,
+			TimeWeight:   qa.TimeWeight,
+		})
+	}
+	return o
+}
+
+// parseEventText parses the FDSN event text format:
+// EventID|Time|Latitude|Longitude|Depth/km|Author|Catalog|Contributor|ContributorID|MagType|Magnitude|MagAuthor|EventLocationName|EventType
+// EventType is missing from older services. Each row's origin and magnitude
+// get publicIDs derived from the EventID.
+func parseEventText(r io.Reader) ([]models.Event, error) {
+	var events []models.Event
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 13 {
+			continue
+		}
+		t := parseTime(fields[1])
+		if fields[0] == "" || t == nil {
+			continue
+		}
+		lat, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			continue
+		}
+		lon, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			continue
+		}
+		ev := models.Event{
+			PublicID:          fields[0],
+			PreferredOriginID: fields[0] + "/origin",
+			Time:              *t,
+			Latitude:          lat,
+			Longitude:         lon,
+			Author:            fields[5],
+			Catalog:           fields[6],
+			Contributor:       fields[7],
+			ContributorID:     fields[8],
+			MagnitudeType:     fields[9],
+			MagnitudeAuthor:   fields[11],
+			Description:       fields[12],
+		}
+		if len(fields) > 13 {
+			ev.Type = fields[13]
+		}
+		if d, err := strconv.ParseFloat(fields[4], 64); err == nil {
+			ev.DepthKM = &d
+		}
+		ev.Origins = []models.Origin{{
+			PublicID:  ev.PreferredOriginID,
+			Time:      ev.Time,
+			Latitude:  lat,
+			Longitude: lon,
+			DepthKM:   ev.DepthKM,
+			Author:    ev.Author,
+		}}
+		if m, err := strconv.ParseFloat(fields[10], 64); err == nil {
+			ev.Magnitude = &m
+			ev.PreferredMagnitudeID = fields[0] + "/magnitude"
+			ev.Magnitudes = []models.Magnitude{{
+				PublicID: ev.PreferredMagnitudeID,
+				Mag:      m,
+				Type:     ev.MagnitudeType,
+				OriginID: ev.PreferredOriginID,
+				Author:   ev.MagnitudeAuthor,
+			}}
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,148 @@
+package fdsnclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testQuakeML = `<?xml version="1.0" encoding="UTF-8"?>
+<q:quakeml xmlns:q="http://quakeml.org/xmlns/quakeml/1.2" xmlns="http://quakeml.org/xmlns/bed/1.2" xmlns:catalog="http://anss.org/xmlns/catalog/0.1">
+  <eventParameters publicID="quakeml:test/eventparameters">
+    <event publicID="quakeml:test/event/1" catalog:datasource="us" catalog:eventsource="us" catalog:dataid="us1000abcd">
+      <description><type>earthquake name</type><text>Somewhere</text></description>
+      <description><type>region name</type><text>FIJI ISLANDS REGION</text></description>
+      <origin publicID="quakeml:test/origin/a">
+        <time><value>2024-03-01T10:00:00.500Z</value></time>
+        <latitude><value>-18</value></latitude>
+        <longitude><value>178</value></longitude>
+        <depth><value>600000</value></depth>
+      </origin>
+      <origin publicID="quakeml:test/origin/b">
+        <time><value>2024-03-01T10:00:01Z</value></time>
+        <latitude><value>-18.5</value></latitude>
+        <longitude><value>178.5</value></longitude>
+        <depth><value>580000</value></depth>
+        <arrival publicID="quakeml:test/arrival/1">
+          <pickID>quakeml:test/pick/1</pickID>
+          <phase>P</phase>
+          <distance>3.2</distance>
+        </arrival>
+        <creationInfo><agencyID>US</agencyID><author>us</author></creationInfo>
+      </origin>
+      <magnitude publicID="quakeml:test/magnitude/1">
+        <mag><value>6.1</value></mag>
+        <type>mww</type>
+        <originID>quakeml:test/origin/b</originID>
+        <stationCount>40</stationCount>
+      </magnitude>
+      <pick publicID="quakeml:test/pick/1">
+        <time><value>2024-03-01T10:01:00Z</value></time>
+        <waveformID networkCode="FJ" stationCode="MSVF" locationCode="00" channelCode="BHZ"/>
+        <phaseHint>P</phaseHint>
+      </pick>
+      <preferredOriginID>quakeml:test/origin/b</preferredOriginID>
+      <preferredMagnitudeID>quakeml:test/magnitude/1</preferredMagnitudeID>
+      <type>earthquake</type>
+    </event>
+    <event publicID="quakeml:test/event/no-origin"><type>not existing</type></event>
+  </eventParameters>
+</q:quakeml>`
+
+func TestQueryEventsQuakeML(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.String()
+		w.Write([]byte(testQuakeML))
+	}))
+	defer srv.Close()
+
+	events, err := New(srv.URL).QueryEvents(EventQuery{MinMag: "6", IncludeArrivals: true})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if !strings.HasPrefix(query, "/fdsnws/event/1/query?") || !strings.Contains(query, "minmag=6") || !strings.Contains(query, "includearrivals=true") {
+		t.Errorf("query = %s", query)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.PublicID != "quakeml:test/event/1" || ev.Type != "earthquake" || ev.Description != "FIJI ISLANDS REGION" {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.Catalog != "us" || ev.Contributor != "us" || ev.ContributorID != "us1000abcd" {
+		t.Errorf("catalog fields = %q %q %q", ev.Catalog, ev.Contributor, ev.ContributorID)
+	}
+	// Summary fields come from the preferred origin, not the first.
+	if ev.Latitude != -18.5 || ev.DepthKM == nil || *ev.DepthKM != 580 || ev.Author != "us" {
+		t.Errorf("preferred origin not used: lat %v depth %v author %q", ev.Latitude, ev.DepthKM, ev.Author)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC); !ev.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", ev.Time, want)
+	}
+	if ev.Magnitude == nil || *ev.Magnitude != 6.1 || ev.MagnitudeType != "mww" {
+		t.Errorf("magnitude = %v %q", ev.Magnitude, ev.MagnitudeType)
+	}
+	if len(ev.Origins) != 2 || len(ev.Origins[1].Arrivals) != 1 || ev.Origins[1].Arrivals[0].PickID != "quakeml:test/pick/1" {
+		t.Errorf("origins = %+v", ev.Origins)
+	}
+	if len(ev.Magnitudes) != 1 || ev.Magnitudes[0].StationCount == nil || *ev.Magnitudes[0].StationCount != 40 {
+		t.Errorf("magnitudes = %+v", ev.Magnitudes)
+	}
+	if len(ev.Picks) != 1 || ev.Picks[0].StationCode != "MSVF" || ev.Picks[0].LocationCode != "00" {
+		t.Errorf("picks = %+v", ev.Picks)
+	}
+}
+
+func TestQueryEventsText(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "text" {
+			t.Errorf("format = %q", r.URL.Query().Get("format"))
+		}
+		w.Write([]byte(`#EventID | Time | Latitude | Longitude | Depth/km | Author | Catalog | Contributor | ContributorID | MagType | Magnitude | MagAuthor | EventLocationName | EventType
+us1000abcd|2024-03-01T10:00:01.000|-18.5|178.5|580.0|us|us|us|us1000abcd|mww|6.1|us|FIJI ISLANDS REGION|earthquake
+old1|2001-01-01T00:00:00|10|20|||ISC|ISC|old1||||SOMEWHERE
+`))
+	}))
+	defer srv.Close()
+
+	events, err := New(srv.URL).QueryEvents(EventQuery{Format: "text"})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	ev := events[0]
+	if ev.PublicID != "us1000abcd" || ev.Type != "earthquake" || ev.Magnitude == nil || *ev.Magnitude != 6.1 || *ev.DepthKM != 580 {
+		t.Errorf("event = %+v", ev)
+	}
+	if len(ev.Origins) != 1 || ev.Origins[0].PublicID != ev.PreferredOriginID {
+		t.Errorf("origins = %+v", ev.Origins)
+	}
+	if len(ev.Magnitudes) != 1 || ev.Magnitudes[0].PublicID != ev.PreferredMagnitudeID || ev.Magnitudes[0].OriginID != ev.PreferredOriginID {
+		t.Errorf("magnitudes = %+v", ev.Magnitudes)
+	}
+
+	// The 13-column format has no EventType; a blank depth and magnitude
+	// stay unset.
+	old := events[1]
+	if old.Type != "" || old.DepthKM != nil || old.Magnitude != nil || len(old.Magnitudes) != 0 || old.Description != "SOMEWHERE" {
+		t.Errorf("old event = %+v", old)
+	}
+}
+
+func TestQueryEventsNoData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	events, err := New(srv.URL).QueryEvents(EventQuery{})
+	if err != nil || events != nil {
+		t.Fatalf("QueryEvents = %v, %v; want nil, nil", events, err)
+	}
+}
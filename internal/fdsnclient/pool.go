@@ -0,0 +1,39 @@
+package fdsnclient
+
+import (
+	"sync"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// Pool keeps one Client per source, so that credentials exchanged for an
+// EIDA token are reused across requests. A source's client is replaced when
+// its URL, name or stored credentials change.
+type Pool struct {
+	mu      sync.Mutex
+	clients map[int64]*Client
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{clients: map[int64]*Client{}}
+}
+
+// Get returns the client of src configured with cred, which may be nil.
+func (p *Pool) Get(src *models.Source, cred *models.SourceCredentials) *Client {
+	var user, pass, token string
+	if cred != nil {
+		user, pass, token = cred.Username, cred.Password, cred.EIDAToken
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.clients[src.ID]
+	if ok && c.BaseURL == src.BaseURL && c.Source == src.Name &&
+		c.Username == user && c.Password == pass && c.EIDAToken == token {
+		return c
+	}
+	c = New(src.BaseURL).WithSource(src.Name).WithCredentials(cred)
+	p.clients[src.ID] = c
+	return c
+}
@@ -0,0 +1,108 @@
+package fdsnclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Services lists the FDSN web services a source is probed for.
+var Services = []string{"station", "dataselect", "availability"}
+
+// ServiceInfo describes one FDSN web service offered by a data centre.
+type ServiceInfo struct {
+	Service    string
+	Version    string
+	Parameters []string
+	Latency    time.Duration // round trip of the /version request
+}
+
+// ProbeService calls /version and application.wadl for an FDSN service.
+// A missing service yields an error for which IsNotSupported is true. The
+// WADL is optional: if it cannot be fetched, Parameters is left empty.
+func (c *Client) ProbeService(service string) (*ServiceInfo, error) {
+	base := "/fdsnws/" + service + "/1/"
+
+	start := time.Now()
+	body, err := c.get(base + "version")
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", service, err)
+	}
+	if body == nil {
+		return nil, fmt.Errorf("probe %s: empty version response", service)
+	}
+	version, err := io.ReadAll(io.LimitReader(body, 256))
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", service, err)
+	}
+	info := &ServiceInfo{
+		Service: service,
+		Version: strings.TrimSpace(string(version)),
+		Latency: time.Since(start),
+	}
+
+	if wadl, err := c.get(base + "application.wadl"); err == nil && wadl != nil {
+		info.Parameters, _ = parseWADLParams(wadl)
+		wadl.Close()
+	}
+	return info, nil
+}
+
+type wadlResource struct {
+	Path      string         `xml:"path,attr"`
+	Params    []wadlParam    `xml:"param"`
+	Methods   []wadlMethod   `xml:"method"`
+	Resources []wadlResource `xml:"resource"`
+}
+
+type wadlMethod struct {
+	Params []wadlParam `xml:"request>param"`
+}
+
+type wadlParam struct {
+	Name string `xml:"name,attr"`
+}
+
+// parseWADLParams returns the sorted, de-duplicated parameter names declared
+// anywhere in a WADL document.
+func parseWADLParams(r io.Reader) ([]string, error) {
+	var doc struct {
+		Resources []struct {
+			Resource []wadlResource `xml:"resource"`
+		} `xml:"resources"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse WADL: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var walk func(res []wadlResource)
+	walk = func(res []wadlResource) {
+		for _, rs := range res {
+			params := rs.Params
+			for _, m := range rs.Methods {
+				params = append(params, m.Params...)
+			}
+			for _, p := range params {
+				if p.Name != "" {
+					seen[p.Name] = true
+				}
+			}
+			walk(rs.Resources)
+		}
+	}
+	for _, rs := range doc.Resources {
+		walk(rs.Resource)
+	}
+
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
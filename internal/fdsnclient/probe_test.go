@@ -0,0 +1,63 @@
+package fdsnclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testWADL = `<?xml version="1.0"?>
+<application xmlns="http://wadl.dev.java.net/2009/02">
+  <resources base="/fdsnws/station/1/">
+    <resource path="query">
+      <method name="GET">
+        <request>
+          <param name="net" style="query"/>
+          <param name="starttime" style="query"/>
+        </request>
+      </method>
+      <method name="POST">
+        <request><param name="net" style="query"/></request>
+      </method>
+    </resource>
+    <resource path="version"><method name="GET"/></resource>
+  </resources>
+</application>`
+
+func TestParseWADLParams(t *testing.T) {
+	got, err := parseWADLParams(strings.NewReader(testWADL))
+	if err != nil {
+		t.Fatalf("parseWADLParams: %v", err)
+	}
+	if want := []string{"net", "starttime"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestProbeService(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/station/1/version", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "1.1.0\n")
+	})
+	mux.HandleFunc("/fdsnws/station/1/application.wadl", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, testWADL)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := New(srv.URL)
+	info, err := c.ProbeService("station")
+	if err != nil {
+		t.Fatalf("ProbeService(station): %v", err)
+	}
+	if info.Version != "1.1.0" || len(info.Parameters) != 2 || info.Latency <= 0 {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	if _, err := c.ProbeService("availability"); !IsNotSupported(err) {
+		t.Errorf("ProbeService(availability) error = %v, want not supported", err)
+	}
+}
@@ -51,6 +51,7 @@ type StationQuery struct {
 	Channel   string
 	Location  string
 	Level     string // "network", "station", or "channel"
+	Format    string // "text" (default) or "xml"
 	StartTime string
 	EndTime   string
 	MinLat    string
@@ -90,7 +91,11 @@ func (c *Client) QueryChannels(q StationQuery) ([]ChannelTextRow, error) {
 
 func buildStationPath(q StationQuery, level string) string {
 	params := url.Values{}
-	params.Set("format", "text")
+	if q.Format != "" {
+		params.Set("format", q.Format)
+	} else {
+		params.Set("format", "text")
+	}
 	params.Set("level", level)
 	if q.Network != "" {
 		params.Set("net", q.Network)
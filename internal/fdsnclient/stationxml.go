@@ -0,0 +1,138 @@
+package fdsnclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// QueryStationXML fetches a StationXML document from an external FDSN source.
+// q.Level defaults to "station".
+func (c *Client) QueryStationXML(q StationQuery) (*models.FDSNStationXML, error) {
+	level := q.Level
+	if level == "" {
+		level = "station"
+	}
+	q.Format = "xml"
+	body, err := c.get(buildStationPath(q, level))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return parseStationXML(body)
+}
+
+func parseStationXML(r io.Reader) (*models.FDSNStationXML, error) {
+	var doc models.FDSNStationXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode StationXML: %w", err)
+	}
+	return &doc, nil
+}
+
+// Restrictions records the upstream restrictedStatus of each node in a
+// StationXML document, keyed by "NET", "NET.STA" and "NET.STA.LOC.CHA".
+// A node is restricted if any of its epochs is "closed".
+type Restrictions map[string]bool
+
+// QueryNodeDetails fetches channel-level StationXML and extracts what the
+// text format used for import lacks: the restrictedStatus and the 1.2
+// metadata of every network, station and channel.
+func (c *Client) QueryNodeDetails(q StationQuery) (Restrictions, Metadata, error) {
+	q.Level = "channel"
+	doc, err := c.QueryStationXML(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return RestrictionsFromXML(doc), MetadataFromXML(doc), nil
+}
+
+// RestrictionsFromXML extracts the restriction state of every node in doc.
+// Nodes without a restrictedStatus attribute are omitted.
+func RestrictionsFromXML(doc *models.FDSNStationXML) Restrictions {
+	res := Restrictions{}
+	if doc == nil {
+		return res
+	}
+	set := func(key, status string) {
+		if status == "" {
+			return
+		}
+		res[key] = res[key] || status == models.RestrictedClosed
+	}
+	for _, n := range doc.Networks {
+		set(n.Code, n.Restricted)
+		for _, s := range n.Stations {
+			staKey := n.Code + "." + s.Code
+			set(staKey, s.Restricted)
+			for _, c := range s.Channels {
+				set(staKey+"."+c.LocationCode+"."+c.Code, c.Restricted)
+			}
+		}
+	}
+	return res
+}
+
+// Lookup returns the restriction state for key, or nil if the upstream
+// document did not say.
+func (r Restrictions) Lookup(key string) *bool {
+	v, ok := r[key]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// Metadata records the StationXML metadata of each node in a document,
+// keyed like Restrictions. Nodes with several epochs keep the first epoch
+// that has any.
+type Metadata map[string]models.NodeMetadata
+
+// MetadataFromXML extracts the source identifiers, identifiers and water
+// levels in doc. Nodes with none of them are omitted. It returns nil for a
+// nil doc.
+func MetadataFromXML(doc *models.FDSNStationXML) Metadata {
+	if doc == nil {
+		return nil
+	}
+	m := Metadata{}
+	set := func(key string, nm models.NodeMetadata) {
+		if _, ok := m[key]; ok || (nm.SourceID == "" && len(nm.Identifiers) == 0 && nm.WaterLevel == nil) {
+			return
+		}
+		m[key] = nm
+	}
+	for _, n := range doc.Networks {
+		set(n.Code, models.NodeMetadata{SourceID: n.SourceID, Identifiers: n.Identifiers})
+		for _, s := range n.Stations {
+			staKey := n.Code + "." + s.Code
+			set(staKey, models.NodeMetadata{SourceID: s.SourceID, Identifiers: s.Identifiers, WaterLevel: xmlFloat(s.WaterLevel)})
+			for _, c := range s.Channels {
+				set(staKey+"."+c.LocationCode+"."+c.Code, models.NodeMetadata{SourceID: c.SourceID, Identifiers: c.Identifiers, WaterLevel: xmlFloat(c.WaterLevel)})
+			}
+		}
+	}
+	return m
+}
+
+// Lookup returns the metadata for key, which is empty if the document had
+// none, or nil if there was no document and stored metadata should be kept.
+func (m Metadata) Lookup(key string) *models.NodeMetadata {
+	if m == nil {
+		return nil
+	}
+	nm := m[key]
+	return &nm
+}
+
+func xmlFloat(v *models.XMLValue) *float64 {
+	if v == nil {
+		return nil
+	}
+	return &v.Value
+}
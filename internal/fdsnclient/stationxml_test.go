@@ -0,0 +1,104 @@
+package fdsnclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestrictionsFromXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<FDSNStationXML xmlns="http://www.fdsn.org/xml/station/1" schemaVersion="1.1">
+  <Source>Test</Source>
+  <Created>2024-01-01T00:00:00</Created>
+  <Network code="XX" restrictedStatus="partial">
+    <Station code="OPEN" restrictedStatus="open">
+      <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Site><Name>A</Name></Site>
+      <Channel code="HHZ" locationCode="00" restrictedStatus="open">
+        <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Depth>0</Depth>
+      </Channel>
+    </Station>
+    <Station code="EMB" restrictedStatus="closed">
+      <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Site><Name>B</Name></Site>
+      <Channel code="HHZ" locationCode="" restrictedStatus="open">
+        <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Depth>0</Depth>
+      </Channel>
+      <Channel code="HHZ" locationCode="" restrictedStatus="closed">
+        <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Depth>0</Depth>
+      </Channel>
+    </Station>
+  </Network>
+</FDSNStationXML>`
+
+	doc, err := parseStationXML(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseStationXML: %v", err)
+	}
+	r := RestrictionsFromXML(doc)
+
+	tests := []struct {
+		key  string
+		want *bool
+	}{
+		{"XX", ptr(false)},
+		{"XX.OPEN", ptr(false)},
+		{"XX.OPEN.00.HHZ", ptr(false)},
+		{"XX.EMB", ptr(true)},
+		{"XX.EMB..HHZ", ptr(true)}, // closed in any epoch
+		{"YY", nil},
+	}
+	for _, tt := range tests {
+		got := r.Lookup(tt.key)
+		switch {
+		case got == nil && tt.want == nil:
+		case got == nil || tt.want == nil || *got != *tt.want:
+			t.Errorf("Lookup(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func ptr(b bool) *bool { return &b }
+
+func TestMetadataFromXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<FDSNStationXML xmlns="http://www.fdsn.org/xml/station/1" schemaVersion="1.2">
+  <Source>Test</Source>
+  <Created>2024-01-01T00:00:00</Created>
+  <Network code="XX" sourceID="FDSN:XX">
+    <Identifier type="DOI">10.7914/SN/XX</Identifier>
+    <Station code="OBS" sourceID="FDSN:XX_OBS">
+      <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>-3000</Elevation><Site><Name>A</Name></Site>
+      <WaterLevel unit="METERS">0</WaterLevel>
+      <Channel code="HHZ" locationCode="00">
+        <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>-3000</Elevation><Depth>0</Depth>
+        <WaterLevel unit="METERS">0.5</WaterLevel>
+      </Channel>
+    </Station>
+    <Station code="PLAIN">
+      <Latitude>1</Latitude><Longitude>2</Longitude><Elevation>3</Elevation><Site><Name>B</Name></Site>
+    </Station>
+  </Network>
+</FDSNStationXML>`
+
+	doc, err := parseStationXML(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseStationXML: %v", err)
+	}
+	m := MetadataFromXML(doc)
+
+	net := m.Lookup("XX")
+	if net.SourceID != "FDSN:XX" || len(net.Identifiers) != 1 || net.Identifiers[0].Type != "DOI" || net.Identifiers[0].Value != "10.7914/SN/XX" {
+		t.Errorf("network metadata = %+v", net)
+	}
+	if sta := m.Lookup("XX.OBS"); sta.SourceID != "FDSN:XX_OBS" || sta.WaterLevel == nil || *sta.WaterLevel != 0 {
+		t.Errorf("station metadata = %+v", sta)
+	}
+	if ch := m.Lookup("XX.OBS.00.HHZ"); ch.WaterLevel == nil || *ch.WaterLevel != 0.5 {
+		t.Errorf("channel metadata = %+v", ch)
+	}
+	if plain := m.Lookup("XX.PLAIN"); plain == nil || plain.SourceID != "" || plain.WaterLevel != nil {
+		t.Errorf("metadata without values = %+v, want empty", plain)
+	}
+	if got := MetadataFromXML(nil).Lookup("XX"); got != nil {
+		t.Errorf("Lookup without a document = %+v, want nil", got)
+	}
+}
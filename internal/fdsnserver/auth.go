@@ -0,0 +1,91 @@
+package fdsnserver
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/digest"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// Realm is the HTTP Digest realm used by the /queryauth endpoints. Stored
+// user credentials (HA1 values) are bound to it.
+const Realm = "FDSN"
+
+type ctxKey int
+
+const userKey ctxKey = iota
+
+// authenticator guards /queryauth routes with Digest authentication against
+// the users table.
+type authenticator struct {
+	users  store.UserStore
+	digest *digest.Server
+}
+
+func newAuthenticator(users store.UserStore) *authenticator {
+	a := &authenticator{users: users}
+	a.digest = digest.NewServer(Realm, func(username string) (string, bool) {
+		u, err := a.users.Get(username)
+		if err != nil {
+			return "", false
+		}
+		return u.HA1, true
+	})
+	return a
+}
+
+// require wraps next so it only runs for authenticated users. The user record
+// is stored in the request context for requestAccess.
+func (a *authenticator) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok, stale := a.digest.Authenticate(r)
+		if !ok {
+			a.digest.Challenge(w, stale)
+			return
+		}
+		u, err := a.users.Get(username)
+		if err != nil {
+			a.digest.Challenge(w, false)
+			return
+		}
+		log.Debug().Str("user", username).Str("path", r.URL.Path).Msg("queryauth request")
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey, u)))
+	}
+}
+
+// access describes which restricted data a request may see.
+type access struct {
+	user              *models.User
+	includeRestricted bool
+}
+
+// requestAccess returns the access rules for r. Anonymous requests (plain
+// /query) never see restricted data.
+func requestAccess(r *http.Request, includeRestricted bool) access {
+	u, _ := r.Context().Value(userKey).(*models.User)
+	return access{user: u, includeRestricted: includeRestricted}
+}
+
+// allows reports whether a node in network with the given restricted state
+// may be returned.
+func (a access) allows(network string, restricted bool) bool {
+	if !restricted {
+		return true
+	}
+	if a.user == nil || !a.includeRestricted {
+		return false
+	}
+	return matchAny(splitCSV(a.user.Networks), network)
+}
+
+// restrictedStatus maps a restricted flag to a StationXML restrictedStatus.
+func restrictedStatus(restricted bool) string {
+	if restricted {
+		return models.RestrictedClosed
+	}
+	return models.RestrictedOpen
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/joescharf/fdsn/internal/store"
 )
 
 type availabilityHandler struct {
@@ -13,25 +14,33 @@ type availabilityHandler struct {
 }
 
 func (h *availabilityHandler) query(w http.ResponseWriter, r *http.Request) {
-	p := ParseStationParams(r)
+	p, err := ParseStationParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	acc := requestAccess(r, p.IncludeRestricted)
 	w.Header().Set("Content-Type", "text/plain")
 
 	type row struct {
-		Network  string    `db:"network_code"`
-		Station  string    `db:"station_code"`
-		Location string    `db:"location_code"`
-		Channel  string    `db:"channel_code"`
-		Earliest time.Time `db:"earliest"`
-		Latest   time.Time `db:"latest"`
+		Network    string    `db:"network_code"`
+		Station    string    `db:"station_code"`
+		Location   string    `db:"location_code"`
+		Channel    string    `db:"channel_code"`
+		Earliest   time.Time `db:"earliest"`
+		Latest     time.Time `db:"latest"`
+		Restricted bool      `db:"restricted"`
 	}
 
 	var rows []row
-	err := h.db.Select(&rows, `SELECT n.code AS network_code, s.code AS station_code,
-		c.location_code, c.code AS channel_code, a.earliest, a.latest
+	err = h.db.Select(&rows, `SELECT n.code AS network_code, s.code AS station_code,
+		c.location_code, c.code AS channel_code, a.earliest, a.latest,
+		`+store.RestrictedExpr("n", "s", "c")+` AS restricted
 		FROM availability a
 		JOIN channels c ON a.channel_id = c.id
 		JOIN stations s ON c.station_id = s.id
 		JOIN networks n ON s.network_id = n.id
+		WHERE `+store.SourceEnabledExpr("n")+`
 		ORDER BY n.code, s.code, c.location_code, c.code`)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -43,7 +52,10 @@ func (h *availabilityHandler) query(w http.ResponseWriter, r *http.Request) {
 		if !matchAny(p.Network, row.Network) || !matchAny(p.Station, row.Station) {
 			continue
 		}
-		if !matchAny(p.Channel, row.Channel) || !matchAny(p.Location, row.Location) {
+		if !matchAny(p.Channel, row.Channel) || !matchAnyLocation(p.Location, row.Location) {
+			continue
+		}
+		if !acc.allows(row.Network, row.Restricted) {
 			continue
 		}
 		fmt.Fprintf(w, "%s|%s|%s|%s|%s|%s\n",
@@ -54,26 +66,34 @@ func (h *availabilityHandler) query(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *availabilityHandler) extent(w http.ResponseWriter, r *http.Request) {
-	p := ParseStationParams(r)
+	p, err := ParseStationParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	acc := requestAccess(r, p.IncludeRestricted)
 	w.Header().Set("Content-Type", "text/plain")
 
 	type row struct {
-		Network  string    `db:"network_code"`
-		Station  string    `db:"station_code"`
-		Location string    `db:"location_code"`
-		Channel  string    `db:"channel_code"`
-		Earliest time.Time `db:"earliest"`
-		Latest   time.Time `db:"latest"`
+		Network    string    `db:"network_code"`
+		Station    string    `db:"station_code"`
+		Location   string    `db:"location_code"`
+		Channel    string    `db:"channel_code"`
+		Earliest   time.Time `db:"earliest"`
+		Latest     time.Time `db:"latest"`
+		Restricted bool      `db:"restricted"`
 	}
 
 	var rows []row
-	err := h.db.Select(&rows, `SELECT n.code AS network_code, s.code AS station_code,
+	err = h.db.Select(&rows, `SELECT n.code AS network_code, s.code AS station_code,
 		c.location_code, c.code AS channel_code,
-		MIN(a.earliest) AS earliest, MAX(a.latest) AS latest
+		MIN(a.earliest) AS earliest, MAX(a.latest) AS latest,
+		MAX(CASE WHEN `+store.RestrictedExpr("n", "s", "c")+` THEN 1 ELSE 0 END) AS restricted
 		FROM availability a
 		JOIN channels c ON a.channel_id = c.id
 		JOIN stations s ON c.station_id = s.id
 		JOIN networks n ON s.network_id = n.id
+		WHERE `+store.SourceEnabledExpr("n")+`
 		GROUP BY n.code, s.code, c.location_code, c.code
 		ORDER BY n.code, s.code, c.location_code, c.code`)
 	if err != nil {
@@ -86,6 +106,9 @@ func (h *availabilityHandler) extent(w http.ResponseWriter, r *http.Request) {
 		if !matchAny(p.Network, row.Network) || !matchAny(p.Station, row.Station) {
 			continue
 		}
+		if !acc.allows(row.Network, row.Restricted) {
+			continue
+		}
 		fmt.Fprintf(w, "%s|%s|%s|%s|%s|%s\n",
 			row.Network, row.Station, row.Location, row.Channel,
 			row.Earliest.Format("2006-01-02T15:04:05"),
@@ -0,0 +1,314 @@
+package fdsnserver
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/digest"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/stationxml"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var update = flag.Bool("update", false, "rewrite the conformance golden files")
+
+// stationCases is the FDSN station parameter matrix, each run against the
+// seeded database and compared with testdata/conformance/<name>.golden.
+var stationCases = []struct {
+	name  string
+	query string
+	auth  bool // send the request to /queryauth as user "alice"
+}{
+	// level x format
+	{"xml_network", "level=network", false},
+	{"xml_station", "level=station", false},
+	{"xml_channel", "level=channel", false},
+	{"xml_response", "level=response&net=IU&sta=ANMO", false},
+	{"xml_response_unknown", "level=response&net=NZ", false},
+	{"text_network", "level=network&format=text", false},
+	{"text_station", "level=station&format=text", false},
+	{"text_channel", "level=channel&format=text", false},
+
+	// network, station, location and channel selection
+	{"net_list", "net=IU,NZ&level=network&format=text", false},
+	{"net_long_names", "network=NZ&station=WEL&level=channel&format=text", false},
+	{"sta_wildcard", "sta=A*O&format=text", false},
+	{"sta_single_char", "sta=COL?&format=text", false},
+	{"cha_wildcard", "cha=BH?&level=channel&format=text", false},
+	{"cha_list", "cha=LHZ,HHZ&level=channel", false},
+	{"loc_code", "loc=10&level=channel&format=text", false},
+	{"loc_empty", "loc=--&level=channel&format=text", false},
+	{"loc_empty_list", "net=NZ&loc=--,10&level=channel", false},
+
+	// geographic constraints
+	{"bbox", "minlat=30&maxlat=70&minlon=-180&maxlon=-100&format=text", false},
+	{"bbox_long_names", "minlatitude=-50&maxlatitude=-30&level=channel", false},
+	{"bbox_antimeridian", "minlon=170&maxlon=-170&format=text", false},
+	{"radius", "lat=35&lon=-106&maxradius=5&format=text", false},
+	{"radius_ring", "lat=35&lon=-106&minradius=10&maxradius=60&format=text", false},
+	{"bbox_bad", "minlat=north&format=text", false},
+
+	// time constraints: COLA closed in 2020, WEL and LBFI opened in 1992,
+	// ANMO in 2002 and XX in 2021
+	{"time_starttime", "starttime=2021-01-01&format=text", false},
+	{"time_endtime", "endtime=2000-01-01&level=channel&format=text", false},
+	{"time_window", "starttime=2019-01-01&endtime=2019-12-31&net=IU&level=channel", false},
+	{"time_startbefore", "startbefore=2000-01-01&format=text", false},
+	{"time_startafter", "startafter=2010-01-01&format=text", false},
+	{"time_endbefore", "endbefore=2021-01-01&level=channel&format=text", false},
+	{"time_endafter", "endafter=2021-01-01&net=IU&level=channel&format=text", false},
+	{"time_bad", "starttime=2021-13-45&format=text", false},
+	{"time_bad_alias", "end=yesterday", false},
+
+	// no data
+	{"nodata_default", "net=ZZ", false},
+	{"nodata_time", "endtime=1980-01-01", false},
+	{"nodata_404", "net=ZZ&nodata=404&format=text", false},
+	{"nodata_bbox", "minlat=80&level=channel", false},
+
+	// restricted data
+	{"restricted_anonymous", "net=XX&level=channel&format=text", false},
+	{"restricted_auth", "net=XX&level=channel", true},
+	{"restricted_excluded", "net=XX&level=channel&format=text&includerestricted=false", true},
+
+	// StationXML versions
+	{"schema_11_metadata", "net=IU&sta=ANMO&cha=BHZ&level=channel&schemaversion=1.1", false},
+	{"schema_12", "net=IU&sta=ANMO&cha=BHZ&level=channel&schemaversion=1.2", false},
+	{"schema_bad", "schemaversion=2.0", false},
+
+	// extensions
+	{"geojson", "format=geojson&net=IU", false},
+	{"bad_format", "format=json", false},
+}
+
+// unsupportedStationParams are FDSN station parameters the service ignores,
+// with the reason. They are reported as skipped cases of the matrix.
+var unsupportedStationParams = []struct {
+	param, reason string
+}{
+	{"updatedafter", "networks, stations and channels do not record when they were last updated"},
+}
+
+func TestStationConformance(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(seedConformanceDB(t), nil, "", nil))
+	defer srv.Close()
+
+	for _, tc := range stationCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := "/station/1/query"
+			if tc.auth {
+				path = "/station/1/queryauth"
+			}
+			resp := getStation(t, srv.URL+path+"?"+tc.query, tc.auth)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/xml") {
+				problems, err := stationxml.Validate(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("response is not well-formed XML: %v", err)
+				}
+				for _, p := range problems {
+					t.Errorf("schema: %s", p)
+				}
+			}
+
+			checkGolden(t, tc.name, resp, body)
+		})
+	}
+	for _, u := range unsupportedStationParams {
+		t.Run(u.param, func(t *testing.T) {
+			t.Skipf("%s is not supported: %s", u.param, u.reason)
+		})
+	}
+}
+
+// eventCases is the FDSN event parameter matrix, each run against the
+// events of seedEvents and compared with testdata/conformance/event_<name>.golden.
+var eventCases = []struct {
+	name  string
+	query string
+}{
+	{"text", "format=text"},
+	{"limit_offset", "format=text&limit=1&offset=2"},
+	{"offset_without_limit", "format=text&offset=2"},
+	{"limit_bad", "limit=0"},
+	{"time_window", "starttime=2024-02-15&endtime=2024-03-15&format=text"},
+	{"time_bad", "starttime=2024-02-30T25:00:00"},
+	{"radius_without_center", "maxradius=10"},
+	{"orderby_bad", "orderby=depth"},
+	{"nodata_404", "minmag=9&nodata=404&format=text"},
+}
+
+func TestEventConformance(t *testing.T) {
+	srv := seedEvents(t)
+	for _, tc := range eventCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/event/1/query?" + tc.query)
+			if err != nil {
+				t.Fatalf("GET %s: %v", tc.query, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			checkGolden(t, "event_"+tc.name, resp, body)
+		})
+	}
+}
+
+// checkGolden compares the status, content type and body of resp with
+// testdata/conformance/<name>.golden, rewriting it under -update.
+func checkGolden(t *testing.T, name string, resp *http.Response, body []byte) {
+	t.Helper()
+	got := fmt.Sprintf("status: %d\ncontent-type: %s\n\n%s", resp.StatusCode, resp.Header.Get("Content-Type"), normalizeCreated(body))
+	golden := filepath.Join("testdata", "conformance", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("response differs from %s:\n%s", golden, got)
+	}
+}
+
+// getStation requests url, answering the Digest challenge as alice if auth
+// is set.
+func getStation(t *testing.T, url string, auth bool) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	if !auth {
+		return resp
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a Digest challenge, got %d", resp.StatusCode)
+	}
+	challenge := digest.ParseHeader(resp.Header.Get("WWW-Authenticate"))
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", digest.Authorization(challenge, http.MethodGet, req.URL.RequestURI(), "alice", "secret", 1, "0a4f113b"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	return resp
+}
+
+var createdRe = regexp.MustCompile(`<Created>[^<]*</Created>`)
+
+func normalizeCreated(body []byte) []byte {
+	return createdRe.ReplaceAll(body, []byte("<Created>2000-01-01T00:00:00Z</Created>"))
+}
+
+// seedConformanceDB returns a database with three open networks, a
+// restricted one, a channel without orientation, StationXML 1.2 metadata on
+// IU.ANMO and a user allowed to see the restricted network.
+func seedConformanceDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.MustExec("INSERT INTO sources (name, base_url, description) VALUES ('TEST', 'http://test.example', '')")
+
+	date := func(y int) *time.Time {
+		t := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	anmo := models.ImportChannel{
+		NetworkCode: "IU", NetworkDescription: "Global Seismograph Network",
+		StationCode: "ANMO", Latitude: 34.9459, Longitude: -106.4572, Elevation: 1850,
+		SiteName: "Albuquerque, New Mexico, USA", StationStartTime: date(2002),
+		LocationCode: "00", ChannelCode: "BHZ",
+		ChanLatitude: 34.9459, ChanLongitude: -106.4572, ChanElevation: 1850, Depth: 145,
+		Azimuth: 0, Dip: -90, SensorDescription: "Streckeisen STS-1",
+		Scale: 3.3e9, ScaleFreq: 0.02, ScaleUnits: "M/S", SampleRate: 40, ChanStartTime: date(2002),
+		NetworkMetadata: &models.NodeMetadata{
+			SourceID:    "FDSN:IU",
+			Identifiers: models.Identifiers{{Type: "DOI", Value: "10.7914/SN/IU"}},
+		},
+		StationMetadata: &models.NodeMetadata{SourceID: "FDSN:IU_ANMO", WaterLevel: floatPtr(1800)},
+		ChanMetadata:    &models.NodeMetadata{SourceID: "FDSN:IU_ANMO_00_B_H_Z"},
+	}
+	anmo1, anmo2, anmoLH := anmo, anmo, anmo
+	anmo1.ChanMetadata, anmo2.ChanMetadata, anmoLH.ChanMetadata = nil, nil, nil
+	anmo1.ChannelCode, anmo1.Azimuth, anmo1.Dip = "BH1", 0, 0
+	anmo2.ChannelCode, anmo2.Azimuth, anmo2.Dip = "BH2", 90, 0
+	anmoLH.LocationCode, anmoLH.ChannelCode, anmoLH.SampleRate = "10", "LHZ", 1
+
+	cola := anmo
+	cola.StationMetadata, cola.ChanMetadata = nil, nil
+	cola.StationCode, cola.Latitude, cola.Longitude, cola.Elevation = "COLA", 64.8738, -147.8511, 200
+	cola.SiteName, cola.StationEndTime = "College Outpost, Alaska, USA", date(2020)
+	cola.ChanLatitude, cola.ChanLongitude, cola.ChanElevation = cola.Latitude, cola.Longitude, cola.Elevation
+	cola.ChannelCode, cola.SampleRate, cola.ChanEndTime = "LHZ", 1, date(2020)
+
+	wel := models.ImportChannel{
+		NetworkCode: "NZ", NetworkDescription: "New Zealand National Seismograph Network",
+		StationCode: "WEL", Latitude: -41.2847, Longitude: 174.7683, Elevation: 138,
+		SiteName: "Wellington", StationStartTime: date(1992),
+		LocationCode: "10", ChannelCode: "HHZ",
+		ChanLatitude: -41.2847, ChanLongitude: 174.7683, ChanElevation: 138,
+		Dip: -90, SensorDescription: "Guralp CMG-3ESP", SampleRate: 100, ChanStartTime: date(1992),
+	}
+	ta := wel
+	ta.NetworkCode, ta.NetworkDescription = "FJ", "Fiji Seismic Network"
+	ta.StationCode, ta.Latitude, ta.Longitude, ta.SiteName = "LBFI", -16.4, -179.3, "Labasa"
+	ta.ChanLatitude, ta.ChanLongitude = ta.Latitude, ta.Longitude
+
+	secret := models.ImportChannel{
+		NetworkCode: "XX", NetworkDescription: "Temporary deployment",
+		StationCode: "TMP1", Latitude: 35.5, Longitude: -106.2, Elevation: 1700, SiteName: "Temporary",
+		StationStartTime: date(2021), ChannelCode: "HHZ", ChanLatitude: 35.5, ChanLongitude: -106.2,
+		ChanElevation: 1700, Dip: -90, SampleRate: 100, ChanStartTime: date(2021),
+		StationRestricted: boolPtr(true), ChanRestricted: boolPtr(true),
+	}
+	secretOpen := secret
+	secretOpen.StationCode, secretOpen.StationRestricted, secretOpen.ChanRestricted = "TMP2", boolPtr(false), boolPtr(false)
+
+	stations := store.NewStationStore(db)
+	if err := stations.ImportStations(1, []models.ImportChannel{anmo, anmo1, anmo2, anmoLH, cola, wel, ta, secret, secretOpen}); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+
+	// A channel with unknown position, orientation and sample rate, as left
+	// by a sparse text-format import
+	db.MustExec(`INSERT INTO channels (station_id, location_code, code)
+		SELECT s.id, '', 'LDO' FROM stations s WHERE s.code = 'WEL'`)
+
+	if err := store.NewUserStore(db).Upsert(&models.User{
+		Username: "alice", HA1: digest.HA1("alice", Realm, "secret"), Networks: "XX",
+	}); err != nil {
+		t.Fatalf("Upsert user: %v", err)
+	}
+	return db
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func floatPtr(f float64) *float64 { return &f }
@@ -1,64 +1,117 @@
 package fdsnserver
 
 import (
+	"bytes"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/wavecache"
 )
 
 type dataselectHandler struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	creds   store.CredentialStore
+	clients *fdsnclient.Pool
+	cache   *wavecache.Cache
 }
 
+// query routes the requested streams to the sources that serve them, fetches
+// each source's share in parallel with one bulk request, and concatenates the
+// miniSEED in source order.
 func (h *dataselectHandler) query(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	net := q.Get("net")
-	sta := q.Get("sta")
-	loc := q.Get("loc")
-	cha := q.Get("cha")
-	starttime := q.Get("starttime")
-	endtime := q.Get("endtime")
-
-	if net == "" || sta == "" || cha == "" || starttime == "" || endtime == "" {
-		http.Error(w, "net, sta, cha, starttime, endtime are required", http.StatusBadRequest)
+	queries, opts, err := parseStreamQueries(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nodata := http.StatusNoContent
+	switch opts.Get("nodata") {
+	case "", "204":
+	case "404":
+		nodata = http.StatusNotFound
+	default:
+		http.Error(w, fmt.Sprintf("invalid nodata %q: use 204 or 404", opts.Get("nodata")), http.StatusBadRequest)
 		return
 	}
 
-	// Look up the source's base URL for the network
-	var baseURL string
-	err := h.db.Get(&baseURL, `SELECT sr.base_url FROM sources sr
-		JOIN networks n ON n.source_id = sr.id
-		WHERE n.code = ? LIMIT 1`, net)
+	// Upstream returns whatever matches, so a request touching any restricted
+	// channel the caller may not see is refused as a whole.
+	acc := requestAccess(r, true)
+	routes, denied, err := resolveRoutes(h.db, "dataselect", queries, &acc)
 	if err != nil {
-		http.Error(w, "Network source not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if denied {
+		http.Error(w, "Request includes restricted data; use /fdsnws/dataselect/1/queryauth", http.StatusForbidden)
+		return
+	}
+	if len(routes) == 0 {
+		writeNoData(w, nodata)
 		return
 	}
 
-	// Proxy to upstream
-	params := url.Values{}
-	params.Set("net", net)
-	params.Set("sta", sta)
-	if loc != "" {
-		params.Set("loc", loc)
+	results := make([]bytes.Buffer, len(routes))
+	errs := make([]error, len(routes))
+	var wg sync.WaitGroup
+	for i := range routes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = h.fetch(routes[i], &results[i])
+		}(i)
 	}
-	params.Set("cha", cha)
-	params.Set("starttime", starttime)
-	params.Set("endtime", endtime)
+	wg.Wait()
 
-	upstream := baseURL + "/fdsnws/dataselect/1/query?" + params.Encode()
-	resp, err := http.Get(upstream)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("upstream error: %v", err), http.StatusBadGateway)
+	var total int
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			log.Warn().Err(err).Str("source", routes[i].Source.Name).Msg("dataselect upstream failed")
+			failed = append(failed, err)
+			continue
+		}
+		total += results[i].Len()
+	}
+	if len(failed) == len(routes) {
+		http.Error(w, fmt.Sprintf("upstream error: %v", failed[0]), http.StatusBadGateway)
+		return
+	}
+	if total == 0 {
+		writeNoData(w, nodata)
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/vnd.fdsn.mseed")
-	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
+	for i := range results {
+		_, _ = results[i].WriteTo(w)
+	}
+}
+
+// fetch requests rt's streams from its source through the waveform cache,
+// using the source's stored credentials (and so its /queryauth method) when
+// any are stored and rt is not Anonymous.
+func (h *dataselectHandler) fetch(rt route, buf *bytes.Buffer) error {
+	if rt.Anonymous {
+		// Streams no local channel matched may be restricted upstream. They
+		// are fetched without credentials and bypass the cache, which holds
+		// data fetched with them.
+		client := fdsnclient.New(rt.Source.BaseURL).WithSource(rt.Source.Name)
+		var direct *wavecache.Cache // nil passes requests straight upstream
+		return direct.Fetch(rt.Source.ID, rt.Streams, client.FetchMiniSEEDBulk, buf)
+	}
+	cred, err := h.creds.Get(rt.Source.ID)
+	if err != nil {
+		return err
+	}
+	client := h.clients.Get(&rt.Source, cred)
+	return h.cache.Fetch(rt.Source.ID, rt.Streams, client.FetchMiniSEEDBulk, buf)
 }
 
 func (h *dataselectHandler) version(w http.ResponseWriter, r *http.Request) {
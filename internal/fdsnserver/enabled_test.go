@@ -0,0 +1,73 @@
+package fdsnserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+func TestDisabledSourceHidden(t *testing.T) {
+	db := seedConformanceDB(t)
+	db.MustExec("INSERT INTO sources (name, base_url, description) VALUES ('OFF', 'http://off.example', '')")
+	if err := store.NewStationStore(db).ImportStations(2, []models.ImportChannel{
+		{NetworkCode: "ZZ", StationCode: "OFF1", ChannelCode: "HHZ", SampleRate: 100},
+	}); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	db.MustExec(`INSERT INTO availability (channel_id, earliest, latest)
+		SELECT c.id, '2020-01-01T00:00:00Z', '2024-01-01T00:00:00Z' FROM channels c WHERE c.code = 'HHZ'`)
+
+	srv := httptest.NewServer(NewRouter(db, nil, "", nil))
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	routes := func() []route {
+		t.Helper()
+		rts, _, err := resolveRoutes(db.DB, "dataselect", []streamQuery{{Network: "ZZ", Station: "*", Location: "*", Channel: "*"}}, nil)
+		if err != nil {
+			t.Fatalf("resolveRoutes: %v", err)
+		}
+		return rts
+	}
+
+	if _, body := get("/station/1/query?level=channel&format=text"); !strings.Contains(body, "OFF1") {
+		t.Fatalf("enabled source missing from station output:\n%s", body)
+	}
+	if rts := routes(); len(rts) != 1 || rts[0].Source.Name != "OFF" {
+		t.Fatalf("routes while enabled = %+v", rts)
+	}
+
+	db.MustExec("UPDATE sources SET enabled = FALSE WHERE name = 'OFF'")
+
+	for _, path := range []string{
+		"/station/1/query?level=network&format=text",
+		"/station/1/query?level=station&format=text",
+		"/station/1/query?level=channel&format=text",
+		"/station/1/query?level=channel",
+		"/availability/1/query?format=text",
+	} {
+		status, body := get(path)
+		if status != http.StatusOK || strings.Contains(body, "ZZ") || strings.Contains(body, "OFF1") {
+			t.Errorf("%s: status %d, output\n%s", path, status, body)
+		}
+	}
+	if status, _ := get("/station/1/query?net=ZZ"); status != http.StatusNoContent {
+		t.Errorf("station net=ZZ: status %d, want 204", status)
+	}
+	if rts := routes(); len(rts) != 0 {
+		t.Errorf("routes to a disabled source: %+v", rts)
+	}
+}
@@ -0,0 +1,363 @@
+package fdsnserver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+type eventHandler struct {
+	events store.EventStore
+}
+
+// eventParams holds parsed FDSN event query parameters.
+type eventParams struct {
+	Query  store.EventQuery
+	Format string // "xml" or "text"
+	NoData int
+
+	IncludeAllOrigins    bool
+	IncludeAllMagnitudes bool
+	IncludeArrivals      bool
+}
+
+// parseEventParams extracts FDSN event parameters from an HTTP request.
+func parseEventParams(r *http.Request) (eventParams, error) {
+	q := r.URL.Query()
+	p := eventParams{
+		Format: q.Get("format"),
+		NoData: http.StatusNoContent,
+		Query:  store.EventQuery{EnabledOnly: true},
+	}
+	if p.Format == "" {
+		p.Format = "xml"
+	}
+	if p.Format != "xml" && p.Format != "text" {
+		return p, fmt.Errorf("unsupported format %q: use xml or text", p.Format)
+	}
+	switch q.Get("nodata") {
+	case "", "204":
+	case "404":
+		p.NoData = http.StatusNotFound
+	default:
+		return p, fmt.Errorf("invalid nodata %q: use 204 or 404", q.Get("nodata"))
+	}
+
+	var err error
+	times := []struct {
+		dest  **time.Time
+		names []string
+	}{
+		{&p.Query.StartTime, []string{"starttime", "start"}},
+		{&p.Query.EndTime, []string{"endtime", "end"}},
+		{&p.Query.UpdatedAfter, []string{"updatedafter"}},
+	}
+	for _, t := range times {
+		if *t.dest, err = timeParam(q, t.names...); err != nil {
+			return p, err
+		}
+	}
+	floats := []struct {
+		dest  **float64
+		names []string
+	}{
+		{&p.Query.MinLatitude, []string{"minlatitude", "minlat"}},
+		{&p.Query.MaxLatitude, []string{"maxlatitude", "maxlat"}},
+		{&p.Query.MinLongitude, []string{"minlongitude", "minlon"}},
+		{&p.Query.MaxLongitude, []string{"maxlongitude", "maxlon"}},
+		{&p.Query.Latitude, []string{"latitude", "lat"}},
+		{&p.Query.Longitude, []string{"longitude", "lon"}},
+		{&p.Query.MinRadius, []string{"minradius"}},
+		{&p.Query.MaxRadius, []string{"maxradius"}},
+		{&p.Query.MinDepth, []string{"mindepth"}},
+		{&p.Query.MaxDepth, []string{"maxdepth"}},
+		{&p.Query.MinMagnitude, []string{"minmagnitude", "minmag"}},
+		{&p.Query.MaxMagnitude, []string{"maxmagnitude", "maxmag"}},
+	}
+	for _, f := range floats {
+		if *f.dest, err = floatParam(q, f.names...); err != nil {
+			return p, err
+		}
+	}
+	if (p.Query.MinRadius != nil || p.Query.MaxRadius != nil) && (p.Query.Latitude == nil || p.Query.Longitude == nil) {
+		return p, fmt.Errorf("minradius and maxradius require latitude and longitude")
+	}
+
+	p.Query.MagType = firstNonEmpty(q.Get("magnitudetype"), q.Get("magtype"))
+	p.Query.EventTypes = splitCSV(q.Get("eventtype"))
+	p.Query.EventIDs = splitCSV(q.Get("eventid"))
+	p.Query.Catalog = q.Get("catalog")
+	p.Query.Contributor = q.Get("contributor")
+
+	p.Query.OrderBy = q.Get("orderby")
+	if _, ok := store.EventOrders[p.Query.OrderBy]; p.Query.OrderBy != "" && !ok {
+		return p, fmt.Errorf("invalid orderby %q: use time, time-asc, magnitude or magnitude-asc", p.Query.OrderBy)
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid limit %q", v)
+		}
+		p.Query.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		// Offsets count from 1
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid offset %q", v)
+		}
+		if p.Query.Limit == 0 {
+			return p, fmt.Errorf("offset requires limit")
+		}
+		p.Query.Offset = n - 1
+	}
+
+	bools := []struct {
+		dest *bool
+		name string
+	}{
+		{&p.IncludeAllOrigins, "includeallorigins"},
+		{&p.IncludeAllMagnitudes, "includeallmagnitudes"},
+		{&p.IncludeArrivals, "includearrivals"},
+	}
+	for _, b := range bools {
+		if v := q.Get(b.name); v != "" {
+			if *b.dest, err = strconv.ParseBool(v); err != nil {
+				return p, fmt.Errorf("invalid %s %q", b.name, v)
+			}
+		}
+	}
+	p.Query.Details = p.Format == "xml"
+	return p, nil
+}
+
+func (h *eventHandler) query(w http.ResponseWriter, r *http.Request) {
+	p, err := parseEventParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := h.events.ListEvents(p.Query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(page.Events) == 0 {
+		writeNoData(w, p.NoData)
+		return
+	}
+
+	if p.Format == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		writeEventText(w, page.Events)
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(quakeML(page.Events, p)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = buf.WriteTo(w)
+}
+
+// writeEventText writes events in the FDSN event text format. EventID is
+// the local event ID, which the eventid parameter accepts; the upstream ID
+// is kept in ContributorID.
+func writeEventText(w http.ResponseWriter, events []models.Event) {
+	fmt.Fprintln(w, "#EventID | Time | Latitude | Longitude | Depth/km | Author | Catalog | Contributor | ContributorID | MagType | Magnitude | MagAuthor | EventLocationName | EventType")
+	for _, ev := range events {
+		fmt.Fprintf(w, "%d|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s\n",
+			ev.ID, ev.Time.UTC().Format("2006-01-02T15:04:05.000"),
+			formatNumber(&ev.Latitude), formatNumber(&ev.Longitude), formatNumber(ev.DepthKM),
+			ev.Author, ev.Catalog, ev.Contributor, ev.ContributorID,
+			ev.MagnitudeType, formatNumber(ev.Magnitude), ev.MagnitudeAuthor,
+			ev.Description, ev.Type)
+	}
+}
+
+// formatNumber formats v without trailing zeros, or "" if v is unknown.
+func formatNumber(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// quakeML builds a QuakeML 1.2 document of events. Only the preferred origin
+// and magnitude of each event are included unless p asks for all of them;
+// picks and arrivals only with includearrivals.
+func quakeML(events []models.Event, p eventParams) models.QuakeML {
+	doc := models.QuakeML{
+		XMLNSQ: models.QuakeMLNamespace,
+		XMLNS:  models.QuakeMLBEDNamespace,
+		EventParameters: models.QMLEventParameters{
+			PublicID:     "smi:local/fdsnws/event/1/query",
+			CreationInfo: &models.QMLCreationInfo{CreationTime: quakeMLTime(time.Now())},
+		},
+	}
+	for _, ev := range events {
+		qe := models.QMLEvent{
+			PublicID:             resourceID(ev.PublicID),
+			PreferredOriginID:    resourceID(ev.PreferredOriginID),
+			PreferredMagnitudeID: resourceID(ev.PreferredMagnitudeID),
+			Type:                 ev.Type,
+		}
+		if ev.Description != "" {
+			qe.Descriptions = []models.QMLEventDescription{{Text: ev.Description, Type: "region name"}}
+		}
+		if ev.Author != "" {
+			qe.CreationInfo = &models.QMLCreationInfo{Author: ev.Author}
+		}
+		for _, o := range ev.Origins {
+			if !p.IncludeAllOrigins && !preferred(o.PublicID, ev.PreferredOriginID, len(ev.Origins)) {
+				continue
+			}
+			qe.Origins = append(qe.Origins, quakeMLOrigin(o, p.IncludeArrivals))
+		}
+		for _, m := range ev.Magnitudes {
+			if !p.IncludeAllMagnitudes && !preferred(m.PublicID, ev.PreferredMagnitudeID, len(ev.Magnitudes)) {
+				continue
+			}
+			qm := models.QMLMagnitude{
+				PublicID:     resourceID(m.PublicID),
+				Mag:          models.QMLReal{Value: m.Mag},
+				Type:         m.Type,
+				OriginID:     resourceID(m.OriginID),
+				StationCount: m.StationCount,
+				CreationInfo: creationInfo(m.AgencyID, m.Author),
+			}
+			qe.Magnitudes = append(qe.Magnitudes, qm)
+		}
+		if p.IncludeArrivals {
+			for _, pk := range ev.Picks {
+				qe.Picks = append(qe.Picks, models.QMLPick{
+					PublicID: resourceID(pk.PublicID),
+					Time:     models.QMLTime{Value: quakeMLTime(pk.Time)},
+					WaveformID: models.QMLWaveformID{
+						NetworkCode:  pk.NetworkCode,
+						StationCode:  pk.StationCode,
+						LocationCode: pk.LocationCode,
+						ChannelCode:  pk.ChannelCode,
+					},
+					Onset:          pk.Onset,
+					PhaseHint:      pk.PhaseHint,
+					Polarity:       pk.Polarity,
+					EvaluationMode: pk.EvaluationMode,
+				})
+			}
+		}
+		doc.EventParameters.Events = append(doc.EventParameters.Events, qe)
+	}
+	return doc
+}
+
+// preferred reports whether the origin or magnitude id is the preferred one:
+// the one named by preferredID or, if none is named, the only one.
+func preferred(id, preferredID string, count int) bool {
+	if preferredID == "" {
+		return count == 1
+	}
+	return id == preferredID
+}
+
+func quakeMLOrigin(o models.Origin, withArrivals bool) models.QMLOrigin {
+	qo := models.QMLOrigin{
+		PublicID:         resourceID(o.PublicID),
+		Time:             models.QMLTime{Value: quakeMLTime(o.Time)},
+		Latitude:         models.QMLReal{Value: o.Latitude},
+		Longitude:        models.QMLReal{Value: o.Longitude},
+		DepthType:        o.DepthType,
+		MethodID:         resourceID(o.MethodID),
+		EarthModelID:     resourceID(o.EarthModelID),
+		EvaluationMode:   o.EvaluationMode,
+		EvaluationStatus: o.EvaluationStatus,
+		CreationInfo:     creationInfo(o.AgencyID, o.Author),
+	}
+	if o.DepthKM != nil {
+		qo.Depth = &models.QMLReal{Value: *o.DepthKM * 1000}
+	}
+	if withArrivals {
+		for _, a := range o.Arrivals {
+			qo.Arrivals = append(qo.Arrivals, models.QMLArrival{
+				PublicID:     resourceID(a.PublicID),
+				PickID:       resourceID(a.PickID),
+				Phase:        a.Phase,
+				Azimuth:      a.Azimuth,
+				Distance:     a.Distance,
+				TimeResidual: a.TimeResidual,
+				TimeWeight:   a.TimeWeight,
+			})
+		}
+	}
+	return qo
+}
+
+func creationInfo(agencyID, author string) *models.QMLCreationInfo {
+	if agencyID == "" && author == "" {
+		return nil
+	}
+	return &models.QMLCreationInfo{AgencyID: agencyID, Author: author}
+}
+
+// resourceID returns id as a QuakeML resource identifier. IDs imported from
+// the text format are plain event IDs, so they are given a local smi: prefix.
+func resourceID(id string) string {
+	if id == "" || strings.HasPrefix(id, "smi:") || strings.HasPrefix(id, "quakeml:") {
+		return id
+	}
+	return "smi:local/" + id
+}
+
+func quakeMLTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+// catalogs lists the catalogs of the stored events.
+func (h *eventHandler) catalogs(w http.ResponseWriter, r *http.Request) {
+	names, err := h.events.Catalogs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNameList(w, "Catalogs", "Catalog", names)
+}
+
+// contributors lists the contributors of the stored events.
+func (h *eventHandler) contributors(w http.ResponseWriter, r *http.Request) {
+	names, err := h.events.Contributors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNameList(w, "Contributors", "Contributor", names)
+}
+
+// writeNameList writes names as an XML list, the format of the event
+// service's catalogs and contributors methods.
+func writeNameList(w http.ResponseWriter, list, item string, names []string) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprintf(w, "<%s>\n", list)
+	for _, n := range names {
+		fmt.Fprintf(w, "  <%s>", item)
+		_ = xml.EscapeText(w, []byte(n))
+		fmt.Fprintf(w, "</%s>\n", item)
+	}
+	fmt.Fprintf(w, "</%s>\n", list)
+}
+
+func (h *eventHandler) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, "1.2.0")
+}
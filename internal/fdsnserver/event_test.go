@@ -0,0 +1,219 @@
+package fdsnserver
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// seedEvents stores two events of source 1: a deep Fiji event with two
+// origins, two magnitudes and a pick, and a shallow one imported from the
+// text format.
+func seedEvents(t *testing.T) *httptest.Server {
+	t.Helper()
+	db := seedConformanceDB(t)
+	fijiTime := time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC)
+	_, _, err := store.NewEventStore(db).ImportEvents(1, []models.Event{
+		{
+			PublicID: "quakeml:test/event/fiji", Type: "earthquake", Description: "FIJI ISLANDS REGION",
+			PreferredOriginID: "quakeml:test/origin/b", PreferredMagnitudeID: "quakeml:test/magnitude/mww",
+			Time: fijiTime, Latitude: -18.5, Longitude: 178.5, DepthKM: floatPtr(580),
+			Magnitude: floatPtr(6.1), MagnitudeType: "mww", Author: "us", Catalog: "us", Contributor: "us", ContributorID: "us1000abcd",
+			Origins: []models.Origin{
+				{PublicID: "quakeml:test/origin/a", Time: fijiTime.Add(-time.Second), Latitude: -18, Longitude: 178, DepthKM: floatPtr(600)},
+				{PublicID: "quakeml:test/origin/b", Time: fijiTime, Latitude: -18.5, Longitude: 178.5, DepthKM: floatPtr(580),
+					Arrivals: []models.Arrival{{PublicID: "quakeml:test/arrival/1", PickID: "quakeml:test/pick/1", Phase: "P"}}},
+			},
+			Magnitudes: []models.Magnitude{
+				{PublicID: "quakeml:test/magnitude/mb", Mag: 5.9, Type: "mb"},
+				{PublicID: "quakeml:test/magnitude/mww", Mag: 6.1, Type: "mww", OriginID: "quakeml:test/origin/b"},
+			},
+			Picks: []models.Pick{{PublicID: "quakeml:test/pick/1", Time: fijiTime.Add(time.Minute),
+				NetworkCode: "FJ", StationCode: "MSVF", LocationCode: "00", ChannelCode: "BHZ", PhaseHint: "P"}},
+		},
+		{
+			PublicID: "nc1234", PreferredOriginID: "nc1234/origin", Type: "earthquake", Description: "CENTRAL CALIFORNIA",
+			Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Latitude: 36, Longitude: -120.5, DepthKM: floatPtr(8),
+			Magnitude: floatPtr(4.2), MagnitudeType: "ml", Catalog: "nc", Contributor: "nc",
+			Origins: []models.Origin{{PublicID: "nc1234/origin", Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Latitude: 36, Longitude: -120.5}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportEvents: %v", err)
+	}
+	srv := httptest.NewServer(NewRouter(db, nil, "", nil))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getEvents(t *testing.T, srv *httptest.Server, query string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/event/1/query?" + query)
+	if err != nil {
+		t.Fatalf("GET %s: %v", query, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestEventQueryText(t *testing.T) {
+	srv := seedEvents(t)
+
+	status, body := getEvents(t, srv, "format=text")
+	want := `#EventID | Time | Latitude | Longitude | Depth/km | Author | Catalog | Contributor | ContributorID | MagType | Magnitude | MagAuthor | EventLocationName | EventType
+1|2024-03-01T10:00:01.000|-18.5|178.5|580|us|us|us|us1000abcd|mww|6.1||FIJI ISLANDS REGION|earthquake
+2|2024-02-01T00:00:00.000|36|-120.5|8||nc|nc||ml|4.2||CENTRAL CALIFORNIA|earthquake
+`
+	if status != http.StatusOK || body != want {
+		t.Errorf("status %d, body\n%s\nwant\n%s", status, body, want)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // EventIDs
+	}{
+		{"minmag=5", []string{"1"}},
+		{"maxmagnitude=5", []string{"2"}},
+		{"starttime=2024-02-15", []string{"1"}},
+		{"endtime=2024-02-15T00:00:00", []string{"2"}},
+		{"mindepth=100", []string{"1"}},
+		{"minlat=0&maxlon=-100", []string{"2"}},
+		{"minlon=170&maxlon=-170", []string{"1"}},
+		{"lat=37&lon=-121&maxradius=2", []string{"2"}},
+		{"eventid=quakeml:test/event/fiji", []string{"1"}},
+		{"eventid=2", []string{"2"}},
+		{"magtype=ML", []string{"2"}},
+		{"catalog=nc", []string{"2"}},
+		{"orderby=time-asc", []string{"2", "1"}},
+		{"limit=1&offset=2", []string{"2"}},
+	}
+	for _, tt := range tests {
+		status, body := getEvents(t, srv, "format=text&"+tt.query)
+		if status != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.query, status, body)
+			continue
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n")[1:] {
+			got = append(got, strings.SplitN(line, "|", 2)[0])
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEventQueryErrors(t *testing.T) {
+	srv := seedEvents(t)
+
+	for _, query := range []string{
+		"minmag=big",
+		"starttime=yesterday",
+		"format=json",
+		"orderby=depth",
+		"limit=0",
+		"offset=2",
+		"maxradius=5",
+		"includearrivals=maybe",
+		"nodata=500",
+	} {
+		if status, _ := getEvents(t, srv, query); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, status)
+		}
+	}
+	if status, _ := getEvents(t, srv, "minmag=9"); status != http.StatusNoContent {
+		t.Errorf("no match: status %d, want 204", status)
+	}
+	if status, _ := getEvents(t, srv, "minmag=9&nodata=404"); status != http.StatusNotFound {
+		t.Errorf("no match with nodata=404: status %d, want 404", status)
+	}
+}
+
+func TestEventQueryQuakeML(t *testing.T) {
+	srv := seedEvents(t)
+
+	var doc struct {
+		XMLName         xml.Name
+		EventParameters models.QMLEventParameters `xml:"eventParameters"`
+	}
+	decode := func(query string) {
+		t.Helper()
+		status, body := getEvents(t, srv, query)
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, status, body)
+		}
+		doc.EventParameters = models.QMLEventParameters{}
+		if err := xml.Unmarshal([]byte(body), &doc); err != nil {
+			t.Fatalf("%s: %v\n%s", query, err, body)
+		}
+	}
+
+	decode("eventid=1")
+	if doc.XMLName.Space != models.QuakeMLNamespace || doc.XMLName.Local != "quakeml" {
+		t.Errorf("root element = %+v", doc.XMLName)
+	}
+	evs := doc.EventParameters.Events
+	if len(evs) != 1 {
+		t.Fatalf("got %d events, want 1", len(evs))
+	}
+	ev := evs[0]
+	if ev.PublicID != "quakeml:test/event/fiji" || ev.Type != "earthquake" || len(ev.Descriptions) != 1 {
+		t.Errorf("event = %+v", ev)
+	}
+	// Only the preferred origin and magnitude by default, without arrivals
+	if len(ev.Origins) != 1 || ev.Origins[0].PublicID != "quakeml:test/origin/b" || len(ev.Origins[0].Arrivals) != 0 {
+		t.Errorf("origins = %+v", ev.Origins)
+	}
+	if ev.Origins[0].Depth == nil || ev.Origins[0].Depth.Value != 580000 {
+		t.Errorf("depth = %+v, want 580000 m", ev.Origins[0].Depth)
+	}
+	if ev.Origins[0].Time.Value != "2024-03-01T10:00:01.000000Z" {
+		t.Errorf("origin time = %q", ev.Origins[0].Time.Value)
+	}
+	if len(ev.Magnitudes) != 1 || ev.Magnitudes[0].Mag.Value != 6.1 || len(ev.Picks) != 0 {
+		t.Errorf("magnitudes = %+v, picks = %+v", ev.Magnitudes, ev.Picks)
+	}
+
+	decode("eventid=1&includeallorigins=true&includeallmagnitudes=true&includearrivals=true")
+	ev = doc.EventParameters.Events[0]
+	if len(ev.Origins) != 2 || len(ev.Magnitudes) != 2 || len(ev.Picks) != 1 || len(ev.Origins[1].Arrivals) != 1 {
+		t.Errorf("with includes: %d origins, %d magnitudes, %d picks", len(ev.Origins), len(ev.Magnitudes), len(ev.Picks))
+	}
+	if ev.Picks[0].WaveformID.StationCode != "MSVF" {
+		t.Errorf("pick = %+v", ev.Picks[0])
+	}
+
+	// IDs from the text format become resource identifiers
+	decode("eventid=2")
+	ev = doc.EventParameters.Events[0]
+	if ev.PublicID != "smi:local/nc1234" || ev.PreferredOriginID != "smi:local/nc1234/origin" || ev.Origins[0].PublicID != ev.PreferredOriginID {
+		t.Errorf("text-imported event = %+v", ev)
+	}
+}
+
+func TestEventCatalogs(t *testing.T) {
+	srv := seedEvents(t)
+	for path, want := range map[string]string{
+		"/event/1/catalogs":     "<Catalog>nc</Catalog>\n  <Catalog>us</Catalog>",
+		"/event/1/contributors": "<Contributor>nc</Contributor>\n  <Contributor>us</Contributor>",
+		"/event/1/version":      "1.2.0",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), want) {
+			t.Errorf("%s:\n%s\nwant %q", path, body, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package fdsnserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// ErrNoData is returned by QueryStations when nothing matches the query.
+var ErrNoData = errors.New("no data matches the request")
+
+// QueryStations answers an FDSN station query without an HTTP server, for
+// the fdsn export command: it runs the /fdsnws/station/1/query handler
+// against db and writes the response body to w. query holds the station
+// service parameters. With includeRestricted, restricted networks, stations
+// and channels are returned as to a queryauth user allowed every network.
+func QueryStations(db *database.DB, w io.Writer, query url.Values, schemaVersion string, includeRestricted bool) error {
+	if schemaVersion == "" {
+		schemaVersion = models.StationXMLVersion11
+	}
+	h := &stationHandler{db: db.Reader, schemaVersion: schemaVersion}
+
+	r, err := http.NewRequest(http.MethodGet, "/fdsnws/station/1/query?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if includeRestricted {
+		r = r.WithContext(context.WithValue(r.Context(), userKey, &models.User{Networks: "*"}))
+	}
+	bw := &bodyWriter{w: w, header: make(http.Header)}
+	h.query(bw, r)
+	switch {
+	case bw.status == http.StatusNoContent || bw.status == http.StatusNotFound:
+		return ErrNoData
+	case bw.status >= http.StatusBadRequest:
+		return errors.New(strings.TrimSpace(bw.errBody.String()))
+	}
+	return bw.err
+}
+
+// bodyWriter is an http.ResponseWriter passing a successful response body
+// to w and keeping an error response for the caller.
+type bodyWriter struct {
+	w       io.Writer
+	header  http.Header
+	status  int
+	errBody bytes.Buffer
+	err     error
+}
+
+func (b *bodyWriter) Header() http.Header { return b.header }
+
+func (b *bodyWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bodyWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if b.status != http.StatusOK {
+		return b.errBody.Write(p)
+	}
+	n, err := b.w.Write(p)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,93 @@
+package fdsnserver
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQueryStations(t *testing.T) {
+	db := seedConformanceDB(t)
+
+	// Same body as the HTTP service
+	var buf bytes.Buffer
+	if err := QueryStations(db, &buf, url.Values{"format": {"text"}}, "", false); err != nil {
+		t.Fatalf("QueryStations: %v", err)
+	}
+	golden, err := os.ReadFile("testdata/conformance/text_station.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(golden[bytes.Index(golden, []byte("\n\n"))+2:]); buf.String() != want {
+		t.Errorf("output differs from text_station.golden:\n%s", buf.String())
+	}
+
+	// Restricted data only when asked for
+	q := url.Values{"format": {"text"}, "net": {"XX"}}
+	buf.Reset()
+	if err := QueryStations(db, &buf, q, "", false); err != nil || strings.Contains(buf.String(), "TMP1") {
+		t.Errorf("without restricted: err %v, output\n%s", err, buf.String())
+	}
+	buf.Reset()
+	if err := QueryStations(db, &buf, q, "", true); err != nil || !strings.Contains(buf.String(), "TMP1") {
+		t.Errorf("with restricted: err %v, output\n%s", err, buf.String())
+	}
+
+	if err := QueryStations(db, &buf, url.Values{"net": {"ZZ"}}, "", false); !errors.Is(err, ErrNoData) {
+		t.Errorf("no match: err = %v, want ErrNoData", err)
+	}
+	if err := QueryStations(db, &buf, url.Values{"format": {"json"}}, "", false); err == nil || err.Error() != "Unsupported format" {
+		t.Errorf("bad format: err = %v", err)
+	}
+}
+
+func TestQueryStationsTimeWindow(t *testing.T) {
+	db := seedConformanceDB(t)
+	query := func(params string) string {
+		t.Helper()
+		q, _ := url.ParseQuery(params)
+		var buf bytes.Buffer
+		if err := QueryStations(db, &buf, q, "", false); err != nil {
+			t.Fatalf("QueryStations(%s): %v", params, err)
+		}
+		return buf.String()
+	}
+
+	for _, tc := range []struct {
+		params      string
+		want, other []string
+	}{
+		// COLA closed in 2020; WEL and LBFI opened in 1992, the rest later
+		{"format=text&starttime=2021-01-01", []string{"|ANMO|", "|WEL|"}, []string{"|COLA|"}},
+		{"format=text&endtime=2000-01-01", []string{"|WEL|", "|LBFI|"}, []string{"|ANMO|", "|COLA|"}},
+		{"format=text&starttime=2019-01-01&endtime=2019-12-31", []string{"|ANMO|", "|COLA|"}, []string{"|TMP2|"}},
+		{"format=text&startafter=2010-01-01", []string{"|TMP2|"}, []string{"|ANMO|", "|WEL|"}},
+		{"format=text&startbefore=2000-01-01", []string{"|WEL|"}, []string{"|ANMO|"}},
+		{"format=text&endbefore=2021-01-01", []string{"|COLA|"}, []string{"|ANMO|"}},
+		{"format=text&endafter=2021-01-01", []string{"|ANMO|"}, []string{"|COLA|"}},
+		{"format=text&level=channel&net=IU&starttime=2021-01-01", []string{"|ANMO|00|BHZ|"}, []string{"|COLA|"}},
+		{"level=channel&net=IU&starttime=2021-01-01", []string{`code="ANMO"`}, []string{`code="COLA"`}},
+		{"format=geojson&net=IU&starttime=2021-01-01", []string{`"ANMO"`}, []string{`"COLA"`}},
+	} {
+		out := query(tc.params)
+		for _, s := range tc.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: missing %s in\n%s", tc.params, s, out)
+			}
+		}
+		for _, s := range tc.other {
+			if strings.Contains(out, s) {
+				t.Errorf("%s: unexpected %s in\n%s", tc.params, s, out)
+			}
+		}
+	}
+
+	// Networks left without stations in the window are dropped
+	var buf bytes.Buffer
+	if err := QueryStations(db, &buf, url.Values{"endtime": {"1990-01-01"}}, "", false); !errors.Is(err, ErrNoData) {
+		t.Errorf("window before every epoch: err = %v, want ErrNoData\n%s", err, buf.String())
+	}
+}
@@ -1,9 +1,14 @@
 package fdsnserver
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/joescharf/fdsn/internal/store"
 )
 
 // StationParams holds parsed FDSN station query parameters.
@@ -16,21 +21,50 @@ type StationParams struct {
 	EndTime   *time.Time
 	Level     string // "network", "station", "channel", "response"
 	Format    string // "text", "xml"
-	MinLat    *float64
-	MaxLat    *float64
-	MinLon    *float64
-	MaxLon    *float64
+
+	// StartBefore, StartAfter, EndBefore and EndAfter bound the start and
+	// end of the selected epochs themselves.
+	StartBefore *time.Time
+	StartAfter  *time.Time
+	EndBefore   *time.Time
+	EndAfter    *time.Time
+
+	// SchemaVersion is the requested StationXML version, "" for the
+	// server's default.
+	SchemaVersion string
+	MinLat        *float64
+	MaxLat        *float64
+	MinLon        *float64
+	MaxLon        *float64
+	Lat           *float64
+	Lon           *float64
+	MinRadius     *float64
+	MaxRadius     *float64
+
+	// NoData is the status for a query matching nothing: 204 (default) or 404.
+	NoData int
+
+	// IncludeRestricted only has an effect on /queryauth; anonymous requests
+	// never see restricted data.
+	IncludeRestricted bool
 }
 
 // ParseStationParams extracts FDSN station parameters from an HTTP request.
-func ParseStationParams(r *http.Request) StationParams {
+// It rejects malformed times and coordinates rather than ignoring them.
+func ParseStationParams(r *http.Request) (StationParams, error) {
 	q := r.URL.Query()
 
 	p := StationParams{
-		Level:  q.Get("level"),
-		Format: q.Get("format"),
+		Level:             q.Get("level"),
+		Format:            q.Get("format"),
+		SchemaVersion:     q.Get("schemaversion"),
+		IncludeRestricted: !strings.EqualFold(q.Get("includerestricted"), "false"),
 	}
 
+	p.NoData = http.StatusNoContent
+	if q.Get("nodata") == "404" {
+		p.NoData = http.StatusNotFound
+	}
 	if p.Level == "" {
 		p.Level = "station"
 	}
@@ -63,21 +97,112 @@ func ParseStationParams(r *http.Request) StationParams {
 		p.Channel = splitCSV(v)
 	}
 
-	p.StartTime = parseOptionalTime(q.Get("starttime"))
-	if p.StartTime == nil {
-		p.StartTime = parseOptionalTime(q.Get("start"))
+	var err error
+	times := []struct {
+		dest  **time.Time
+		names []string
+	}{
+		{&p.StartTime, []string{"starttime", "start"}},
+		{&p.EndTime, []string{"endtime", "end"}},
+		{&p.StartBefore, []string{"startbefore"}},
+		{&p.StartAfter, []string{"startafter"}},
+		{&p.EndBefore, []string{"endbefore"}},
+		{&p.EndAfter, []string{"endafter"}},
 	}
-	p.EndTime = parseOptionalTime(q.Get("endtime"))
-	if p.EndTime == nil {
-		p.EndTime = parseOptionalTime(q.Get("end"))
+	for _, t := range times {
+		if *t.dest, err = timeParam(q, t.names...); err != nil {
+			return p, err
+		}
+	}
+	floats := []struct {
+		dest  **float64
+		names []string
+	}{
+		{&p.MinLat, []string{"minlatitude", "minlat"}},
+		{&p.MaxLat, []string{"maxlatitude", "maxlat"}},
+		{&p.MinLon, []string{"minlongitude", "minlon"}},
+		{&p.MaxLon, []string{"maxlongitude", "maxlon"}},
+		{&p.Lat, []string{"latitude", "lat"}},
+		{&p.Lon, []string{"longitude", "lon"}},
+		{&p.MinRadius, []string{"minradius"}},
+		{&p.MaxRadius, []string{"maxradius"}},
+	}
+	for _, f := range floats {
+		if *f.dest, err = floatParam(q, f.names...); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// timeParam parses the first of names set in q as a time.
+func timeParam(q url.Values, names ...string) (*time.Time, error) {
+	for _, name := range names {
+		if v := q.Get(name); v != "" {
+			t := parseOptionalTime(v)
+			if t == nil {
+				return nil, fmt.Errorf("invalid %s %q", name, v)
+			}
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// floatParam parses the first of names set in q as a number.
+func floatParam(q url.Values, names ...string) (*float64, error) {
+	for _, name := range names {
+		if v := q.Get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, v)
+			}
+			return &f, nil
+		}
+	}
+	return nil, nil
+}
+
+// Geo returns the bounding box and radius constraints as a store filter.
+func (p StationParams) Geo() store.GeoFilter {
+	return store.GeoFilter{
+		MinLatitude:  p.MinLat,
+		MaxLatitude:  p.MaxLat,
+		MinLongitude: p.MinLon,
+		MaxLongitude: p.MaxLon,
+		Latitude:     p.Lat,
+		Longitude:    p.Lon,
+		MinRadius:    p.MinRadius,
+		MaxRadius:    p.MaxRadius,
 	}
+}
 
-	p.MinLat = parseOptionalFloat(q.Get("minlat"))
-	p.MaxLat = parseOptionalFloat(q.Get("maxlat"))
-	p.MinLon = parseOptionalFloat(q.Get("minlon"))
-	p.MaxLon = parseOptionalFloat(q.Get("maxlon"))
+// epochWhere returns the SQL condition applying p's time constraints to the
+// epochs of the networks, stations or channels aliased alias. starttime and
+// endtime select the epochs overlapping the window; an epoch without a start
+// or end is open on that side.
+func (p StationParams) epochWhere(alias string) (string, []any) {
+	conds := []string{"1=1"}
+	var args []any
+	add := func(t *time.Time, cond string) {
+		if t != nil {
+			conds = append(conds, strings.ReplaceAll(cond, "x.", alias+"."))
+			args = append(args, *t)
+		}
+	}
+	add(p.StartTime, "(x.end_time IS NULL OR x.end_time >= ?)")
+	add(p.EndTime, "(x.start_time IS NULL OR x.start_time <= ?)")
+	add(p.StartBefore, "(x.start_time IS NULL OR x.start_time < ?)")
+	add(p.StartAfter, "x.start_time > ?")
+	add(p.EndBefore, "x.end_time < ?")
+	add(p.EndAfter, "(x.end_time IS NULL OR x.end_time > ?)")
+	return strings.Join(conds, " AND "), args
+}
 
-	return p
+// timeFiltered reports whether p constrains epochs by time.
+func (p StationParams) timeFiltered() bool {
+	return p.StartTime != nil || p.EndTime != nil || p.StartBefore != nil ||
+		p.StartAfter != nil || p.EndBefore != nil || p.EndAfter != nil
 }
 
 func splitCSV(s string) []string {
@@ -109,35 +234,6 @@ func parseOptionalTime(s string) *time.Time {
 	return nil
 }
 
-func parseOptionalFloat(s string) *float64 {
-	if s == "" {
-		return nil
-	}
-	var f float64
-	negative := false
-	decimal := false
-	divisor := 1.0
-	for _, c := range s {
-		switch {
-		case c == '-':
-			negative = true
-		case c == '.':
-			decimal = true
-		case c >= '0' && c <= '9':
-			if decimal {
-				divisor *= 10
-				f += float64(c-'0') / divisor
-			} else {
-				f = f*10 + float64(c-'0')
-			}
-		}
-	}
-	if negative {
-		f = -f
-	}
-	return &f
-}
-
 // matchWildcard checks if a string matches a simple wildcard pattern (* and ?).
 func matchWildcard(pattern, s string) bool {
 	if pattern == "*" || pattern == "" {
@@ -176,3 +272,17 @@ func matchAny(patterns []string, value string) bool {
 	}
 	return false
 }
+
+// matchAnyLocation is matchAny for location codes, where "--" selects the
+// empty code.
+func matchAnyLocation(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if matchLocation(p, value) {
+			return true
+		}
+	}
+	return false
+}
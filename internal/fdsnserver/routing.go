@@ -0,0 +1,494 @@
+package fdsnserver
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// Open-ended time bounds in routing output, following the EIDA routing service.
+const (
+	farPast   = "1900-01-01T00:00:00"
+	farFuture = "2599-12-31T23:59:59"
+)
+
+// streamQuery is one NSLC selection (wildcards allowed) with an optional time
+// window. A location of "--" selects the empty location code. Queries
+// expanded from the same request line share a Group.
+type streamQuery struct {
+	Network, Station, Location, Channel string
+	Start, End                          *time.Time
+	Group                               int
+}
+
+// route lists the streams one source serves for a request. Anonymous routes
+// hold streams no local channel matched; their restriction upstream is
+// unknown, so they are fetched without the source's credentials.
+type route struct {
+	Source    models.Source
+	Streams   []fdsnclient.StreamRequest
+	Anonymous bool
+}
+
+// resolveRoutes maps each query to the enabled sources holding matching
+// channels and offering service (sources never probed are assumed to offer
+// every service). Streams are expanded to concrete channel codes.
+//
+// If acc is non-nil, restricted channels it does not allow are left out and
+// denied is set. When no query of a request line matches a local channel, each
+// of its queries falls back to the first source holding a matching network,
+// so streams that were never imported can still be proxied. Such fallback
+// routes are Anonymous, and a restricted network acc does not allow is
+// denied rather than proxied.
+func resolveRoutes(db *sqlx.DB, service string, queries []streamQuery, acc *access) (routes []route, denied bool, err error) {
+	var sources []models.Source
+	if err := db.Select(&sources, "SELECT * FROM sources WHERE enabled = TRUE ORDER BY id"); err != nil {
+		return nil, false, err
+	}
+	offered := make(map[int64]map[string]bool)
+	var svcRows []struct {
+		SourceID int64  `db:"source_id"`
+		Service  string `db:"service"`
+	}
+	if err := db.Select(&svcRows, "SELECT source_id, service FROM source_services"); err != nil {
+		return nil, false, err
+	}
+	for _, s := range svcRows {
+		if offered[s.SourceID] == nil {
+			offered[s.SourceID] = make(map[string]bool)
+		}
+		offered[s.SourceID][s.Service] = true
+	}
+	usable := make(map[int64]int) // source id -> index in sources
+	for i, s := range sources {
+		if svcs, probed := offered[s.ID]; !probed || svcs[service] {
+			usable[s.ID] = i
+		}
+	}
+
+	chans, err := matchingChannels(db, queries)
+	if err != nil {
+		return nil, false, err
+	}
+
+	type routeKey struct {
+		sourceID  int64
+		anonymous bool
+	}
+	byKey := make(map[routeKey]*route)
+	var order []routeKey
+	seen := make(map[string]bool)
+	add := func(sourceID int64, anonymous bool, sr fdsnclient.StreamRequest) {
+		key := fmt.Sprintf("%d|%t|%s", sourceID, anonymous, sr.Line())
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		k := routeKey{sourceID, anonymous}
+		rt, ok := byKey[k]
+		if !ok {
+			rt = &route{Source: sources[usable[sourceID]], Anonymous: anonymous}
+			byKey[k] = rt
+			order = append(order, k)
+		}
+		rt.Streams = append(rt.Streams, sr)
+	}
+
+	matchedGroup := make(map[int]bool)
+	for _, q := range queries {
+		for _, c := range chans {
+			if _, ok := usable[c.SourceID]; !ok {
+				continue
+			}
+			if !matchWildcard(q.Network, c.Network) || !matchWildcard(q.Station, c.Station) ||
+				!matchLocation(q.Location, c.Location) || !matchWildcard(q.Channel, c.Channel) {
+				continue
+			}
+			if !overlaps(q.Start, q.End, c.StartTime, c.EndTime) {
+				continue
+			}
+			matchedGroup[q.Group] = true
+			if acc != nil && !acc.allows(c.Network, c.Restricted) {
+				denied = true
+				continue
+			}
+			add(c.SourceID, false, fdsnclient.StreamRequest{
+				Network:   c.Network,
+				Station:   c.Station,
+				Location:  c.Location,
+				Channel:   c.Channel,
+				StartTime: routeTime(q.Start, c.StartTime, farPast),
+				EndTime:   routeTime(q.End, c.EndTime, farFuture),
+			})
+		}
+	}
+
+	var nets []struct {
+		SourceID   int64  `db:"source_id"`
+		Code       string `db:"code"`
+		Restricted bool   `db:"restricted"`
+	}
+	for _, q := range queries {
+		if matchedGroup[q.Group] {
+			continue
+		}
+		if nets == nil {
+			err := db.Select(&nets, `SELECT n.source_id, n.code, `+store.RestrictedExpr("n", "", "")+` AS restricted
+				FROM networks n ORDER BY n.source_id, n.code`)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		for _, n := range nets {
+			if _, ok := usable[n.SourceID]; !ok || !matchWildcard(q.Network, n.Code) {
+				continue
+			}
+			if acc != nil && !acc.allows(n.Code, n.Restricted) {
+				denied = true
+				break
+			}
+			loc := q.Location
+			if loc == "--" {
+				loc = ""
+			}
+			// Routing answers (nil acc) are not fetched here, so they keep
+			// one route per source
+			add(n.SourceID, acc != nil, fdsnclient.StreamRequest{
+				Network:   q.Network,
+				Station:   q.Station,
+				Location:  loc,
+				Channel:   q.Channel,
+				StartTime: routeTime(q.Start, nil, farPast),
+				EndTime:   routeTime(q.End, nil, farFuture),
+			})
+			break
+		}
+	}
+
+	for _, k := range order {
+		routes = append(routes, *byKey[k])
+	}
+	return routes, denied, nil
+}
+
+// routeBatch is how many stream queries one channel lookup covers, keeping
+// the OR-ed filter well within SQLite's expression depth limit.
+const routeBatch = 100
+
+// channelRow is a local channel a stream query matched.
+type channelRow struct {
+	ID         int64      `db:"id"`
+	SourceID   int64      `db:"source_id"`
+	Network    string     `db:"network_code"`
+	Station    string     `db:"station_code"`
+	Location   string     `db:"location_code"`
+	Channel    string     `db:"channel_code"`
+	StartTime  *time.Time `db:"start_time"`
+	EndTime    *time.Time `db:"end_time"`
+	Restricted bool       `db:"restricted"`
+}
+
+// matchingChannels returns the channels of enabled sources that any of
+// queries selects, ordered by source and codes. The codes and time windows
+// are filtered in SQL; LIKE is broader than the FDSN wildcards on SQLite,
+// where it ignores case, so callers still match each row to its queries.
+func matchingChannels(db *sqlx.DB, queries []streamQuery) ([]channelRow, error) {
+	var out []channelRow
+	seen := make(map[int64]bool)
+	for batch := range slices.Chunk(queries, routeBatch) {
+		var conds []string
+		var args []any
+		for _, q := range batch {
+			cond, a := q.where()
+			conds = append(conds, cond)
+			args = append(args, a...)
+		}
+		var rows []channelRow
+		err := db.Select(&rows, `SELECT c.id, n.source_id, n.code AS network_code, s.code AS station_code,
+			c.location_code, c.code AS channel_code, c.start_time, c.end_time,
+			`+store.RestrictedExpr("n", "s", "c")+` AS restricted
+			FROM channels c
+			JOIN stations s ON c.station_id = s.id
+			JOIN networks n ON s.network_id = n.id
+			WHERE `+store.SourceEnabledExpr("n")+` AND (`+strings.Join(conds, " OR ")+`)
+			ORDER BY n.source_id, n.code, s.code, c.location_code, c.code`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				out = append(out, r)
+			}
+		}
+	}
+	if len(queries) > routeBatch {
+		sort.SliceStable(out, func(i, j int) bool {
+			a, b := out[i], out[j]
+			return cmp.Or(cmp.Compare(a.SourceID, b.SourceID), cmp.Compare(a.Network, b.Network),
+				cmp.Compare(a.Station, b.Station), cmp.Compare(a.Location, b.Location),
+				cmp.Compare(a.Channel, b.Channel)) < 0
+		})
+	}
+	return out, nil
+}
+
+// where returns the SQL filter for the channels q selects, over channels c,
+// stations s and networks n.
+func (q streamQuery) where() (string, []any) {
+	conds := []string{"1=1"}
+	var args []any
+	like := func(column, pattern string) {
+		if pattern == "" || pattern == "*" {
+			return
+		}
+		conds = append(conds, column+` LIKE ? ESCAPE '\'`)
+		args = append(args, store.WildcardToLike(pattern))
+	}
+	like("n.code", q.Network)
+	like("s.code", q.Station)
+	if q.Location == "--" {
+		conds = append(conds, "c.location_code = ''")
+	} else {
+		like("c.location_code", q.Location)
+	}
+	like("c.code", q.Channel)
+	if q.Start != nil {
+		conds = append(conds, "(c.end_time IS NULL OR c.end_time >= ?)")
+		args = append(args, *q.Start)
+	}
+	if q.End != nil {
+		conds = append(conds, "(c.start_time IS NULL OR c.start_time <= ?)")
+		args = append(args, *q.End)
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
+
+// overlaps reports whether the request window [qs, qe] intersects the epoch
+// [cs, ce]; nil bounds are open.
+func overlaps(qs, qe, cs, ce *time.Time) bool {
+	if qe != nil && cs != nil && cs.After(*qe) {
+		return false
+	}
+	if qs != nil && ce != nil && ce.Before(*qs) {
+		return false
+	}
+	return true
+}
+
+// routeTime picks the requested bound, else the channel epoch bound, else def.
+func routeTime(requested, epoch *time.Time, def string) string {
+	switch {
+	case requested != nil:
+		return formatTime(requested)
+	case epoch != nil:
+		return formatTime(epoch)
+	default:
+		return def
+	}
+}
+
+// matchLocation is matchWildcard with "--" selecting the empty location code.
+func matchLocation(pattern, s string) bool {
+	if pattern == "--" {
+		return s == ""
+	}
+	return matchWildcard(pattern, s)
+}
+
+// parseStreamQueries reads stream selections from a request, along with its
+// options: the query parameters and the key=value lines of a POST body. A
+// POST body uses the FDSN bulk format ("NET STA LOC CHA START END" per line);
+// otherwise the comma-separated net/sta/loc/cha query parameters are expanded
+// into one query per combination.
+func parseStreamQueries(r *http.Request, requireTime bool) ([]streamQuery, url.Values, error) {
+	q := r.URL.Query()
+	if r.Method == http.MethodPost {
+		qs, err := parseBulkBody(r, q)
+		if err != nil || len(qs) > 0 {
+			return qs, q, err
+		}
+	}
+
+	param := func(long, short, def string) []string {
+		v := q.Get(long)
+		if v == "" {
+			v = q.Get(short)
+		}
+		if v == "" {
+			return []string{def}
+		}
+		return splitCSV(v)
+	}
+	start := parseOptionalTime(firstNonEmpty(q.Get("starttime"), q.Get("start")))
+	end := parseOptionalTime(firstNonEmpty(q.Get("endtime"), q.Get("end")))
+	if requireTime && (start == nil || end == nil) {
+		return nil, q, fmt.Errorf("starttime and endtime are required")
+	}
+
+	var out []streamQuery
+	for _, n := range param("network", "net", "*") {
+		for _, s := range param("station", "sta", "*") {
+			for _, l := range param("location", "loc", "*") {
+				for _, c := range param("channel", "cha", "*") {
+					out = append(out, streamQuery{Network: n, Station: s, Location: l, Channel: c, Start: start, End: end})
+				}
+			}
+		}
+	}
+	return out, q, nil
+}
+
+// parseBulkBody reads the request lines of a POST body, setting its
+// key=value lines in opts.
+func parseBulkBody(r *http.Request, opts url.Values) ([]streamQuery, error) {
+	var out []streamQuery
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			opts.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 6 {
+			return nil, fmt.Errorf("invalid request line %q: want NET STA LOC CHA START END", line)
+		}
+		q := streamQuery{Network: f[0], Station: f[1], Location: f[2], Channel: f[3], Group: len(out)}
+		if f[4] != "*" {
+			if q.Start = parseOptionalTime(f[4]); q.Start == nil {
+				return nil, fmt.Errorf("invalid start time %q", f[4])
+			}
+		}
+		if f[5] != "*" {
+			if q.End = parseOptionalTime(f[5]); q.End == nil {
+				return nil, fmt.Errorf("invalid end time %q", f[5])
+			}
+		}
+		out = append(out, q)
+	}
+	return out, sc.Err()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// routingHandler serves /fdsnws/routing/1, telling clients which source
+// serves which streams, in the style of the EIDA routing service.
+type routingHandler struct {
+	db *sqlx.DB
+}
+
+func (h *routingHandler) query(w http.ResponseWriter, r *http.Request) {
+	queries, opts, err := parseStreamQueries(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	service := opts.Get("service")
+	if service == "" {
+		service = "dataselect"
+	}
+	known := false
+	for _, s := range fdsnclient.Services {
+		known = known || s == service
+	}
+	if !known {
+		http.Error(w, "Unsupported service "+service, http.StatusBadRequest)
+		return
+	}
+	format := opts.Get("format")
+	if format == "" {
+		format = "post"
+	}
+	routes, _, err := resolveRoutes(h.db, service, queries, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(routes) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	serviceURL := func(src models.Source) string {
+		return strings.TrimRight(src.BaseURL, "/") + "/fdsnws/" + service + "/1/query"
+	}
+
+	switch format {
+	case "post":
+		w.Header().Set("Content-Type", "text/plain")
+		for i, rt := range routes {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, serviceURL(rt.Source))
+			for _, s := range rt.Streams {
+				fmt.Fprintln(w, s.Line())
+			}
+		}
+	case "get":
+		w.Header().Set("Content-Type", "text/plain")
+		for _, rt := range routes {
+			for _, s := range rt.Streams {
+				v := url.Values{}
+				v.Set("net", s.Network)
+				v.Set("sta", s.Station)
+				v.Set("loc", locationParam(s.Location))
+				v.Set("cha", s.Channel)
+				v.Set("starttime", s.StartTime)
+				v.Set("endtime", s.EndTime)
+				fmt.Fprintln(w, serviceURL(rt.Source)+"?"+v.Encode())
+			}
+		}
+	case "json":
+		type jsonRoute struct {
+			URL        string                     `json:"url"`
+			Name       string                     `json:"name"`
+			DataCenter string                     `json:"datacenter"`
+			Params     []fdsnclient.StreamRequest `json:"params"`
+		}
+		out := make([]jsonRoute, 0, len(routes))
+		for _, rt := range routes {
+			out = append(out, jsonRoute{URL: serviceURL(rt.Source), Name: service, DataCenter: rt.Source.Name, Params: rt.Streams})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+	}
+}
+
+func locationParam(loc string) string {
+	if loc == "" {
+		return "--"
+	}
+	return loc
+}
+
+func (h *routingHandler) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, "1.0.0")
+}
@@ -0,0 +1,143 @@
+package fdsnserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/secrets"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+func TestResolveRoutes(t *testing.T) {
+	db := seedConformanceDB(t)
+	day := func(y int) *time.Time {
+		t := time.Date(y, 6, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	anonymous := &access{}
+	alice := &access{user: &models.User{Username: "alice", Networks: "XX"}, includeRestricted: true}
+	resolve := func(q streamQuery, acc *access) ([]route, bool) {
+		t.Helper()
+		routes, denied, err := resolveRoutes(db.DB, "dataselect", []streamQuery{q}, acc)
+		if err != nil {
+			t.Fatalf("resolveRoutes: %v", err)
+		}
+		return routes, denied
+	}
+	lines := func(rt route) string {
+		var out []string
+		for _, s := range rt.Streams {
+			out = append(out, s.Line())
+		}
+		return strings.Join(out, "\n")
+	}
+
+	// Wildcards expand to the local channels within the window
+	routes, _ := resolve(streamQuery{Network: "IU", Station: "A*", Location: "00", Channel: "BH?", Start: day(2010), End: day(2011)}, anonymous)
+	want := "IU ANMO 00 BH1 2010-06-01T00:00:00 2011-06-01T00:00:00\n" +
+		"IU ANMO 00 BH2 2010-06-01T00:00:00 2011-06-01T00:00:00\n" +
+		"IU ANMO 00 BHZ 2010-06-01T00:00:00 2011-06-01T00:00:00"
+	if len(routes) != 1 || routes[0].Anonymous || lines(routes[0]) != want {
+		t.Errorf("routes = %+v, want\n%s", routes, want)
+	}
+
+	// The lookup itself filters codes and windows
+	chans, err := matchingChannels(db.DB, []streamQuery{
+		{Network: "IU", Station: "COLA", Location: "*", Channel: "*", Start: day(2021), End: day(2022)},
+		{Network: "NZ", Station: "*", Location: "--", Channel: "*"},
+	})
+	if err != nil {
+		t.Fatalf("matchingChannels: %v", err)
+	}
+	if len(chans) != 1 || chans[0].Station != "WEL" || chans[0].Channel != "LDO" {
+		t.Errorf("matchingChannels = %+v, want NZ.WEL..LDO only", chans)
+	}
+
+	// A window after the COLA epoch falls back to the network's source,
+	// fetched anonymously
+	routes, _ = resolve(streamQuery{Network: "IU", Station: "COLA", Location: "*", Channel: "LHZ", Start: day(2021), End: day(2022)}, anonymous)
+	if len(routes) != 1 || !routes[0].Anonymous || len(routes[0].Streams) != 1 {
+		t.Errorf("fallback routes = %+v, want one anonymous stream", routes)
+	}
+	if routes, _ = resolve(streamQuery{Network: "IU", Station: "COLA", Location: "*", Channel: "LHZ", Start: day(2021), End: day(2022)}, nil); len(routes) != 1 || routes[0].Anonymous {
+		t.Errorf("routing fallback = %+v, want one route", routes)
+	}
+
+	// Restricted channels and, through the fallback, restricted networks
+	// are denied to callers who may not see them
+	if routes, denied := resolve(streamQuery{Network: "XX", Station: "TMP1", Location: "*", Channel: "*", Start: day(2022), End: day(2023)}, anonymous); !denied || len(routes) != 0 {
+		t.Errorf("restricted channel: routes %+v, denied %v", routes, denied)
+	}
+	var xx int64
+	db.Get(&xx, "SELECT id FROM networks WHERE code = 'XX'")
+	if err := store.NewStationStore(db).SetRestricted(store.LevelNetwork, xx, true); err != nil {
+		t.Fatalf("SetRestricted: %v", err)
+	}
+	unknown := streamQuery{Network: "XX", Station: "NEW", Location: "*", Channel: "HHZ", Start: day(2022), End: day(2023)}
+	if routes, denied := resolve(unknown, anonymous); !denied || len(routes) != 0 {
+		t.Errorf("restricted network fallback: routes %+v, denied %v", routes, denied)
+	}
+	if routes, denied := resolve(unknown, alice); denied || len(routes) != 1 || !routes[0].Anonymous {
+		t.Errorf("restricted network fallback for alice: routes %+v, denied %v", routes, denied)
+	}
+}
+
+func TestDataselectFallback(t *testing.T) {
+	db := seedConformanceDB(t)
+
+	var paths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = io.WriteString(w, "mseed")
+	}))
+	defer upstream.Close()
+	db.MustExec("UPDATE sources SET base_url = ? WHERE id = 1", upstream.URL)
+
+	box, err := secrets.New(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("secrets.New: %v", err)
+	}
+	creds := store.NewCredentialStore(db, box)
+	if err := creds.Set(1, &models.SourceCredentials{Username: "portal", Password: "secret"}); err != nil {
+		t.Fatalf("Set credentials: %v", err)
+	}
+
+	srv := httptest.NewServer(NewRouter(db, creds, "", nil))
+	defer srv.Close()
+	get := func(query string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/dataselect/1/query?" + query)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// A stream that was never imported is proxied without credentials
+	status, body := get("net=IU&sta=NEW&cha=BHZ&starttime=2024-01-01T00:00:00&endtime=2024-01-01T01:00:00")
+	if status != http.StatusOK || body != "mseed" {
+		t.Errorf("fallback: status %d, body %q", status, body)
+	}
+	if len(paths) != 1 || paths[0] != "/fdsnws/dataselect/1/query" {
+		t.Errorf("upstream paths = %v, want the anonymous query method", paths)
+	}
+
+	// No source holds the network
+	window := "&starttime=2024-01-01T00:00:00&endtime=2024-01-01T01:00:00"
+	if status, _ := get("net=ZZ" + window); status != http.StatusNoContent {
+		t.Errorf("unknown network: status %d, want 204", status)
+	}
+	if status, _ := get("net=ZZ&nodata=404" + window); status != http.StatusNotFound {
+		t.Errorf("unknown network with nodata=404: status %d, want 404", status)
+	}
+	if status, _ := get("net=ZZ&nodata=500" + window); status != http.StatusBadRequest {
+		t.Errorf("invalid nodata: status %d, want 400", status)
+	}
+}
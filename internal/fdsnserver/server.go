@@ -2,16 +2,32 @@ package fdsnserver
 
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+	"github.com/joescharf/fdsn/internal/wavecache"
 )
 
 // NewRouter creates a chi sub-router for the /fdsnws/* FDSN-compliant endpoints.
-func NewRouter(db *sqlx.DB) chi.Router {
+// The services only read, so they query db's read-only pool and never wait on
+// an import. creds supplies upstream credentials for proxied dataselect
+// requests. schemaVersion is the StationXML version written when a request
+// does not ask for one; "" means 1.1. waves caches proxied dataselect data;
+// nil disables caching.
+func NewRouter(db *database.DB, creds store.CredentialStore, schemaVersion string, waves *wavecache.Cache) chi.Router {
 	r := chi.NewRouter()
 
-	station := &stationHandler{db: db}
-	dataselect := &dataselectHandler{db: db}
-	avail := &availabilityHandler{db: db}
+	if schemaVersion == "" {
+		schemaVersion = models.StationXMLVersion11
+	}
+	station := &stationHandler{db: db.Reader, schemaVersion: schemaVersion}
+	dataselect := &dataselectHandler{db: db.Reader, creds: creds, clients: fdsnclient.NewPool(), cache: waves}
+	avail := &availabilityHandler{db: db.Reader}
+	routing := &routingHandler{db: db.Reader}
+	event := &eventHandler{events: store.NewEventStore(db)}
+	auth := newAuthenticator(store.NewUserStore(db))
 
 	// Station service
 	r.Route("/station/1", func(r chi.Router) {
@@ -19,6 +35,8 @@ func NewRouter(db *sqlx.DB) chi.Router {
 		r.Get("/application.wadl", stationWADL)
 		r.Get("/query", station.query)
 		r.Post("/query", station.query)
+		r.Get("/queryauth", auth.require(station.query))
+		r.Post("/queryauth", auth.require(station.query))
 	})
 
 	// Dataselect service
@@ -27,6 +45,8 @@ func NewRouter(db *sqlx.DB) chi.Router {
 		r.Get("/application.wadl", dataselectWADL)
 		r.Get("/query", dataselect.query)
 		r.Post("/query", dataselect.query)
+		r.Get("/queryauth", auth.require(dataselect.query))
+		r.Post("/queryauth", auth.require(dataselect.query))
 	})
 
 	// Availability service
@@ -37,6 +57,27 @@ func NewRouter(db *sqlx.DB) chi.Router {
 		r.Post("/query", avail.query)
 		r.Get("/extent", avail.extent)
 		r.Post("/extent", avail.extent)
+		r.Get("/queryauth", auth.require(avail.query))
+		r.Post("/queryauth", auth.require(avail.query))
+		r.Get("/extentauth", auth.require(avail.extent))
+		r.Post("/extentauth", auth.require(avail.extent))
+	})
+
+	// Event service: events imported from upstream event services
+	r.Route("/event/1", func(r chi.Router) {
+		r.Get("/version", event.version)
+		r.Get("/application.wadl", eventWADL)
+		r.Get("/catalogs", event.catalogs)
+		r.Get("/contributors", event.contributors)
+		r.Get("/query", event.query)
+	})
+
+	// Routing service: which source serves which streams
+	r.Route("/routing/1", func(r chi.Router) {
+		r.Get("/version", routing.version)
+		r.Get("/application.wadl", routingWADL)
+		r.Get("/query", routing.query)
+		r.Post("/query", routing.query)
 	})
 
 	return r
@@ -1,119 +1,173 @@
 package fdsnserver
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/joescharf/fdsn/internal/export"
 	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
 )
 
 type stationHandler struct {
-	db *sqlx.DB
+	db            *sqlx.DB
+	schemaVersion string // default StationXML version
+}
+
+// SupportedSchemaVersion reports whether v is a StationXML version the
+// station service can write.
+func SupportedSchemaVersion(v string) bool {
+	return v == models.StationXMLVersion11 || v == models.StationXMLVersion12
 }
 
 func (h *stationHandler) query(w http.ResponseWriter, r *http.Request) {
-	params := ParseStationParams(r)
+	params, err := ParseStationParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	acc := requestAccess(r, params.IncludeRestricted)
+	if params.SchemaVersion == "" {
+		params.SchemaVersion = h.schemaVersion
+	}
+	if !SupportedSchemaVersion(params.SchemaVersion) {
+		http.Error(w, "Unsupported schemaversion "+params.SchemaVersion+": use 1.1 or 1.2", http.StatusBadRequest)
+		return
+	}
 
 	switch params.Format {
 	case "text":
-		h.queryText(w, params)
+		h.queryText(w, params, acc)
 	case "xml":
-		h.queryXML(w, params)
+		h.queryXML(w, params, acc)
+	case export.GeoJSON, export.KML, export.CSV:
+		h.queryExport(w, params, acc)
 	default:
 		http.Error(w, "Unsupported format", http.StatusBadRequest)
 	}
 }
 
-func (h *stationHandler) queryText(w http.ResponseWriter, p StationParams) {
-	w.Header().Set("Content-Type", "text/plain")
-
+func (h *stationHandler) queryText(w http.ResponseWriter, p StationParams, acc access) {
+	// Buffered so an empty result can still be answered with p.NoData
+	var buf bytes.Buffer
+	var n int
+	var err error
 	switch p.Level {
 	case "network":
-		h.queryTextNetworks(w, p)
-	case "station":
-		h.queryTextStations(w, p)
+		n, err = h.queryTextNetworks(&buf, p, acc)
 	case "channel":
-		h.queryTextChannels(w, p)
+		n, err = h.queryTextChannels(&buf, p, acc)
 	default:
-		h.queryTextStations(w, p)
+		n, err = h.queryTextStations(&buf, p, acc)
 	}
-}
-
-func (h *stationHandler) queryTextNetworks(w http.ResponseWriter, p StationParams) {
-	rows, err := h.db.Queryx(`SELECT DISTINCT n.code, n.description, n.start_time, n.end_time
-		FROM networks n ORDER BY n.code`)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
+	if n == 0 {
+		writeNoData(w, p.NoData)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = buf.WriteTo(w)
+}
+
+func (h *stationHandler) queryTextNetworks(w io.Writer, p StationParams, acc access) (int, error) {
+	type row struct {
+		ID          int64      `db:"id"`
+		Code        string     `db:"code"`
+		Description string     `db:"description"`
+		StartTime   *time.Time `db:"start_time"`
+		EndTime     *time.Time `db:"end_time"`
+		Restricted  bool       `db:"restricted"`
+		dataCenter
+	}
+	epoch, epochArgs := p.epochWhere("n")
+	var rows []row
+	err := h.db.Select(&rows, `SELECT n.id, n.code, COALESCE(n.description, '') AS description, n.start_time, n.end_time,
+		`+store.RestrictedExpr("n", "", "")+` AS restricted, `+dataCenterColumns+`
+		FROM networks n `+dataCenterJoin+`
+		WHERE `+epoch+` AND `+store.SourceEnabledExpr("n")+`
+		ORDER BY dc_name, n.code`, epochArgs...)
+	if err != nil {
+		return 0, err
+	}
 
 	fmt.Fprintln(w, "#Network|Description|StartTime|EndTime|TotalStations")
-	for rows.Next() {
-		var code, desc string
-		var start, end *time.Time
-		if err := rows.Scan(&code, &desc, &start, &end); err != nil {
+	var dc dataCenterLines
+	n := 0
+	for _, r := range rows {
+		if !matchAny(p.Network, r.Code) || !acc.allows(r.Code, r.Restricted) {
 			continue
 		}
-		if !matchAny(p.Network, code) {
-			continue
+		// Count stations visible to this request
+		staEpoch, staArgs := p.epochWhere("s")
+		countQ := "SELECT COUNT(*) FROM stations s JOIN networks n ON s.network_id = n.id WHERE n.id = ? AND " + staEpoch
+		if !acc.allows(r.Code, true) {
+			countQ += " AND NOT " + store.RestrictedExpr("n", "s", "")
 		}
-		// Count stations
 		var count int
-		_ = h.db.Get(&count, "SELECT COUNT(*) FROM stations s JOIN networks n ON s.network_id = n.id WHERE n.code = ?", code)
+		_ = h.db.Get(&count, countQ, append([]any{r.ID}, staArgs...)...)
+		dc.write(w, r.dataCenter)
+		n++
 		fmt.Fprintf(w, "%s|%s|%s|%s|%d\n",
-			code, desc, formatTime(start), formatTime(end), count)
+			r.Code, r.Description, formatTime(r.StartTime), formatTime(r.EndTime), count)
 	}
+	return n, nil
 }
 
-func (h *stationHandler) queryTextStations(w http.ResponseWriter, p StationParams) {
+func (h *stationHandler) queryTextStations(w io.Writer, p StationParams, acc access) (int, error) {
 	type row struct {
-		Network   string     `db:"network_code"`
-		Station   string     `db:"code"`
-		Latitude  float64    `db:"latitude"`
-		Longitude float64    `db:"longitude"`
-		Elevation float64    `db:"elevation"`
-		SiteName  string     `db:"site_name"`
-		StartTime *time.Time `db:"start_time"`
-		EndTime   *time.Time `db:"end_time"`
+		Network    string     `db:"network_code"`
+		Station    string     `db:"code"`
+		Latitude   float64    `db:"latitude"`
+		Longitude  float64    `db:"longitude"`
+		Elevation  float64    `db:"elevation"`
+		SiteName   string     `db:"site_name"`
+		StartTime  *time.Time `db:"start_time"`
+		EndTime    *time.Time `db:"end_time"`
+		Restricted bool       `db:"restricted"`
+		dataCenter
 	}
 
+	geo, geoArgs := h.geoWhere(p)
+	epoch, epochArgs := p.epochWhere("s")
 	var rows []row
-	err := h.db.Select(&rows, `SELECT n.code AS network_code, s.code, s.latitude, s.longitude, s.elevation, s.site_name, s.start_time, s.end_time
-		FROM stations s JOIN networks n ON s.network_id = n.id
-		ORDER BY n.code, s.code`)
+	err := h.db.Select(&rows, `SELECT n.code AS network_code, s.code, s.latitude, s.longitude, s.elevation, COALESCE(s.site_name, '') AS site_name, s.start_time, s.end_time,
+		`+store.RestrictedExpr("n", "s", "")+` AS restricted, `+dataCenterColumns+`
+		FROM stations s JOIN networks n ON s.network_id = n.id `+dataCenterJoin+`
+		WHERE `+geo+` AND `+epoch+` AND `+store.SourceEnabledExpr("n")+`
+		ORDER BY dc_name, n.code, s.code`, append(geoArgs, epochArgs...)...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return 0, err
 	}
 
 	fmt.Fprintln(w, "#Network|Station|Latitude|Longitude|Elevation|SiteName|StartTime|EndTime")
+	var dc dataCenterLines
+	n := 0
 	for _, r := range rows {
 		if !matchAny(p.Network, r.Network) || !matchAny(p.Station, r.Station) {
 			continue
 		}
-		if p.MinLat != nil && r.Latitude < *p.MinLat {
-			continue
-		}
-		if p.MaxLat != nil && r.Latitude > *p.MaxLat {
-			continue
-		}
-		if p.MinLon != nil && r.Longitude < *p.MinLon {
-			continue
-		}
-		if p.MaxLon != nil && r.Longitude > *p.MaxLon {
+		if !acc.allows(r.Network, r.Restricted) {
 			continue
 		}
+		dc.write(w, r.dataCenter)
+		n++
 		fmt.Fprintf(w, "%s|%s|%.6f|%.6f|%.1f|%s|%s|%s\n",
 			r.Network, r.Station, r.Latitude, r.Longitude, r.Elevation,
 			r.SiteName, formatTime(r.StartTime), formatTime(r.EndTime))
 	}
+	return n, nil
 }
 
-func (h *stationHandler) queryTextChannels(w http.ResponseWriter, p StationParams) {
+func (h *stationHandler) queryTextChannels(w io.Writer, p StationParams, acc access) (int, error) {
 	type row struct {
 		Network    string     `db:"network_code"`
 		Station    string     `db:"station_code"`
@@ -132,31 +186,45 @@ func (h *stationHandler) queryTextChannels(w http.ResponseWriter, p StationParam
 		SampleRate float64    `db:"sample_rate"`
 		StartTime  *time.Time `db:"start_time"`
 		EndTime    *time.Time `db:"end_time"`
+		Restricted bool       `db:"restricted"`
+		dataCenter
 	}
 
+	geo, geoArgs := h.geoWhere(p)
+	epoch, epochArgs := p.epochWhere("c")
 	var rows []row
 	err := h.db.Select(&rows, `SELECT n.code AS network_code, s.code AS station_code,
 		c.location_code, c.code AS channel_code,
-		c.latitude, c.longitude, c.elevation, c.depth,
-		c.azimuth, c.dip, c.sensor_description, c.scale, c.scale_freq, c.scale_units, c.sample_rate,
-		c.start_time, c.end_time
+		COALESCE(c.latitude, s.latitude) AS latitude, COALESCE(c.longitude, s.longitude) AS longitude,
+		COALESCE(c.elevation, s.elevation) AS elevation, COALESCE(c.depth, 0) AS depth,
+		COALESCE(c.azimuth, 0) AS azimuth, COALESCE(c.dip, 0) AS dip, COALESCE(c.sensor_description, '') AS sensor_description,
+		COALESCE(c.scale, 0) AS scale, COALESCE(c.scale_freq, 0) AS scale_freq, COALESCE(c.scale_units, '') AS scale_units,
+		COALESCE(c.sample_rate, 0) AS sample_rate,
+		c.start_time, c.end_time, `+store.RestrictedExpr("n", "s", "c")+` AS restricted, `+dataCenterColumns+`
 		FROM channels c
 		JOIN stations s ON c.station_id = s.id
-		JOIN networks n ON s.network_id = n.id
-		ORDER BY n.code, s.code, c.location_code, c.code`)
+		JOIN networks n ON s.network_id = n.id `+dataCenterJoin+`
+		WHERE `+geo+` AND `+epoch+` AND `+store.SourceEnabledExpr("n")+`
+		ORDER BY dc_name, n.code, s.code, c.location_code, c.code`, append(geoArgs, epochArgs...)...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return 0, err
 	}
 
 	fmt.Fprintln(w, "#Network|Station|Location|Channel|Latitude|Longitude|Elevation|Depth|Azimuth|Dip|SensorDescription|Scale|ScaleFreq|ScaleUnits|SampleRate|StartTime|EndTime")
+	var dc dataCenterLines
+	n := 0
 	for _, r := range rows {
 		if !matchAny(p.Network, r.Network) || !matchAny(p.Station, r.Station) {
 			continue
 		}
-		if !matchAny(p.Channel, r.Channel) || !matchAny(p.Location, r.Location) {
+		if !matchAny(p.Channel, r.Channel) || !matchAnyLocation(p.Location, r.Location) {
+			continue
+		}
+		if !acc.allows(r.Network, r.Restricted) {
 			continue
 		}
+		dc.write(w, r.dataCenter)
+		n++
 		fmt.Fprintf(w, "%s|%s|%s|%s|%.6f|%.6f|%.1f|%.1f|%.1f|%.1f|%s|%.4e|%.4f|%s|%.1f|%s|%s\n",
 			r.Network, r.Station, r.Location, r.Channel,
 			r.Latitude, r.Longitude, r.Elevation, r.Depth,
@@ -164,13 +232,22 @@ func (h *stationHandler) queryTextChannels(w http.ResponseWriter, p StationParam
 			r.Scale, r.ScaleFreq, r.ScaleUnits, r.SampleRate,
 			formatTime(r.StartTime), formatTime(r.EndTime))
 	}
+	return n, nil
 }
 
-func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams) {
-	// Build StationXML from database
+func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams, acc access) {
+	// Build StationXML from database. The sourceID attributes only exist in
+	// 1.2.
+	v12 := p.SchemaVersion == models.StationXMLVersion12
+	sourceID := func(id string) string {
+		if v12 {
+			return id
+		}
+		return ""
+	}
 	stationXML := models.FDSNStationXML{
 		XMLNS:     "http://www.fdsn.org/xml/station/1",
-		SchemaVer: "1.1",
+		SchemaVer: p.SchemaVersion,
 		Source:    "FDSN Portal",
 		Sender:    "FDSN",
 		Created:   time.Now().UTC().Format(time.RFC3339),
@@ -178,20 +255,29 @@ func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams) {
 
 	// Get networks
 	type netRow struct {
-		ID          int64      `db:"id"`
-		Code        string     `db:"code"`
-		Description string     `db:"description"`
-		StartTime   *time.Time `db:"start_time"`
-		EndTime     *time.Time `db:"end_time"`
+		ID          int64              `db:"id"`
+		Code        string             `db:"code"`
+		Description string             `db:"description"`
+		StartTime   *time.Time         `db:"start_time"`
+		EndTime     *time.Time         `db:"end_time"`
+		Restricted  bool               `db:"restricted"`
+		SourceID    string             `db:"fdsn_source_id"`
+		Identifiers models.Identifiers `db:"identifiers"`
+		dataCenter
 	}
+	netEpoch, netEpochArgs := p.epochWhere("n")
+	staEpoch, staEpochArgs := p.epochWhere("s")
+	chaEpoch, chaEpochArgs := p.epochWhere("c")
 	var nets []netRow
-	if err := h.db.Select(&nets, "SELECT id, code, description, start_time, end_time FROM networks ORDER BY code"); err != nil {
+	if err := h.db.Select(&nets, "SELECT n.id, n.code, COALESCE(n.description, '') AS description, n.start_time, n.end_time, n.fdsn_source_id, n.identifiers, "+
+		store.RestrictedExpr("n", "", "")+" AS restricted, "+dataCenterColumns+
+		" FROM networks n "+dataCenterJoin+" WHERE "+netEpoch+" AND "+store.SourceEnabledExpr("n")+" ORDER BY n.code, dc_name", netEpochArgs...); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	for _, n := range nets {
-		if !matchAny(p.Network, n.Code) {
+		if !matchAny(p.Network, n.Code) || !acc.allows(n.Code, n.Restricted) {
 			continue
 		}
 
@@ -200,57 +286,95 @@ func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams) {
 			Description: n.Description,
 			StartDate:   formatTime(n.StartTime),
 			EndDate:     formatTime(n.EndTime),
+			Restricted:  restrictedStatus(n.Restricted),
+			SourceID:    sourceID(n.SourceID),
+			Identifiers: n.Identifiers,
+		}
+		if n.Name != "" {
+			xmlNet.Comments = []models.XMLComment{{Subject: "DataCenter", Value: n.dataCenter.String()}}
 		}
 
 		if p.Level == "station" || p.Level == "channel" || p.Level == "response" {
 			type staRow struct {
-				ID        int64      `db:"id"`
-				Code      string     `db:"code"`
-				Lat       float64    `db:"latitude"`
-				Lon       float64    `db:"longitude"`
-				Elev      float64    `db:"elevation"`
-				SiteName  string     `db:"site_name"`
-				StartTime *time.Time `db:"start_time"`
-				EndTime   *time.Time `db:"end_time"`
+				ID          int64              `db:"id"`
+				Code        string             `db:"code"`
+				Lat         float64            `db:"latitude"`
+				Lon         float64            `db:"longitude"`
+				Elev        float64            `db:"elevation"`
+				SiteName    string             `db:"site_name"`
+				StartTime   *time.Time         `db:"start_time"`
+				EndTime     *time.Time         `db:"end_time"`
+				Restricted  bool               `db:"restricted"`
+				SourceID    string             `db:"fdsn_source_id"`
+				Identifiers models.Identifiers `db:"identifiers"`
+				WaterLevel  *float64           `db:"water_level"`
 			}
+			geo, geoArgs := h.geoWhere(p)
 			var stas []staRow
-			_ = h.db.Select(&stas, "SELECT id, code, latitude, longitude, elevation, site_name, start_time, end_time FROM stations WHERE network_id = ? ORDER BY code", n.ID)
+			_ = h.db.Select(&stas, `SELECT s.id, s.code, s.latitude, s.longitude, s.elevation, COALESCE(s.site_name, '') AS site_name, s.start_time, s.end_time,
+				s.fdsn_source_id, s.identifiers, s.water_level,
+				`+store.RestrictedExpr("n", "s", "")+` AS restricted
+				FROM stations s JOIN networks n ON s.network_id = n.id
+				WHERE s.network_id = ? AND `+geo+` AND `+staEpoch+` ORDER BY s.code`, append(append([]any{n.ID}, geoArgs...), staEpochArgs...)...)
 
 			for _, s := range stas {
-				if !matchAny(p.Station, s.Code) {
+				if !matchAny(p.Station, s.Code) || !acc.allows(n.Code, s.Restricted) {
 					continue
 				}
 
 				xmlSta := models.XMLStation{
-					Code:      s.Code,
-					StartDate: formatTime(s.StartTime),
-					EndDate:   formatTime(s.EndTime),
-					Latitude:  models.XMLValue{Value: s.Lat},
-					Longitude: models.XMLValue{Value: s.Lon},
-					Elevation: models.XMLValue{Value: s.Elev},
-					Site:      models.XMLSite{Name: s.SiteName},
+					Code:        s.Code,
+					StartDate:   formatTime(s.StartTime),
+					EndDate:     formatTime(s.EndTime),
+					Restricted:  restrictedStatus(s.Restricted),
+					Latitude:    models.Degrees(s.Lat),
+					Longitude:   models.Degrees(s.Lon),
+					Elevation:   models.Meters(s.Elev),
+					Site:        models.XMLSite{Name: s.SiteName},
+					SourceID:    sourceID(s.SourceID),
+					Identifiers: s.Identifiers,
+					WaterLevel:  meters(s.WaterLevel),
 				}
 
 				if p.Level == "channel" || p.Level == "response" {
 					type chRow struct {
-						Code         string     `db:"code"`
-						LocationCode string     `db:"location_code"`
-						Lat          float64    `db:"latitude"`
-						Lon          float64    `db:"longitude"`
-						Elev         float64    `db:"elevation"`
-						Depth        float64    `db:"depth"`
-						Azimuth      float64    `db:"azimuth"`
-						Dip          float64    `db:"dip"`
-						Sensor       string     `db:"sensor_description"`
-						SampleRate   float64    `db:"sample_rate"`
-						StartTime    *time.Time `db:"start_time"`
-						EndTime      *time.Time `db:"end_time"`
+						Code         string             `db:"code"`
+						LocationCode string             `db:"location_code"`
+						Lat          float64            `db:"latitude"`
+						Lon          float64            `db:"longitude"`
+						Elev         float64            `db:"elevation"`
+						Depth        float64            `db:"depth"`
+						Azimuth      *float64           `db:"azimuth"`
+						Dip          *float64           `db:"dip"`
+						Sensor       string             `db:"sensor_description"`
+						SampleRate   float64            `db:"sample_rate"`
+						Scale        float64            `db:"scale"`
+						ScaleFreq    float64            `db:"scale_freq"`
+						ScaleUnits   string             `db:"scale_units"`
+						StartTime    *time.Time         `db:"start_time"`
+						EndTime      *time.Time         `db:"end_time"`
+						Restricted   bool               `db:"restricted"`
+						SourceID     string             `db:"fdsn_source_id"`
+						Identifiers  models.Identifiers `db:"identifiers"`
+						WaterLevel   *float64           `db:"water_level"`
 					}
 					var chs []chRow
-					_ = h.db.Select(&chs, "SELECT code, location_code, latitude, longitude, elevation, depth, azimuth, dip, sensor_description, sample_rate, start_time, end_time FROM channels WHERE station_id = ? ORDER BY location_code, code", s.ID)
+					_ = h.db.Select(&chs, `SELECT c.code, c.location_code,
+						COALESCE(c.latitude, s.latitude) AS latitude, COALESCE(c.longitude, s.longitude) AS longitude,
+						COALESCE(c.elevation, s.elevation) AS elevation, COALESCE(c.depth, 0) AS depth, c.azimuth, c.dip,
+						COALESCE(c.sensor_description, '') AS sensor_description, COALESCE(c.sample_rate, 0) AS sample_rate,
+						COALESCE(c.scale, 0) AS scale, COALESCE(c.scale_freq, 0) AS scale_freq, COALESCE(c.scale_units, '') AS scale_units, c.start_time, c.end_time,
+						c.fdsn_source_id, c.identifiers, c.water_level, `+store.RestrictedExpr("n", "s", "c")+` AS restricted
+						FROM channels c
+						JOIN stations s ON c.station_id = s.id
+						JOIN networks n ON s.network_id = n.id
+						WHERE c.station_id = ? AND `+chaEpoch+` ORDER BY c.location_code, c.code`, append([]any{s.ID}, chaEpochArgs...)...)
 
 					for _, c := range chs {
-						if !matchAny(p.Channel, c.Code) || !matchAny(p.Location, c.LocationCode) {
+						if !matchAny(p.Channel, c.Code) || !matchAnyLocation(p.Location, c.LocationCode) {
+							continue
+						}
+						if !acc.allows(n.Code, c.Restricted) {
 							continue
 						}
 
@@ -259,28 +383,60 @@ func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams) {
 							LocationCode: c.LocationCode,
 							StartDate:    formatTime(c.StartTime),
 							EndDate:      formatTime(c.EndTime),
-							Latitude:     models.XMLValue{Value: c.Lat},
-							Longitude:    models.XMLValue{Value: c.Lon},
-							Elevation:    models.XMLValue{Value: c.Elev},
-							Depth:        models.XMLValue{Value: c.Depth},
-							Azimuth:      models.XMLValue{Value: c.Azimuth},
-							Dip:          models.XMLValue{Value: c.Dip},
-							SampleRate:   c.SampleRate,
+							Restricted:   restrictedStatus(c.Restricted),
+							Latitude:     models.Degrees(c.Lat),
+							Longitude:    models.Degrees(c.Lon),
+							Elevation:    models.Meters(c.Elev),
+							Depth:        models.Meters(c.Depth),
+							WaterLevel:   meters(c.WaterLevel),
+							SourceID:     sourceID(c.SourceID),
+							Identifiers:  c.Identifiers,
+						}
+						// Unknown orientation and rate are omitted rather than
+						// reported as zero
+						if c.Azimuth != nil {
+							v := models.Degrees(*c.Azimuth)
+							xmlCh.Azimuth = &v
+						}
+						if c.Dip != nil {
+							v := models.Degrees(*c.Dip)
+							xmlCh.Dip = &v
+						}
+						if c.SampleRate > 0 {
+							v := models.SamplesPerSecond(c.SampleRate)
+							xmlCh.SampleRate = &v
 						}
 						if c.Sensor != "" {
 							xmlCh.Sensor = &models.XMLSensor{Description: c.Sensor}
 						}
+						// The response is the overall sensitivity from the
+						// text-format scale, when it is known
+						if p.Level == "response" && c.Scale != 0 && c.ScaleUnits != "" {
+							xmlCh.Response = &models.XMLResponse{InstrumentSensitivity: &models.XMLSensitivity{
+								Value:       c.Scale,
+								Frequency:   c.ScaleFreq,
+								InputUnits:  models.XMLUnits{Name: c.ScaleUnits},
+								OutputUnits: models.XMLUnits{Name: "COUNTS"},
+							}}
+						}
 						xmlSta.Channels = append(xmlSta.Channels, xmlCh)
 					}
 				}
 
 				xmlNet.Stations = append(xmlNet.Stations, xmlSta)
 			}
+			if (len(geoArgs) > 0 || p.timeFiltered()) && len(xmlNet.Stations) == 0 {
+				continue // no stations inside the requested area or window
+			}
 		}
 
 		stationXML.Networks = append(stationXML.Networks, xmlNet)
 	}
 
+	if len(stationXML.Networks) == 0 {
+		writeNoData(w, p.NoData)
+		return
+	}
 	w.Header().Set("Content-Type", "application/xml")
 	_, _ = w.Write([]byte(xml.Header))
 	enc := xml.NewEncoder(w)
@@ -288,11 +444,189 @@ func (h *stationHandler) queryXML(w http.ResponseWriter, p StationParams) {
 	_ = enc.Encode(stationXML)
 }
 
+// queryExport writes the matching stations, with their visible channels and
+// availability, as GeoJSON, KML or CSV. These formats are extensions to the
+// FDSN specification and ignore level: each station is one feature.
+func (h *stationHandler) queryExport(w http.ResponseWriter, p StationParams, acc access) {
+	type staRow struct {
+		ID         int64      `db:"id"`
+		Network    string     `db:"network_code"`
+		NetDesc    *string    `db:"network_description"`
+		Station    string     `db:"code"`
+		Latitude   float64    `db:"latitude"`
+		Longitude  float64    `db:"longitude"`
+		Elevation  float64    `db:"elevation"`
+		SiteName   *string    `db:"site_name"`
+		StartTime  *time.Time `db:"start_time"`
+		EndTime    *time.Time `db:"end_time"`
+		Restricted bool       `db:"restricted"`
+		dataCenter
+	}
+	geo, geoArgs := h.geoWhere(p)
+	staEpoch, staEpochArgs := p.epochWhere("s")
+	var stas []staRow
+	err := h.db.Select(&stas, `SELECT s.id, n.code AS network_code, n.description AS network_description,
+		s.code, s.latitude, s.longitude, s.elevation, s.site_name, s.start_time, s.end_time,
+		`+store.RestrictedExpr("n", "s", "")+` AS restricted, `+dataCenterColumns+`
+		FROM stations s JOIN networks n ON s.network_id = n.id `+dataCenterJoin+`
+		WHERE `+geo+` AND `+staEpoch+` AND `+store.SourceEnabledExpr("n")+`
+		ORDER BY n.code, s.code`, append(geoArgs, staEpochArgs...)...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chaEpoch, chaEpochArgs := p.epochWhere("c")
+	type chRow struct {
+		StationID  int64   `db:"station_id"`
+		Location   string  `db:"location_code"`
+		Channel    string  `db:"code"`
+		Restricted bool    `db:"restricted"`
+		Earliest   *string `db:"earliest"`
+		Latest     *string `db:"latest"`
+	}
+	var chs []chRow
+	err = h.db.Select(&chs, `SELECT c.station_id, COALESCE(c.location_code, '') AS location_code, c.code,
+		`+store.RestrictedExpr("n", "s", "c")+` AS restricted, MIN(a.earliest) AS earliest, MAX(a.latest) AS latest
+		FROM channels c
+		JOIN stations s ON c.station_id = s.id
+		JOIN networks n ON s.network_id = n.id
+		LEFT JOIN availability a ON a.channel_id = c.id
+		WHERE `+geo+` AND `+chaEpoch+` AND `+store.SourceEnabledExpr("n")+`
+		GROUP BY c.id, c.station_id, c.location_code, c.code, n.id, s.id
+		ORDER BY c.location_code, c.code`, append(geoArgs, chaEpochArgs...)...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels := make(map[int64][]chRow)
+	for _, c := range chs {
+		channels[c.StationID] = append(channels[c.StationID], c)
+	}
+
+	var out []models.StationSummary
+	for _, r := range stas {
+		if !matchAny(p.Network, r.Network) || !matchAny(p.Station, r.Station) {
+			continue
+		}
+		if !acc.allows(r.Network, r.Restricted) {
+			continue
+		}
+		sum := models.StationSummary{
+			StationID:  r.ID,
+			Network:    r.Network,
+			Station:    r.Station,
+			Latitude:   r.Latitude,
+			Longitude:  r.Longitude,
+			Elevation:  r.Elevation,
+			StartTime:  r.StartTime,
+			EndTime:    r.EndTime,
+			Restricted: r.Restricted,
+			DataCenter: r.Name,
+		}
+		if r.NetDesc != nil {
+			sum.NetworkDescription = *r.NetDesc
+		}
+		if r.SiteName != nil {
+			sum.SiteName = *r.SiteName
+		}
+		for _, c := range channels[r.ID] {
+			if !matchAny(p.Channel, c.Channel) || !matchAnyLocation(p.Location, c.Location) || !acc.allows(r.Network, c.Restricted) {
+				continue
+			}
+			code := c.Channel
+			if c.Location != "" {
+				code = c.Location + "." + c.Channel
+			}
+			if !slices.Contains(sum.Channels, code) {
+				sum.Channels = append(sum.Channels, code)
+			}
+			if c.Earliest != nil && (sum.AvailabilityStart == nil || *c.Earliest < *sum.AvailabilityStart) {
+				sum.AvailabilityStart = c.Earliest
+			}
+			if c.Latest != nil && (sum.AvailabilityEnd == nil || *c.Latest > *sum.AvailabilityEnd) {
+				sum.AvailabilityEnd = c.Latest
+			}
+		}
+		if len(sum.Channels) == 0 && (len(p.Channel) > 0 || len(p.Location) > 0) {
+			continue // no channel matches the channel or location constraint
+		}
+		out = append(out, sum)
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(p.Format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.Filename(p.Format)+`"`)
+	_ = export.Write(w, p.Format, out)
+}
+
+// writeNoData answers a query that matched nothing with status: 204 No
+// Content, or 404 Not Found if the client asked for it.
+func writeNoData(w http.ResponseWriter, status int) {
+	if status == http.StatusNotFound {
+		http.Error(w, "No data matches the request", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// geoWhere returns the SQL condition applying p's bounding box and radius to
+// stations aliased s, through the spatial index.
+func (h *stationHandler) geoWhere(p StationParams) (string, []any) {
+	cond, args := p.Geo().Where(h.db.DriverName(), "s")
+	if cond == "" {
+		return "1=1", nil
+	}
+	return cond, args
+}
+
 func (h *stationHandler) version(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprint(w, "1.1.0")
 }
 
+// dataCenter identifies the source a row was imported from. Rows whose
+// source has been deleted have an empty Name.
+type dataCenter struct {
+	Name string `db:"dc_name"`
+	URL  string `db:"dc_url"`
+}
+
+// dataCenterColumns and dataCenterJoin add the dataCenter fields to a query
+// over networks aliased n.
+const (
+	dataCenterColumns = "COALESCE(sr.name, '') AS dc_name, COALESCE(sr.base_url, '') AS dc_url"
+	dataCenterJoin    = "LEFT JOIN sources sr ON n.source_id = sr.id"
+)
+
+// String formats the data centre as "name,url", as in IRIS fedcatalog output.
+func (d dataCenter) String() string {
+	return d.Name + "," + d.URL
+}
+
+// dataCenterLines writes a "#DATACENTER=name,url" line to text output
+// whenever the source of the following rows changes.
+type dataCenterLines struct {
+	last    dataCenter
+	started bool
+}
+
+func (l *dataCenterLines) write(w io.Writer, dc dataCenter) {
+	if (l.started && dc == l.last) || dc.Name == "" {
+		return
+	}
+	l.last, l.started = dc, true
+	fmt.Fprintf(w, "#DATACENTER=%s\n", dc)
+}
+
+// meters returns v as a StationXML distance, or nil if v is unknown.
+func meters(v *float64) *models.XMLValue {
+	if v == nil {
+		return nil
+	}
+	m := models.Meters(*v)
+	return &m
+}
+
 func formatTime(t *time.Time) string {
 	if t == nil {
 		return ""
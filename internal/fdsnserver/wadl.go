@@ -21,18 +21,43 @@ func stationWADL(w http.ResponseWriter, r *http.Request) {
           <param name="starttime" style="query" type="xsd:dateTime"/>
           <param name="endtime" style="query" type="xsd:dateTime"/>
           <param name="level" style="query" type="xsd:string" default="station"/>
-          <param name="format" style="query" type="xsd:string" default="xml"/>
+          <param name="format" style="query" type="xsd:string" default="xml">
+            <option value="xml"/>
+            <option value="text"/>
+            <option value="geojson"/>
+            <option value="kml"/>
+            <option value="csv"/>
+          </param>
           <param name="minlat" style="query" type="xsd:float"/>
           <param name="maxlat" style="query" type="xsd:float"/>
           <param name="minlon" style="query" type="xsd:float"/>
           <param name="maxlon" style="query" type="xsd:float"/>
+          <param name="lat" style="query" type="xsd:float"/>
+          <param name="lon" style="query" type="xsd:float"/>
+          <param name="minradius" style="query" type="xsd:float"/>
+          <param name="maxradius" style="query" type="xsd:float"/>
+          <param name="includerestricted" style="query" type="xsd:boolean" default="true"/>
+          <param name="schemaversion" style="query" type="xsd:string">
+            <option value="1.1"/>
+            <option value="1.2"/>
+          </param>
+          <param name="nodata" style="query" type="xsd:int" default="204">
+            <option value="204"/>
+            <option value="404"/>
+          </param>
         </request>
         <response>
           <representation mediaType="application/xml"/>
           <representation mediaType="text/plain"/>
+          <representation mediaType="application/geo+json"/>
+          <representation mediaType="application/vnd.google-earth.kml+xml"/>
+          <representation mediaType="text/csv"/>
         </response>
       </method>
     </resource>
+    <resource path="queryauth">
+      <method name="GET"/>
+    </resource>
     <resource path="version">
       <method name="GET"/>
     </resource>
@@ -49,10 +74,10 @@ func dataselectWADL(w http.ResponseWriter, r *http.Request) {
     <resource path="query">
       <method name="GET">
         <request>
-          <param name="net" style="query" type="xsd:string" required="true"/>
-          <param name="sta" style="query" type="xsd:string" required="true"/>
+          <param name="net" style="query" type="xsd:string"/>
+          <param name="sta" style="query" type="xsd:string"/>
           <param name="loc" style="query" type="xsd:string"/>
-          <param name="cha" style="query" type="xsd:string" required="true"/>
+          <param name="cha" style="query" type="xsd:string"/>
           <param name="starttime" style="query" type="xsd:dateTime" required="true"/>
           <param name="endtime" style="query" type="xsd:dateTime" required="true"/>
         </request>
@@ -60,6 +85,17 @@ func dataselectWADL(w http.ResponseWriter, r *http.Request) {
           <representation mediaType="application/vnd.fdsn.mseed"/>
         </response>
       </method>
+      <method name="POST">
+        <request>
+          <representation mediaType="text/plain"/>
+        </request>
+        <response>
+          <representation mediaType="application/vnd.fdsn.mseed"/>
+        </response>
+      </method>
+    </resource>
+    <resource path="queryauth">
+      <method name="GET"/>
     </resource>
     <resource path="version">
       <method name="GET"/>
@@ -81,6 +117,7 @@ func availabilityWADL(w http.ResponseWriter, r *http.Request) {
           <param name="sta" style="query" type="xsd:string"/>
           <param name="loc" style="query" type="xsd:string"/>
           <param name="cha" style="query" type="xsd:string"/>
+          <param name="includerestricted" style="query" type="xsd:boolean" default="true"/>
         </request>
         <response>
           <representation mediaType="text/plain"/>
@@ -94,12 +131,112 @@ func availabilityWADL(w http.ResponseWriter, r *http.Request) {
           <param name="sta" style="query" type="xsd:string"/>
           <param name="loc" style="query" type="xsd:string"/>
           <param name="cha" style="query" type="xsd:string"/>
+          <param name="includerestricted" style="query" type="xsd:boolean" default="true"/>
         </request>
         <response>
           <representation mediaType="text/plain"/>
         </response>
       </method>
     </resource>
+    <resource path="queryauth">
+      <method name="GET"/>
+    </resource>
+    <resource path="extentauth">
+      <method name="GET"/>
+    </resource>
+    <resource path="version">
+      <method name="GET"/>
+    </resource>
+  </resources>
+</application>`)
+}
+
+func eventWADL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, `<?xml version="1.0"?>
+<application xmlns="http://wadl.dev.java.net/2009/02"
+  xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <resources base="/fdsnws/event/1/">
+    <resource path="query">
+      <method name="GET">
+        <request>
+          <param name="starttime" style="query" type="xsd:dateTime"/>
+          <param name="endtime" style="query" type="xsd:dateTime"/>
+          <param name="minlatitude" style="query" type="xsd:double"/>
+          <param name="maxlatitude" style="query" type="xsd:double"/>
+          <param name="minlongitude" style="query" type="xsd:double"/>
+          <param name="maxlongitude" style="query" type="xsd:double"/>
+          <param name="latitude" style="query" type="xsd:double"/>
+          <param name="longitude" style="query" type="xsd:double"/>
+          <param name="minradius" style="query" type="xsd:double"/>
+          <param name="maxradius" style="query" type="xsd:double"/>
+          <param name="mindepth" style="query" type="xsd:double"/>
+          <param name="maxdepth" style="query" type="xsd:double"/>
+          <param name="minmagnitude" style="query" type="xsd:double"/>
+          <param name="maxmagnitude" style="query" type="xsd:double"/>
+          <param name="magnitudetype" style="query" type="xsd:string"/>
+          <param name="eventtype" style="query" type="xsd:string"/>
+          <param name="includeallorigins" style="query" type="xsd:boolean" default="false"/>
+          <param name="includeallmagnitudes" style="query" type="xsd:boolean" default="false"/>
+          <param name="includearrivals" style="query" type="xsd:boolean" default="false"/>
+          <param name="eventid" style="query" type="xsd:string"/>
+          <param name="limit" style="query" type="xsd:int"/>
+          <param name="offset" style="query" type="xsd:int" default="1"/>
+          <param name="orderby" style="query" type="xsd:string" default="time"/>
+          <param name="catalog" style="query" type="xsd:string"/>
+          <param name="contributor" style="query" type="xsd:string"/>
+          <param name="updatedafter" style="query" type="xsd:dateTime"/>
+          <param name="format" style="query" type="xsd:string" default="xml"/>
+          <param name="nodata" style="query" type="xsd:int" default="204"/>
+        </request>
+        <response>
+          <representation mediaType="application/xml"/>
+          <representation mediaType="text/plain"/>
+        </response>
+      </method>
+    </resource>
+    <resource path="catalogs">
+      <method name="GET"/>
+    </resource>
+    <resource path="contributors">
+      <method name="GET"/>
+    </resource>
+    <resource path="version">
+      <method name="GET"/>
+    </resource>
+  </resources>
+</application>`)
+}
+
+func routingWADL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, `<?xml version="1.0"?>
+<application xmlns="http://wadl.dev.java.net/2009/02"
+  xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <resources base="/fdsnws/routing/1/">
+    <resource path="query">
+      <method name="GET">
+        <request>
+          <param name="net" style="query" type="xsd:string"/>
+          <param name="sta" style="query" type="xsd:string"/>
+          <param name="loc" style="query" type="xsd:string"/>
+          <param name="cha" style="query" type="xsd:string"/>
+          <param name="starttime" style="query" type="xsd:dateTime"/>
+          <param name="endtime" style="query" type="xsd:dateTime"/>
+          <param name="service" style="query" type="xsd:string" default="dataselect"/>
+          <param name="format" style="query" type="xsd:string" default="post"/>
+        </request>
+        <response>
+          <representation mediaType="text/plain"/>
+          <representation mediaType="application/json"/>
+        </response>
+      </method>
+      <method name="POST">
+        <request>
+          <representation mediaType="text/plain"/>
+        </request>
+      </method>
+    </resource>
     <resource path="version">
       <method name="GET"/>
     </resource>
@@ -0,0 +1,43 @@
+// Package geodesy computes great-circle distances and azimuths on a
+// spherical Earth, as the station and event filters do in SQL.
+package geodesy
+
+import "math"
+
+// EarthRadiusKM is the mean Earth radius in kilometres, the radius PostGIS
+// uses for spherical distances.
+const EarthRadiusKM = 6371.0087714
+
+// Distance returns the great-circle distance in degrees between two points
+// given in degrees, using the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := radians(lat1), radians(lat2)
+	dφ, dλ := radians(lat2-lat1), radians(lon2-lon1)
+	h := math.Sin(dφ/2)*math.Sin(dφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(dλ/2)*math.Sin(dλ/2)
+	return degrees(2 * math.Asin(math.Sqrt(min(h, 1))))
+}
+
+// Azimuth returns the initial bearing in degrees clockwise from north, in
+// [0, 360), of the great circle from the first point to the second. The
+// back-azimuth at the second point is Azimuth with the points swapped.
+// Coincident points and antipodes have no defined direction; 0 is returned
+// for them.
+func Azimuth(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := radians(lat1), radians(lat2)
+	dλ := radians(lon2 - lon1)
+	y := math.Sin(dλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(dλ)
+	if math.Abs(x) < 1e-12 && math.Abs(y) < 1e-12 {
+		return 0
+	}
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// DegreesToKM converts a great-circle distance in degrees to kilometres.
+func DegreesToKM(deg float64) float64 {
+	return radians(deg) * EarthRadiusKM
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
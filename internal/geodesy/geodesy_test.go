@@ -0,0 +1,50 @@
+package geodesy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 10, 20, 10, 20, 0},
+		{"along the equator", 0, 0, 0, 90, 90},
+		{"pole to pole", 90, 0, -90, 0, 180},
+		{"across the antimeridian", 0, 179, 0, -179, 2},
+		{"antipodes", 10, 20, -10, -160, 180},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Distance = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if km := DegreesToKM(1); math.Abs(km-111.195) > 0.001 {
+		t.Errorf("DegreesToKM(1) = %v, want 111.195", km)
+	}
+}
+
+func TestAzimuth(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"north", 0, 0, 10, 0, 0},
+		{"east", 0, 0, 0, 10, 90},
+		{"south", 10, 0, 0, 0, 180},
+		{"west across the antimeridian", 0, -179, 0, 179, 270},
+		{"to the pole", -30, 120, 90, 0, 0},
+		{"same point", 10, 20, 10, 20, 0},
+		// Steeper than the 45° rhumb line
+		{"north-east", 0, 0, 45, 45, 35.2644},
+	}
+	for _, tt := range tests {
+		if got := Azimuth(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s: Azimuth = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package health probes FDSN sources for the web services they offer and
+// records the outcome, so unusable sources are noticed before an import.
+package health
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// probeTimeout bounds each probe request; /version and the WADL are tiny.
+const probeTimeout = 15 * time.Second
+
+// Checker probes sources and records the results in a HealthStore.
+type Checker struct {
+	sources store.SourceStore
+	health  store.HealthStore
+	creds   store.CredentialStore
+}
+
+// NewChecker returns a Checker using the given stores.
+func NewChecker(sources store.SourceStore, health store.HealthStore, creds store.CredentialStore) *Checker {
+	return &Checker{sources: sources, health: health, creds: creds}
+}
+
+// Check probes each FDSN service of src and records the result. The probe
+// succeeds if at least one service answers; services that are absent (404 or
+// 501) are simply not listed, and the errors of services that failed are
+// kept in ServiceErrors.
+func (c *Checker) Check(src *models.Source) *models.ProbeResult {
+	res := &models.ProbeResult{SourceID: src.ID, CheckedAt: time.Now(), Services: []models.SourceService{}}
+
+	cred, err := c.creds.Get(src.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("source", src.Name).Msg("probe without credentials")
+	}
+	client := fdsnclient.New(src.BaseURL).WithSource(src.Name).WithCredentials(cred)
+	client.HTTPClient.Timeout = probeTimeout
+
+	var errs []string
+	var total time.Duration
+	for _, service := range fdsnclient.Services {
+		info, err := client.ProbeService(service)
+		if fdsnclient.IsNotSupported(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			if res.ServiceErrors == nil {
+				res.ServiceErrors = map[string]string{}
+			}
+			res.ServiceErrors[service] = err.Error()
+			continue
+		}
+		total += info.Latency
+		res.Services = append(res.Services, models.SourceService{
+			SourceID:   src.ID,
+			Service:    info.Service,
+			Version:    info.Version,
+			Parameters: info.Parameters,
+			CheckedAt:  res.CheckedAt,
+		})
+	}
+
+	switch {
+	case len(res.Services) > 0:
+		res.LatencyMS = (total / time.Duration(len(res.Services))).Milliseconds()
+	case len(errs) > 0:
+		res.Error = strings.Join(errs, "; ")
+	default:
+		res.Error = "no FDSN services found"
+	}
+
+	if err := c.health.Record(res); err != nil {
+		log.Error().Err(err).Str("source", src.Name).Msg("record probe result")
+	}
+	switch {
+	case res.Error != "":
+		log.Warn().Str("source", src.Name).Str("error", res.Error).Msg("source probe failed")
+	case len(errs) > 0:
+		log.Warn().Str("source", src.Name).Str("error", strings.Join(errs, "; ")).Msg("source probe partly failed")
+	default:
+		log.Debug().Str("source", src.Name).Int("services", len(res.Services)).
+			Int64("latency_ms", res.LatencyMS).Msg("source probed")
+	}
+	return res
+}
+
+// CheckAll probes every enabled source concurrently.
+func (c *Checker) CheckAll() {
+	sources, err := c.sources.List()
+	if err != nil {
+		log.Error().Err(err).Msg("list sources for health check")
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range sources {
+		if !sources[i].Enabled {
+			continue
+		}
+		wg.Add(1)
+		go func(src *models.Source) {
+			defer wg.Done()
+			c.Check(src)
+		}(&sources[i])
+	}
+	wg.Wait()
+}
+
+// Run probes all sources immediately and then every interval until ctx is
+// cancelled.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	c.CheckAll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckAll()
+		}
+	}
+}
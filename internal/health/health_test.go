@@ -0,0 +1,140 @@
+package health
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+func TestCheck(t *testing.T) {
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	up := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/station/1/version", func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "1.1.0")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sources := store.NewSourceStore(db)
+	src := &models.Source{Name: "test", BaseURL: srv.URL, Enabled: true}
+	if err := sources.Create(src); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	c := NewChecker(sources, store.NewHealthStore(db), store.NewCredentialStore(db, nil))
+
+	res := c.Check(src)
+	if res.Error != "" || len(res.Services) != 1 || res.Services[0].Version != "1.1.0" {
+		t.Fatalf("unexpected probe result %+v", res)
+	}
+
+	up = false
+	if res := c.Check(src); res.Error == "" {
+		t.Fatal("expected probe of failing source to report an error")
+	}
+
+	summaries, err := sources.ListWithStats()
+	if err != nil {
+		t.Fatalf("ListWithStats: %v", err)
+	}
+	s := summaries[0]
+	if s.LastSuccess == nil || s.LastFailure == nil || s.LastError == "" || s.LatencyMS == nil {
+		t.Errorf("health not recorded: %+v", s)
+	}
+	// Services from the last successful probe are kept after a failure
+	if len(s.Services) != 1 || s.Services[0].Service != "station" {
+		t.Errorf("services = %+v, want station only", s.Services)
+	}
+}
+
+func TestCheckKeepsFailedServices(t *testing.T) {
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	dataselect := http.StatusOK
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/station/1/version", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "1.1.0")
+	})
+	mux.HandleFunc("/fdsnws/dataselect/1/version", func(w http.ResponseWriter, r *http.Request) {
+		if dataselect != http.StatusOK {
+			http.Error(w, "unavailable", dataselect)
+			return
+		}
+		_, _ = io.WriteString(w, "1.1.0")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sources := store.NewSourceStore(db)
+	src := &models.Source{Name: "test", BaseURL: srv.URL, Enabled: true}
+	if err := sources.Create(src); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	health := store.NewHealthStore(db)
+	c := NewChecker(sources, health, store.NewCredentialStore(db, nil))
+	services := func() map[string]string {
+		t.Helper()
+		list, err := health.Services(src.ID)
+		if err != nil {
+			t.Fatalf("Services: %v", err)
+		}
+		m := map[string]string{}
+		for _, s := range list {
+			m[s.Service] = s.LastError
+		}
+		return m
+	}
+
+	c.Check(src)
+	if got := services(); len(got) != 2 {
+		t.Fatalf("services = %v, want station and dataselect", got)
+	}
+
+	// A transient dataselect failure keeps the service, with its error
+	dataselect = http.StatusServiceUnavailable
+	res := c.Check(src)
+	if res.Error != "" || res.ServiceErrors["dataselect"] == "" {
+		t.Errorf("probe result %+v, want a dataselect service error", res)
+	}
+	if got := services(); len(got) != 2 || got["dataselect"] == "" || got["station"] != "" {
+		t.Errorf("services = %v, want dataselect kept with its error", got)
+	}
+
+	// Recovery clears the error
+	dataselect = http.StatusOK
+	c.Check(src)
+	if got := services(); len(got) != 2 || got["dataselect"] != "" {
+		t.Errorf("services = %v, want dataselect without error", got)
+	}
+
+	// A service found absent is dropped
+	dataselect = http.StatusNotFound
+	c.Check(src)
+	if got := services(); len(got) != 1 || got["station"] != "" {
+		t.Errorf("services = %v, want station only", got)
+	}
+}
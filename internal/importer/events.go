@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/metrics"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+// EventRequest selects the events to import from a source's FDSN event
+// service. Query.Format "text" imports the text format, which carries one
+// origin and magnitude per event; anything else imports QuakeML.
+type EventRequest struct {
+	SourceID int64
+	Query    fdsnclient.EventQuery
+}
+
+// EventResult summarises an event import.
+type EventResult struct {
+	SourceID   int64  `json:"source_id"`
+	SourceName string `json:"source_name"`
+	Fetched    int    `json:"fetched"`
+	Inserted   int    `json:"inserted"`
+	Updated    int    `json:"updated"`
+}
+
+// EventImporter imports events from sources into the event store.
+type EventImporter struct {
+	sources store.SourceStore
+	events  store.EventStore
+}
+
+// NewEventImporter returns an EventImporter using the given stores.
+func NewEventImporter(sources store.SourceStore, events store.EventStore) *EventImporter {
+	return &EventImporter{sources: sources, events: events}
+}
+
+// Import fetches the events selected by req and stores them, updating events
+// imported before. Event services have no authenticated query method, so
+// requests are anonymous.
+func (im *EventImporter) Import(req EventRequest) (*EventResult, error) {
+	start := time.Now()
+	res, err := im.importEvents(req)
+	metrics.ObserveImport("events", start, err)
+	return res, err
+}
+
+func (im *EventImporter) importEvents(req EventRequest) (*EventResult, error) {
+	if req.SourceID == 0 {
+		return nil, ErrNoSource
+	}
+	src, err := im.sources.Get(req.SourceID)
+	if err != nil {
+		return nil, ErrSourceNotFound
+	}
+	if !src.Enabled {
+		return nil, fmt.Errorf("cannot import from %w %s", ErrSourceDisabled, src.Name)
+	}
+
+	events, err := fdsnclient.New(src.BaseURL).WithSource(src.Name).QueryEvents(req.Query)
+	if err != nil {
+		return nil, &UpstreamError{err}
+	}
+	res := &EventResult{SourceID: src.ID, SourceName: src.Name, Fetched: len(events)}
+	if len(events) == 0 {
+		return res, nil
+	}
+
+	log.Info().Int("events", len(events)).Str("source", src.Name).Msg("importing events")
+	if res.Inserted, res.Updated, err = im.events.ImportEvents(src.ID, events); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
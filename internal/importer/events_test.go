@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+const eventText = `#EventID | Time | Latitude | Longitude | Depth/km | Author | Catalog | Contributor | ContributorID | MagType | Magnitude | MagAuthor | EventLocationName | EventType
+us1000abcd|2024-03-01T10:00:01|-18.5|178.5|580|us|us|us|us1000abcd|mww|6.1|us|FIJI ISLANDS REGION|earthquake
+`
+
+func TestImportEvents(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/fdsnws/event/1/query" || r.URL.Query().Get("minmag") != "6" {
+			t.Errorf("request = %s", r.URL)
+		}
+		fmt.Fprint(w, eventText)
+	}))
+	t.Cleanup(srv.Close)
+	_, db, src := setup(t, srv.URL)
+	im := NewEventImporter(store.NewSourceStore(db), store.NewEventStore(db))
+
+	req := EventRequest{SourceID: src.ID, Query: fdsnclient.EventQuery{Format: "text", MinMag: "6"}}
+	res, err := im.Import(req)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	want := EventResult{SourceID: src.ID, SourceName: "TEST", Fetched: 1, Inserted: 1}
+	if *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+	if res, err = im.Import(req); err != nil || res.Inserted != 0 || res.Updated != 1 {
+		t.Errorf("re-import = %+v, %v; want 1 updated", res, err)
+	}
+
+	if _, err := im.Import(EventRequest{}); !errors.Is(err, ErrNoSource) {
+		t.Errorf("no source: err = %v", err)
+	}
+	if _, err := im.Import(EventRequest{SourceID: 99}); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("unknown source: err = %v", err)
+	}
+	db.MustExec("UPDATE sources SET enabled = FALSE WHERE id = ?", src.ID)
+	requests = 0
+	if _, err := im.Import(req); !errors.Is(err, ErrSourceDisabled) || requests != 0 {
+		t.Errorf("disabled source: err = %v after %d requests", err, requests)
+	}
+	db.MustExec("UPDATE sources SET enabled = TRUE, base_url = 'http://127.0.0.1:1' WHERE id = ?", src.ID)
+	var upstreamErr *UpstreamError
+	if _, err := im.Import(req); !errors.As(err, &upstreamErr) {
+		t.Errorf("unreachable source: err = %v, want an UpstreamError", err)
+	}
+}
@@ -0,0 +1,329 @@
+// Package importer fetches channel metadata, availability and events from
+// upstream FDSN sources into the local catalog. It is shared by the import
+// API and the fdsn import, refresh and event commands.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/discovery"
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+	"github.com/joescharf/fdsn/internal/metrics"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+var (
+	// ErrSourceNotFound is returned when Request.SourceID names no source.
+	ErrSourceNotFound = errors.New("source not found")
+	// ErrNoTarget is returned when a request has neither a source nor a network.
+	ErrNoTarget = errors.New("source_id or network is required")
+	// ErrNoSource is returned when an event import names no source; events
+	// are not routed by discovery.
+	ErrNoSource = errors.New("source_id is required")
+	// ErrNotDiscovered is returned when discovery finds no data centre
+	// serving the requested network.
+	ErrNotDiscovered = errors.New("no data centre found")
+	// ErrSourceDisabled is returned when the requested source, or every
+	// source found to serve the network, is disabled.
+	ErrSourceDisabled = errors.New("disabled source")
+)
+
+// UpstreamError is a failure of the source or a discovery service.
+type UpstreamError struct {
+	Err error
+}
+
+func (e *UpstreamError) Error() string { return e.Err.Error() }
+
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+// Request selects the channels to import. A zero SourceID imports a single
+// network from the data centre found to serve it by discovery. StartTime and
+// EndTime, if set, limit the import to channel epochs active in that window.
+type Request struct {
+	SourceID  int64
+	Network   string
+	Station   string
+	Location  string
+	Channel   string
+	StartTime string
+	EndTime   string
+}
+
+// Result summarises an import.
+type Result struct {
+	SourceID           int64  `json:"source_id"`
+	SourceName         string `json:"source_name"`
+	Imported           int    `json:"imported"`
+	AvailabilityCount  int    `json:"availability_count"`
+	AvailabilityError  string `json:"availability_error,omitempty"`
+	AvailabilityStatus string `json:"availability_status"`
+}
+
+// Importer imports channels from sources into the station and availability
+// stores.
+type Importer struct {
+	sources      store.SourceStore
+	stations     store.StationStore
+	availability store.AvailabilityStore
+	creds        store.CredentialStore
+	discovery    *discovery.Discoverer
+}
+
+// New returns an Importer using the given stores. A nil availability store
+// skips availability; a nil discoverer rejects requests without a source.
+func New(sources store.SourceStore, stations store.StationStore, availability store.AvailabilityStore, creds store.CredentialStore, disc *discovery.Discoverer) *Importer {
+	return &Importer{sources: sources, stations: stations, availability: availability, creds: creds, discovery: disc}
+}
+
+// Import fetches the channels selected by req, with their StationXML
+// restrictions and 1.2 metadata, stores them and then fetches availability
+// extents for their stations.
+func (im *Importer) Import(req Request) (*Result, error) {
+	start := time.Now()
+	res, err := im.importStations(req)
+	metrics.ObserveImport("stations", start, err)
+	return res, err
+}
+
+func (im *Importer) importStations(req Request) (*Result, error) {
+	var src *models.Source
+	if req.SourceID == 0 {
+		if req.Network == "" {
+			return nil, ErrNoTarget
+		}
+		var err error
+		if src, err = im.discoverSource(req.Network); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if src, err = im.sources.Get(req.SourceID); err != nil {
+			return nil, ErrSourceNotFound
+		}
+		if !src.Enabled {
+			return nil, fmt.Errorf("cannot import from %w %s", ErrSourceDisabled, src.Name)
+		}
+	}
+
+	// Fetch channel-level data from the external source
+	cred, err := im.creds.Get(src.ID)
+	if err != nil {
+		return nil, err
+	}
+	client := fdsnclient.New(src.BaseURL).WithSource(src.Name).WithCredentials(cred)
+	q := fdsnclient.StationQuery{
+		Network:   req.Network,
+		Station:   req.Station,
+		Channel:   req.Channel,
+		Location:  req.Location,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}
+	channels, err := client.QueryChannels(q)
+	if err != nil {
+		return nil, &UpstreamError{err}
+	}
+	if len(channels) == 0 {
+		return &Result{SourceID: src.ID, SourceName: src.Name}, nil
+	}
+
+	log.Info().Int("channels", len(channels)).Str("source", src.Name).Msg("importing stations")
+
+	// restrictedStatus and the 1.2 metadata are only carried by StationXML.
+	// If it can't be fetched the stored values are left as they are.
+	restrictions, metadata, err := client.QueryNodeDetails(q)
+	if err != nil {
+		log.Warn().Err(err).Str("source", src.Name).Msg("failed to fetch StationXML restrictions and metadata")
+	}
+
+	// Convert to import format
+	importChannels := make([]models.ImportChannel, len(channels))
+	for i, ch := range channels {
+		staKey := ch.Network + "." + ch.Station
+		importChannels[i] = models.ImportChannel{
+			NetworkCode:       ch.Network,
+			StationCode:       ch.Station,
+			Latitude:          ch.Latitude,
+			Longitude:         ch.Longitude,
+			Elevation:         ch.Elevation,
+			LocationCode:      ch.Location,
+			ChannelCode:       ch.Channel,
+			ChanLatitude:      ch.Latitude,
+			ChanLongitude:     ch.Longitude,
+			ChanElevation:     ch.Elevation,
+			Depth:             ch.Depth,
+			Azimuth:           ch.Azimuth,
+			Dip:               ch.Dip,
+			SensorDescription: ch.SensorDescription,
+			Scale:             ch.Scale,
+			ScaleFreq:         ch.ScaleFreq,
+			ScaleUnits:        ch.ScaleUnits,
+			SampleRate:        ch.SampleRate,
+			ChanStartTime:     ch.StartTime,
+			ChanEndTime:       ch.EndTime,
+			NetworkRestricted: restrictions.Lookup(ch.Network),
+			StationRestricted: restrictions.Lookup(staKey),
+			ChanRestricted:    restrictions.Lookup(staKey + "." + ch.Location + "." + ch.Channel),
+			NetworkMetadata:   metadata.Lookup(ch.Network),
+			StationMetadata:   metadata.Lookup(staKey),
+			ChanMetadata:      metadata.Lookup(staKey + "." + ch.Location + "." + ch.Channel),
+		}
+	}
+
+	if err := im.stations.ImportStations(src.ID, importChannels); err != nil {
+		return nil, err
+	}
+
+	// Fetch availability data for imported channels
+	res := &Result{SourceID: src.ID, SourceName: src.Name, Imported: len(channels)}
+
+	if im.availability != nil {
+		availCount, availErr := im.fetchAvailability(client, src.ID, channels)
+		res.AvailabilityCount = availCount
+		switch {
+		case availErr != "":
+			res.AvailabilityError = availErr
+			if strings.Contains(availErr, "not supported") {
+				res.AvailabilityStatus = "not_supported"
+			} else {
+				res.AvailabilityStatus = "error"
+			}
+		case availCount > 0:
+			res.AvailabilityStatus = "ok"
+		default:
+			res.AvailabilityStatus = "no_data"
+		}
+	} else {
+		res.AvailabilityStatus = "not_configured"
+	}
+	return res, nil
+}
+
+// Targets returns every source and network pair in the catalog from an
+// enabled source: what a full refresh re-imports.
+func (im *Importer) Targets() ([]models.SourceNetwork, error) {
+	return im.stations.ListUniqueSourceNetworks()
+}
+
+// netStaKey is a deduplicated network+station pair.
+type netStaKey struct {
+	Network string
+	Station string
+}
+
+// fetchAvailability queries availability extents for all unique network+station
+// pairs in the imported channels and upserts them into the availability store.
+// It returns the count of availability records upserted and an error string (if any).
+func (im *Importer) fetchAvailability(client *fdsnclient.Client, sourceID int64, channels []fdsnclient.ChannelTextRow) (int, string) {
+	// Build deduplicated set of network+station pairs
+	seen := make(map[netStaKey]bool)
+	var pairs []netStaKey
+	for _, ch := range channels {
+		key := netStaKey{Network: ch.Network, Station: ch.Station}
+		if !seen[key] {
+			seen[key] = true
+			pairs = append(pairs, key)
+		}
+	}
+
+	var allItems []store.AvailabilityItem
+	var availErr string
+
+	for _, pair := range pairs {
+		// Query availability extent from external source
+		extents, err := client.QueryAvailabilityExtent(fdsnclient.AvailabilityQuery{
+			Network: pair.Network,
+			Station: pair.Station,
+		})
+		if err != nil {
+			if fdsnclient.IsNotSupported(err) {
+				log.Info().
+					Str("network", pair.Network).
+					Str("station", pair.Station).
+					Msg("availability not supported by source")
+				availErr = "availability not supported by this source"
+				continue
+			}
+			log.Warn().Err(err).
+				Str("network", pair.Network).
+				Str("station", pair.Station).
+				Msg("failed to fetch availability extent")
+			availErr = fmt.Sprintf("availability fetch error for %s.%s: %s", pair.Network, pair.Station, err.Error())
+			continue
+		}
+
+		if len(extents) == 0 {
+			continue
+		}
+
+		// Lookup channel IDs for this network+station
+		chanIDMap, err := im.stations.LookupChannelIDs(sourceID, pair.Network, pair.Station)
+		if err != nil {
+			log.Warn().Err(err).
+				Str("network", pair.Network).
+				Str("station", pair.Station).
+				Msg("failed to lookup channel IDs")
+			availErr = fmt.Sprintf("channel lookup error for %s.%s: %s", pair.Network, pair.Station, err.Error())
+			continue
+		}
+
+		// Match availability extents to channel IDs using location+channel key
+		for _, ext := range extents {
+			key := ext.Location + "." + ext.Channel
+			chanID, ok := chanIDMap[key]
+			if !ok {
+				continue
+			}
+			if ext.Earliest == nil || ext.Latest == nil {
+				continue
+			}
+			allItems = append(allItems, store.AvailabilityItem{
+				ChannelID: chanID,
+				Earliest:  fdsnclient.FormatTime(ext.Earliest),
+				Latest:    fdsnclient.FormatTime(ext.Latest),
+			})
+		}
+	}
+
+	// Batch upsert all collected availability items
+	if len(allItems) > 0 {
+		if err := im.availability.UpsertBatch(allItems); err != nil {
+			log.Warn().Err(err).Int("items", len(allItems)).Msg("failed to upsert availability batch")
+			availErr = fmt.Sprintf("availability upsert error: %s", err.Error())
+			return 0, availErr
+		}
+	}
+
+	log.Info().Int("availability_records", len(allItems)).Msg("availability import complete")
+	return len(allItems), availErr
+}
+
+// discoverSource returns the first enabled source serving network, looking
+// it up (and registering it) through the EIDA routing service and fedcatalog.
+func (im *Importer) discoverSource(network string) (*models.Source, error) {
+	if im.discovery == nil {
+		return nil, ErrNoTarget
+	}
+	found, err := im.discovery.Discover(network)
+	if err != nil {
+		return nil, &UpstreamError{err}
+	}
+	for _, d := range found {
+		if d.Enabled {
+			log.Info().Str("network", network).Str("source", d.Name).Msg("routed import to discovered source")
+			src := d.Source
+			return &src, nil
+		}
+	}
+	if len(found) > 0 {
+		return nil, fmt.Errorf("network %s is served by %w %s", network, ErrSourceDisabled, found[0].Name)
+	}
+	return nil, fmt.Errorf("%w for network %s", ErrNotDiscovered, network)
+}
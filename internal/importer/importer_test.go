@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/store"
+)
+
+const channelText = `#Network|Station|Location|Channel|Latitude|Longitude|Elevation|Depth|Azimuth|Dip|SensorDescription|Scale|ScaleFreq|ScaleUnits|SampleRate|StartTime|EndTime
+IU|ANMO|00|BHZ|34.9459|-106.4572|1850|145|0|-90|Streckeisen STS-1|3.3e9|0.02|M/S|40|2002-11-19T21:07:00|
+IU|ANMO|00|BH1|34.9459|-106.4572|1850|145|0|0|Streckeisen STS-1|3.3e9|0.02|M/S|40|2002-11-19T21:07:00|
+`
+
+const availabilityText = `#Network|Station|Location|Channel|Quality|SampleRate|Earliest|Latest
+IU|ANMO|00|BHZ|M|40|2002-11-19T21:07:00Z|2024-01-01T00:00:00Z
+`
+
+// upstream serves channel text and availability extents; StationXML is not
+// offered, so restrictions and metadata are left as stored.
+func upstream(t *testing.T, availability bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdsnws/station/1/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "text" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, channelText)
+	})
+	mux.HandleFunc("/fdsnws/availability/1/extent", func(w http.ResponseWriter, r *http.Request) {
+		if !availability {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, availabilityText)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func setup(t *testing.T, baseURL string) (*Importer, *database.DB, *models.Source) {
+	t.Helper()
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sources := store.NewSourceStore(db)
+	src := &models.Source{Name: "TEST", BaseURL: baseURL, Enabled: true}
+	if err := sources.Create(src); err != nil {
+		t.Fatalf("Create source: %v", err)
+	}
+	im := New(sources, store.NewStationStore(db), store.NewAvailabilityStore(db), store.NewCredentialStore(db, nil), nil)
+	return im, db, src
+}
+
+func TestImport(t *testing.T) {
+	im, db, src := setup(t, upstream(t, true).URL)
+
+	res, err := im.Import(Request{SourceID: src.ID, Network: "IU", Station: "ANMO"})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	want := Result{SourceID: src.ID, SourceName: "TEST", Imported: 2, AvailabilityCount: 1, AvailabilityStatus: "ok"}
+	if *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+
+	var channels int
+	if err := db.Get(&channels, "SELECT COUNT(*) FROM channels"); err != nil || channels != 2 {
+		t.Errorf("channels = %d (%v), want 2", channels, err)
+	}
+
+	targets, err := im.Targets()
+	if err != nil || len(targets) != 1 || targets[0].NetworkCode != "IU" {
+		t.Errorf("Targets = %v, %v", targets, err)
+	}
+}
+
+func TestImportAvailabilityNotSupported(t *testing.T) {
+	im, _, src := setup(t, upstream(t, false).URL)
+
+	res, err := im.Import(Request{SourceID: src.ID, Network: "IU"})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if res.Imported != 2 || res.AvailabilityStatus != "not_supported" {
+		t.Errorf("result = %+v", *res)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	im, _, src := setup(t, "http://127.0.0.1:1")
+
+	if _, err := im.Import(Request{SourceID: 99}); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("unknown source: err = %v", err)
+	}
+	if _, err := im.Import(Request{}); !errors.Is(err, ErrNoTarget) {
+		t.Errorf("no target: err = %v", err)
+	}
+	var upstreamErr *UpstreamError
+	if _, err := im.Import(Request{SourceID: src.ID, Network: "IU"}); !errors.As(err, &upstreamErr) {
+		t.Errorf("unreachable source: err = %v, want an UpstreamError", err)
+	}
+}
+
+func TestImportDisabledSource(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, channelText)
+	}))
+	t.Cleanup(srv.Close)
+	im, db, src := setup(t, srv.URL)
+
+	if _, err := im.Import(Request{SourceID: src.ID, Network: "IU"}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	db.MustExec("UPDATE sources SET enabled = FALSE WHERE id = ?", src.ID)
+	requests = 0
+
+	if _, err := im.Import(Request{SourceID: src.ID, Network: "IU"}); !errors.Is(err, ErrSourceDisabled) {
+		t.Errorf("disabled source: err = %v, want ErrSourceDisabled", err)
+	}
+	if requests != 0 {
+		t.Errorf("disabled source was contacted %d times", requests)
+	}
+	if targets, err := im.Targets(); err != nil || len(targets) != 0 {
+		t.Errorf("Targets = %v, %v; want none", targets, err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package metrics exposes the server's Prometheus metrics: HTTP requests
+// per route, upstream FDSN requests per source, import jobs, database pool
+// statistics and the collectors other packages register, such as the
+// waveform cache and the catalog counts. Metrics are recorded whether or
+// not /metrics is served.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// namespace prefixes every metric of the server.
+const namespace = "fdsn"
+
+// Registry holds the server's metrics along with the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve HTTP requests, by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Time until upstream FDSN services answered with response headers, by source and service.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"source", "service"})
+
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Upstream FDSN requests that failed or were answered with an error status, by source and service.",
+	}, []string{"source", "service"})
+
+	importJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "import_jobs_total",
+		Help:      "Import jobs run, by kind (stations or events) and outcome (success or error).",
+	}, []string{"kind", "outcome"})
+
+	importDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "import_duration_seconds",
+		Help:      "Time taken by import jobs, by kind.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"kind"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		upstreamDuration, upstreamErrors,
+		importJobs, importDuration,
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition
+// format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware counts and times the requests handled by a chi router. The
+// route label is the matched route pattern, so IDs in paths do not create
+// new series; requests no route matched, such as the web UI's, are labelled
+// "other".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "other"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if p := rctx.RoutePattern(); p != "" && p != "/*" {
+				route = p
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveUpstream records an upstream request to service of the named
+// source that took d, counting it as an error if failed is set.
+func ObserveUpstream(source, service string, d time.Duration, failed bool) {
+	upstreamDuration.WithLabelValues(source, service).Observe(d.Seconds())
+	if failed {
+		upstreamErrors.WithLabelValues(source, service).Inc()
+	}
+}
+
+// ObserveImport records an import job of kind that started at start and
+// ended with err.
+func ObserveImport(kind string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	importJobs.WithLabelValues(kind, outcome).Inc()
+	importDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDB adds the statistics of a database connection pool, among them
+// the number of waits for a connection and the time spent waiting, labelled
+// with name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCatalog adds gauges of the catalog counts, read from stats on
+// every scrape.
+func RegisterCatalog(stats func() (*models.Stats, error)) {
+	Registry.MustRegister(&catalogCollector{stats: stats})
+}
+
+var (
+	catalogDescs = map[string]*prometheus.Desc{
+		"sources":         catalogDesc("sources", "Configured sources."),
+		"enabled_sources": catalogDesc("enabled_sources", "Enabled sources."),
+		"networks":        catalogDesc("networks", "Imported networks of enabled sources."),
+		"stations":        catalogDesc("stations", "Imported stations of enabled sources."),
+		"channels":        catalogDesc("channels", "Imported channels of enabled sources."),
+	}
+	catalogUp = prometheus.NewDesc(namespace+"_catalog_up", "Whether the catalog counts could be read.", nil, nil)
+)
+
+func catalogDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "catalog", name), help, nil, nil)
+}
+
+type catalogCollector struct {
+	stats func() (*models.Stats, error)
+}
+
+func (c *catalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range catalogDescs {
+		ch <- d
+	}
+	ch <- catalogUp
+}
+
+func (c *catalogCollector) Collect(ch chan<- prometheus.Metric) {
+	s, err := c.stats()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(catalogUp, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(catalogUp, prometheus.GaugeValue, 1)
+	for name, v := range map[string]int64{
+		"sources":         s.Sources,
+		"enabled_sources": s.EnabledSources,
+		"networks":        s.Networks,
+		"stations":        s.Stations,
+		"channels":        s.Channels,
+	} {
+		ch <- prometheus.MustNewConstMetric(catalogDescs[name], prometheus.GaugeValue, float64(v))
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// scrape returns the exposition text of Registry.
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/api/v1/stations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Get("/api/v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	r.Get("/*", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, path := range []string{"/api/v1/stations/1", "/api/v1/stations/2", "/api/v1/stats", "/map"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t)
+	for _, want := range []string{
+		`fdsn_http_requests_total{method="GET",route="/api/v1/stations/{id}",status="404"} 2`,
+		`fdsn_http_requests_total{method="GET",route="/api/v1/stats",status="200"} 1`,
+		`fdsn_http_requests_total{method="GET",route="other",status="200"} 1`,
+		`fdsn_http_request_duration_seconds_count{method="GET",route="/api/v1/stats",status="200"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ObserveUpstream("IRIS", "dataselect", 0, false)
+	ObserveUpstream("IRIS", "dataselect", 0, true)
+	ObserveImport("events", time.Now(), errors.New("boom"))
+
+	out := scrape(t)
+	for _, want := range []string{
+		`fdsn_upstream_request_duration_seconds_count{service="dataselect",source="IRIS"} 2`,
+		`fdsn_upstream_errors_total{service="dataselect",source="IRIS"} 1`,
+		`fdsn_import_jobs_total{kind="events",outcome="error"} 1`,
+		`fdsn_import_duration_seconds_count{kind="events"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestCatalogCollector(t *testing.T) {
+	var err error
+	RegisterCatalog(func() (*models.Stats, error) {
+		return &models.Stats{Sources: 3, EnabledSources: 2, Networks: 4, Stations: 10, Channels: 30}, err
+	})
+
+	out := scrape(t)
+	for _, want := range []string{
+		"fdsn_catalog_up 1",
+		"fdsn_catalog_enabled_sources 2",
+		"fdsn_catalog_channels 30",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	err = errors.New("database is locked")
+	out = scrape(t)
+	if !strings.Contains(out, "fdsn_catalog_up 0") || strings.Contains(out, "fdsn_catalog_channels") {
+		t.Errorf("failed stats should report only fdsn_catalog_up 0")
+	}
+}
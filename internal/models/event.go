@@ -0,0 +1,121 @@
+package models
+
+import "time"
+
+// Event is a seismic event imported from a source's FDSN event service. Its
+// time, position, depth and magnitude are those of the preferred origin and
+// magnitude. PublicID is the QuakeML publicID the event had upstream.
+type Event struct {
+	ID                   int64     `db:"id" json:"id"`
+	SourceID             int64     `db:"source_id" json:"source_id"`
+	PublicID             string    `db:"public_id" json:"public_id"`
+	Type                 string    `db:"event_type" json:"type"`
+	Description          string    `db:"description" json:"description"`
+	PreferredOriginID    string    `db:"preferred_origin_id" json:"preferred_origin_id"`
+	PreferredMagnitudeID string    `db:"preferred_magnitude_id" json:"preferred_magnitude_id"`
+	Time                 time.Time `db:"time" json:"time"`
+	Latitude             float64   `db:"latitude" json:"latitude"`
+	Longitude            float64   `db:"longitude" json:"longitude"`
+	DepthKM              *float64  `db:"depth_km" json:"depth_km"`
+	Magnitude            *float64  `db:"magnitude" json:"magnitude"`
+	MagnitudeType        string    `db:"magnitude_type" json:"magnitude_type"`
+	MagnitudeAuthor      string    `db:"magnitude_author" json:"magnitude_author"`
+	Author               string    `db:"author" json:"author"`
+	Catalog              string    `db:"catalog" json:"catalog"`
+	Contributor          string    `db:"contributor" json:"contributor"`
+	ContributorID        string    `db:"contributor_id" json:"contributor_id"`
+	CreatedAt            time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt            time.Time `db:"updated_at" json:"updated_at"`
+
+	// Joined fields (not always populated)
+	SourceName string `db:"source_name" json:"source_name,omitempty"`
+
+	Origins    []Origin    `db:"-" json:"origins,omitempty"`
+	Magnitudes []Magnitude `db:"-" json:"magnitudes,omitempty"`
+	Picks      []Pick      `db:"-" json:"picks,omitempty"`
+}
+
+// Origin is one location estimate of an event.
+type Origin struct {
+	ID               int64     `db:"id" json:"id"`
+	EventID          int64     `db:"event_id" json:"-"`
+	PublicID         string    `db:"public_id" json:"public_id"`
+	Time             time.Time `db:"time" json:"time"`
+	Latitude         float64   `db:"latitude" json:"latitude"`
+	Longitude        float64   `db:"longitude" json:"longitude"`
+	DepthKM          *float64  `db:"depth_km" json:"depth_km"`
+	DepthType        string    `db:"depth_type" json:"depth_type,omitempty"`
+	MethodID         string    `db:"method_id" json:"method_id,omitempty"`
+	EarthModelID     string    `db:"earth_model_id" json:"earth_model_id,omitempty"`
+	EvaluationMode   string    `db:"evaluation_mode" json:"evaluation_mode,omitempty"`
+	EvaluationStatus string    `db:"evaluation_status" json:"evaluation_status,omitempty"`
+	Author           string    `db:"author" json:"author,omitempty"`
+	AgencyID         string    `db:"agency_id" json:"agency_id,omitempty"`
+
+	Arrivals []Arrival `db:"-" json:"arrivals,omitempty"`
+}
+
+// Magnitude is one magnitude estimate of an event. OriginID is the publicID
+// of the origin it was computed for.
+type Magnitude struct {
+	ID           int64   `db:"id" json:"id"`
+	EventID      int64   `db:"event_id" json:"-"`
+	PublicID     string  `db:"public_id" json:"public_id"`
+	Mag          float64 `db:"mag" json:"mag"`
+	Type         string  `db:"magnitude_type" json:"type"`
+	OriginID     string  `db:"origin_public_id" json:"origin_id,omitempty"`
+	StationCount *int64  `db:"station_count" json:"station_count,omitempty"`
+	Author       string  `db:"author" json:"author,omitempty"`
+	AgencyID     string  `db:"agency_id" json:"agency_id,omitempty"`
+}
+
+// Pick is a phase onset read on one channel.
+type Pick struct {
+	ID             int64     `db:"id" json:"id"`
+	EventID        int64     `db:"event_id" json:"-"`
+	PublicID       string    `db:"public_id" json:"public_id"`
+	Time           time.Time `db:"time" json:"time"`
+	NetworkCode    string    `db:"network_code" json:"network_code"`
+	StationCode    string    `db:"station_code" json:"station_code"`
+	LocationCode   string    `db:"location_code" json:"location_code"`
+	ChannelCode    string    `db:"channel_code" json:"channel_code"`
+	PhaseHint      string    `db:"phase_hint" json:"phase_hint,omitempty"`
+	Polarity       string    `db:"polarity" json:"polarity,omitempty"`
+	Onset          string    `db:"onset" json:"onset,omitempty"`
+	EvaluationMode string    `db:"evaluation_mode" json:"evaluation_mode,omitempty"`
+}
+
+// Arrival associates a pick with an origin. PickID is the pick's publicID;
+// Distance is in degrees.
+type Arrival struct {
+	ID           int64    `db:"id" json:"id"`
+	OriginID     int64    `db:"origin_id" json:"-"`
+	PublicID     string   `db:"public_id" json:"public_id"`
+	PickID       string   `db:"pick_public_id" json:"pick_id"`
+	Phase        string   `db:"phase" json:"phase"`
+	Distance     *float64 `db:"distance" json:"distance,omitempty"`
+	Azimuth      *float64 `db:"azimuth" json:"azimuth,omitempty"`
+	TimeResidual *float64 `db:"time_residual" json:"time_residual,omitempty"`
+	TimeWeight   *float64 `db:"time_weight" json:"time_weight,omitempty"`
+}
+
+// EventPage is one page of an event listing.
+type EventPage struct {
+	Events []Event `json:"events"`
+	Total  int64   `json:"total"`
+}
+
+// EventStation is a stored station near an event, with its epicentral
+// distance, the azimuth from the event to it and back, and a waveform window
+// around the event's origin time. WaveformURL is a dataselect query for the
+// station's data in that window.
+type EventStation struct {
+	Station
+	DistanceKM  float64   `json:"distance_km"`
+	DistanceDeg float64   `json:"distance_deg"`
+	Azimuth     float64   `json:"azimuth"`
+	BackAzimuth float64   `json:"back_azimuth"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	WaveformURL string    `json:"waveform_url"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Extraction statuses.
+const (
+	ExtractionPending = "pending"
+	ExtractionRunning = "running"
+	ExtractionDone    = "done"
+	ExtractionFailed  = "failed"
+)
+
+// Extraction request statuses: NoData is a request the source answered
+// without data.
+const (
+	RequestPending = "pending"
+	RequestDone    = "done"
+	RequestNoData  = "nodata"
+	RequestFailed  = "failed"
+)
+
+// Extraction is a job fetching the waveforms of the stations around an
+// event into a downloadable bundle. Window is "phases" (from Before seconds
+// ahead of the predicted first P to After seconds past the first S) or
+// "offsets" (from Before seconds ahead of the origin time to After seconds
+// past it). Radii are degrees; Channel is a comma-separated list of channel
+// code patterns.
+type Extraction struct {
+	ID         int64      `db:"id" json:"id"`
+	EventID    int64      `db:"event_id" json:"event_id"`
+	Status     string     `db:"status" json:"status"`
+	Window     string     `db:"window_mode" json:"window"`
+	Before     float64    `db:"before_seconds" json:"before"`
+	After      float64    `db:"after_seconds" json:"after"`
+	Channel    string     `db:"channel" json:"channel"`
+	MinRadius  float64    `db:"min_radius" json:"min_radius"`
+	MaxRadius  float64    `db:"max_radius" json:"max_radius"`
+	Bytes      int64      `db:"bytes" json:"bytes"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at"`
+
+	// Counts of the requests by outcome
+	RequestCount int `db:"request_count" json:"request_count"`
+	DoneCount    int `db:"done_count" json:"done_count"`
+	NoDataCount  int `db:"nodata_count" json:"nodata_count"`
+	FailedCount  int `db:"failed_count" json:"failed_count"`
+
+	// Joined fields (not always populated)
+	EventDescription string `db:"event_description" json:"event_description,omitempty"`
+
+	Requests []ExtractionRequest `db:"-" json:"requests,omitempty"`
+}
+
+// ExtractionRequest is the waveform request of an extraction for one
+// channel. PArrival and SArrival are the predicted first P and S arrivals
+// in seconds after the origin time.
+type ExtractionRequest struct {
+	ID           int64     `db:"id" json:"id"`
+	ExtractionID int64     `db:"extraction_id" json:"-"`
+	SourceID     int64     `db:"source_id" json:"source_id"`
+	NetworkCode  string    `db:"network_code" json:"network_code"`
+	StationCode  string    `db:"station_code" json:"station_code"`
+	LocationCode string    `db:"location_code" json:"location_code"`
+	ChannelCode  string    `db:"channel_code" json:"channel_code"`
+	DistanceDeg  float64   `db:"distance_deg" json:"distance_deg"`
+	PArrival     *float64  `db:"p_arrival" json:"p_arrival"`
+	SArrival     *float64  `db:"s_arrival" json:"s_arrival"`
+	StartTime    time.Time `db:"start_time" json:"start_time"`
+	EndTime      time.Time `db:"end_time" json:"end_time"`
+	Status       string    `db:"status" json:"status"`
+	Bytes        int64     `db:"bytes" json:"bytes"`
+	Error        string    `db:"error" json:"error,omitempty"`
+}
@@ -10,6 +10,34 @@ type Source struct {
 	Enabled     bool      `db:"enabled" json:"enabled"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+
+	// ManagedBy is ManagedByConfig for sources reconciled from the config
+	// file, which are read-only in the API, and empty otherwise.
+	ManagedBy string `db:"managed_by" json:"managed_by"`
+
+	// HasCredentials reports whether upstream credentials are stored.
+	HasCredentials bool `db:"has_credentials" json:"has_credentials"`
+}
+
+// ManagedByConfig marks a source owned by the sources config key.
+const ManagedByConfig = "config"
+
+// SourceCredentials authenticate requests to a source's /queryauth methods:
+// either a Digest username/password or the contents of an EIDA token file.
+// They are stored encrypted and never returned by the API.
+type SourceCredentials struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	EIDAToken string `json:"eida_token"`
+}
+
+// DiscoveredSource is a source found to serve a network by the EIDA routing
+// service or the IRIS fedcatalog. Created is set if it was registered by the
+// lookup.
+type DiscoveredSource struct {
+	Source
+	Via     string `json:"via"`
+	Created bool   `json:"created"`
 }
 
 // SourceSummary extends Source with aggregate counts.
@@ -18,6 +46,38 @@ type SourceSummary struct {
 	NetworkCount      int64 `db:"network_count" json:"network_count"`
 	StationCount      int64 `db:"station_count" json:"station_count"`
 	AvailabilityCount int64 `db:"availability_count" json:"availability_count"`
+
+	// Health probe results; nil until the source has been probed.
+	LatencyMS   *int64     `db:"latency_ms" json:"latency_ms"`
+	LastChecked *time.Time `db:"last_checked" json:"last_checked"`
+	LastSuccess *time.Time `db:"last_success" json:"last_success"`
+	LastFailure *time.Time `db:"last_failure" json:"last_failure"`
+	LastError   string     `db:"last_error" json:"last_error,omitempty"`
+
+	Services []SourceService `db:"-" json:"services"`
+}
+
+// SourceService is an FDSN web service found on a source by the health probe.
+type SourceService struct {
+	SourceID   int64     `db:"source_id" json:"-"`
+	Service    string    `db:"service" json:"service"`
+	Version    string    `db:"version" json:"version"`
+	Params     string    `db:"parameters" json:"-"`
+	Parameters []string  `db:"-" json:"parameters"`
+	CheckedAt  time.Time `db:"checked_at" json:"checked_at"`
+	LastError  string    `db:"last_error" json:"last_error,omitempty"`
+}
+
+// ProbeResult is the outcome of one health probe of a source.
+type ProbeResult struct {
+	SourceID  int64           `json:"source_id"`
+	CheckedAt time.Time       `json:"checked_at"`
+	LatencyMS int64           `json:"latency_ms"`
+	Services  []SourceService `json:"services"`
+	Error     string          `json:"error,omitempty"`
+
+	// ServiceErrors maps the services that failed to answer to their error.
+	ServiceErrors map[string]string `json:"service_errors,omitempty"`
 }
 
 type Network struct {
@@ -28,6 +88,11 @@ type Network struct {
 	StartTime   *time.Time `db:"start_time" json:"start_time"`
 	EndTime     *time.Time `db:"end_time" json:"end_time"`
 	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	Restricted  bool       `db:"restricted" json:"restricted"`
+
+	// StationXML 1.2 metadata
+	FDSNSourceID string      `db:"fdsn_source_id" json:"fdsn_source_id,omitempty"`
+	Identifiers  Identifiers `db:"identifiers" json:"identifiers,omitempty"`
 }
 
 type Station struct {
@@ -42,11 +107,17 @@ type Station struct {
 	EndTime   *time.Time `db:"end_time" json:"end_time"`
 	CreatedAt time.Time  `db:"created_at" json:"created_at"`
 
+	// StationXML 1.2 metadata
+	FDSNSourceID string      `db:"fdsn_source_id" json:"fdsn_source_id,omitempty"`
+	Identifiers  Identifiers `db:"identifiers" json:"identifiers,omitempty"`
+	WaterLevel   *float64    `db:"water_level" json:"water_level,omitempty"`
+
 	// Joined fields (not always populated)
 	NetworkCode     string `db:"network_code" json:"network_code,omitempty"`
 	SourceName      string `db:"source_name" json:"source_name,omitempty"`
 	SourceID        int64  `db:"source_id" json:"source_id,omitempty"`
 	HasAvailability bool   `db:"has_availability" json:"has_availability"`
+	Restricted      bool   `db:"restricted" json:"restricted"`
 }
 
 type Channel struct {
@@ -68,6 +139,12 @@ type Channel struct {
 	StartTime         *time.Time `db:"start_time" json:"start_time"`
 	EndTime           *time.Time `db:"end_time" json:"end_time"`
 	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	Restricted        bool       `db:"restricted" json:"restricted"`
+
+	// StationXML 1.2 metadata
+	FDSNSourceID string      `db:"fdsn_source_id" json:"fdsn_source_id,omitempty"`
+	Identifiers  Identifiers `db:"identifiers" json:"identifiers,omitempty"`
+	WaterLevel   *float64    `db:"water_level" json:"water_level,omitempty"`
 }
 
 type Availability struct {
@@ -88,6 +165,45 @@ type ChannelAvailability struct {
 	Latest       *string  `db:"latest" json:"latest"`
 }
 
+// StationPage is one page of a station listing. NextCursor fetches the next
+// page and is empty on the last one.
+type StationPage struct {
+	Stations   []Station `json:"stations"`
+	Total      int64     `json:"total"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// StationCluster is a group of nearby stations drawn as one map marker,
+// positioned at their mean location. A cluster of one carries the station's
+// ID and NET.STA code.
+type StationCluster struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int64   `json:"count"`
+	StationID *int64  `json:"station_id,omitempty"`
+	Code      string  `json:"code,omitempty"`
+}
+
+// StationSummary is a station with its network, channel list and overall
+// data availability, the record written by the GeoJSON, KML and CSV exports.
+type StationSummary struct {
+	StationID          int64
+	Network            string
+	NetworkDescription string
+	Station            string
+	Latitude           float64
+	Longitude          float64
+	Elevation          float64
+	SiteName           string
+	StartTime          *time.Time
+	EndTime            *time.Time
+	Restricted         bool
+	DataCenter         string
+	Channels           []string // LOC.CHA, e.g. "00.BHZ", or CHA for an empty location
+	AvailabilityStart  *string
+	AvailabilityEnd    *string
+}
+
 // StationDetail includes a Station plus its channels.
 type StationDetail struct {
 	Station
@@ -121,6 +237,27 @@ type ImportChannel struct {
 	SampleRate         float64
 	ChanStartTime      *time.Time
 	ChanEndTime        *time.Time
+
+	// Upstream restrictedStatus per level; nil leaves the stored flag untouched.
+	NetworkRestricted *bool
+	StationRestricted *bool
+	ChanRestricted    *bool
+
+	// Upstream StationXML metadata per level; nil leaves the stored values
+	// untouched.
+	NetworkMetadata *NodeMetadata
+	StationMetadata *NodeMetadata
+	ChanMetadata    *NodeMetadata
+}
+
+// User is a credential for the FDSN /queryauth endpoints.
+type User struct {
+	ID        int64     `db:"id" json:"id"`
+	Username  string    `db:"username" json:"username"`
+	HA1       string    `db:"ha1" json:"-"`
+	Networks  string    `db:"networks" json:"networks"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // SourceNetwork represents a unique source+network pair for refresh targets.
@@ -130,10 +267,73 @@ type SourceNetwork struct {
 	NetworkCode string `db:"network_code" json:"network_code"`
 }
 
-// Stats holds dashboard summary counts.
+// ChannelEpoch is a stored channel's codes and epoch, with the station,
+// network and source it belongs to.
+type ChannelEpoch struct {
+	ID        int64      `db:"id"`
+	StationID int64      `db:"station_id"`
+	NetworkID int64      `db:"network_id"`
+	SourceID  int64      `db:"source_id"`
+	Network   string     `db:"network_code"`
+	Station   string     `db:"station_code"`
+	Location  string     `db:"location_code"`
+	Channel   string     `db:"code"`
+	StartTime *time.Time `db:"start_time"`
+	EndTime   *time.Time `db:"end_time"`
+}
+
+// PruneResult counts the channels removed from the catalog and the stations
+// and networks removed with them.
+type PruneResult struct {
+	Channels int `json:"channels"`
+	Stations int `json:"stations"`
+	Networks int `json:"networks"`
+}
+
+// SourceDeletion reports the catalog of a deleted source: the networks,
+// stations, channels, availability extents and events removed with it or,
+// when ReassignedTo is set, moved to that source.
+type SourceDeletion struct {
+	SourceID     int64  `json:"source_id"`
+	SourceName   string `json:"source_name"`
+	ReassignedTo int64  `json:"reassigned_to,omitempty"`
+	DryRun       bool   `json:"dry_run"`
+	Networks     int    `json:"networks"`
+	Stations     int    `json:"stations"`
+	Channels     int    `json:"channels"`
+	Availability int    `json:"availability"`
+	Events       int    `json:"events"`
+}
+
+// Stats holds dashboard summary counts. Networks, stations and channels of
+// disabled sources are not counted.
 type Stats struct {
-	Sources  int64 `json:"sources"`
-	Networks int64 `json:"networks"`
-	Stations int64 `json:"stations"`
-	Channels int64 `json:"channels"`
+	Sources        int64 `json:"sources"`
+	EnabledSources int64 `json:"enabled_sources"`
+	Networks       int64 `json:"networks"`
+	Stations       int64 `json:"stations"`
+	Channels       int64 `json:"channels"`
+}
+
+// Kinds of SearchResult.
+const (
+	SearchNetwork = "network"
+	SearchStation = "station"
+	SearchChannel = "channel"
+)
+
+// SearchResult is a network, station or channel matching a search query.
+// Channels of a station that share a sensor are merged into one result, with
+// their codes comma-separated in Code. Score is the share of the query's
+// trigrams found in the result, from 0 to 1.
+type SearchResult struct {
+	Kind         string  `db:"kind" json:"kind"`
+	ID           int64   `db:"ref_id" json:"id"`
+	StationID    *int64  `db:"station_id" json:"station_id,omitempty"`
+	NetworkCode  string  `db:"network" json:"network_code"`
+	StationCode  string  `db:"station" json:"station_code,omitempty"`
+	LocationCode string  `db:"location" json:"location_code,omitempty"`
+	Code         string  `db:"code" json:"code"`
+	Label        string  `db:"label" json:"label"`
+	Score        float64 `db:"-" json:"score"`
 }
@@ -0,0 +1,124 @@
+package models
+
+import "encoding/xml"
+
+// QuakeML 1.2 namespaces: the document root and the basic event description
+// (BED) elements inside it.
+const (
+	QuakeMLNamespace    = "http://quakeml.org/xmlns/quakeml/1.2"
+	QuakeMLBEDNamespace = "http://quakeml.org/xmlns/bed/1.2"
+)
+
+// QuakeML is the root element for QuakeML 1.2 output. Only the parts of the
+// BED schema that are stored are modelled; unknown elements are skipped when
+// parsing.
+type QuakeML struct {
+	XMLName         xml.Name           `xml:"q:quakeml"`
+	XMLNSQ          string             `xml:"xmlns:q,attr"`
+	XMLNS           string             `xml:"xmlns,attr"`
+	EventParameters QMLEventParameters `xml:"eventParameters"`
+}
+
+type QMLEventParameters struct {
+	PublicID     string           `xml:"publicID,attr"`
+	CreationInfo *QMLCreationInfo `xml:"creationInfo,omitempty"`
+	Events       []QMLEvent       `xml:"event"`
+}
+
+// QMLEvent is a QuakeML event. Catalog, Contributor and ContributorID are read
+// from the ANSS catalog:datasource, catalog:eventsource and catalog:dataid
+// attributes, matched in any namespace; they are not written.
+type QMLEvent struct {
+	PublicID             string                `xml:"publicID,attr"`
+	Catalog              string                `xml:"datasource,attr,omitempty"`
+	Contributor          string                `xml:"eventsource,attr,omitempty"`
+	ContributorID        string                `xml:"dataid,attr,omitempty"`
+	Descriptions         []QMLEventDescription `xml:"description,omitempty"`
+	Origins              []QMLOrigin           `xml:"origin,omitempty"`
+	Magnitudes           []QMLMagnitude        `xml:"magnitude,omitempty"`
+	Picks                []QMLPick             `xml:"pick,omitempty"`
+	PreferredOriginID    string                `xml:"preferredOriginID,omitempty"`
+	PreferredMagnitudeID string                `xml:"preferredMagnitudeID,omitempty"`
+	Type                 string                `xml:"type,omitempty"`
+	CreationInfo         *QMLCreationInfo      `xml:"creationInfo,omitempty"`
+}
+
+// QMLEventDescription is a free-text description; the FDSN event service
+// reports the Flinn-Engdahl region with type "region name".
+type QMLEventDescription struct {
+	Text string `xml:"text"`
+	Type string `xml:"type,omitempty"`
+}
+
+type QMLCreationInfo struct {
+	AgencyID     string `xml:"agencyID,omitempty"`
+	Author       string `xml:"author,omitempty"`
+	CreationTime string `xml:"creationTime,omitempty"`
+}
+
+// QMLTime is a QuakeML TimeQuantity.
+type QMLTime struct {
+	Value string `xml:"value"`
+}
+
+// QMLReal is a QuakeML RealQuantity.
+type QMLReal struct {
+	Value       float64  `xml:"value"`
+	Uncertainty *float64 `xml:"uncertainty,omitempty"`
+}
+
+// QMLOrigin is a QuakeML origin. Depth is in metres.
+type QMLOrigin struct {
+	PublicID         string           `xml:"publicID,attr"`
+	Time             QMLTime          `xml:"time"`
+	Latitude         QMLReal          `xml:"latitude"`
+	Longitude        QMLReal          `xml:"longitude"`
+	Depth            *QMLReal         `xml:"depth,omitempty"`
+	DepthType        string           `xml:"depthType,omitempty"`
+	MethodID         string           `xml:"methodID,omitempty"`
+	EarthModelID     string           `xml:"earthModelID,omitempty"`
+	Arrivals         []QMLArrival     `xml:"arrival,omitempty"`
+	EvaluationMode   string           `xml:"evaluationMode,omitempty"`
+	EvaluationStatus string           `xml:"evaluationStatus,omitempty"`
+	CreationInfo     *QMLCreationInfo `xml:"creationInfo,omitempty"`
+}
+
+type QMLMagnitude struct {
+	PublicID     string           `xml:"publicID,attr"`
+	Mag          QMLReal          `xml:"mag"`
+	Type         string           `xml:"type,omitempty"`
+	OriginID     string           `xml:"originID,omitempty"`
+	StationCount *int64           `xml:"stationCount,omitempty"`
+	CreationInfo *QMLCreationInfo `xml:"creationInfo,omitempty"`
+}
+
+type QMLPick struct {
+	PublicID       string           `xml:"publicID,attr"`
+	Time           QMLTime          `xml:"time"`
+	WaveformID     QMLWaveformID    `xml:"waveformID"`
+	Onset          string           `xml:"onset,omitempty"`
+	PhaseHint      string           `xml:"phaseHint,omitempty"`
+	Polarity       string           `xml:"polarity,omitempty"`
+	EvaluationMode string           `xml:"evaluationMode,omitempty"`
+	CreationInfo   *QMLCreationInfo `xml:"creationInfo,omitempty"`
+}
+
+// QMLWaveformID names the channel a pick was read on.
+type QMLWaveformID struct {
+	NetworkCode  string `xml:"networkCode,attr"`
+	StationCode  string `xml:"stationCode,attr"`
+	LocationCode string `xml:"locationCode,attr,omitempty"`
+	ChannelCode  string `xml:"channelCode,attr,omitempty"`
+}
+
+// QMLArrival is a QuakeML arrival. Distance and azimuth are in degrees,
+// the time residual in seconds.
+type QMLArrival struct {
+	PublicID     string   `xml:"publicID,attr"`
+	PickID       string   `xml:"pickID"`
+	Phase        string   `xml:"phase"`
+	Azimuth      *float64 `xml:"azimuth,omitempty"`
+	Distance     *float64 `xml:"distance,omitempty"`
+	TimeResidual *float64 `xml:"timeResidual,omitempty"`
+	TimeWeight   *float64 `xml:"timeWeight,omitempty"`
+}
@@ -1,6 +1,17 @@
 package models
 
-import "encoding/xml"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+)
+
+// StationXML schema versions this server reads and writes.
+const (
+	StationXMLVersion11 = "1.1"
+	StationXMLVersion12 = "1.2"
+)
 
 // FDSNStationXML is the root element for StationXML output.
 type FDSNStationXML struct {
@@ -17,40 +28,72 @@ type XMLNetwork struct {
 	Code        string       `xml:"code,attr"`
 	StartDate   string       `xml:"startDate,attr,omitempty"`
 	EndDate     string       `xml:"endDate,attr,omitempty"`
+	Restricted  string       `xml:"restrictedStatus,attr,omitempty"`
+	SourceID    string       `xml:"sourceID,attr,omitempty"` // 1.2
 	Description string       `xml:"Description,omitempty"`
+	Identifiers Identifiers  `xml:"Identifier,omitempty"`
+	Comments    []XMLComment `xml:"Comment,omitempty"`
 	Stations    []XMLStation `xml:"Station,omitempty"`
 }
 
+// XMLComment is a StationXML Comment. Networks carry one with subject
+// "DataCenter" naming the source they were imported from.
+type XMLComment struct {
+	Subject string `xml:"subject,attr,omitempty"`
+	Value   string `xml:"Value"`
+}
+
 type XMLStation struct {
-	Code      string       `xml:"code,attr"`
-	StartDate string       `xml:"startDate,attr,omitempty"`
-	EndDate   string       `xml:"endDate,attr,omitempty"`
-	Latitude  XMLValue     `xml:"Latitude"`
-	Longitude XMLValue     `xml:"Longitude"`
-	Elevation XMLValue     `xml:"Elevation"`
-	Site      XMLSite      `xml:"Site"`
-	Channels  []XMLChannel `xml:"Channel,omitempty"`
+	Code        string       `xml:"code,attr"`
+	StartDate   string       `xml:"startDate,attr,omitempty"`
+	EndDate     string       `xml:"endDate,attr,omitempty"`
+	Restricted  string       `xml:"restrictedStatus,attr,omitempty"`
+	SourceID    string       `xml:"sourceID,attr,omitempty"` // 1.2
+	Identifiers Identifiers  `xml:"Identifier,omitempty"`
+	Latitude    XMLValue     `xml:"Latitude"`
+	Longitude   XMLValue     `xml:"Longitude"`
+	Elevation   XMLValue     `xml:"Elevation"`
+	Site        XMLSite      `xml:"Site"`
+	WaterLevel  *XMLValue    `xml:"WaterLevel,omitempty"`
+	Channels    []XMLChannel `xml:"Channel,omitempty"`
 }
 
 type XMLChannel struct {
-	Code         string     `xml:"code,attr"`
-	LocationCode string     `xml:"locationCode,attr"`
-	StartDate    string     `xml:"startDate,attr,omitempty"`
-	EndDate      string     `xml:"endDate,attr,omitempty"`
-	Latitude     XMLValue   `xml:"Latitude"`
-	Longitude    XMLValue   `xml:"Longitude"`
-	Elevation    XMLValue   `xml:"Elevation"`
-	Depth        XMLValue   `xml:"Depth"`
-	Azimuth      XMLValue   `xml:"Azimuth,omitempty"`
-	Dip          XMLValue   `xml:"Dip,omitempty"`
-	SampleRate   float64    `xml:"SampleRate,omitempty"`
-	Sensor       *XMLSensor `xml:"Sensor,omitempty"`
+	Code         string       `xml:"code,attr"`
+	LocationCode string       `xml:"locationCode,attr"`
+	StartDate    string       `xml:"startDate,attr,omitempty"`
+	EndDate      string       `xml:"endDate,attr,omitempty"`
+	Restricted   string       `xml:"restrictedStatus,attr,omitempty"`
+	SourceID     string       `xml:"sourceID,attr,omitempty"` // 1.2
+	Identifiers  Identifiers  `xml:"Identifier,omitempty"`
+	Latitude     XMLValue     `xml:"Latitude"`
+	Longitude    XMLValue     `xml:"Longitude"`
+	Elevation    XMLValue     `xml:"Elevation"`
+	Depth        XMLValue     `xml:"Depth"`
+	Azimuth      *XMLValue    `xml:"Azimuth,omitempty"`
+	Dip          *XMLValue    `xml:"Dip,omitempty"`
+	WaterLevel   *XMLValue    `xml:"WaterLevel,omitempty"`
+	SampleRate   *XMLValue    `xml:"SampleRate,omitempty"`
+	Sensor       *XMLSensor   `xml:"Sensor,omitempty"`
+	Response     *XMLResponse `xml:"Response,omitempty"`
 }
 
+// XMLValue is a StationXML FloatType. Unit is fixed by the schema for each
+// element; use the constructors below to set it.
 type XMLValue struct {
 	Value float64 `xml:",chardata"`
+	Unit  string  `xml:"unit,attr,omitempty"`
 }
 
+// Degrees returns a latitude, longitude, azimuth or dip value.
+func Degrees(v float64) XMLValue { return XMLValue{Value: v, Unit: "DEGREES"} }
+
+// Meters returns an elevation or depth value.
+func Meters(v float64) XMLValue { return XMLValue{Value: v, Unit: "METERS"} }
+
+// SamplesPerSecond returns a sample rate value.
+func SamplesPerSecond(v float64) XMLValue { return XMLValue{Value: v, Unit: "SAMPLES/S"} }
+
 type XMLSite struct {
 	Name string `xml:"Name"`
 }
@@ -58,3 +101,75 @@ type XMLSite struct {
 type XMLSensor struct {
 	Description string `xml:"Description,omitempty"`
 }
+
+// XMLResponse is a channel's Response. Only the overall sensitivity is
+// known: response stages are not imported.
+type XMLResponse struct {
+	InstrumentSensitivity *XMLSensitivity `xml:"InstrumentSensitivity,omitempty"`
+}
+
+// XMLSensitivity is the gain from InputUnits to OutputUnits at Frequency.
+type XMLSensitivity struct {
+	Value       float64  `xml:"Value"`
+	Frequency   float64  `xml:"Frequency"`
+	InputUnits  XMLUnits `xml:"InputUnits"`
+	OutputUnits XMLUnits `xml:"OutputUnits"`
+}
+
+// XMLUnits names the units of a response, e.g. M/S or COUNTS.
+type XMLUnits struct {
+	Name string `xml:"Name"`
+}
+
+// StationXML restrictedStatus values.
+const (
+	RestrictedOpen    = "open"
+	RestrictedClosed  = "closed"
+	RestrictedPartial = "partial"
+)
+
+// XMLIdentifier is a persistent identifier of a node, such as a DOI.
+type XMLIdentifier struct {
+	Type  string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Value string `xml:",chardata" json:"value"`
+}
+
+// Identifiers are stored as a JSON array; an empty column is no identifiers.
+type Identifiers []XMLIdentifier
+
+// Scan implements sql.Scanner.
+func (ids *Identifiers) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into Identifiers", src)
+	}
+	*ids = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, ids)
+}
+
+// Value implements driver.Valuer.
+func (ids Identifiers) Value() (driver.Value, error) {
+	if len(ids) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(ids)
+	return string(data), err
+}
+
+// NodeMetadata is what StationXML says about a network, station or channel
+// beyond the text format used for import. WaterLevel is only set for
+// stations and channels.
+type NodeMetadata struct {
+	SourceID    string
+	Identifiers Identifiers
+	WaterLevel  *float64
+}
@@ -0,0 +1,103 @@
+// Package secrets encrypts small values (such as upstream credentials) for
+// storage at rest using AES-256-GCM with a locally held key.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefix marks the ciphertext format so it can be changed later.
+const prefix = "v1:"
+
+// Box seals and opens values with a single AES-256-GCM key.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box for a 32-byte key.
+func New(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secret key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// LoadOrCreateKeyFile reads a hex-encoded key from path, generating and
+// writing a new random key (mode 0600) if the file does not exist.
+func LoadOrCreateKeyFile(path string) (*Box, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("create key directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("write key file: %w", err)
+		}
+		return New(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key file %s: %w", path, err)
+	}
+	return New(key)
+}
+
+// Seal encrypts plaintext. The empty string seals to the empty string.
+func (b *Box) Seal(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal.
+func (b *Box) Open(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(ciphertext, prefix) {
+		return "", errors.New("unrecognised ciphertext format")
+	}
+	raw, err := base64.StdEncoding.DecodeString(ciphertext[len(prefix):])
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext: %w", err)
+	}
+	n := b.aead.NonceSize()
+	if len(raw) < n {
+		return "", errors.New("ciphertext too short")
+	}
+	plain, err := b.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	box, err := LoadOrCreateKeyFile(filepath.Join(t.TempDir(), "k", "secret.key"))
+	if err != nil {
+		t.Fatalf("LoadOrCreateKeyFile: %v", err)
+	}
+
+	sealed, err := box.Seal("hunter2")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if strings.Contains(sealed, "hunter2") || !strings.HasPrefix(sealed, prefix) {
+		t.Errorf("unexpected sealed value %q", sealed)
+	}
+	plain, err := box.Open(sealed)
+	if err != nil || plain != "hunter2" {
+		t.Errorf("Open = %q, %v", plain, err)
+	}
+
+	if s, _ := box.Seal(""); s != "" {
+		t.Errorf("Seal(\"\") = %q, want empty", s)
+	}
+}
+
+func TestKeyFileReused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.key")
+	a, err := LoadOrCreateKeyFile(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	sealed, _ := a.Seal("value")
+	b, err := LoadOrCreateKeyFile(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if plain, err := b.Open(sealed); err != nil || plain != "value" {
+		t.Errorf("Open with reloaded key = %q, %v", plain, err)
+	}
+
+	other, _ := LoadOrCreateKeyFile(filepath.Join(t.TempDir(), "other.key"))
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("expected Open with a different key to fail")
+	}
+}
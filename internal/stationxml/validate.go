@@ -0,0 +1,486 @@
+// Package stationxml checks StationXML documents against the rules of the
+// FDSN StationXML 1.1 and 1.2 schemas: element order and occurrence,
+// required and allowed attributes, fixed unit attributes, and value formats
+// and ranges. It covers the network, station and channel levels this server
+// emits, and a Response's InstrumentSensitivity; the contents of elements
+// such as response stages, Equipment and DataAvailability are not checked.
+package stationxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Namespace is the StationXML namespace for every schema version.
+const Namespace = "http://www.fdsn.org/xml/station/1"
+
+// Problem is a schema violation at Path, e.g.
+// "/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]/Azimuth".
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Validate reads a StationXML document and returns its schema violations,
+// or none if it is valid. The error is only for documents that are not
+// well-formed XML.
+func Validate(r io.Reader) ([]Problem, error) {
+	root, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{}
+	if root.name != "FDSNStationXML" {
+		v.addf("/"+root.name, "root element must be FDSNStationXML")
+		return v.problems, nil
+	}
+	if root.space != Namespace {
+		v.addf("/FDSNStationXML", "namespace is %q, want %q", root.space, Namespace)
+	}
+	v.version = root.attrs["schemaVersion"]
+	switch v.version {
+	case "1.1", "1.2":
+	case "":
+		v.addf("/FDSNStationXML", "missing schemaVersion")
+		v.version = "1.1"
+	default:
+		v.addf("/FDSNStationXML", "schemaVersion %q is not 1.1 or 1.2", v.version)
+	}
+	v.check(root, "/FDSNStationXML", "FDSNStationXML")
+	return v.problems, nil
+}
+
+// node is a parsed element. Namespace prefixes are dropped from names.
+type node struct {
+	name     string
+	space    string
+	attrs    map[string]string
+	children []*node
+	text     string
+}
+
+func parse(r io.Reader) (*node, error) {
+	dec := xml.NewDecoder(r)
+	var stack []*node
+	var root *node
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, space: t.Name.Space, attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				if a.Name.Space == "" {
+					n.attrs[a.Name.Local] = a.Value
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("empty document")
+	}
+	return root, nil
+}
+
+type validator struct {
+	version  string
+	problems []Problem
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check validates n as an element of type typ.
+func (v *validator) check(n *node, path, typ string) {
+	t, ok := types[typ]
+	if !ok {
+		return // contents not checked
+	}
+
+	attrs := t.attrs
+	if t.node {
+		attrs = append(nodeAttrs(v.version), attrs...)
+	}
+	allowed := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		allowed[a.name] = true
+		val, ok := n.attrs[a.name]
+		if !ok {
+			if a.required {
+				v.addf(path, "missing required attribute %s", a.name)
+			}
+			continue
+		}
+		if a.fixed != "" && val != a.fixed {
+			v.addf(path, "attribute %s is %q, must be %q", a.name, val, a.fixed)
+		}
+		if msg := a.kind.check(val); msg != "" {
+			v.addf(path, "attribute %s %s", a.name, msg)
+		}
+	}
+	for name := range n.attrs {
+		if !allowed[name] && name != "xmlns" {
+			v.addf(path, "attribute %s is not allowed", name)
+		}
+	}
+	if t.node {
+		if s, e := n.attrs["startDate"], n.attrs["endDate"]; s != "" && e != "" && dateTimeRe.MatchString(s) && dateTimeRe.MatchString(e) && e < s {
+			v.addf(path, "endDate %s is before startDate %s", e, s)
+		}
+	}
+
+	if t.value != nil {
+		text := strings.TrimSpace(n.text)
+		if msg := t.value.check(text); msg != "" {
+			v.addf(path, "value %s", msg)
+		}
+		if t.min != nil || t.max != nil {
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				if (t.min != nil && f < *t.min) || (t.max != nil && f > *t.max) {
+					v.addf(path, "value %s is outside [%g, %g]", text, deref(t.min), deref(t.max))
+				}
+			}
+		}
+	}
+
+	children := t.children
+	if typ == "Network" && v.version == "1.2" {
+		children = append([]child{{"Operator", "", 0, unbounded}}, children...)
+	}
+	if t.node {
+		children = append(nodeChildren(), children...)
+	}
+	v.checkChildren(n, path, children)
+}
+
+// checkChildren checks that n's child elements follow the schema sequence.
+func (v *validator) checkChildren(n *node, path string, seq []child) {
+	pos := make(map[string]int, len(seq))
+	for i, c := range seq {
+		pos[c.name] = i
+	}
+	counts := make([]int, len(seq))
+	cur := 0
+	for _, c := range n.children {
+		i, ok := pos[c.name]
+		if !ok {
+			v.addf(path, "unexpected element %s", c.name)
+			continue
+		}
+		if i < cur {
+			v.addf(path, "element %s is out of order: it must come before %s", c.name, seq[cur].name)
+		} else {
+			cur = i
+		}
+		counts[i]++
+		if seq[i].max != unbounded && counts[i] > seq[i].max {
+			v.addf(path, "too many %s elements", c.name)
+		}
+		typ := seq[i].typ
+		if typ == "" {
+			typ = c.name
+		}
+		v.check(c, path+"/"+c.name+label(c), typ)
+	}
+	for i, c := range seq {
+		if counts[i] < c.min {
+			v.addf(path, "missing required element %s", c.name)
+		}
+	}
+}
+
+// label identifies repeated elements in paths.
+func label(n *node) string {
+	switch n.name {
+	case "Network", "Station":
+		return "[" + n.attrs["code"] + "]"
+	case "Channel":
+		return "[" + n.attrs["locationCode"] + "." + n.attrs["code"] + "]"
+	}
+	return ""
+}
+
+const unbounded = -1
+
+type child struct {
+	name     string
+	typ      string // type in types; defaults to name
+	min, max int
+}
+
+type attr struct {
+	name     string
+	required bool
+	kind     kind
+	fixed    string
+}
+
+type elementType struct {
+	node     bool // a BaseNodeType: Network, Station or Channel
+	attrs    []attr
+	children []child
+	value    kind // simple content
+	min, max *float64
+}
+
+type kind func(string) string
+
+func (k kind) check(s string) string {
+	if k == nil {
+		return ""
+	}
+	return k(s)
+}
+
+var (
+	dateTimeRe = regexp.MustCompile(`^-?\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
+	// Codes are letters, digits and a few symbols; location codes may be empty.
+	codeRe = regexp.MustCompile(`^[A-Za-z0-9*?_-]*$`)
+)
+
+var (
+	anyString kind = func(string) string { return "" }
+	dateTime  kind = func(s string) string {
+		if !dateTimeRe.MatchString(s) {
+			return fmt.Sprintf("%q is not an xsd:dateTime", s)
+		}
+		return ""
+	}
+	decimal kind = func(s string) string {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", s)
+		}
+		return ""
+	}
+	integer kind = func(s string) string {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			return fmt.Sprintf("%q is not a non-negative integer", s)
+		}
+		return ""
+	}
+	code kind = func(s string) string {
+		if !codeRe.MatchString(s) {
+			return fmt.Sprintf("%q is not a valid code", s)
+		}
+		return ""
+	}
+	restricted kind = func(s string) string {
+		switch s {
+		case "open", "closed", "partial":
+			return ""
+		}
+		return fmt.Sprintf("%q is not open, closed or partial", s)
+	}
+)
+
+func nodeAttrs(version string) []attr {
+	attrs := []attr{
+		{name: "code", required: true, kind: code},
+		{name: "startDate", kind: dateTime},
+		{name: "endDate", kind: dateTime},
+		{name: "restrictedStatus", kind: restricted},
+		{name: "alternateCode", kind: anyString},
+		{name: "historicalCode", kind: anyString},
+	}
+	if version == "1.2" {
+		attrs = append(attrs, attr{name: "sourceID", kind: anyString})
+	}
+	return attrs
+}
+
+func nodeChildren() []child {
+	return []child{
+		{"Description", "string", 0, 1},
+		{"Identifier", "", 0, unbounded},
+		{"Comment", "", 0, unbounded},
+		{"DataAvailability", "", 0, 1},
+	}
+}
+
+// floatAttrs are the attributes of FloatType, with unit fixed if not "".
+func floatAttrs(unit string) []attr {
+	return []attr{
+		{name: "unit", kind: anyString, fixed: unit},
+		{name: "plusError", kind: decimal},
+		{name: "minusError", kind: decimal},
+		{name: "measurementMethod", kind: anyString},
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func deref(p *float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+var types = map[string]elementType{
+	"FDSNStationXML": {
+		attrs: []attr{{name: "schemaVersion", required: true, kind: decimal}},
+		children: []child{
+			{"Source", "string", 1, 1},
+			{"Sender", "string", 0, 1},
+			{"Module", "string", 0, 1},
+			{"ModuleURI", "string", 0, 1},
+			{"Created", "dateTime", 1, 1},
+			{"Network", "", 1, unbounded},
+		},
+	},
+	"Network": {
+		node: true,
+		children: []child{
+			{"TotalNumberStations", "counter", 0, 1},
+			{"SelectedNumberStations", "counter", 0, 1},
+			{"Station", "", 0, unbounded},
+		},
+	},
+	"Station": {
+		node: true,
+		children: []child{
+			{"Latitude", "", 1, 1},
+			{"Longitude", "", 1, 1},
+			{"Elevation", "Distance", 1, 1},
+			{"Site", "", 1, 1},
+			{"WaterLevel", "Float", 0, 1},
+			{"Vault", "string", 0, 1},
+			{"Geology", "string", 0, 1},
+			{"Equipment", "", 0, unbounded},
+			{"Operator", "", 0, unbounded},
+			{"CreationDate", "dateTime", 0, 1},
+			{"TerminationDate", "dateTime", 0, 1},
+			{"TotalNumberChannels", "counter", 0, 1},
+			{"SelectedNumberChannels", "counter", 0, 1},
+			{"ExternalReference", "", 0, unbounded},
+			{"Channel", "", 0, unbounded},
+		},
+	},
+	"Channel": {
+		node:  true,
+		attrs: []attr{{name: "locationCode", required: true, kind: code}},
+		children: []child{
+			{"ExternalReference", "", 0, unbounded},
+			{"Latitude", "", 1, 1},
+			{"Longitude", "", 1, 1},
+			{"Elevation", "Distance", 1, 1},
+			{"Depth", "Distance", 1, 1},
+			{"Azimuth", "", 0, 1},
+			{"Dip", "", 0, 1},
+			{"WaterLevel", "Float", 0, 1},
+			{"Type", "string", 0, unbounded},
+			{"SampleRate", "", 0, 1},
+			{"SampleRateRatio", "", 0, 1},
+			{"ClockDrift", "", 0, 1},
+			{"CalibrationUnits", "", 0, 1},
+			{"Sensor", "Equipment", 0, 1},
+			{"PreAmplifier", "Equipment", 0, 1},
+			{"DataLogger", "Equipment", 0, 1},
+			{"Equipment", "", 0, unbounded},
+			{"Response", "", 0, 1},
+		},
+	},
+	"Comment": {
+		attrs: []attr{{name: "id", kind: integer}, {name: "subject", kind: anyString}},
+		children: []child{
+			{"Value", "string", 1, 1},
+			{"BeginEffectiveTime", "dateTime", 0, 1},
+			{"EndEffectiveTime", "dateTime", 0, 1},
+			{"Author", "", 0, unbounded},
+		},
+	},
+	"Site": {
+		children: []child{
+			{"Name", "string", 1, 1},
+			{"Description", "string", 0, 1},
+			{"Town", "string", 0, 1},
+			{"County", "string", 0, 1},
+			{"Region", "string", 0, 1},
+			{"Country", "string", 0, 1},
+		},
+	},
+	"Equipment": {
+		attrs: []attr{{name: "resourceId", kind: anyString}},
+		children: []child{
+			{"Type", "string", 0, 1},
+			{"Description", "string", 0, 1},
+			{"Manufacturer", "string", 0, 1},
+			{"Vendor", "string", 0, 1},
+			{"Model", "string", 0, 1},
+			{"SerialNumber", "string", 0, 1},
+			{"InstallationDate", "dateTime", 0, 1},
+			{"RemovalDate", "dateTime", 0, 1},
+			{"CalibrationDate", "dateTime", 0, unbounded},
+		},
+	},
+	"Response": {
+		attrs: []attr{{name: "resourceId", kind: anyString}},
+		children: []child{
+			{"InstrumentSensitivity", "", 0, 1},
+			{"InstrumentPolynomial", "", 0, 1},
+			{"Stage", "", 0, unbounded},
+		},
+	},
+	"InstrumentSensitivity": {
+		children: []child{
+			{"Value", "double", 1, 1},
+			{"Frequency", "double", 1, 1},
+			{"InputUnits", "Units", 1, 1},
+			{"OutputUnits", "Units", 1, 1},
+			{"FrequencyStart", "double", 0, 1},
+			{"FrequencyEnd", "double", 0, 1},
+			{"FrequencyDBVariation", "double", 0, 1},
+		},
+	},
+	"Units": {
+		children: []child{
+			{"Name", "string", 1, 1},
+			{"Description", "string", 0, 1},
+		},
+	},
+	"Latitude": {
+		attrs: append(floatAttrs("DEGREES"), attr{name: "datum", kind: anyString}),
+		value: decimal, min: ptr(-90), max: ptr(90),
+	},
+	"Longitude": {
+		attrs: append(floatAttrs("DEGREES"), attr{name: "datum", kind: anyString}),
+		value: decimal, min: ptr(-180), max: ptr(180),
+	},
+	"Azimuth":    {attrs: floatAttrs("DEGREES"), value: decimal, min: ptr(0), max: ptr(360)},
+	"Dip":        {attrs: floatAttrs("DEGREES"), value: decimal, min: ptr(-90), max: ptr(90)},
+	"SampleRate": {attrs: floatAttrs("SAMPLES/S"), value: decimal},
+	"Distance":   {attrs: floatAttrs("METERS"), value: decimal},
+	"Float":      {attrs: floatAttrs(""), value: decimal},
+	"double":     {value: decimal},
+	"string":     {value: anyString},
+	"dateTime":   {value: dateTime},
+	"counter":    {value: integer},
+}
@@ -0,0 +1,125 @@
+package stationxml
+
+import (
+	"strings"
+	"testing"
+)
+
+const validDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<FDSNStationXML xmlns="http://www.fdsn.org/xml/station/1" schemaVersion="1.1">
+  <Source>test</Source>
+  <Created>2024-01-01T00:00:00</Created>
+  <Network code="IU" startDate="1988-01-01T00:00:00" restrictedStatus="open">
+    <Description>Global Seismograph Network</Description>
+    <Comment subject="DataCenter"><Value>IRIS,http://service.iris.edu</Value></Comment>
+    <Station code="ANMO" startDate="2002-11-19T21:07:00">
+      <Latitude unit="DEGREES">34.9459</Latitude>
+      <Longitude unit="DEGREES">-106.4572</Longitude>
+      <Elevation unit="METERS">1850</Elevation>
+      <Site><Name>Albuquerque, New Mexico, USA</Name></Site>
+      <Channel code="BHZ" locationCode="00">
+        <Latitude>34.9459</Latitude>
+        <Longitude>-106.4572</Longitude>
+        <Elevation>1850</Elevation>
+        <Depth unit="METERS">145</Depth>
+        <Azimuth unit="DEGREES">0</Azimuth>
+        <Dip unit="DEGREES">-90</Dip>
+        <SampleRate unit="SAMPLES/S">40</SampleRate>
+        <Sensor><Description>Streckeisen STS-1</Description></Sensor>
+        <Response>
+          <InstrumentSensitivity>
+            <Value>3.3e9</Value>
+            <Frequency>0.02</Frequency>
+            <InputUnits><Name>M/S</Name></InputUnits>
+            <OutputUnits><Name>COUNTS</Name></OutputUnits>
+          </InstrumentSensitivity>
+        </Response>
+      </Channel>
+    </Station>
+  </Network>
+</FDSNStationXML>`
+
+func TestValidateValid(t *testing.T) {
+	problems, err := Validate(strings.NewReader(validDoc))
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for _, p := range problems {
+		t.Errorf("unexpected problem: %s", p)
+	}
+
+	v12 := strings.Replace(validDoc, `schemaVersion="1.1"`, `schemaVersion="1.2"`, 1)
+	v12 = strings.Replace(v12, `<Network code="IU"`, `<Network code="IU" sourceID="http://example.org/IU"`, 1)
+	if problems, _ := Validate(strings.NewReader(v12)); len(problems) != 0 {
+		t.Errorf("1.2 document: unexpected problems %v", problems)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		path    string
+		message string
+	}{
+		{"wrong unit", `<Depth unit="METERS">`, `<Depth unit="KILOMETERS">`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]/Depth", `attribute unit is "KILOMETERS", must be "METERS"`},
+		{"out of order", `<Azimuth unit="DEGREES">0</Azimuth>
+        <Dip unit="DEGREES">-90</Dip>`, `<Dip unit="DEGREES">-90</Dip>
+        <Azimuth unit="DEGREES">0</Azimuth>`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]", "element Azimuth is out of order: it must come before Dip"},
+		{"missing element", `<Latitude unit="DEGREES">34.9459</Latitude>
+      <Longitude`, `<Longitude`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]", "missing required element Latitude"},
+		{"empty date", `startDate="2002-11-19T21:07:00"`, `startDate=""`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]", `attribute startDate "" is not an xsd:dateTime`},
+		{"end before start", `<Station code="ANMO" startDate="2002-11-19T21:07:00">`, `<Station code="ANMO" startDate="2002-11-19T21:07:00" endDate="2001-01-01T00:00:00">`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]", "endDate 2001-01-01T00:00:00 is before startDate 2002-11-19T21:07:00"},
+		{"out of range", `<Dip unit="DEGREES">-90</Dip>`, `<Dip unit="DEGREES">-95</Dip>`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]/Dip", "value -95 is outside [-90, 90]"},
+		{"not a number", `<SampleRate unit="SAMPLES/S">40</SampleRate>`, `<SampleRate unit="SAMPLES/S"></SampleRate>`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]/SampleRate", `value "" is not a number`},
+		{"bad restricted status", `restrictedStatus="open"`, `restrictedStatus="public"`,
+			"/FDSNStationXML/Network[IU]", `attribute restrictedStatus "public" is not open, closed or partial`},
+		{"missing location code", `<Channel code="BHZ" locationCode="00">`, `<Channel code="BHZ">`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[.BHZ]", "missing required attribute locationCode"},
+		{"1.2 attribute in 1.1", `<Network code="IU"`, `<Network code="IU" sourceID="x"`,
+			"/FDSNStationXML/Network[IU]", "attribute sourceID is not allowed"},
+		{"no networks", validDoc[strings.Index(validDoc, "  <Network"):strings.Index(validDoc, "</FDSNStationXML>")], "",
+			"/FDSNStationXML", "missing required element Network"},
+		{"sensitivity without units", `<OutputUnits><Name>COUNTS</Name></OutputUnits>`, "",
+			"/FDSNStationXML/Network[IU]/Station[ANMO]/Channel[00.BHZ]/Response/InstrumentSensitivity", "missing required element OutputUnits"},
+		{"unknown element", `<Site>`, `<Vault>rock</Vault><Site>`,
+			"/FDSNStationXML/Network[IU]/Station[ANMO]", "element Site is out of order: it must come before Vault"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := strings.Replace(validDoc, tt.old, tt.new, 1)
+			if doc == validDoc {
+				t.Fatal("test did not change the document")
+			}
+			problems, err := Validate(strings.NewReader(doc))
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			want := Problem{Path: tt.path, Message: tt.message}
+			for _, p := range problems {
+				if p == want {
+					return
+				}
+			}
+			t.Errorf("problems %v do not include %q", problems, want)
+		})
+	}
+}
+
+func TestValidateRoot(t *testing.T) {
+	problems, err := Validate(strings.NewReader(`<Other/>`))
+	if err != nil || len(problems) != 1 {
+		t.Fatalf("Validate = %v, %v; want one problem", problems, err)
+	}
+	if _, err := Validate(strings.NewReader(`<FDSNStationXML>`)); err == nil {
+		t.Error("expected an error for a document that is not well-formed")
+	}
+}
@@ -3,16 +3,16 @@ package store
 import (
 	"fmt"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/joescharf/fdsn/internal/database"
 	"github.com/joescharf/fdsn/internal/models"
 )
 
 type availabilityStore struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
-// NewAvailabilityStore returns an AvailabilityStore backed by SQLite.
-func NewAvailabilityStore(db *sqlx.DB) AvailabilityStore {
+// NewAvailabilityStore returns an AvailabilityStore backed by the SQL database.
+func NewAvailabilityStore(db *database.DB) AvailabilityStore {
 	return &availabilityStore{db: db}
 }
 
@@ -52,7 +52,7 @@ func (s *availabilityStore) UpsertBatch(items []AvailabilityItem) error {
 
 func (s *availabilityStore) GetByStationID(stationID int64) ([]models.ChannelAvailability, error) {
 	var rows []models.ChannelAvailability
-	err := s.db.Select(&rows, `
+	err := s.db.Reader.Select(&rows, `
 		SELECT c.id AS channel_id, c.location_code, c.code, c.sample_rate,
 		       a.earliest, a.latest
 		FROM channels c
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/secrets"
+)
+
+// ErrNoSecretKey is returned when credentials are written without an
+// encryption key configured.
+var ErrNoSecretKey = errors.New("no secret key configured for credential encryption")
+
+type credentialStore struct {
+	db  *database.DB
+	box *secrets.Box
+}
+
+// NewCredentialStore returns a CredentialStore that seals secrets with box.
+// With a nil box, stored credentials are treated as absent and Set fails.
+func NewCredentialStore(db *database.DB, box *secrets.Box) CredentialStore {
+	return &credentialStore{db: db, box: box}
+}
+
+func (s *credentialStore) Get(sourceID int64) (*models.SourceCredentials, error) {
+	if s.box == nil {
+		return nil, nil
+	}
+	var row struct {
+		Username  string `db:"username"`
+		Password  string `db:"password"`
+		EIDAToken string `db:"eida_token"`
+	}
+	err := s.db.Reader.Get(&row, "SELECT username, password, eida_token FROM source_credentials WHERE source_id = ?", sourceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &models.SourceCredentials{Username: row.Username}
+	if c.Password, err = s.box.Open(row.Password); err != nil {
+		return nil, fmt.Errorf("decrypt password for source %d: %w", sourceID, err)
+	}
+	if c.EIDAToken, err = s.box.Open(row.EIDAToken); err != nil {
+		return nil, fmt.Errorf("decrypt EIDA token for source %d: %w", sourceID, err)
+	}
+	return c, nil
+}
+
+func (s *credentialStore) Set(sourceID int64, c *models.SourceCredentials) error {
+	if s.box == nil {
+		return ErrNoSecretKey
+	}
+	password, err := s.box.Seal(c.Password)
+	if err != nil {
+		return fmt.Errorf("encrypt password: %w", err)
+	}
+	token, err := s.box.Seal(c.EIDAToken)
+	if err != nil {
+		return fmt.Errorf("encrypt EIDA token: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT INTO source_credentials (source_id, username, password, eida_token, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (source_id) DO UPDATE SET username = excluded.username, password = excluded.password,
+			eida_token = excluded.eida_token, updated_at = excluded.updated_at`,
+		sourceID, c.Username, password, token, time.Now())
+	if err != nil {
+		return fmt.Errorf("save credentials: %w", err)
+	}
+	return nil
+}
+
+func (s *credentialStore) Delete(sourceID int64) error {
+	_, err := s.db.Exec("DELETE FROM source_credentials WHERE source_id = ?", sourceID)
+	return err
+}
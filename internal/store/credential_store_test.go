@@ -0,0 +1,58 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/models"
+	"github.com/joescharf/fdsn/internal/secrets"
+)
+
+func TestCredentialStore(t *testing.T) {
+	db := setupStationStore(t).db
+	box, err := secrets.LoadOrCreateKeyFile(filepath.Join(t.TempDir(), "secret.key"))
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	s := NewCredentialStore(db, box)
+
+	if c, err := s.Get(1); err != nil || c != nil {
+		t.Fatalf("Get before Set = %+v, %v", c, err)
+	}
+
+	want := &models.SourceCredentials{Username: "alice", Password: "secret", EIDAToken: "-----BEGIN PGP MESSAGE-----"}
+	if err := s.Set(1, want); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Secrets are not stored in plain text
+	var raw string
+	if err := db.Get(&raw, "SELECT password || eida_token FROM source_credentials WHERE source_id = 1"); err != nil {
+		t.Fatalf("raw select: %v", err)
+	}
+	if strings.Contains(raw, "secret") || strings.Contains(raw, "PGP") {
+		t.Errorf("credentials stored in plain text: %q", raw)
+	}
+
+	got, err := s.Get(1)
+	if err != nil || got == nil || *got != *want {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	src, err := NewSourceStore(db).Get(1)
+	if err != nil || !src.HasCredentials {
+		t.Errorf("source HasCredentials = %v, %v", src != nil && src.HasCredentials, err)
+	}
+
+	if err := NewCredentialStore(db, nil).Set(1, want); err != ErrNoSecretKey {
+		t.Errorf("Set without key: got %v, want ErrNoSecretKey", err)
+	}
+
+	if err := s.Delete(1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if c, _ := s.Get(1); c != nil {
+		t.Errorf("Get after Delete = %+v", c)
+	}
+}
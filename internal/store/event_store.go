@@ -0,0 +1,381 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// ErrEventNotFound is returned by GetEvent and DeleteEvent for an unknown ID.
+var ErrEventNotFound = errors.New("event not found")
+
+// EventQuery selects, orders and pages events for EventStore.ListEvents.
+// Zero values and nil pointers do not filter.
+type EventQuery struct {
+	SourceID int64
+
+	StartTime *time.Time
+	EndTime   *time.Time
+
+	// Position of the event. Radii are great-circle degrees.
+	GeoFilter
+
+	MinDepth     *float64 // km
+	MaxDepth     *float64
+	MinMagnitude *float64
+	MaxMagnitude *float64
+	MagType      string   // case-insensitive
+	EventTypes   []string // case-insensitive
+	EventIDs     []string // local IDs or upstream publicIDs
+	Catalog      string
+	Contributor  string
+	UpdatedAfter *time.Time
+
+	// EnabledOnly hides the events of disabled sources, as the FDSN event
+	// service does.
+	EnabledOnly bool
+
+	OrderBy string // a key of EventOrders; default "time"
+	Limit   int    // no limit if zero
+	Offset  int
+
+	// Details loads every origin with its arrivals, magnitude and pick.
+	Details bool
+}
+
+// EventOrders maps the FDSN orderby values to SQL over the events alias e.
+// Events without a magnitude sort last.
+var EventOrders = map[string]string{
+	"time":          "e.time DESC",
+	"time-asc":      "e.time ASC",
+	"magnitude":     "COALESCE(e.magnitude, -99) DESC, e.time DESC",
+	"magnitude-asc": "COALESCE(e.magnitude, 99) ASC, e.time DESC",
+}
+
+type eventStore struct {
+	db *database.DB
+}
+
+// NewEventStore returns an EventStore backed by the SQL database.
+func NewEventStore(db *database.DB) EventStore {
+	return &eventStore{db: db}
+}
+
+func (s *eventStore) ImportEvents(sourceID int64, events []models.Event) (inserted, updated int, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	for _, ev := range events {
+		var id int64
+		err := tx.Get(&id, "SELECT id FROM events WHERE source_id = ? AND public_id = ?", sourceID, ev.PublicID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err = tx.Get(&id, `INSERT INTO events (source_id, public_id, event_type, description,
+				preferred_origin_id, preferred_magnitude_id, time, latitude, longitude, depth_km,
+				magnitude, magnitude_type, magnitude_author, author, catalog, contributor, contributor_id,
+				created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+				sourceID, ev.PublicID, ev.Type, ev.Description,
+				ev.PreferredOriginID, ev.PreferredMagnitudeID, ev.Time.UTC(), ev.Latitude, ev.Longitude, ev.DepthKM,
+				ev.Magnitude, ev.MagnitudeType, ev.MagnitudeAuthor, ev.Author, ev.Catalog, ev.Contributor, ev.ContributorID,
+				now, now)
+			if err != nil {
+				return 0, 0, fmt.Errorf("insert event %s: %w", ev.PublicID, err)
+			}
+			inserted++
+		case err != nil:
+			return 0, 0, fmt.Errorf("find event %s: %w", ev.PublicID, err)
+		default:
+			// Replace the event's children; its ID stays stable
+			_, err = tx.Exec(`UPDATE events SET event_type = ?, description = ?,
+				preferred_origin_id = ?, preferred_magnitude_id = ?, time = ?, latitude = ?, longitude = ?, depth_km = ?,
+				magnitude = ?, magnitude_type = ?, magnitude_author = ?, author = ?, catalog = ?, contributor = ?, contributor_id = ?,
+				updated_at = ?
+				WHERE id = ?`,
+				ev.Type, ev.Description,
+				ev.PreferredOriginID, ev.PreferredMagnitudeID, ev.Time.UTC(), ev.Latitude, ev.Longitude, ev.DepthKM,
+				ev.Magnitude, ev.MagnitudeType, ev.MagnitudeAuthor, ev.Author, ev.Catalog, ev.Contributor, ev.ContributorID,
+				now, id)
+			if err != nil {
+				return 0, 0, fmt.Errorf("update event %s: %w", ev.PublicID, err)
+			}
+			for _, table := range []string{"origins", "magnitudes", "picks"} {
+				if _, err := tx.Exec("DELETE FROM "+table+" WHERE event_id = ?", id); err != nil {
+					return 0, 0, fmt.Errorf("delete %s of event %s: %w", table, ev.PublicID, err)
+				}
+			}
+			updated++
+		}
+		if err := insertEventDetails(tx, id, ev); err != nil {
+			return 0, 0, fmt.Errorf("event %s: %w", ev.PublicID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return inserted, updated, nil
+}
+
+// insertEventDetails stores the origins, arrivals, magnitudes and picks of
+// an event.
+func insertEventDetails(tx *sqlx.Tx, eventID int64, ev models.Event) error {
+	for _, o := range ev.Origins {
+		var originID int64
+		err := tx.Get(&originID, `INSERT INTO origins (event_id, public_id, time, latitude, longitude, depth_km,
+			depth_type, method_id, earth_model_id, evaluation_mode, evaluation_status, author, agency_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			eventID, o.PublicID, o.Time.UTC(), o.Latitude, o.Longitude, o.DepthKM,
+			o.DepthType, o.MethodID, o.EarthModelID, o.EvaluationMode, o.EvaluationStatus, o.Author, o.AgencyID)
+		if err != nil {
+			return fmt.Errorf("insert origin %s: %w", o.PublicID, err)
+		}
+		for _, a := range o.Arrivals {
+			_, err := tx.Exec(`INSERT INTO arrivals (origin_id, public_id, pick_public_id, phase, distance, azimuth, time_residual, time_weight)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				originID, a.PublicID, a.PickID, a.Phase, a.Distance, a.Azimuth, a.TimeResidual, a.TimeWeight)
+			if err != nil {
+				return fmt.Errorf("insert arrival %s: %w", a.PublicID, err)
+			}
+		}
+	}
+	for _, m := range ev.Magnitudes {
+		_, err := tx.Exec(`INSERT INTO magnitudes (event_id, public_id, mag, magnitude_type, origin_public_id, station_count, author, agency_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			eventID, m.PublicID, m.Mag, m.Type, m.OriginID, m.StationCount, m.Author, m.AgencyID)
+		if err != nil {
+			return fmt.Errorf("insert magnitude %s: %w", m.PublicID, err)
+		}
+	}
+	for _, p := range ev.Picks {
+		_, err := tx.Exec(`INSERT INTO picks (event_id, public_id, time, network_code, station_code, location_code, channel_code,
+			phase_hint, polarity, onset, evaluation_mode)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			eventID, p.PublicID, p.Time.UTC(), p.NetworkCode, p.StationCode, p.LocationCode, p.ChannelCode,
+			p.PhaseHint, p.Polarity, p.Onset, p.EvaluationMode)
+		if err != nil {
+			return fmt.Errorf("insert pick %s: %w", p.PublicID, err)
+		}
+	}
+	return nil
+}
+
+// where returns the SQL filter for q over the events alias e, and its
+// arguments.
+func (q *EventQuery) where() (string, []any) {
+	conds := []string{"1=1"}
+	var args []any
+	add := func(cond string, a ...any) {
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+
+	if q.SourceID != 0 {
+		add("e.source_id = ?", q.SourceID)
+	}
+	if q.EnabledOnly {
+		add(SourceEnabledExpr("e"))
+	}
+	if q.StartTime != nil {
+		add("e.time >= ?", q.StartTime.UTC())
+	}
+	if q.EndTime != nil {
+		add("e.time <= ?", q.EndTime.UTC())
+	}
+	if cond, a := q.GeoFilter.Exact("e"); cond != "" {
+		add(cond, a...)
+	}
+	if q.MinDepth != nil {
+		add("e.depth_km >= ?", *q.MinDepth)
+	}
+	if q.MaxDepth != nil {
+		add("e.depth_km <= ?", *q.MaxDepth)
+	}
+	if q.MinMagnitude != nil {
+		add("e.magnitude >= ?", *q.MinMagnitude)
+	}
+	if q.MaxMagnitude != nil {
+		add("e.magnitude <= ?", *q.MaxMagnitude)
+	}
+	if q.MagType != "" {
+		add("LOWER(e.magnitude_type) = ?", strings.ToLower(q.MagType))
+	}
+	if len(q.EventTypes) > 0 {
+		marks := make([]string, len(q.EventTypes))
+		for i, t := range q.EventTypes {
+			marks[i] = "?"
+			args = append(args, strings.ToLower(t))
+		}
+		conds = append(conds, "LOWER(e.event_type) IN ("+strings.Join(marks, ", ")+")")
+	}
+	if len(q.EventIDs) > 0 {
+		var parts []string
+		for _, id := range q.EventIDs {
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+				parts = append(parts, "e.id = ?")
+				args = append(args, n)
+			}
+			parts = append(parts, "e.public_id = ?")
+			args = append(args, id)
+		}
+		conds = append(conds, "("+strings.Join(parts, " OR ")+")")
+	}
+	if q.Catalog != "" {
+		add("e.catalog = ?", q.Catalog)
+	}
+	if q.Contributor != "" {
+		add("e.contributor = ?", q.Contributor)
+	}
+	if q.UpdatedAfter != nil {
+		add("e.updated_at > ?", q.UpdatedAfter.UTC())
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (s *eventStore) ListEvents(q EventQuery) (*models.EventPage, error) {
+	orderName := q.OrderBy
+	if orderName == "" {
+		orderName = "time"
+	}
+	order, ok := EventOrders[orderName]
+	if !ok {
+		return nil, fmt.Errorf("unknown orderby %q", q.OrderBy)
+	}
+	where, args := q.where()
+
+	page := &models.EventPage{Events: []models.Event{}}
+	if err := s.db.Reader.Get(&page.Total, "SELECT COUNT(*) FROM events e WHERE "+where, args...); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT e.*, src.name AS source_name FROM events e
+		JOIN sources src ON src.id = e.source_id
+		WHERE ` + where + " ORDER BY " + order + ", e.id"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	if err := s.db.Reader.Select(&page.Events, query, args...); err != nil {
+		return nil, err
+	}
+	if q.Details {
+		if err := s.loadDetails(page.Events); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (s *eventStore) GetEvent(id int64) (*models.Event, error) {
+	var ev models.Event
+	err := s.db.Reader.Get(&ev, `SELECT e.*, src.name AS source_name FROM events e
+		JOIN sources src ON src.id = e.source_id WHERE e.id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	events := []models.Event{ev}
+	if err := s.loadDetails(events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// loadDetails fills in the origins, arrivals, magnitudes and picks of events.
+// Rows are selected in batches of event and origin ids, each batch ordered
+// by id, so every event's rows keep their order.
+func (s *eventStore) loadDetails(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(events))
+	index := make(map[int64]int, len(events))
+	for i, ev := range events {
+		ids[i] = ev.ID
+		index[ev.ID] = i
+	}
+
+	origins, err := selectIn[models.Origin](s.db.Reader, "SELECT * FROM origins WHERE event_id IN (?) ORDER BY id", ids)
+	if err != nil {
+		return fmt.Errorf("load origins: %w", err)
+	}
+	if len(origins) > 0 {
+		originIDs := make([]int64, len(origins))
+		for i, o := range origins {
+			originIDs[i] = o.ID
+		}
+		arrivals, err := selectIn[models.Arrival](s.db.Reader, "SELECT * FROM arrivals WHERE origin_id IN (?) ORDER BY id", originIDs)
+		if err != nil {
+			return fmt.Errorf("load arrivals: %w", err)
+		}
+		byOrigin := map[int64][]models.Arrival{}
+		for _, a := range arrivals {
+			byOrigin[a.OriginID] = append(byOrigin[a.OriginID], a)
+		}
+		for _, o := range origins {
+			o.Arrivals = byOrigin[o.ID]
+			ev := &events[index[o.EventID]]
+			ev.Origins = append(ev.Origins, o)
+		}
+	}
+
+	magnitudes, err := selectIn[models.Magnitude](s.db.Reader, "SELECT * FROM magnitudes WHERE event_id IN (?) ORDER BY id", ids)
+	if err != nil {
+		return fmt.Errorf("load magnitudes: %w", err)
+	}
+	for _, m := range magnitudes {
+		ev := &events[index[m.EventID]]
+		ev.Magnitudes = append(ev.Magnitudes, m)
+	}
+
+	picks, err := selectIn[models.Pick](s.db.Reader, "SELECT * FROM picks WHERE event_id IN (?) ORDER BY id", ids)
+	if err != nil {
+		return fmt.Errorf("load picks: %w", err)
+	}
+	for _, p := range picks {
+		ev := &events[index[p.EventID]]
+		ev.Picks = append(ev.Picks, p)
+	}
+	return nil
+}
+
+func (s *eventStore) DeleteEvent(id int64) error {
+	res, err := s.db.Exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+func (s *eventStore) Catalogs() ([]string, error) {
+	return s.distinct("catalog")
+}
+
+func (s *eventStore) Contributors() ([]string, error) {
+	return s.distinct("contributor")
+}
+
+// distinct lists the non-empty values of an events column among the events
+// of enabled sources.
+func (s *eventStore) distinct(column string) ([]string, error) {
+	values := []string{}
+	err := s.db.Reader.Select(&values, "SELECT DISTINCT e."+column+" FROM events e WHERE e."+column+" <> '' AND "+
+		SourceEnabledExpr("e")+" ORDER BY e."+column)
+	return values, err
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+// testEvents are three events of source 1: a deep Fiji event with full
+// details, a shallow Californian one and one without a magnitude.
+func testEvents() []models.Event {
+	return []models.Event{
+		{
+			PublicID: "quakeml:test/event/fiji", Type: "earthquake", Description: "FIJI ISLANDS REGION",
+			PreferredOriginID: "quakeml:test/origin/fiji", PreferredMagnitudeID: "quakeml:test/magnitude/fiji",
+			Time: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Latitude: -18, Longitude: 178,
+			DepthKM: floatPtr(580), Magnitude: floatPtr(6.1), MagnitudeType: "Mww", Catalog: "us", Contributor: "us",
+			Origins: []models.Origin{{
+				PublicID: "quakeml:test/origin/fiji", Time: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				Latitude: -18, Longitude: 178, DepthKM: floatPtr(580),
+				Arrivals: []models.Arrival{{PublicID: "quakeml:test/arrival/1", PickID: "quakeml:test/pick/1", Phase: "P", Distance: floatPtr(3.2)}},
+			}},
+			Magnitudes: []models.Magnitude{{PublicID: "quakeml:test/magnitude/fiji", Mag: 6.1, Type: "Mww"}},
+			Picks: []models.Pick{{PublicID: "quakeml:test/pick/1", Time: time.Date(2024, 3, 1, 10, 1, 0, 0, time.UTC),
+				NetworkCode: "FJ", StationCode: "MSVF", ChannelCode: "BHZ", PhaseHint: "P"}},
+		},
+		{
+			PublicID: "quakeml:test/event/ca", Type: "earthquake", Description: "CENTRAL CALIFORNIA",
+			Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Latitude: 36, Longitude: -120.5,
+			DepthKM: floatPtr(8), Magnitude: floatPtr(4.2), MagnitudeType: "ml", Catalog: "nc", Contributor: "nc",
+		},
+		{
+			PublicID: "quakeml:test/event/blast", Type: "quarry blast",
+			Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Latitude: 40, Longitude: -110,
+		},
+	}
+}
+
+func TestImportEvents(t *testing.T) {
+	s := setupStationStore(t)
+	events := NewEventStore(s.db)
+
+	ins, upd, err := events.ImportEvents(1, testEvents())
+	if err != nil || ins != 3 || upd != 0 {
+		t.Fatalf("ImportEvents = %d, %d, %v; want 3 inserted", ins, upd, err)
+	}
+	page, err := events.ListEvents(EventQuery{EventIDs: []string{"quakeml:test/event/fiji"}, Details: true})
+	if err != nil || len(page.Events) != 1 {
+		t.Fatalf("ListEvents = %+v, %v", page, err)
+	}
+	fiji := page.Events[0]
+	if fiji.SourceName != "test" || len(fiji.Origins) != 1 || len(fiji.Origins[0].Arrivals) != 1 ||
+		len(fiji.Magnitudes) != 1 || len(fiji.Picks) != 1 || fiji.Picks[0].StationCode != "MSVF" {
+		t.Fatalf("event details = %+v", fiji)
+	}
+
+	// Re-importing updates in place: the ID is kept and the children are
+	// replaced, not duplicated.
+	again := testEvents()[:1]
+	again[0].Magnitude = floatPtr(6.3)
+	again[0].Magnitudes[0].Mag = 6.3
+	ins, upd, err = events.ImportEvents(1, again)
+	if err != nil || ins != 0 || upd != 1 {
+		t.Fatalf("re-import = %d, %d, %v; want 1 updated", ins, upd, err)
+	}
+	got, err := events.GetEvent(fiji.ID)
+	if err != nil {
+		t.Fatalf("GetEvent: %v", err)
+	}
+	if *got.Magnitude != 6.3 || len(got.Magnitudes) != 1 || got.Magnitudes[0].Mag != 6.3 || len(got.Origins) != 1 || len(got.Origins[0].Arrivals) != 1 {
+		t.Errorf("re-imported event = %+v", got)
+	}
+
+	if err := events.DeleteEvent(fiji.ID); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if _, err := events.GetEvent(fiji.ID); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("GetEvent after delete: %v", err)
+	}
+	if err := events.DeleteEvent(fiji.ID); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("DeleteEvent twice: %v", err)
+	}
+	for _, table := range []string{"origins", "arrivals", "magnitudes", "picks"} {
+		var n int
+		s.db.Get(&n, "SELECT COUNT(*) FROM "+table)
+		if n != 0 {
+			t.Errorf("%d %s left after delete", n, table)
+		}
+	}
+}
+
+func TestListEventsDetailsBatches(t *testing.T) {
+	s := setupStationStore(t)
+	events := NewEventStore(s.db)
+
+	// More events, and twice as many origins, than fit in one batch
+	n := 2*inBatch + 100
+	var evs []models.Event
+	for i := range n {
+		id := fmt.Sprintf("quakeml:test/event/%d", i)
+		at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute)
+		evs = append(evs, models.Event{
+			PublicID: id, Time: at,
+			Origins: []models.Origin{
+				{PublicID: id + "/origin/a", Time: at},
+				{PublicID: id + "/origin/b", Time: at,
+					Arrivals: []models.Arrival{{PublicID: id + "/arrival", PickID: id + "/pick", Phase: "P"}}},
+			},
+			Magnitudes: []models.Magnitude{{PublicID: id + "/magnitude", Mag: 4, Type: "ml"}},
+			Picks:      []models.Pick{{PublicID: id + "/pick", Time: at.Add(time.Minute), NetworkCode: "IU", StationCode: "ANMO", ChannelCode: "BHZ"}},
+		})
+	}
+	if _, _, err := events.ImportEvents(1, evs); err != nil {
+		t.Fatalf("ImportEvents: %v", err)
+	}
+
+	page, err := events.ListEvents(EventQuery{Details: true})
+	if err != nil || len(page.Events) != n {
+		t.Fatalf("ListEvents = %d events, %v; want %d", len(page.Events), err, n)
+	}
+	for _, ev := range page.Events {
+		if len(ev.Origins) != 2 || ev.Origins[0].PublicID != ev.PublicID+"/origin/a" ||
+			len(ev.Origins[0].Arrivals) != 0 || len(ev.Origins[1].Arrivals) != 1 ||
+			len(ev.Magnitudes) != 1 || len(ev.Picks) != 1 || ev.Picks[0].PublicID != ev.PublicID+"/pick" {
+			t.Fatalf("event %s details = %+v", ev.PublicID, ev)
+		}
+	}
+}
+
+func TestListEventsQuery(t *testing.T) {
+	s := setupStationStore(t)
+	events := NewEventStore(s.db)
+	if _, _, err := events.ImportEvents(1, testEvents()); err != nil {
+		t.Fatal(err)
+	}
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		q    EventQuery
+		want []string
+	}{
+		{"all, newest first", EventQuery{}, []string{"fiji", "ca", "blast"}},
+		{"time-asc", EventQuery{OrderBy: "time-asc"}, []string{"blast", "ca", "fiji"}},
+		{"magnitude", EventQuery{OrderBy: "magnitude"}, []string{"fiji", "ca", "blast"}},
+		{"magnitude-asc", EventQuery{OrderBy: "magnitude-asc"}, []string{"ca", "fiji", "blast"}},
+		{"starttime", EventQuery{StartTime: &feb}, []string{"fiji", "ca"}},
+		{"endtime", EventQuery{EndTime: &feb}, []string{"ca", "blast"}},
+		{"minmag", EventQuery{MinMagnitude: floatPtr(5)}, []string{"fiji"}},
+		{"maxmag", EventQuery{MaxMagnitude: floatPtr(5)}, []string{"ca"}},
+		{"magtype", EventQuery{MagType: "MWW"}, []string{"fiji"}},
+		{"depth", EventQuery{MinDepth: floatPtr(100)}, []string{"fiji"}},
+		{"eventtype", EventQuery{EventTypes: []string{"Quarry Blast"}}, []string{"blast"}},
+		{"catalog", EventQuery{Catalog: "nc"}, []string{"ca"}},
+		{"box", EventQuery{GeoFilter: GeoFilter{MinLatitude: floatPtr(30), MaxLongitude: floatPtr(-115)}}, []string{"ca"}},
+		{"antimeridian box", EventQuery{GeoFilter: GeoFilter{MinLongitude: floatPtr(170), MaxLongitude: floatPtr(-170)}}, []string{"fiji"}},
+		{"radius", EventQuery{GeoFilter: GeoFilter{Latitude: floatPtr(-17), Longitude: floatPtr(179), MaxRadius: floatPtr(5)}}, []string{"fiji"}},
+		{"limit offset", EventQuery{Limit: 1, Offset: 1}, []string{"ca"}},
+	}
+	for _, tt := range tests {
+		page, err := events.ListEvents(tt.q)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, ev := range page.Events {
+			got = append(got, ev.PublicID[len("quakeml:test/event/"):])
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	page, err := events.ListEvents(EventQuery{Limit: 1})
+	if err != nil || page.Total != 3 || len(page.Events) != 1 {
+		t.Errorf("limited page = %+v, %v; want total 3", page, err)
+	}
+	if _, err := events.ListEvents(EventQuery{OrderBy: "depth"}); err == nil {
+		t.Error("unknown orderby accepted")
+	}
+
+	// Local IDs match as well as publicIDs; disabled sources are hidden
+	// when asked.
+	page, _ = events.ListEvents(EventQuery{EventIDs: []string{"2"}})
+	if len(page.Events) != 1 || page.Events[0].ID != 2 {
+		t.Errorf("eventid by local ID = %+v", page.Events)
+	}
+	s.db.MustExec("UPDATE sources SET enabled = FALSE WHERE id = 1")
+	if page, _ := events.ListEvents(EventQuery{EnabledOnly: true}); len(page.Events) != 0 {
+		t.Errorf("events of a disabled source listed: %+v", page.Events)
+	}
+	if cats, err := events.Catalogs(); err != nil || len(cats) != 0 {
+		t.Errorf("Catalogs of disabled source = %v, %v", cats, err)
+	}
+	s.db.MustExec("UPDATE sources SET enabled = TRUE WHERE id = 1")
+	if cats, _ := events.Catalogs(); len(cats) != 2 || cats[0] != "nc" || cats[1] != "us" {
+		t.Errorf("Catalogs = %v", cats)
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// ErrExtractionNotFound is returned for an unknown extraction ID.
+var ErrExtractionNotFound = errors.New("extraction not found")
+
+// extractionSelect selects extractions x with their request counts and the
+// description of their event.
+const extractionSelect = `SELECT x.*, e.description AS event_description,
+	(SELECT COUNT(*) FROM extraction_requests r WHERE r.extraction_id = x.id) AS request_count,
+	(SELECT COUNT(*) FROM extraction_requests r WHERE r.extraction_id = x.id AND r.status = 'done') AS done_count,
+	(SELECT COUNT(*) FROM extraction_requests r WHERE r.extraction_id = x.id AND r.status = 'nodata') AS nodata_count,
+	(SELECT COUNT(*) FROM extraction_requests r WHERE r.extraction_id = x.id AND r.status = 'failed') AS failed_count
+	FROM extractions x JOIN events e ON e.id = x.event_id`
+
+type extractionStore struct {
+	db *database.DB
+}
+
+// NewExtractionStore returns an ExtractionStore backed by the SQL database.
+func NewExtractionStore(db *database.DB) ExtractionStore {
+	return &extractionStore{db: db}
+}
+
+func (s *extractionStore) Create(x *models.Extraction) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	x.Status = models.ExtractionPending
+	x.CreatedAt = time.Now().UTC()
+	err = tx.Get(&x.ID, `INSERT INTO extractions (event_id, status, window_mode, before_seconds, after_seconds,
+		channel, min_radius, max_radius, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		x.EventID, x.Status, x.Window, x.Before, x.After, x.Channel, x.MinRadius, x.MaxRadius, x.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert extraction: %w", err)
+	}
+	for i := range x.Requests {
+		r := &x.Requests[i]
+		r.ExtractionID = x.ID
+		r.Status = models.RequestPending
+		err := tx.Get(&r.ID, `INSERT INTO extraction_requests (extraction_id, source_id, network_code, station_code,
+			location_code, channel_code, distance_deg, p_arrival, s_arrival, start_time, end_time, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			x.ID, r.SourceID, r.NetworkCode, r.StationCode, r.LocationCode, r.ChannelCode, r.DistanceDeg,
+			r.PArrival, r.SArrival, r.StartTime.UTC(), r.EndTime.UTC(), r.Status)
+		if err != nil {
+			return fmt.Errorf("insert request %s.%s.%s.%s: %w", r.NetworkCode, r.StationCode, r.LocationCode, r.ChannelCode, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	x.RequestCount = len(x.Requests)
+	return nil
+}
+
+func (s *extractionStore) Get(id int64) (*models.Extraction, error) {
+	var x models.Extraction
+	err := s.db.Reader.Get(&x, extractionSelect+" WHERE x.id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExtractionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Reader.Select(&x.Requests, `SELECT * FROM extraction_requests WHERE extraction_id = ?
+		ORDER BY distance_deg, network_code, station_code, location_code, channel_code`, id)
+	if err != nil {
+		return nil, fmt.Errorf("load requests: %w", err)
+	}
+	return &x, nil
+}
+
+func (s *extractionStore) List(eventID int64) ([]models.Extraction, error) {
+	query, args := extractionSelect, []any{}
+	if eventID != 0 {
+		query += " WHERE x.event_id = ?"
+		args = append(args, eventID)
+	}
+	extractions := []models.Extraction{}
+	err := s.db.Reader.Select(&extractions, query+" ORDER BY x.created_at DESC, x.id DESC", args...)
+	return extractions, err
+}
+
+func (s *extractionStore) SetStatus(id int64, status string, bytes int64, errMsg string) error {
+	var finished *time.Time
+	if status == models.ExtractionDone || status == models.ExtractionFailed {
+		now := time.Now().UTC()
+		finished = &now
+	}
+	res, err := s.db.Exec("UPDATE extractions SET status = ?, bytes = ?, error = ?, finished_at = ? WHERE id = ?",
+		status, bytes, errMsg, finished, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExtractionNotFound
+	}
+	return nil
+}
+
+func (s *extractionStore) UpdateRequest(r *models.ExtractionRequest) error {
+	_, err := s.db.Exec("UPDATE extraction_requests SET status = ?, bytes = ?, error = ? WHERE id = ?",
+		r.Status, r.Bytes, r.Error, r.ID)
+	return err
+}
+
+func (s *extractionStore) Delete(id int64) error {
+	res, err := s.db.Exec("DELETE FROM extractions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExtractionNotFound
+	}
+	return nil
+}
+
+func (s *extractionStore) FailInterrupted() (int64, error) {
+	res, err := s.db.Exec("UPDATE extractions SET status = ?, error = ?, finished_at = ? WHERE status IN (?, ?)",
+		models.ExtractionFailed, "interrupted by a server restart", time.Now().UTC(),
+		models.ExtractionPending, models.ExtractionRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func TestExtractionStore(t *testing.T) {
+	s := setupStationStore(t)
+	events := NewEventStore(s.db)
+	if _, _, err := events.ImportEvents(1, testEvents()); err != nil {
+		t.Fatal(err)
+	}
+	extractions := NewExtractionStore(s.db)
+
+	start := time.Date(2024, 3, 1, 10, 5, 0, 0, time.UTC)
+	x := &models.Extraction{
+		EventID: 1, Window: "phases", Before: 60, After: 300, Channel: "BH?", MaxRadius: 30,
+		Requests: []models.ExtractionRequest{
+			{SourceID: 1, NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BHZ",
+				DistanceDeg: 20, PArrival: floatPtr(274), StartTime: start, EndTime: start.Add(10 * time.Minute)},
+			{SourceID: 1, NetworkCode: "FJ", StationCode: "MSVF", ChannelCode: "BHZ",
+				DistanceDeg: 3, StartTime: start, EndTime: start.Add(5 * time.Minute)},
+		},
+	}
+	if err := extractions.Create(x); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if x.ID == 0 || x.Status != models.ExtractionPending || x.Requests[1].ID == 0 {
+		t.Fatalf("created extraction = %+v", x)
+	}
+
+	x.Requests[0].Status, x.Requests[0].Bytes = models.RequestDone, 4096
+	if err := extractions.UpdateRequest(&x.Requests[0]); err != nil {
+		t.Fatalf("UpdateRequest: %v", err)
+	}
+	if err := extractions.SetStatus(x.ID, models.ExtractionDone, 5000, ""); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	got, err := extractions.Get(x.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Requests come nearest first
+	if got.Status != models.ExtractionDone || got.FinishedAt == nil || got.Bytes != 5000 ||
+		got.RequestCount != 2 || got.DoneCount != 1 || got.EventDescription != "FIJI ISLANDS REGION" ||
+		len(got.Requests) != 2 || got.Requests[0].StationCode != "MSVF" || got.Requests[1].Bytes != 4096 ||
+		*got.Requests[1].PArrival != 274 {
+		t.Errorf("Get = %+v", got)
+	}
+
+	// A second, unfinished extraction is failed on restart
+	y := &models.Extraction{EventID: 2, Window: "offsets", Before: 60, After: 600, MaxRadius: 10}
+	if err := extractions.Create(y); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := extractions.FailInterrupted(); err != nil || n != 1 {
+		t.Errorf("FailInterrupted = %d, %v; want 1", n, err)
+	}
+	if list, err := extractions.List(0); err != nil || len(list) != 2 || list[0].ID != y.ID || list[0].Status != models.ExtractionFailed {
+		t.Errorf("List(0) = %+v, %v", list, err)
+	}
+	if list, _ := extractions.List(1); len(list) != 1 || list[0].ID != x.ID {
+		t.Errorf("List(1) = %+v", list)
+	}
+
+	// Deleting the event deletes its extractions and their requests
+	if err := events.DeleteEvent(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractions.Get(x.ID); !errors.Is(err, ErrExtractionNotFound) {
+		t.Errorf("Get after event delete: %v", err)
+	}
+	var n int
+	s.db.Get(&n, "SELECT COUNT(*) FROM extraction_requests")
+	if n != 0 {
+		t.Errorf("%d requests left after delete", n)
+	}
+	if err := extractions.Delete(y.ID); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := extractions.Delete(y.ID); !errors.Is(err, ErrExtractionNotFound) {
+		t.Errorf("Delete twice: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/joescharf/fdsn/internal/database"
+)
+
+// GeoFilter restricts stations by position. Nil fields do not filter.
+type GeoFilter struct {
+	// Bounding box in degrees. MinLongitude > MaxLongitude crosses the
+	// antimeridian.
+	MinLatitude  *float64
+	MaxLatitude  *float64
+	MinLongitude *float64
+	MaxLongitude *float64
+
+	// Great-circle distance in degrees from Latitude, Longitude.
+	Latitude  *float64
+	Longitude *float64
+	MinRadius *float64
+	MaxRadius *float64
+}
+
+// earthRadius is the mean radius in metres PostGIS uses for spherical
+// distances.
+const earthRadius = 6371008.7714
+
+// lonRange is a longitude interval that does not cross the antimeridian.
+type lonRange struct{ min, max float64 }
+
+// Where returns SQL conditions, joined with AND, limiting the stations table
+// aliased as alias to g, and their arguments; an empty string if g does not
+// filter. Candidates come from the spatial index (station_rtree on SQLite,
+// station_locations on PostgreSQL) and are then checked exactly.
+func (g GeoFilter) Where(driver, alias string) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, a ...any) {
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+
+	if g.hasBox() {
+		minLat, maxLat := deref(g.MinLatitude, -90), deref(g.MaxLatitude, 90)
+		lons := splitLongitudes(deref(g.MinLongitude, -180), deref(g.MaxLongitude, 180))
+		cond, a := indexedBox(driver, alias, minLat, maxLat, lons)
+		add(cond, a...)
+	}
+	if g.hasRadius() && g.MaxRadius != nil {
+		lat, lon := *g.Latitude, *g.Longitude
+		if driver == database.DriverPostgres {
+			// Slightly wider than the radius; the exact check follows
+			meters := *g.MaxRadius * math.Pi / 180 * earthRadius * 1.001
+			add(alias+`.id IN (SELECT station_id FROM station_locations
+				WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?, false))`, lon, lat, meters)
+		} else {
+			minLat, maxLat, lons := radiusBox(lat, lon, *g.MaxRadius)
+			cond, a := indexedBox(driver, alias, minLat, maxLat, lons)
+			add(cond, a...)
+		}
+	}
+	if cond, a := g.Exact(alias); cond != "" {
+		add(cond, a...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Exact returns SQL conditions, joined with AND, checking the latitude and
+// longitude columns of the table aliased as alias against g without a
+// spatial index, and their arguments; an empty string if g does not filter.
+// It suits tables other than stations, such as events.
+func (g GeoFilter) Exact(alias string) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, a ...any) {
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+
+	if g.hasBox() {
+		if g.MinLatitude != nil {
+			add(alias+".latitude >= ?", *g.MinLatitude)
+		}
+		if g.MaxLatitude != nil {
+			add(alias+".latitude <= ?", *g.MaxLatitude)
+		}
+		if g.MinLongitude != nil && g.MaxLongitude != nil && *g.MinLongitude > *g.MaxLongitude {
+			add(fmt.Sprintf("(%[1]s.longitude >= ? OR %[1]s.longitude <= ?)", alias), *g.MinLongitude, *g.MaxLongitude)
+		} else {
+			if g.MinLongitude != nil {
+				add(alias+".longitude >= ?", *g.MinLongitude)
+			}
+			if g.MaxLongitude != nil {
+				add(alias+".longitude <= ?", *g.MaxLongitude)
+			}
+		}
+	}
+
+	if g.hasRadius() {
+		lat, lon := *g.Latitude, *g.Longitude
+		// Haversine distance in degrees
+		dist := fmt.Sprintf(`2 * degrees(asin(sqrt(
+			sin(radians(%[1]s.latitude - ?) / 2) * sin(radians(%[1]s.latitude - ?) / 2) +
+			cos(radians(?)) * cos(radians(%[1]s.latitude)) *
+			sin(radians(%[1]s.longitude - ?) / 2) * sin(radians(%[1]s.longitude - ?) / 2))))`, alias)
+		if g.MinRadius != nil {
+			add(dist+" >= ?", lat, lat, lat, lon, lon, *g.MinRadius)
+		}
+		if g.MaxRadius != nil {
+			add(dist+" <= ?", lat, lat, lat, lon, lon, *g.MaxRadius)
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (g GeoFilter) hasBox() bool {
+	return g.MinLatitude != nil || g.MaxLatitude != nil || g.MinLongitude != nil || g.MaxLongitude != nil
+}
+
+func (g GeoFilter) hasRadius() bool {
+	return g.Latitude != nil && g.Longitude != nil && (g.MinRadius != nil || g.MaxRadius != nil)
+}
+
+// indexedBox returns a condition selecting the stations inside the box from
+// the spatial index.
+func indexedBox(driver, alias string, minLat, maxLat float64, lons []lonRange) (string, []any) {
+	var parts []string
+	var args []any
+	for _, r := range lons {
+		if driver == database.DriverPostgres {
+			parts = append(parts, "location::geometry && ST_MakeEnvelope(?, ?, ?, ?, 4326)")
+			args = append(args, r.min, minLat, r.max, maxLat)
+		} else {
+			parts = append(parts, "(max_lat >= ? AND min_lat <= ? AND max_lon >= ? AND min_lon <= ?)")
+			args = append(args, minLat, maxLat, r.min, r.max)
+		}
+	}
+	if driver == database.DriverPostgres {
+		return alias + ".id IN (SELECT station_id FROM station_locations WHERE " + strings.Join(parts, " OR ") + ")", args
+	}
+	return alias + ".id IN (SELECT id FROM station_rtree WHERE " + strings.Join(parts, " OR ") + ")", args
+}
+
+// splitLongitudes splits a longitude interval crossing the antimeridian
+// (min > max) in two.
+func splitLongitudes(min, max float64) []lonRange {
+	if min > max {
+		return []lonRange{{min, 180}, {-180, max}}
+	}
+	return []lonRange{{min, max}}
+}
+
+// radiusBox returns the latitude/longitude box enclosing the circle of
+// radius degrees around lat, lon.
+func radiusBox(lat, lon, radius float64) (minLat, maxLat float64, lons []lonRange) {
+	minLat, maxLat = lat-radius, lat+radius
+	if minLat <= -90 || maxLat >= 90 {
+		// The circle covers a pole, so every longitude
+		return max(minLat, -90), min(maxLat, 90), []lonRange{{-180, 180}}
+	}
+	s := math.Sin(radius*math.Pi/180) / math.Cos(lat*math.Pi/180)
+	if s >= 1 {
+		return minLat, maxLat, []lonRange{{-180, 180}}
+	}
+	dlon := math.Asin(s) * 180 / math.Pi
+	west, east := lon-dlon, lon+dlon
+	switch {
+	case west < -180:
+		return minLat, maxLat, []lonRange{{west + 360, 180}, {-180, east}}
+	case east > 180:
+		return minLat, maxLat, []lonRange{{west, 180}, {-180, east - 360}}
+	}
+	return minLat, maxLat, []lonRange{{west, east}}
+}
+
+func deref(p *float64, def float64) float64 {
+	if p == nil {
+		return def
+	}
+	return *p
+}
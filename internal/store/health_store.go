@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+type healthStore struct {
+	db *database.DB
+}
+
+// NewHealthStore returns a HealthStore backed by the SQL database.
+func NewHealthStore(db *database.DB) HealthStore {
+	return &healthStore{db: db}
+}
+
+// Record stores a probe outcome. A successful probe updates the services
+// that answered and drops those it found absent; services that failed to
+// answer keep their last known details along with the error. A failed probe
+// keeps every known service and only records the failure.
+func (s *healthStore) Record(r *models.ProbeResult) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for service, msg := range r.ServiceErrors {
+		_, err := tx.Exec("UPDATE source_services SET last_error = ? WHERE source_id = ? AND service = ?",
+			msg, r.SourceID, service)
+		if err != nil {
+			return fmt.Errorf("record service %s error: %w", service, err)
+		}
+	}
+
+	if r.Error != "" {
+		_, err = tx.Exec(`INSERT INTO source_health (source_id, last_checked, last_failure, last_error)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (source_id) DO UPDATE SET last_checked = excluded.last_checked,
+				last_failure = excluded.last_failure, last_error = excluded.last_error`,
+			r.SourceID, r.CheckedAt, r.CheckedAt, r.Error)
+		if err != nil {
+			return fmt.Errorf("record probe failure: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	keep := make([]string, 0, len(r.Services)+len(r.ServiceErrors))
+	for service := range r.ServiceErrors {
+		keep = append(keep, service)
+	}
+	for _, svc := range r.Services {
+		keep = append(keep, svc.Service)
+		_, err := tx.Exec(`INSERT INTO source_services (source_id, service, version, parameters, checked_at, last_error)
+			VALUES (?, ?, ?, ?, ?, '')
+			ON CONFLICT (source_id, service) DO UPDATE SET version = excluded.version,
+				parameters = excluded.parameters, checked_at = excluded.checked_at, last_error = ''`,
+			r.SourceID, svc.Service, svc.Version, strings.Join(svc.Parameters, ","), r.CheckedAt)
+		if err != nil {
+			return fmt.Errorf("upsert service %s: %w", svc.Service, err)
+		}
+	}
+	q, args, err := sqlx.In("DELETE FROM source_services WHERE source_id = ? AND service NOT IN (?)", r.SourceID, keep)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(q, args...); err != nil {
+		return fmt.Errorf("delete absent services: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO source_health (source_id, latency_ms, last_checked, last_success, last_error)
+		VALUES (?, ?, ?, ?, '')
+		ON CONFLICT (source_id) DO UPDATE SET latency_ms = excluded.latency_ms,
+			last_checked = excluded.last_checked, last_success = excluded.last_success, last_error = ''`,
+		r.SourceID, r.LatencyMS, r.CheckedAt, r.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("record probe success: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *healthStore) Services(sourceID int64) ([]models.SourceService, error) {
+	var services []models.SourceService
+	err := s.db.Reader.Select(&services, "SELECT * FROM source_services WHERE source_id = ? ORDER BY service", sourceID)
+	if err != nil {
+		return nil, err
+	}
+	splitParameters(services)
+	return services, nil
+}
+
+// splitParameters fills Parameters from the stored comma-separated list.
+func splitParameters(services []models.SourceService) {
+	for i := range services {
+		services[i].Parameters = []string{}
+		if services[i].Params != "" {
+			services[i].Parameters = strings.Split(services[i].Params, ",")
+		}
+	}
+}
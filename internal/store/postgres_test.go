@@ -0,0 +1,118 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+// setupPostgres returns a migrated PostgreSQL database in a throwaway schema
+// on the server in FDSN_TEST_POSTGRES_DSN (a postgres:// URL), skipping the
+// test if it is not set.
+func setupPostgres(t *testing.T) *database.DB {
+	t.Helper()
+	dsn := os.Getenv("FDSN_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("FDSN_TEST_POSTGRES_DSN not set")
+	}
+	admin, err := database.NewPostgres(dsn)
+	if err != nil {
+		t.Fatalf("NewPostgres: %v", err)
+	}
+	schema := fmt.Sprintf("fdsn_store_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("FDSN_TEST_POSTGRES_DSN must be a URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+	db, err := database.NewPostgres(u.String())
+	if err != nil {
+		t.Fatalf("NewPostgres(schema): %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
+
+func TestPostgresStores(t *testing.T) {
+	db := setupPostgres(t)
+
+	sources := NewSourceStore(db)
+	src := &models.Source{Name: "test", BaseURL: "http://test", Enabled: true}
+	if err := sources.Create(src); err != nil || src.ID == 0 {
+		t.Fatalf("Create = id %d, %v", src.ID, err)
+	}
+
+	stations := NewStationStore(db)
+	chans := []models.ImportChannel{
+		{NetworkCode: "XX", StationCode: "TMP1", ChannelCode: "HHZ", Latitude: 46.5, Longitude: 7.25,
+			StationRestricted: boolPtr(true)},
+		{NetworkCode: "XX", StationCode: "TMP2", ChannelCode: "HHZ"},
+	}
+	if err := stations.ImportStations(src.ID, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	if err := stations.ImportStations(src.ID, chans); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	page, err := stations.ListStations(StationQuery{Network: "XX"})
+	if err != nil || page.Total != 2 || len(page.Stations) != 2 {
+		t.Fatalf("ListStations = %+v, %v", page, err)
+	}
+	list := page.Stations
+	if !list[0].Restricted || list[1].Restricted {
+		t.Errorf("restricted flags: %v/%v, want true/false", list[0].Restricted, list[1].Restricted)
+	}
+
+	found, err := NewSearchStore(db).Search("tmp1", 5)
+	if err != nil || len(found) == 0 || found[0].StationCode != "TMP1" {
+		t.Errorf("Search = %+v, %v", found, err)
+	}
+
+	summaries, err := sources.ListWithStats()
+	if err != nil || len(summaries) != 1 || summaries[0].StationCount != 2 {
+		t.Fatalf("ListWithStats = %+v, %v", summaries, err)
+	}
+
+	health := NewHealthStore(db)
+	if err := health.Record(&models.ProbeResult{SourceID: src.ID, CheckedAt: time.Now(),
+		Services: []models.SourceService{{Service: "station", Version: "1.1.0"}}}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	users := NewUserStore(db)
+	if err := users.Upsert(&models.User{Username: "alice", HA1: "abc"}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	avail := NewAvailabilityStore(db)
+	ids, err := stations.LookupChannelIDs(src.ID, "XX", "TMP1")
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("LookupChannelIDs = %v, %v", ids, err)
+	}
+	if err := avail.Upsert(ids[".HHZ"], "2020-01-01T00:00:00", "2024-01-01T00:00:00"); err != nil {
+		t.Fatalf("availability Upsert: %v", err)
+	}
+
+	res, err := sources.Delete(src.ID, DeleteSourceOptions{})
+	if err != nil || res.Networks != 1 || res.Stations != 2 || res.Channels != 2 || res.Availability != 1 {
+		t.Fatalf("Delete = %+v, %v", res, err)
+	}
+}
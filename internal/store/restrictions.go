@@ -0,0 +1,57 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Restriction levels, matching the restrictions.level column.
+const (
+	LevelNetwork = "network"
+	LevelStation = "station"
+	LevelChannel = "channel"
+)
+
+// RestrictedExpr returns an SQL boolean expression that is true when the row
+// identified by the given table aliases is restricted, either directly or
+// through one of its parents. Pass "" for levels that are not joined; for
+// example RestrictedExpr("n", "st", "") for a station query.
+func RestrictedExpr(netAlias, staAlias, chaAlias string) string {
+	var conds []string
+	if netAlias != "" {
+		conds = append(conds, fmt.Sprintf("(r.level = 'network' AND r.ref_id = %s.id)", netAlias))
+	}
+	if staAlias != "" {
+		conds = append(conds, fmt.Sprintf("(r.level = 'station' AND r.ref_id = %s.id)", staAlias))
+	}
+	if chaAlias != "" {
+		conds = append(conds, fmt.Sprintf("(r.level = 'channel' AND r.ref_id = %s.id)", chaAlias))
+	}
+	return "EXISTS (SELECT 1 FROM restrictions r WHERE " + strings.Join(conds, " OR ") + ")"
+}
+
+// applyUpstreamRestriction records the upstream restrictedStatus for a node.
+// Markers set manually are never cleared by an import; a nil status leaves
+// the stored state untouched.
+func applyUpstreamRestriction(e sqlx.Execer, level string, id int64, restricted *bool) error {
+	if restricted == nil {
+		return nil
+	}
+	var err error
+	if *restricted {
+		_, err = e.Exec(`INSERT INTO restrictions (level, ref_id, origin) VALUES (?, ?, 'upstream')
+			ON CONFLICT (level, ref_id) DO NOTHING`, level, id)
+	} else {
+		_, err = e.Exec("DELETE FROM restrictions WHERE level = ? AND ref_id = ? AND origin = 'upstream'", level, id)
+	}
+	if err != nil {
+		return fmt.Errorf("set %s %d restriction: %w", level, id, err)
+	}
+	return nil
+}
+
+func validLevel(level string) bool {
+	return level == LevelNetwork || level == LevelStation || level == LevelChannel
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+const (
+	// searchCandidates bounds the rows read from the index before scoring.
+	searchCandidates = 500
+	// minSearchScore is the share of the query's trigrams a result must
+	// contain; below 1 so misspelt queries still match.
+	minSearchScore = 0.5
+)
+
+type searchStore struct {
+	db *database.DB
+}
+
+// NewSearchStore returns a SearchStore backed by the search_index table.
+func NewSearchStore(db *database.DB) SearchStore {
+	return &searchStore{db: db}
+}
+
+const searchColumns = "kind, ref_id, station_id, network, station, location, code, label"
+
+func (s *searchStore) Search(query string, limit int) ([]models.SearchResult, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return []models.SearchResult{}, nil
+	}
+	qgrams := trigrams(q)
+
+	var rows []models.SearchResult
+	var err error
+	switch {
+	case len(qgrams) == 0:
+		// Too short for trigrams: match network and station codes by prefix
+		err = s.db.Reader.Select(&rows, "SELECT "+searchColumns+` FROM search_index
+			WHERE kind <> 'channel' AND lower(code) LIKE ? ESCAPE '\' ORDER BY code LIMIT ?`,
+			escapeLike(q)+"%", searchCandidates)
+	case s.db.DriverName() == database.DriverPostgres:
+		err = s.db.Reader.Select(&rows, "SELECT "+searchColumns+` FROM search_index
+			WHERE ? <% lower(code || ' ' || label)
+			ORDER BY word_similarity(?, lower(code || ' ' || label)) DESC LIMIT ?`,
+			q, q, searchCandidates)
+	default:
+		err = s.db.Reader.Select(&rows, "SELECT "+searchColumns+` FROM search_index
+			WHERE search_index MATCH ? ORDER BY rank LIMIT ?`,
+			ftsQuery(qgrams), searchCandidates)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SearchResult, 0, len(rows))
+	channels := make(map[string]int) // merge key -> index in results
+	for _, r := range rows {
+		if len(qgrams) == 0 {
+			r.Score = 0.75
+			if strings.EqualFold(r.Code, q) {
+				r.Score = 1
+			}
+		} else {
+			r.Score = trigramScore(qgrams, r.Code+" "+r.Label)
+			if r.Score < minSearchScore {
+				continue
+			}
+		}
+		if r.Kind == models.SearchChannel && r.StationID != nil {
+			key := fmt.Sprintf("%d\x00%s\x00%s", *r.StationID, r.LocationCode, r.Label)
+			if i, ok := channels[key]; ok {
+				results[i].Code += "," + r.Code
+				results[i].Score = max(results[i].Score, r.Score)
+				continue
+			}
+			channels[key] = len(results)
+		}
+		results = append(results, r)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if ea, eb := strings.EqualFold(a.Code, q), strings.EqualFold(b.Code, q); ea != eb {
+			return ea
+		}
+		if ka, kb := searchKindOrder[a.Kind], searchKindOrder[b.Kind]; ka != kb {
+			return ka < kb
+		}
+		return len(a.Label) < len(b.Label)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		if results[i].Kind == models.SearchChannel {
+			codes := strings.Split(results[i].Code, ",")
+			sort.Strings(codes)
+			results[i].Code = strings.Join(codes, ",")
+		}
+	}
+	return results, nil
+}
+
+// searchKindOrder ranks equally scored results: networks, then stations,
+// then channels.
+var searchKindOrder = map[string]int{
+	models.SearchNetwork: 0,
+	models.SearchStation: 1,
+	models.SearchChannel: 2,
+}
+
+// trigrams returns the set of three-character substrings of s, the units the
+// FTS5 trigram tokenizer indexes.
+func trigrams(s string) map[string]bool {
+	r := []rune(s)
+	out := make(map[string]bool, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		out[string(r[i:i+3])] = true
+	}
+	return out
+}
+
+// trigramScore is the share of qgrams found in text.
+func trigramScore(qgrams map[string]bool, text string) float64 {
+	tgrams := trigrams(strings.ToLower(text))
+	hits := 0
+	for g := range qgrams {
+		if tgrams[g] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(qgrams))
+}
+
+// ftsQuery matches rows containing any of qgrams; ranking and scoring then
+// prefer rows that contain most of them.
+func ftsQuery(qgrams map[string]bool) string {
+	terms := make([]string, 0, len(qgrams))
+	for g := range qgrams {
+		terms = append(terms, `"`+strings.ReplaceAll(g, `"`, `""`)+`"`)
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, " OR ")
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
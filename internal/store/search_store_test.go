@@ -0,0 +1,68 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func TestSearch(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", NetworkDescription: "Global Seismograph Network", StationCode: "ANMO",
+			SiteName: "Albuquerque, New Mexico, USA", LocationCode: "00", ChannelCode: "BHZ",
+			SensorDescription: "Streckeisen STS-2 Seismometer"},
+		{NetworkCode: "IU", StationCode: "ANMO", SiteName: "Albuquerque, New Mexico, USA",
+			LocationCode: "00", ChannelCode: "BHN", SensorDescription: "Streckeisen STS-2 Seismometer"},
+		{NetworkCode: "IU", StationCode: "COLA", SiteName: "College Outpost, Alaska, USA",
+			LocationCode: "00", ChannelCode: "BHZ", SensorDescription: "Streckeisen STS-1"},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	search := NewSearchStore(s.db)
+
+	tests := []struct {
+		query, kind, code string
+	}{
+		{"Albuquerque", models.SearchStation, "ANMO"},
+		{"albuqerque", models.SearchStation, "ANMO"}, // misspelt
+		{"anmo", models.SearchStation, "ANMO"},
+		{"STS-2", models.SearchChannel, "BHN,BHZ"},
+		{"global seismograph", models.SearchNetwork, "IU"},
+		{"iu", models.SearchNetwork, "IU"}, // too short for trigrams
+	}
+	for _, tt := range tests {
+		results, err := search.Search(tt.query, 10)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", tt.query, err)
+		}
+		if len(results) == 0 || results[0].Kind != tt.kind || results[0].Code != tt.code {
+			t.Errorf("Search(%q) = %+v, want a %s %s first", tt.query, results, tt.kind, tt.code)
+		}
+	}
+
+	if results, _ := search.Search("zzzzzz", 10); len(results) != 0 {
+		t.Errorf("expected no results, got %+v", results)
+	}
+
+	// The index follows renames and deletes
+	chans[2].SiteName = "Fairbanks, Alaska"
+	if err := s.ImportStations(1, chans[2:]); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	if results, _ := search.Search("Fairbanks", 10); len(results) != 1 || results[0].StationCode != "COLA" {
+		t.Errorf("Search(Fairbanks) after rename = %+v", results)
+	}
+	var id int64
+	s.db.Get(&id, "SELECT id FROM stations WHERE code = 'ANMO'")
+	if err := s.DeleteStation(id); err != nil {
+		t.Fatalf("DeleteStation: %v", err)
+	}
+	results, _ := search.Search("STS-2", 10)
+	for _, r := range results {
+		if r.StationCode == "ANMO" {
+			t.Errorf("deleted station's channels still found: %+v", r)
+		}
+	}
+}
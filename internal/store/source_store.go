@@ -1,35 +1,58 @@
 package store
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/database"
 	"github.com/joescharf/fdsn/internal/models"
 )
 
+// hasCredentialsExpr selects whether a source (aliased s) has stored credentials.
+const hasCredentialsExpr = "EXISTS (SELECT 1 FROM source_credentials sc WHERE sc.source_id = s.id) AS has_credentials"
+
+// SourceEnabledExpr returns an SQL boolean expression that is true when the
+// network (or event) aliased netAlias belongs to an enabled source. The data
+// of disabled sources stays in the catalog but is not served by the FDSN web
+// services.
+func SourceEnabledExpr(netAlias string) string {
+	return "NOT EXISTS (SELECT 1 FROM sources sd WHERE sd.id = " + netAlias + ".source_id AND sd.enabled = FALSE)"
+}
+
+// ErrReassign is returned by Delete when the networks and events of a source
+// cannot be moved to the requested source.
+var ErrReassign = errors.New("cannot reassign catalog")
+
 type sourceStore struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
-// NewSourceStore returns a SourceStore backed by SQLite.
-func NewSourceStore(db *sqlx.DB) SourceStore {
+// NewSourceStore returns a SourceStore backed by the SQL database.
+func NewSourceStore(db *database.DB) SourceStore {
 	return &sourceStore{db: db}
 }
 
 func (s *sourceStore) List() ([]models.Source, error) {
 	var sources []models.Source
-	err := s.db.Select(&sources, "SELECT * FROM sources ORDER BY name")
+	err := s.db.Reader.Select(&sources, "SELECT s.*, "+hasCredentialsExpr+" FROM sources s ORDER BY s.name")
 	return sources, err
 }
 
 func (s *sourceStore) ListWithStats() ([]models.SourceSummary, error) {
 	const query = `
-SELECT s.*,
+SELECT s.*, ` + hasCredentialsExpr + `,
   COALESCE(agg.network_count, 0) AS network_count,
   COALESCE(agg.station_count, 0) AS station_count,
-  COALESCE(agg.availability_count, 0) AS availability_count
+  COALESCE(agg.availability_count, 0) AS availability_count,
+  h.latency_ms, h.last_checked, h.last_success, h.last_failure,
+  COALESCE(h.last_error, '') AS last_error
 FROM sources s
+LEFT JOIN source_health h ON h.source_id = s.id
 LEFT JOIN (
   SELECT n.source_id,
     COUNT(DISTINCT n.id) AS network_count,
@@ -46,13 +69,31 @@ LEFT JOIN (
 ORDER BY s.name`
 
 	var summaries []models.SourceSummary
-	err := s.db.Select(&summaries, query)
-	return summaries, err
+	if err := s.db.Reader.Select(&summaries, query); err != nil {
+		return nil, err
+	}
+
+	var services []models.SourceService
+	if err := s.db.Reader.Select(&services, "SELECT * FROM source_services ORDER BY source_id, service"); err != nil {
+		return nil, err
+	}
+	splitParameters(services)
+	bySource := make(map[int64][]models.SourceService)
+	for _, svc := range services {
+		bySource[svc.SourceID] = append(bySource[svc.SourceID], svc)
+	}
+	for i := range summaries {
+		summaries[i].Services = bySource[summaries[i].ID]
+		if summaries[i].Services == nil {
+			summaries[i].Services = []models.SourceService{}
+		}
+	}
+	return summaries, nil
 }
 
 func (s *sourceStore) Get(id int64) (*models.Source, error) {
 	var src models.Source
-	err := s.db.Get(&src, "SELECT * FROM sources WHERE id = ?", id)
+	err := s.db.Reader.Get(&src, "SELECT s.*, "+hasCredentialsExpr+" FROM sources s WHERE s.id = ?", id)
 	if err != nil {
 		return nil, err
 	}
@@ -62,27 +103,146 @@ func (s *sourceStore) Get(id int64) (*models.Source, error) {
 func (s *sourceStore) Create(src *models.Source) error {
 	src.CreatedAt = time.Now()
 	src.UpdatedAt = time.Now()
-	result, err := s.db.Exec(
-		"INSERT INTO sources (name, base_url, description, enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
-		src.Name, src.BaseURL, src.Description, src.Enabled, src.CreatedAt, src.UpdatedAt,
+	err := s.db.Get(&src.ID,
+		"INSERT INTO sources (name, base_url, description, enabled, managed_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		src.Name, src.BaseURL, src.Description, src.Enabled, src.ManagedBy, src.CreatedAt, src.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert source: %w", err)
 	}
-	src.ID, _ = result.LastInsertId()
 	return nil
 }
 
 func (s *sourceStore) Update(src *models.Source) error {
 	src.UpdatedAt = time.Now()
 	_, err := s.db.Exec(
-		"UPDATE sources SET name = ?, base_url = ?, description = ?, enabled = ?, updated_at = ? WHERE id = ?",
-		src.Name, src.BaseURL, src.Description, src.Enabled, src.UpdatedAt, src.ID,
+		"UPDATE sources SET name = ?, base_url = ?, description = ?, enabled = ?, managed_by = ?, updated_at = ? WHERE id = ?",
+		src.Name, src.BaseURL, src.Description, src.Enabled, src.ManagedBy, src.UpdatedAt, src.ID,
 	)
 	return err
 }
 
-func (s *sourceStore) Delete(id int64) error {
-	_, err := s.db.Exec("DELETE FROM sources WHERE id = ?", id)
-	return err
+func (s *sourceStore) Delete(id int64, opts DeleteSourceOptions) (*models.SourceDeletion, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res := &models.SourceDeletion{SourceID: id, ReassignedTo: opts.ReassignTo, DryRun: opts.DryRun}
+	if err := tx.Get(&res.SourceName, "SELECT name FROM sources WHERE id = ?", id); err != nil {
+		return nil, fmt.Errorf("get source: %w", err)
+	}
+	for _, c := range []struct {
+		dest  *int
+		query string
+	}{
+		{&res.Networks, "SELECT COUNT(*) FROM networks WHERE source_id = ?"},
+		{&res.Stations, "SELECT COUNT(*) FROM stations WHERE network_id IN (" + sourceNetworks + ")"},
+		{&res.Channels, "SELECT COUNT(*) FROM channels WHERE station_id IN (" + sourceStations + ")"},
+		{&res.Availability, "SELECT COUNT(*) FROM availability WHERE channel_id IN (" + sourceChannels + ")"},
+		{&res.Events, "SELECT COUNT(*) FROM events WHERE source_id = ?"},
+	} {
+		if err := tx.Get(c.dest, c.query, id); err != nil {
+			return nil, fmt.Errorf("count catalog: %w", err)
+		}
+	}
+
+	if opts.ReassignTo != 0 {
+		if err := reassignNetworks(tx, id, opts.ReassignTo); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := deleteCatalog(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	for _, table := range []string{"source_credentials", "source_services", "source_health"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE source_id = ?", id); err != nil {
+			return nil, fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM sources WHERE id = ?", id); err != nil {
+		return nil, fmt.Errorf("delete source: %w", err)
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+// Subqueries selecting the catalog rows of the source bound to their
+// single parameter.
+const (
+	sourceNetworks = "SELECT id FROM networks WHERE source_id = ?"
+	sourceStations = "SELECT id FROM stations WHERE network_id IN (" + sourceNetworks + ")"
+	sourceChannels = "SELECT id FROM channels WHERE station_id IN (" + sourceStations + ")"
+)
+
+// deleteCatalog deletes the networks of a source and everything below them,
+// including restriction markers, which have no foreign keys to cascade, and
+// its events.
+func deleteCatalog(tx *sqlx.Tx, sourceID int64) error {
+	for _, step := range []struct {
+		what, query string
+	}{
+		{"restrictions", "DELETE FROM restrictions WHERE level = '" + LevelChannel + "' AND ref_id IN (" + sourceChannels + ")"},
+		{"restrictions", "DELETE FROM restrictions WHERE level = '" + LevelStation + "' AND ref_id IN (" + sourceStations + ")"},
+		{"restrictions", "DELETE FROM restrictions WHERE level = '" + LevelNetwork + "' AND ref_id IN (" + sourceNetworks + ")"},
+		{"availability", "DELETE FROM availability WHERE channel_id IN (" + sourceChannels + ")"},
+		{"channels", "DELETE FROM channels WHERE station_id IN (" + sourceStations + ")"},
+		{"stations", "DELETE FROM stations WHERE network_id IN (" + sourceNetworks + ")"},
+		{"networks", "DELETE FROM networks WHERE source_id = ?"},
+		{"events", "DELETE FROM events WHERE source_id = ?"},
+	} {
+		if _, err := tx.Exec(step.query, sourceID); err != nil {
+			return fmt.Errorf("delete %s: %w", step.what, err)
+		}
+	}
+	return nil
+}
+
+// reassignNetworks moves the networks and events of a source to another one.
+// Nothing is merged: if the target already holds one of the network codes or
+// event publicIDs, nothing is moved.
+func reassignNetworks(tx *sqlx.Tx, sourceID, targetID int64) error {
+	if targetID == sourceID {
+		return fmt.Errorf("%w: a source cannot be reassigned to itself", ErrReassign)
+	}
+	var target string
+	if err := tx.Get(&target, "SELECT name FROM sources WHERE id = ?", targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: source %d not found", ErrReassign, targetID)
+		}
+		return fmt.Errorf("get source: %w", err)
+	}
+	var codes []string
+	if err := tx.Select(&codes, `SELECT n.code FROM networks n
+		WHERE n.source_id = ? AND EXISTS (SELECT 1 FROM networks t WHERE t.source_id = ? AND t.code = n.code)
+		ORDER BY n.code`, sourceID, targetID); err != nil {
+		return fmt.Errorf("find conflicting networks: %w", err)
+	}
+	if len(codes) > 0 {
+		return fmt.Errorf("%w: %s already holds network %s", ErrReassign, target, strings.Join(codes, ", "))
+	}
+	var events []string
+	if err := tx.Select(&events, `SELECT e.public_id FROM events e
+		WHERE e.source_id = ? AND EXISTS (SELECT 1 FROM events t WHERE t.source_id = ? AND t.public_id = e.public_id)
+		ORDER BY e.public_id`, sourceID, targetID); err != nil {
+		return fmt.Errorf("find conflicting events: %w", err)
+	}
+	if len(events) > 0 {
+		return fmt.Errorf("%w: %s already holds event %s", ErrReassign, target, strings.Join(events, ", "))
+	}
+	if _, err := tx.Exec("UPDATE networks SET source_id = ? WHERE source_id = ?", targetID, sourceID); err != nil {
+		return fmt.Errorf("reassign networks: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE events SET source_id = ? WHERE source_id = ?", targetID, sourceID); err != nil {
+		return fmt.Errorf("reassign events: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func TestDeleteSource(t *testing.T) {
+	s := setupStationStore(t)
+	sources := NewSourceStore(s.db)
+	other := &models.Source{Name: "other", BaseURL: "http://other", Enabled: true}
+	if err := sources.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ImportStations(1, []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BHZ"},
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BH1"},
+		{NetworkCode: "XX", StationCode: "TMP1", ChannelCode: "HHZ", StationRestricted: boolPtr(true)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ImportStations(other.ID, []models.ImportChannel{
+		{NetworkCode: "XX", StationCode: "TMP2", ChannelCode: "HHZ"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.db.MustExec(`INSERT INTO availability (channel_id, earliest, latest)
+		SELECT id, '2020-01-01T00:00:00', '2024-01-01T00:00:00' FROM channels`)
+	events := NewEventStore(s.db)
+	if _, _, err := events.ImportEvents(1, testEvents()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := events.ImportEvents(other.ID, testEvents()[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := s.db.Get(&n, "SELECT COUNT(*) FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	want := models.SourceDeletion{SourceID: 1, SourceName: "test", DryRun: true, Networks: 2, Stations: 2, Channels: 3, Availability: 3, Events: 3}
+	res, err := sources.Delete(1, DeleteSourceOptions{DryRun: true})
+	if err != nil || *res != want {
+		t.Fatalf("dry run = %+v, %v; want %+v", res, err, want)
+	}
+	if count("sources") != 2 || count("channels") != 4 {
+		t.Fatalf("dry run changed the catalog")
+	}
+
+	// other already holds XX, so nothing moves
+	if _, err := sources.Delete(1, DeleteSourceOptions{ReassignTo: other.ID}); !errors.Is(err, ErrReassign) {
+		t.Errorf("conflicting reassign: err = %v, want ErrReassign", err)
+	}
+	if _, err := sources.Delete(1, DeleteSourceOptions{ReassignTo: 1}); !errors.Is(err, ErrReassign) {
+		t.Errorf("reassign to itself: err = %v, want ErrReassign", err)
+	}
+	if count("sources") != 2 || count("networks") != 3 {
+		t.Fatalf("failed reassign changed the catalog")
+	}
+
+	want.DryRun = false
+	if res, err = sources.Delete(1, DeleteSourceOptions{}); err != nil || *res != want {
+		t.Fatalf("Delete = %+v, %v; want %+v", res, err, want)
+	}
+	for table, n := range map[string]int{"sources": 1, "networks": 1, "stations": 1, "channels": 1, "availability": 1, "restrictions": 0, "events": 1, "picks": 0} {
+		if got := count(table); got != n {
+			t.Errorf("%s left = %d, want %d", table, got, n)
+		}
+	}
+
+	// Reassigning moves the whole catalog
+	third := &models.Source{Name: "third", BaseURL: "http://third", Enabled: true}
+	if err := sources.Create(third); err != nil {
+		t.Fatal(err)
+	}
+	res, err = sources.Delete(other.ID, DeleteSourceOptions{ReassignTo: third.ID})
+	if err != nil || res.ReassignedTo != third.ID || res.Stations != 1 || res.Events != 1 {
+		t.Fatalf("reassign = %+v, %v", res, err)
+	}
+	var sourceID int64
+	s.db.Get(&sourceID, "SELECT source_id FROM networks")
+	if sourceID != third.ID || count("channels") != 1 || count("availability") != 1 {
+		t.Errorf("after reassign: network source %d, %d channels, %d availability", sourceID, count("channels"), count("availability"))
+	}
+	s.db.Get(&sourceID, "SELECT source_id FROM events")
+	if sourceID != third.ID {
+		t.Errorf("after reassign: event source %d, want %d", sourceID, third.ID)
+	}
+}
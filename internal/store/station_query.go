@@ -0,0 +1,176 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for a StationQuery with an unknown sort column
+// or a cursor that does not belong to it.
+var ErrInvalidQuery = errors.New("invalid station query")
+
+// Page sizes for StationQuery.Limit.
+const (
+	DefaultStationLimit = 100
+	MaxStationLimit     = 1000
+)
+
+// StationQuery selects, orders and pages stations for
+// StationStore.ListStations. Zero values and nil pointers do not filter.
+type StationQuery struct {
+	SourceID int64
+	Network  string // exact network code
+	Station  string // exact station code
+
+	// A station matches only if one of its channels matches all of
+	// Channel, the sample rate range and Sensor.
+	Channel       string // channel code pattern; * and ? are wildcards
+	MinSampleRate *float64
+	MaxSampleRate *float64
+	Sensor        string // case-insensitive substring of the sensor description
+
+	GeoFilter
+
+	ActiveAt        *time.Time // operating at this time (open start or end times match)
+	HasAvailability *bool
+
+	Sort   string // a key of StationSortColumns; default "network"
+	Desc   bool
+	Limit  int    // page size; DefaultStationLimit if zero, at most MaxStationLimit
+	Cursor string // NextCursor of the previous page
+}
+
+// stationSorts maps sort names to non-null SQL sort keys over the stations
+// (st) and networks (n) aliases. Missing times sort as open-ended.
+var stationSorts = map[string]string{
+	"network":          "n.code || '.' || st.code",
+	"station":          "st.code || '.' || n.code",
+	"site_name":        "COALESCE(st.site_name, '')",
+	"latitude":         "st.latitude",
+	"longitude":        "st.longitude",
+	"elevation":        "st.elevation",
+	"start_time":       "COALESCE(st.start_time, '0001-01-01')",
+	"end_time":         "COALESCE(st.end_time, '9999-12-31')",
+	"created_at":       "st.created_at",
+	"source":           "n.source_id",
+	"has_availability": "CASE WHEN " + hasAvailabilityExpr + " THEN 1 ELSE 0 END",
+	"restricted":       "CASE WHEN " + RestrictedExpr("n", "st", "") + " THEN 1 ELSE 0 END",
+}
+
+// StationSortColumns lists the values accepted by StationQuery.Sort.
+func StationSortColumns() []string {
+	cols := make([]string, 0, len(stationSorts))
+	for c := range stationSorts {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+const hasAvailabilityExpr = "EXISTS (SELECT 1 FROM availability a JOIN channels c ON a.channel_id = c.id WHERE c.station_id = st.id)"
+
+// stationCursor is the position after the last row of a page. It records the
+// sort so a cursor cannot be reused with a different one.
+type stationCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  any    `json:"k"`
+	ID   int64  `json:"id"`
+}
+
+func encodeCursor(c stationCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (stationCursor, error) {
+	var c stationCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	// Bind numbers as integers where possible so they compare with integer
+	// columns on PostgreSQL
+	if n, ok := c.Key.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			c.Key = i
+		} else if f, err := n.Float64(); err == nil {
+			c.Key = f
+		}
+	}
+	return c, nil
+}
+
+// where returns the SQL filter for q, without the cursor, and its arguments.
+func (q *StationQuery) where(driver string) (string, []any) {
+	conds := []string{"1=1"}
+	var args []any
+	add := func(cond string, a ...any) {
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+
+	if q.SourceID != 0 {
+		add("n.source_id = ?", q.SourceID)
+	}
+	if q.Network != "" {
+		add("n.code = ?", q.Network)
+	}
+	if q.Station != "" {
+		add("st.code = ?", q.Station)
+	}
+
+	var chConds []string
+	var chArgs []any
+	if q.Channel != "" {
+		chConds = append(chConds, `c.code LIKE ? ESCAPE '\'`)
+		chArgs = append(chArgs, WildcardToLike(q.Channel))
+	}
+	if q.MinSampleRate != nil {
+		chConds = append(chConds, "c.sample_rate >= ?")
+		chArgs = append(chArgs, *q.MinSampleRate)
+	}
+	if q.MaxSampleRate != nil {
+		chConds = append(chConds, "c.sample_rate <= ?")
+		chArgs = append(chArgs, *q.MaxSampleRate)
+	}
+	if q.Sensor != "" {
+		chConds = append(chConds, `lower(c.sensor_description) LIKE ? ESCAPE '\'`)
+		chArgs = append(chArgs, "%"+escapeLike(strings.ToLower(q.Sensor))+"%")
+	}
+	if len(chConds) > 0 {
+		add("EXISTS (SELECT 1 FROM channels c WHERE c.station_id = st.id AND "+strings.Join(chConds, " AND ")+")", chArgs...)
+	}
+
+	if geo, geoArgs := q.GeoFilter.Where(driver, "st"); geo != "" {
+		add(geo, geoArgs...)
+	}
+
+	if q.ActiveAt != nil {
+		add("(st.start_time IS NULL OR st.start_time <= ?) AND (st.end_time IS NULL OR st.end_time > ?)", *q.ActiveAt, *q.ActiveAt)
+	}
+	if q.HasAvailability != nil {
+		if *q.HasAvailability {
+			add(hasAvailabilityExpr)
+		} else {
+			add("NOT " + hasAvailabilityExpr)
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// WildcardToLike converts an FDSN-style pattern (* and ?) to a LIKE pattern.
+func WildcardToLike(pattern string) string {
+	return strings.NewReplacer("*", "%", "?", "_").Replace(escapeLike(pattern))
+}
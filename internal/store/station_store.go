@@ -2,60 +2,255 @@ package store
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+
+	"github.com/joescharf/fdsn/internal/database"
 	"github.com/joescharf/fdsn/internal/models"
 )
 
 type stationStore struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
-// NewStationStore returns a StationStore backed by SQLite.
-func NewStationStore(db *sqlx.DB) StationStore {
+// NewStationStore returns a StationStore backed by the SQL database.
+func NewStationStore(db *database.DB) StationStore {
 	return &stationStore{db: db}
 }
 
-func (s *stationStore) ListStations(networkCode, stationCode string, limit, offset int) ([]models.Station, int64, error) {
-	where := "1=1"
-	args := []any{}
-
-	if networkCode != "" {
-		where += " AND n.code = ?"
-		args = append(args, networkCode)
+func (s *stationStore) ListStations(q StationQuery) (*models.StationPage, error) {
+	sortName := q.Sort
+	if sortName == "" {
+		sortName = "network"
+	}
+	key, ok := stationSorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, q.Sort)
 	}
-	if stationCode != "" {
-		where += " AND st.code = ?"
-		args = append(args, stationCode)
+	if q.Limit <= 0 {
+		q.Limit = DefaultStationLimit
 	}
+	q.Limit = min(q.Limit, MaxStationLimit)
+	where, args := q.where(s.db.DriverName())
 
-	// Count
 	var total int64
-	countQ := fmt.Sprintf("SELECT COUNT(*) FROM stations st JOIN networks n ON st.network_id = n.id WHERE %s", where)
-	if err := s.db.Get(&total, countQ, args...); err != nil {
-		return nil, 0, err
+	countQ := "SELECT COUNT(*) FROM stations st JOIN networks n ON st.network_id = n.id WHERE " + where
+	if err := s.db.Reader.Get(&total, countQ, args...); err != nil {
+		return nil, err
+	}
+
+	// Keyset pagination: continue after the cursor's (sort key, id)
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sortName || c.Desc != q.Desc {
+			return nil, fmt.Errorf("%w: cursor belongs to a different sort", ErrInvalidQuery)
+		}
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND st.id %[2]s ?))", key, cmp)
+		args = append(args, c.Key, c.Key, c.ID)
 	}
 
-	// Query
-	q := fmt.Sprintf(`SELECT st.*, n.code AS network_code, n.source_id AS source_id,
-		EXISTS (SELECT 1 FROM availability a JOIN channels c ON a.channel_id = c.id WHERE c.station_id = st.id) AS has_availability
+	query := fmt.Sprintf(`SELECT st.*, n.code AS network_code, n.source_id AS source_id,
+		%s AS has_availability,
+		%s AS restricted,
+		%s AS sort_key
 		FROM stations st
 		JOIN networks n ON st.network_id = n.id
 		WHERE %s
-		ORDER BY n.code, st.code
-		LIMIT ? OFFSET ?`, where)
-	args = append(args, limit, offset)
+		ORDER BY sort_key %s, st.id %s
+		LIMIT ?`, hasAvailabilityExpr, RestrictedExpr("n", "st", ""), key, where, dir, dir)
+	args = append(args, q.Limit+1)
 
-	var stations []models.Station
-	if err := s.db.Select(&stations, q, args...); err != nil {
-		return nil, 0, err
+	var rows []struct {
+		models.Station
+		SortKey any `db:"sort_key"`
+	}
+	if err := s.db.Reader.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	page := &models.StationPage{Stations: make([]models.Station, 0, len(rows)), Total: total}
+	for i, r := range rows {
+		if i == q.Limit {
+			last := rows[i-1]
+			page.NextCursor = encodeCursor(stationCursor{Sort: sortName, Desc: q.Desc, Key: cursorKey(last.SortKey), ID: last.ID})
+			break
+		}
+		page.Stations = append(page.Stations, r.Station)
+	}
+	return page, nil
+}
+
+func (s *stationStore) ListStationSummaries(q StationQuery) ([]models.StationSummary, error) {
+	sortName := q.Sort
+	if sortName == "" {
+		sortName = "network"
+	}
+	key, ok := stationSorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, q.Sort)
+	}
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+	where, args := q.where(s.db.DriverName())
+
+	var rows []struct {
+		ID                 int64      `db:"id"`
+		Network            string     `db:"network"`
+		NetworkDescription *string    `db:"network_description"`
+		Station            string     `db:"station"`
+		Latitude           float64    `db:"latitude"`
+		Longitude          float64    `db:"longitude"`
+		Elevation          float64    `db:"elevation"`
+		SiteName           *string    `db:"site_name"`
+		StartTime          *time.Time `db:"start_time"`
+		EndTime            *time.Time `db:"end_time"`
+		Restricted         bool       `db:"restricted"`
+		DataCenter         string     `db:"data_center"`
+	}
+	err := s.db.Reader.Select(&rows, fmt.Sprintf(`SELECT st.id, n.code AS network, n.description AS network_description,
+		st.code AS station, st.latitude, st.longitude, st.elevation, st.site_name, st.start_time, st.end_time,
+		%s AS restricted, COALESCE(src.name, '') AS data_center
+		FROM stations st
+		JOIN networks n ON st.network_id = n.id
+		LEFT JOIN sources src ON n.source_id = src.id
+		WHERE %s
+		ORDER BY %s %s, st.id %s`, RestrictedExpr("n", "st", ""), where, key, dir, dir), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var chans []struct {
+		StationID    int64   `db:"station_id"`
+		LocationCode string  `db:"location_code"`
+		Code         string  `db:"code"`
+		Earliest     *string `db:"earliest"`
+		Latest       *string `db:"latest"`
+	}
+	err = s.db.Reader.Select(&chans, `SELECT c.station_id, COALESCE(c.location_code, '') AS location_code, c.code,
+		MIN(a.earliest) AS earliest, MAX(a.latest) AS latest
+		FROM channels c
+		JOIN stations st ON c.station_id = st.id
+		JOIN networks n ON st.network_id = n.id
+		LEFT JOIN availability a ON a.channel_id = c.id
+		WHERE `+where+`
+		GROUP BY c.id, c.station_id, c.location_code, c.code
+		ORDER BY c.location_code, c.code`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.StationSummary, len(rows))
+	index := make(map[int64]int, len(rows))
+	for i, r := range rows {
+		index[r.ID] = i
+		summaries[i] = models.StationSummary{
+			StationID:  r.ID,
+			Network:    r.Network,
+			Station:    r.Station,
+			Latitude:   r.Latitude,
+			Longitude:  r.Longitude,
+			Elevation:  r.Elevation,
+			StartTime:  r.StartTime,
+			EndTime:    r.EndTime,
+			Restricted: r.Restricted,
+			DataCenter: r.DataCenter,
+		}
+		if r.NetworkDescription != nil {
+			summaries[i].NetworkDescription = *r.NetworkDescription
+		}
+		if r.SiteName != nil {
+			summaries[i].SiteName = *r.SiteName
+		}
+	}
+	for _, c := range chans {
+		sum := &summaries[index[c.StationID]]
+		code := c.Code
+		if c.LocationCode != "" {
+			code = c.LocationCode + "." + c.Code
+		}
+		if !slices.Contains(sum.Channels, code) {
+			sum.Channels = append(sum.Channels, code)
+		}
+		if c.Earliest != nil && (sum.AvailabilityStart == nil || *c.Earliest < *sum.AvailabilityStart) {
+			sum.AvailabilityStart = c.Earliest
+		}
+		if c.Latest != nil && (sum.AvailabilityEnd == nil || *c.Latest > *sum.AvailabilityEnd) {
+			sum.AvailabilityEnd = c.Latest
+		}
+	}
+	return summaries, nil
+}
+
+// clusterCellsPerTile is how many grid cells span a 256px map tile, giving
+// cells of about 64px.
+const clusterCellsPerTile = 4
+
+func (s *stationStore) ClusterStations(box GeoFilter, zoom int) ([]models.StationCluster, error) {
+	zoom = min(max(zoom, 0), 22)
+	cell := 360 / (float64(int64(1)<<zoom) * clusterCellsPerTile)
+
+	where, args := box.Where(s.db.DriverName(), "st")
+	if where == "" {
+		where = "1=1"
+	}
+	query := `SELECT COUNT(*) AS count, AVG(st.latitude) AS latitude, AVG(st.longitude) AS longitude,
+		MIN(st.id) AS station_id, MIN(n.code || '.' || st.code) AS code
+		FROM stations st
+		JOIN networks n ON st.network_id = n.id
+		WHERE ` + where + `
+		GROUP BY floor((st.latitude + 90) / ?), floor((st.longitude + 180) / ?)
+		ORDER BY count DESC`
+	args = append(args, cell, cell)
+
+	var rows []struct {
+		Count     int64   `db:"count"`
+		Latitude  float64 `db:"latitude"`
+		Longitude float64 `db:"longitude"`
+		StationID int64   `db:"station_id"`
+		Code      string  `db:"code"`
+	}
+	if err := s.db.Reader.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+	clusters := make([]models.StationCluster, len(rows))
+	for i, r := range rows {
+		clusters[i] = models.StationCluster{Latitude: r.Latitude, Longitude: r.Longitude, Count: r.Count}
+		if r.Count == 1 {
+			clusters[i].StationID = &r.StationID
+			clusters[i].Code = r.Code
+		}
+	}
+	return clusters, nil
+}
+
+// cursorKey makes a scanned sort key JSON-friendly. Drivers return text as
+// []byte and PostgreSQL timestamps as time.Time.
+func cursorKey(v any) any {
+	switch k := v.(type) {
+	case []byte:
+		return string(k)
+	case time.Time:
+		return k.UTC().Format(time.RFC3339Nano)
+	default:
+		return v
 	}
-	return stations, total, nil
 }
 
 func (s *stationStore) GetStation(id int64) (*models.StationDetail, error) {
 	var station models.Station
-	err := s.db.Get(&station, `SELECT st.*, n.code AS network_code, n.source_id AS source_id
+	err := s.db.Reader.Get(&station, `SELECT st.*, n.code AS network_code, n.source_id AS source_id,
+		`+RestrictedExpr("n", "st", "")+` AS restricted
 		FROM stations st
 		JOIN networks n ON st.network_id = n.id
 		WHERE st.id = ?`, id)
@@ -64,7 +259,12 @@ func (s *stationStore) GetStation(id int64) (*models.StationDetail, error) {
 	}
 
 	var channels []models.Channel
-	if err := s.db.Select(&channels, "SELECT * FROM channels WHERE station_id = ? ORDER BY location_code, code", id); err != nil {
+	if err := s.db.Reader.Select(&channels, `SELECT c.*, `+RestrictedExpr("n", "st", "c")+` AS restricted
+		FROM channels c
+		JOIN stations st ON c.station_id = st.id
+		JOIN networks n ON st.network_id = n.id
+		WHERE c.station_id = ?
+		ORDER BY c.location_code, c.code`, id); err != nil {
 		return nil, err
 	}
 
@@ -74,9 +274,28 @@ func (s *stationStore) GetStation(id int64) (*models.StationDetail, error) {
 	}, nil
 }
 
+// DeleteStation deletes a station and, through the foreign keys, its
+// channels. The restriction markers of both have no foreign keys to cascade
+// and are deleted in the same transaction.
 func (s *stationStore) DeleteStation(id int64) error {
-	_, err := s.db.Exec("DELETE FROM stations WHERE id = ?", id)
-	return err
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, step := range []struct {
+		what, query string
+	}{
+		{"channel restrictions", "DELETE FROM restrictions WHERE level = '" + LevelChannel + "' AND ref_id IN (SELECT id FROM channels WHERE station_id = ?)"},
+		{"station restrictions", "DELETE FROM restrictions WHERE level = '" + LevelStation + "' AND ref_id = ?"},
+		{"station", "DELETE FROM stations WHERE id = ?"},
+	} {
+		if _, err := tx.Exec(step.query, id); err != nil {
+			return fmt.Errorf("delete %s: %w", step.what, err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportChannel) error {
@@ -102,14 +321,13 @@ func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportCh
 		if !ok {
 			err := tx.Get(&netID, "SELECT id FROM networks WHERE source_id = ? AND code = ? LIMIT 1", sourceID, ch.NetworkCode)
 			if err != nil {
-				res, err := tx.Exec(
-					"INSERT INTO networks (source_id, code, description) VALUES (?, ?, ?)",
+				err := tx.Get(&netID,
+					"INSERT INTO networks (source_id, code, description) VALUES (?, ?, ?) RETURNING id",
 					sourceID, ch.NetworkCode, ch.NetworkDescription,
 				)
 				if err != nil {
 					return fmt.Errorf("insert network %s: %w", ch.NetworkCode, err)
 				}
-				netID, _ = res.LastInsertId()
 			} else {
 				// Update existing network metadata
 				_, err = tx.Exec("UPDATE networks SET description = ? WHERE id = ?",
@@ -118,6 +336,12 @@ func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportCh
 					return fmt.Errorf("update network %s: %w", ch.NetworkCode, err)
 				}
 			}
+			if err := applyUpstreamRestriction(tx, LevelNetwork, netID, ch.NetworkRestricted); err != nil {
+				return err
+			}
+			if err := applyUpstreamMetadata(tx, LevelNetwork, netID, ch.NetworkMetadata); err != nil {
+				return err
+			}
 			networkIDs[nk] = netID
 		}
 
@@ -127,14 +351,13 @@ func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportCh
 		if !ok {
 			err := tx.Get(&staID, "SELECT id FROM stations WHERE network_id = ? AND code = ? LIMIT 1", netID, ch.StationCode)
 			if err != nil {
-				res, err := tx.Exec(
-					"INSERT INTO stations (network_id, code, latitude, longitude, elevation, site_name, start_time, end_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				err := tx.Get(&staID,
+					"INSERT INTO stations (network_id, code, latitude, longitude, elevation, site_name, start_time, end_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id",
 					netID, ch.StationCode, ch.Latitude, ch.Longitude, ch.Elevation, ch.SiteName, ch.StationStartTime, ch.StationEndTime,
 				)
 				if err != nil {
 					return fmt.Errorf("insert station %s: %w", ch.StationCode, err)
 				}
-				staID, _ = res.LastInsertId()
 			} else {
 				// Update existing station metadata
 				_, err = tx.Exec(
@@ -145,13 +368,28 @@ func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportCh
 					return fmt.Errorf("update station %s: %w", ch.StationCode, err)
 				}
 			}
+			if err := applyUpstreamRestriction(tx, LevelStation, staID, ch.StationRestricted); err != nil {
+				return err
+			}
+			if err := applyUpstreamMetadata(tx, LevelStation, staID, ch.StationMetadata); err != nil {
+				return err
+			}
 			stationIDs[sk] = staID
 		}
 
-		// Upsert channel: replace on conflict so metadata gets updated on re-import
-		_, err := tx.Exec(
-			`INSERT OR REPLACE INTO channels (station_id, location_code, code, latitude, longitude, elevation, depth, azimuth, dip, sensor_description, scale, scale_freq, scale_units, sample_rate, start_time, end_time)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		// Upsert channel: update in place on conflict so the channel ID (and
+		// anything keyed by it, such as availability) survives re-import
+		var chanID int64
+		err := tx.Get(&chanID,
+			`INSERT INTO channels (station_id, location_code, code, latitude, longitude, elevation, depth, azimuth, dip, sensor_description, scale, scale_freq, scale_units, sample_rate, start_time, end_time)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (station_id, location_code, code) DO UPDATE SET
+			   latitude = excluded.latitude, longitude = excluded.longitude, elevation = excluded.elevation,
+			   depth = excluded.depth, azimuth = excluded.azimuth, dip = excluded.dip,
+			   sensor_description = excluded.sensor_description, scale = excluded.scale,
+			   scale_freq = excluded.scale_freq, scale_units = excluded.scale_units,
+			   sample_rate = excluded.sample_rate, start_time = excluded.start_time, end_time = excluded.end_time
+			 RETURNING id`,
 			staID, ch.LocationCode, ch.ChannelCode,
 			ch.ChanLatitude, ch.ChanLongitude, ch.ChanElevation, ch.Depth,
 			ch.Azimuth, ch.Dip, ch.SensorDescription,
@@ -161,14 +399,41 @@ func (s *stationStore) ImportStations(sourceID int64, channels []models.ImportCh
 		if err != nil {
 			return fmt.Errorf("insert channel %s.%s: %w", ch.StationCode, ch.ChannelCode, err)
 		}
+		if err := applyUpstreamRestriction(tx, LevelChannel, chanID, ch.ChanRestricted); err != nil {
+			return err
+		}
+		if err := applyUpstreamMetadata(tx, LevelChannel, chanID, ch.ChanMetadata); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
 
+// applyUpstreamMetadata stores the StationXML source identifier,
+// identifiers and water level of a node; nil metadata leaves them untouched.
+func applyUpstreamMetadata(e sqlx.Execer, level string, id int64, m *models.NodeMetadata) error {
+	if m == nil {
+		return nil
+	}
+	var err error
+	switch level {
+	case LevelNetwork:
+		_, err = e.Exec("UPDATE networks SET fdsn_source_id = ?, identifiers = ? WHERE id = ?", m.SourceID, m.Identifiers, id)
+	case LevelStation:
+		_, err = e.Exec("UPDATE stations SET fdsn_source_id = ?, identifiers = ?, water_level = ? WHERE id = ?", m.SourceID, m.Identifiers, m.WaterLevel, id)
+	default:
+		_, err = e.Exec("UPDATE channels SET fdsn_source_id = ?, identifiers = ?, water_level = ? WHERE id = ?", m.SourceID, m.Identifiers, m.WaterLevel, id)
+	}
+	if err != nil {
+		return fmt.Errorf("set %s %d metadata: %w", level, id, err)
+	}
+	return nil
+}
+
 func (s *stationStore) ListNetworks() ([]models.Network, error) {
 	var networks []models.Network
-	err := s.db.Select(&networks, "SELECT * FROM networks ORDER BY code")
+	err := s.db.Reader.Select(&networks, "SELECT n.*, "+RestrictedExpr("n", "", "")+" AS restricted FROM networks n ORDER BY n.code")
 	return networks, err
 }
 
@@ -179,7 +444,7 @@ func (s *stationStore) LookupChannelIDs(sourceID int64, networkCode, stationCode
 		Code         string `db:"code"`
 	}
 	var rows []row
-	err := s.db.Select(&rows, `
+	err := s.db.Reader.Select(&rows, `
 		SELECT c.id, c.location_code, c.code
 		FROM channels c
 		JOIN stations st ON c.station_id = st.id
@@ -199,67 +464,168 @@ func (s *stationStore) LookupChannelIDs(sourceID int64, networkCode, stationCode
 
 func (s *stationStore) ListNetworksBySource(sourceID int64) ([]models.Network, error) {
 	var networks []models.Network
-	err := s.db.Select(&networks, "SELECT * FROM networks WHERE source_id = ? ORDER BY code", sourceID)
+	err := s.db.Reader.Select(&networks, "SELECT n.*, "+RestrictedExpr("n", "", "")+" AS restricted FROM networks n WHERE n.source_id = ? ORDER BY n.code", sourceID)
 	return networks, err
 }
 
-func (s *stationStore) ListStationsBySource(sourceID int64, networkCode string, limit, offset int) ([]models.Station, int64, error) {
-	where := "n.source_id = ?"
-	args := []any{sourceID}
-
-	if networkCode != "" {
-		where += " AND n.code = ?"
-		args = append(args, networkCode)
+func (s *stationStore) SetRestricted(level string, id int64, restricted bool) error {
+	if !validLevel(level) {
+		return fmt.Errorf("invalid restriction level %q", level)
 	}
-
-	var total int64
-	countQ := fmt.Sprintf("SELECT COUNT(*) FROM stations st JOIN networks n ON st.network_id = n.id WHERE %s", where)
-	if err := s.db.Get(&total, countQ, args...); err != nil {
-		return nil, 0, err
+	if restricted {
+		_, err := s.db.Exec(`INSERT INTO restrictions (level, ref_id, origin) VALUES (?, ?, 'manual')
+			ON CONFLICT (level, ref_id) DO UPDATE SET origin = 'manual'`, level, id)
+		return err
 	}
-
-	q := fmt.Sprintf(`SELECT st.*, n.code AS network_code, n.source_id AS source_id,
-		EXISTS (SELECT 1 FROM availability a JOIN channels c ON a.channel_id = c.id WHERE c.station_id = st.id) AS has_availability
-		FROM stations st
-		JOIN networks n ON st.network_id = n.id
-		WHERE %s
-		ORDER BY n.code, st.code
-		LIMIT ? OFFSET ?`, where)
-	args = append(args, limit, offset)
-
-	var stations []models.Station
-	if err := s.db.Select(&stations, q, args...); err != nil {
-		return nil, 0, err
-	}
-	return stations, total, nil
+	_, err := s.db.Exec("DELETE FROM restrictions WHERE level = ? AND ref_id = ?", level, id)
+	return err
 }
 
 func (s *stationStore) ListUniqueSourceNetworks() ([]models.SourceNetwork, error) {
 	var result []models.SourceNetwork
-	err := s.db.Select(&result, `
+	err := s.db.Reader.Select(&result, `
 		SELECT DISTINCT n.source_id, src.name AS source_name, n.code AS network_code
 		FROM networks n
 		JOIN sources src ON n.source_id = src.id
+		WHERE src.enabled = TRUE
 		ORDER BY src.name, n.code`)
 	return result, err
 }
 
-// NewStatsStore returns a StatsStore backed by SQLite.
-func NewStatsStore(db *sqlx.DB) StatsStore {
+func (s *stationStore) ListChannelEpochs() ([]models.ChannelEpoch, error) {
+	var result []models.ChannelEpoch
+	err := s.db.Reader.Select(&result, `
+		SELECT c.id, c.station_id, st.network_id, n.source_id,
+			n.code AS network_code, st.code AS station_code, COALESCE(c.location_code, '') AS location_code,
+			c.code, c.start_time, c.end_time
+		FROM channels c
+		JOIN stations st ON c.station_id = st.id
+		JOIN networks n ON st.network_id = n.id
+		ORDER BY n.source_id, n.code, st.code, c.location_code, c.code`)
+	return result, err
+}
+
+func (s *stationStore) DeleteChannels(ids []int64) (*models.PruneResult, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res := &models.PruneResult{}
+	stationIDs, err := selectIn[int64](tx, "SELECT DISTINCT station_id FROM channels WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, fmt.Errorf("find stations: %w", err)
+	}
+	if res.Channels, err = deleteNodes(tx, "channels", LevelChannel, ids); err != nil {
+		return nil, err
+	}
+
+	// Stations and networks are only removed once they hold no channels
+	stationIDs = uniqueIDs(stationIDs)
+	networkIDs, err := selectIn[int64](tx, "SELECT DISTINCT network_id FROM stations WHERE id IN (?)", stationIDs)
+	if err != nil {
+		return nil, fmt.Errorf("find networks: %w", err)
+	}
+	empty, err := selectIn[int64](tx, `SELECT id FROM stations WHERE id IN (?)
+		AND NOT EXISTS (SELECT 1 FROM channels c WHERE c.station_id = stations.id)`, stationIDs)
+	if err != nil {
+		return nil, fmt.Errorf("find empty stations: %w", err)
+	}
+	if res.Stations, err = deleteNodes(tx, "stations", LevelStation, empty); err != nil {
+		return nil, err
+	}
+
+	empty, err = selectIn[int64](tx, `SELECT id FROM networks WHERE id IN (?)
+		AND NOT EXISTS (SELECT 1 FROM stations st WHERE st.network_id = networks.id)`, uniqueIDs(networkIDs))
+	if err != nil {
+		return nil, fmt.Errorf("find empty networks: %w", err)
+	}
+	if res.Networks, err = deleteNodes(tx, "networks", LevelNetwork, empty); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+// inBatch is the number of ids bound in one "IN (?)" list, well under the
+// bind parameter limits of both databases.
+const inBatch = 500
+
+// selectIn runs query with its single "IN (?)" expanded to ids, inBatch ids
+// at a time, and returns the rows of all batches.
+func selectIn[T any](q sqlx.Queryer, query string, ids []int64) ([]T, error) {
+	var rows []T
+	for batch := range slices.Chunk(ids, inBatch) {
+		bq, args, err := sqlx.In(query, batch)
+		if err != nil {
+			return nil, err
+		}
+		var part []T
+		if err := sqlx.Select(q, &part, bq, args...); err != nil {
+			return nil, err
+		}
+		rows = append(rows, part...)
+	}
+	return rows, nil
+}
+
+// deleteNodes deletes rows of table by id along with their restriction
+// markers, inBatch ids at a time, returning the number of rows deleted.
+func deleteNodes(tx *sqlx.Tx, table, level string, ids []int64) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(ids, inBatch) {
+		q, args, err := sqlx.In("DELETE FROM restrictions WHERE level = ? AND ref_id IN (?)", level, batch)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(q, args...); err != nil {
+			return 0, fmt.Errorf("delete %s restrictions: %w", level, err)
+		}
+		q, args, err = sqlx.In("DELETE FROM "+table+" WHERE id IN (?)", batch)
+		if err != nil {
+			return 0, err
+		}
+		r, err := tx.Exec(q, args...)
+		if err != nil {
+			return 0, fmt.Errorf("delete %s: %w", table, err)
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// NewStatsStore returns a StatsStore backed by the SQL database.
+func NewStatsStore(db *database.DB) StatsStore {
 	return &statsStore{db: db}
 }
 
 type statsStore struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
 func (s *statsStore) GetStats() (*models.Stats, error) {
 	var stats models.Stats
-	row := s.db.QueryRow(`SELECT
+	enabled := SourceEnabledExpr("n")
+	row := s.db.Reader.QueryRow(`SELECT
 		(SELECT COUNT(*) FROM sources) AS sources,
-		(SELECT COUNT(*) FROM networks) AS networks,
-		(SELECT COUNT(*) FROM stations) AS stations,
-		(SELECT COUNT(*) FROM channels) AS channels`)
-	err := row.Scan(&stats.Sources, &stats.Networks, &stats.Stations, &stats.Channels)
+		(SELECT COUNT(*) FROM sources WHERE enabled = TRUE) AS enabled_sources,
+		(SELECT COUNT(*) FROM networks n WHERE ` + enabled + `) AS networks,
+		(SELECT COUNT(*) FROM stations st JOIN networks n ON st.network_id = n.id WHERE ` + enabled + `) AS stations,
+		(SELECT COUNT(*) FROM channels c JOIN stations st ON c.station_id = st.id
+			JOIN networks n ON st.network_id = n.id WHERE ` + enabled + `) AS channels`)
+	err := row.Scan(&stats.Sources, &stats.EnabledSources, &stats.Networks, &stats.Stations, &stats.Channels)
 	return &stats, err
 }
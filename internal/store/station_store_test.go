@@ -0,0 +1,466 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func setupStationStore(t *testing.T) *stationStore {
+	t.Helper()
+	db, err := database.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	db.Exec("INSERT INTO sources (name, base_url, description) VALUES ('test', 'http://test', '')")
+	return &stationStore{db: db}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestImportStationsKeepsChannelIDs(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BHZ", SampleRate: 40},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	var before int64
+	s.db.Get(&before, "SELECT id FROM channels WHERE code = 'BHZ'")
+
+	chans[0].SampleRate = 20
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	var after int64
+	var rate float64
+	s.db.Get(&after, "SELECT id FROM channels WHERE code = 'BHZ'")
+	s.db.Get(&rate, "SELECT sample_rate FROM channels WHERE code = 'BHZ'")
+	if after != before {
+		t.Errorf("channel id changed on re-import: %d -> %d", before, after)
+	}
+	if rate != 20 {
+		t.Errorf("sample_rate not updated: got %v", rate)
+	}
+}
+
+func TestImportStationsRestrictions(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "XX", StationCode: "TMP1", LocationCode: "", ChannelCode: "HHZ",
+			NetworkRestricted: boolPtr(false), StationRestricted: boolPtr(true), ChanRestricted: boolPtr(true)},
+		{NetworkCode: "XX", StationCode: "TMP2", LocationCode: "", ChannelCode: "HHZ"},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+
+	page, err := s.ListStations(StationQuery{Network: "XX"})
+	if err != nil {
+		t.Fatalf("ListStations: %v", err)
+	}
+	stations := page.Stations
+	if len(stations) != 2 {
+		t.Fatalf("expected 2 stations, got %d", len(stations))
+	}
+	if !stations[0].Restricted || stations[1].Restricted {
+		t.Errorf("restricted flags: TMP1=%v TMP2=%v, want true/false", stations[0].Restricted, stations[1].Restricted)
+	}
+
+	// Manual restriction on TMP2 survives a re-import that reports it open
+	if err := s.SetRestricted(LevelStation, stations[1].ID, true); err != nil {
+		t.Fatalf("SetRestricted: %v", err)
+	}
+	chans[1].StationRestricted = boolPtr(false)
+	chans[0].StationRestricted = boolPtr(false)
+	chans[0].ChanRestricted = boolPtr(false)
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	page, _ = s.ListStations(StationQuery{Network: "XX"})
+	stations = page.Stations
+	if stations[0].Restricted {
+		t.Error("expected upstream restriction on TMP1 to be cleared")
+	}
+	if !stations[1].Restricted {
+		t.Error("expected manual restriction on TMP2 to survive re-import")
+	}
+
+	// Channels inherit restriction from their station
+	detail, err := s.GetStation(stations[1].ID)
+	if err != nil {
+		t.Fatalf("GetStation: %v", err)
+	}
+	if len(detail.Channels) != 1 || !detail.Channels[0].Restricted {
+		t.Error("expected channel to inherit station restriction")
+	}
+
+	if err := s.SetRestricted("site", 1, true); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestImportStationsMetadata(t *testing.T) {
+	s := setupStationStore(t)
+	level := 12.5
+	chans := []models.ImportChannel{{
+		NetworkCode: "XX", StationCode: "OBS", ChannelCode: "HHZ",
+		NetworkMetadata: &models.NodeMetadata{
+			SourceID:    "FDSN:XX",
+			Identifiers: models.Identifiers{{Type: "DOI", Value: "10.7914/SN/XX"}},
+		},
+		StationMetadata: &models.NodeMetadata{WaterLevel: &level},
+		ChanMetadata:    &models.NodeMetadata{SourceID: "FDSN:XX_OBS__H_H_Z"},
+	}}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+
+	// Without metadata, a re-import keeps what is stored
+	chans[0].NetworkMetadata, chans[0].StationMetadata, chans[0].ChanMetadata = nil, nil, nil
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	networks, err := s.ListNetworks()
+	if err != nil || len(networks) != 1 {
+		t.Fatalf("ListNetworks = %v, %v", networks, err)
+	}
+	n := networks[0]
+	if n.FDSNSourceID != "FDSN:XX" || len(n.Identifiers) != 1 || n.Identifiers[0].Value != "10.7914/SN/XX" {
+		t.Errorf("network metadata = %q %v", n.FDSNSourceID, n.Identifiers)
+	}
+	page, _ := s.ListStations(StationQuery{})
+	detail, err := s.GetStation(page.Stations[0].ID)
+	if err != nil {
+		t.Fatalf("GetStation: %v", err)
+	}
+	if detail.WaterLevel == nil || *detail.WaterLevel != level {
+		t.Errorf("station water level = %v, want %v", detail.WaterLevel, level)
+	}
+	if detail.Channels[0].FDSNSourceID != "FDSN:XX_OBS__H_H_Z" {
+		t.Errorf("channel source ID = %q", detail.Channels[0].FDSNSourceID)
+	}
+
+	// Empty metadata clears it
+	chans[0].NetworkMetadata = &models.NodeMetadata{}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	networks, _ = s.ListNetworks()
+	if networks[0].FDSNSourceID != "" || networks[0].Identifiers != nil {
+		t.Errorf("network metadata not cleared: %q %v", networks[0].FDSNSourceID, networks[0].Identifiers)
+	}
+}
+
+func TestListStationsQuery(t *testing.T) {
+	s := setupStationStore(t)
+	ended := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", Latitude: 34.9, Longitude: -106.5, ChannelCode: "BHZ", SampleRate: 40, SensorDescription: "Streckeisen STS-2"},
+		{NetworkCode: "IU", StationCode: "COLA", Latitude: 64.9, Longitude: -147.8, ChannelCode: "LHZ", SampleRate: 1, SensorDescription: "Geotech KS-54000"},
+		{NetworkCode: "IU", StationCode: "OLD", Latitude: 35, Longitude: -106, ChannelCode: "BHZ", SampleRate: 20, StationEndTime: &ended},
+		{NetworkCode: "NZ", StationCode: "WEL", Latitude: -41.3, Longitude: 174.8, ChannelCode: "HHZ", SampleRate: 100},
+		{NetworkCode: "XX", StationCode: "FJI", Latitude: -18, Longitude: -179.5, ChannelCode: "HHZ", SampleRate: 100},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	var bhz int64
+	s.db.Get(&bhz, "SELECT c.id FROM channels c JOIN stations st ON c.station_id = st.id WHERE st.code = 'ANMO'")
+	NewAvailabilityStore(s.db).Upsert(bhz, "2020-01-01T00:00:00Z", "2021-01-01T00:00:00Z")
+
+	f := func(v float64) *float64 { return &v }
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		q    StationQuery
+		want string
+	}{
+		{"all", StationQuery{}, "IU.ANMO IU.COLA IU.OLD NZ.WEL XX.FJI"},
+		{"channel pattern", StationQuery{Channel: "B?Z"}, "IU.ANMO IU.OLD"},
+		{"sample rate", StationQuery{MinSampleRate: f(20), MaxSampleRate: f(50)}, "IU.ANMO IU.OLD"},
+		{"sensor", StationQuery{Sensor: "sts-2"}, "IU.ANMO"},
+		{"bbox", StationQuery{GeoFilter: GeoFilter{MinLatitude: f(30), MaxLatitude: f(40), MinLongitude: f(-110), MaxLongitude: f(-100)}}, "IU.ANMO IU.OLD"},
+		{"antimeridian", StationQuery{GeoFilter: GeoFilter{MinLongitude: f(170), MaxLongitude: f(-170)}}, "NZ.WEL XX.FJI"},
+		{"radius", StationQuery{GeoFilter: GeoFilter{Latitude: f(35), Longitude: f(-106), MaxRadius: f(1)}}, "IU.ANMO IU.OLD"},
+		{"radius across antimeridian", StationQuery{GeoFilter: GeoFilter{Latitude: f(-18), Longitude: f(179.8), MaxRadius: f(1)}}, "XX.FJI"},
+		{"radius over pole", StationQuery{GeoFilter: GeoFilter{Latitude: f(89), Longitude: f(0), MaxRadius: f(30)}}, "IU.COLA"},
+		{"min radius", StationQuery{GeoFilter: GeoFilter{Latitude: f(35), Longitude: f(-106), MinRadius: f(1)}}, "IU.COLA NZ.WEL XX.FJI"},
+		{"active", StationQuery{ActiveAt: &now}, "IU.ANMO IU.COLA NZ.WEL XX.FJI"},
+		{"availability", StationQuery{HasAvailability: boolPtr(true)}, "IU.ANMO"},
+		{"sort desc", StationQuery{Sort: "latitude", Desc: true}, "IU.COLA IU.OLD IU.ANMO XX.FJI NZ.WEL"},
+	}
+	for _, tt := range tests {
+		page, err := s.ListStations(tt.q)
+		if err != nil {
+			t.Fatalf("%s: ListStations: %v", tt.name, err)
+		}
+		var got []string
+		for _, st := range page.Stations {
+			got = append(got, st.NetworkCode+"."+st.Code)
+		}
+		if strings.Join(got, " ") != tt.want || page.Total != int64(len(got)) {
+			t.Errorf("%s: got %v (total %d), want %s", tt.name, got, page.Total, tt.want)
+		}
+	}
+
+	// Cursor paging visits every station once, in sort order
+	var seen []string
+	q := StationQuery{Sort: "longitude", Limit: 2}
+	for {
+		page, err := s.ListStations(q)
+		if err != nil {
+			t.Fatalf("page: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("total = %d, want 5", page.Total)
+		}
+		for _, st := range page.Stations {
+			seen = append(seen, st.Code)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if got := strings.Join(seen, " "); got != "FJI COLA ANMO OLD WEL" {
+		t.Errorf("paged = %s", got)
+	}
+
+	if _, err := s.ListStations(StationQuery{Sort: "bogus"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unknown sort: err = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := s.ListStations(StationQuery{Sort: "latitude", Cursor: q.Cursor}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("mismatched cursor: err = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestClusterStations(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", Latitude: 34.9, Longitude: -106.5, ChannelCode: "BHZ"},
+		{NetworkCode: "IU", StationCode: "OLD", Latitude: 35, Longitude: -106, ChannelCode: "BHZ"},
+		{NetworkCode: "NZ", StationCode: "WEL", Latitude: -41.3, Longitude: 174.8, ChannelCode: "HHZ"},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+
+	// At zoom 2 the two New Mexico stations share a cell
+	clusters, err := s.ClusterStations(GeoFilter{}, 2)
+	if err != nil {
+		t.Fatalf("ClusterStations: %v", err)
+	}
+	if len(clusters) != 2 || clusters[0].Count != 2 || clusters[0].StationID != nil {
+		t.Fatalf("zoom 2 clusters = %+v, want a pair and a single", clusters)
+	}
+	if clusters[1].Count != 1 || clusters[1].Code != "NZ.WEL" || clusters[1].StationID == nil {
+		t.Errorf("single cluster = %+v, want NZ.WEL with its id", clusters[1])
+	}
+
+	// Zoomed in they separate; the bounding box drops New Zealand
+	f := func(v float64) *float64 { return &v }
+	clusters, err = s.ClusterStations(GeoFilter{MinLatitude: f(30), MaxLatitude: f(40), MinLongitude: f(-110), MaxLongitude: f(-100)}, 10)
+	if err != nil {
+		t.Fatalf("ClusterStations: %v", err)
+	}
+	if len(clusters) != 2 || clusters[0].Count != 1 || clusters[1].Count != 1 {
+		t.Errorf("zoom 10 clusters = %+v, want two singles", clusters)
+	}
+
+	// Moving a station moves it in the spatial index
+	if _, err := s.db.Exec("UPDATE stations SET latitude = -41, longitude = 175 WHERE code = 'OLD'"); err != nil {
+		t.Fatal(err)
+	}
+	var indexed int
+	s.db.Get(&indexed, "SELECT COUNT(*) FROM station_rtree WHERE max_lat < -40")
+	if indexed != 2 {
+		t.Errorf("station_rtree has %d stations in New Zealand, want 2", indexed)
+	}
+	clusters, _ = s.ClusterStations(GeoFilter{MinLatitude: f(30), MaxLatitude: f(40), MinLongitude: f(-110), MaxLongitude: f(-100)}, 10)
+	if len(clusters) != 1 || clusters[0].Code != "IU.ANMO" {
+		t.Errorf("after move clusters = %+v, want IU.ANMO only", clusters)
+	}
+}
+
+func TestListStationSummaries(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", NetworkDescription: "GSN", StationCode: "ANMO", SiteName: "Albuquerque", LocationCode: "00", ChannelCode: "BHZ"},
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "10", ChannelCode: "BHZ"},
+		{NetworkCode: "IU", StationCode: "COLA", ChannelCode: "LHZ"},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	ids, _ := s.LookupChannelIDs(1, "IU", "ANMO")
+	avail := NewAvailabilityStore(s.db)
+	avail.Upsert(ids["00.BHZ"], "2020-01-01T00:00:00Z", "2020-06-01T00:00:00Z")
+	avail.Upsert(ids["10.BHZ"], "2019-01-01T00:00:00Z", "2021-01-01T00:00:00Z")
+
+	// The limit does not apply to exports
+	sums, err := s.ListStationSummaries(StationQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListStationSummaries: %v", err)
+	}
+	if len(sums) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(sums))
+	}
+	anmo := sums[0]
+	if anmo.Station != "ANMO" || anmo.NetworkDescription != "GSN" || anmo.SiteName != "Albuquerque" || anmo.DataCenter != "test" {
+		t.Errorf("ANMO = %+v", anmo)
+	}
+	if strings.Join(anmo.Channels, " ") != "00.BHZ 10.BHZ" {
+		t.Errorf("channels = %v", anmo.Channels)
+	}
+	if anmo.AvailabilityStart == nil || *anmo.AvailabilityStart != "2019-01-01T00:00:00Z" ||
+		anmo.AvailabilityEnd == nil || *anmo.AvailabilityEnd != "2021-01-01T00:00:00Z" {
+		t.Errorf("availability = %v - %v, want the span over both channels", anmo.AvailabilityStart, anmo.AvailabilityEnd)
+	}
+	if cola := sums[1]; strings.Join(cola.Channels, " ") != "LHZ" || cola.AvailabilityStart != nil {
+		t.Errorf("COLA = %+v", cola)
+	}
+}
+
+func TestDeleteChannels(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BHZ"},
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BH1"},
+		{NetworkCode: "IU", StationCode: "COLA", LocationCode: "00", ChannelCode: "BHZ"},
+		{NetworkCode: "XX", StationCode: "TMP1", ChannelCode: "HHZ", StationRestricted: boolPtr(true)},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	epochs, err := s.ListChannelEpochs()
+	if err != nil || len(epochs) != 4 {
+		t.Fatalf("ListChannelEpochs = %d, %v", len(epochs), err)
+	}
+	var ids []int64
+	for _, ep := range epochs {
+		if ep.SourceID != 1 || ep.StationID == 0 || ep.NetworkID == 0 {
+			t.Errorf("epoch %+v", ep)
+		}
+		if ep.Station == "TMP1" || (ep.Station == "ANMO" && ep.Channel == "BH1") || ep.Station == "COLA" {
+			ids = append(ids, ep.ID)
+		}
+	}
+
+	res, err := s.DeleteChannels(ids)
+	if err != nil {
+		t.Fatalf("DeleteChannels: %v", err)
+	}
+	if want := (models.PruneResult{Channels: 3, Stations: 2, Networks: 1}); *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+	var stations []string
+	s.db.Select(&stations, "SELECT code FROM stations")
+	if len(stations) != 1 || stations[0] != "ANMO" {
+		t.Errorf("stations left = %v, want [ANMO]", stations)
+	}
+	var restrictions int
+	s.db.Get(&restrictions, "SELECT COUNT(*) FROM restrictions")
+	if restrictions != 0 {
+		t.Errorf("restrictions left = %d, want 0", restrictions)
+	}
+}
+
+func TestDeleteChannelsBatches(t *testing.T) {
+	s := setupStationStore(t)
+	// More stations, channels and restriction markers than fit in one batch
+	n := 2*inBatch + 100
+	chans := []models.ImportChannel{{NetworkCode: "IU", StationCode: "ANMO", ChannelCode: "BHZ"}}
+	for i := range n {
+		chans = append(chans, models.ImportChannel{
+			NetworkCode: "XX", StationCode: fmt.Sprintf("S%04d", i), ChannelCode: "HHZ", ChanRestricted: boolPtr(true),
+		})
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	var ids []int64
+	s.db.Select(&ids, "SELECT id FROM channels WHERE code = 'HHZ'")
+
+	res, err := s.DeleteChannels(ids)
+	if err != nil {
+		t.Fatalf("DeleteChannels: %v", err)
+	}
+	if want := (models.PruneResult{Channels: n, Stations: n, Networks: 1}); *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+	var channels, restrictions int
+	s.db.Get(&channels, "SELECT COUNT(*) FROM channels")
+	s.db.Get(&restrictions, "SELECT COUNT(*) FROM restrictions")
+	if channels != 1 || restrictions != 0 {
+		t.Errorf("left %d channels and %d restrictions, want 1 and 0", channels, restrictions)
+	}
+}
+
+func TestDeleteStationRestrictions(t *testing.T) {
+	s := setupStationStore(t)
+	chans := []models.ImportChannel{
+		{NetworkCode: "XX", StationCode: "TMP1", ChannelCode: "HHZ", StationRestricted: boolPtr(true), ChanRestricted: boolPtr(true)},
+		{NetworkCode: "XX", StationCode: "TMP2", ChannelCode: "HHZ", ChanRestricted: boolPtr(true)},
+	}
+	if err := s.ImportStations(1, chans); err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	var id int64
+	s.db.Get(&id, "SELECT id FROM stations WHERE code = 'TMP1'")
+
+	if err := s.DeleteStation(id); err != nil {
+		t.Fatalf("DeleteStation: %v", err)
+	}
+	var channels, restrictions int
+	s.db.Get(&channels, "SELECT COUNT(*) FROM channels")
+	s.db.Get(&restrictions, "SELECT COUNT(*) FROM restrictions")
+	if channels != 1 {
+		t.Errorf("channels left = %d, want 1", channels)
+	}
+	// Only the TMP2 channel marker remains
+	if restrictions != 1 {
+		t.Errorf("restrictions left = %d, want 1", restrictions)
+	}
+}
+
+func TestDisabledSourceStats(t *testing.T) {
+	s := setupStationStore(t)
+	s.db.MustExec("INSERT INTO sources (name, base_url, description, enabled) VALUES ('off', 'http://off', '', FALSE)")
+	if err := s.ImportStations(1, []models.ImportChannel{
+		{NetworkCode: "IU", StationCode: "ANMO", LocationCode: "00", ChannelCode: "BHZ"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ImportStations(2, []models.ImportChannel{
+		{NetworkCode: "ZZ", StationCode: "OFF1", ChannelCode: "HHZ"},
+		{NetworkCode: "ZZ", StationCode: "OFF2", ChannelCode: "HHZ"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := NewStatsStore(s.db).GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	want := models.Stats{Sources: 2, EnabledSources: 1, Networks: 1, Stations: 1, Channels: 1}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	nets, err := s.ListUniqueSourceNetworks()
+	if err != nil || len(nets) != 1 || nets[0].NetworkCode != "IU" {
+		t.Errorf("ListUniqueSourceNetworks = %+v, %v; want only IU", nets, err)
+	}
+}
@@ -11,20 +11,73 @@ type SourceStore interface {
 	Get(id int64) (*models.Source, error)
 	Create(s *models.Source) error
 	Update(s *models.Source) error
-	Delete(id int64) error
+	// Delete removes a source in one transaction, with its networks,
+	// stations, channels and availability unless opts reassigns them.
+	Delete(id int64, opts DeleteSourceOptions) (*models.SourceDeletion, error)
+}
+
+// DeleteSourceOptions controls SourceStore.Delete.
+type DeleteSourceOptions struct {
+	// ReassignTo is the ID of a source that takes over the deleted source's
+	// networks instead of them being deleted.
+	ReassignTo int64
+	// DryRun reports what the deletion would remove or move, rolling it back.
+	DryRun bool
+}
+
+// CredentialStore manages encrypted upstream credentials for sources.
+type CredentialStore interface {
+	// Get returns the decrypted credentials for a source, or nil if none are stored.
+	Get(sourceID int64) (*models.SourceCredentials, error)
+	Set(sourceID int64, c *models.SourceCredentials) error
+	Delete(sourceID int64) error
+}
+
+// HealthStore records source health probes and the services they discover.
+type HealthStore interface {
+	Record(r *models.ProbeResult) error
+	Services(sourceID int64) ([]models.SourceService, error)
 }
 
 // StationStore manages imported station metadata.
 type StationStore interface {
-	ListStations(networkCode, stationCode string, limit, offset int) ([]models.Station, int64, error)
+	// ListStations returns a page of the stations matching q.
+	ListStations(q StationQuery) (*models.StationPage, error)
+	// ListStationSummaries returns every station matching q, ignoring its
+	// limit and cursor, with channel lists and availability for export.
+	ListStationSummaries(q StationQuery) ([]models.StationSummary, error)
+	// ClusterStations groups the stations inside box into grid cells sized
+	// for map tiles at zoom.
+	ClusterStations(box GeoFilter, zoom int) ([]models.StationCluster, error)
 	GetStation(id int64) (*models.StationDetail, error)
 	DeleteStation(id int64) error
 	ImportStations(sourceID int64, channels []models.ImportChannel) error
 	ListNetworks() ([]models.Network, error)
 	LookupChannelIDs(sourceID int64, networkCode, stationCode string) (map[string]int64, error)
 	ListNetworksBySource(sourceID int64) ([]models.Network, error)
-	ListStationsBySource(sourceID int64, networkCode string, limit, offset int) ([]models.Station, int64, error)
+	// ListUniqueSourceNetworks returns the networks of enabled sources.
 	ListUniqueSourceNetworks() ([]models.SourceNetwork, error)
+	// ListChannelEpochs returns the codes and epoch of every channel.
+	ListChannelEpochs() ([]models.ChannelEpoch, error)
+	// DeleteChannels removes the given channels, then any of their stations
+	// and networks left without channels, in one transaction.
+	DeleteChannels(ids []int64) (*models.PruneResult, error)
+	SetRestricted(level string, id int64, restricted bool) error
+}
+
+// SearchStore finds networks, stations and channels by code, site name,
+// network description or sensor, tolerating typos.
+type SearchStore interface {
+	// Search returns up to limit results, best match first.
+	Search(query string, limit int) ([]models.SearchResult, error)
+}
+
+// UserStore manages credentials for the FDSN /queryauth endpoints.
+type UserStore interface {
+	List() ([]models.User, error)
+	Get(username string) (*models.User, error)
+	Upsert(u *models.User) error
+	Delete(username string) error
 }
 
 // AvailabilityItem represents a single availability record for batch operations.
@@ -41,7 +94,48 @@ type AvailabilityStore interface {
 	GetByStationID(stationID int64) ([]models.ChannelAvailability, error)
 }
 
+// EventStore manages events imported from upstream event services.
+type EventStore interface {
+	// ImportEvents upserts events by source and publicID in one
+	// transaction, replacing the origins, magnitudes and picks of events
+	// already stored, and returns how many were inserted and updated.
+	ImportEvents(sourceID int64, events []models.Event) (inserted, updated int, err error)
+	// ListEvents returns the events matching q with the total count.
+	ListEvents(q EventQuery) (*models.EventPage, error)
+	// GetEvent returns an event with its origins, magnitudes and picks.
+	GetEvent(id int64) (*models.Event, error)
+	DeleteEvent(id int64) error
+	// Catalogs and Contributors list the catalogs and contributors of the
+	// events of enabled sources.
+	Catalogs() ([]string, error)
+	Contributors() ([]string, error)
+}
+
+// ExtractionStore records event waveform extraction jobs and their
+// requests.
+type ExtractionStore interface {
+	// Create stores a pending extraction with its requests, setting their
+	// IDs.
+	Create(x *models.Extraction) error
+	// Get returns an extraction with its requests.
+	Get(id int64) (*models.Extraction, error)
+	// List returns the extractions of an event, or of every event if
+	// eventID is zero, newest first.
+	List(eventID int64) ([]models.Extraction, error)
+	// SetStatus moves an extraction to status, recording the bundle size
+	// and error. Done and failed extractions get their finish time.
+	SetStatus(id int64, status string, bytes int64, errMsg string) error
+	// UpdateRequest records the outcome of a request.
+	UpdateRequest(r *models.ExtractionRequest) error
+	Delete(id int64) error
+	// FailInterrupted marks the extractions left pending or running by a
+	// previous process as failed, returning how many there were.
+	FailInterrupted() (int64, error)
+}
+
 // StatsStore provides dashboard statistics.
 type StatsStore interface {
+	// GetStats counts sources, and the networks, stations and channels of
+	// enabled sources: what the FDSN services serve.
 	GetStats() (*models.Stats, error)
 }
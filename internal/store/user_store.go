@@ -0,0 +1,69 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/database"
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+type userStore struct {
+	db *database.DB
+}
+
+// NewUserStore returns a UserStore backed by the SQL database.
+func NewUserStore(db *database.DB) UserStore {
+	return &userStore{db: db}
+}
+
+func (s *userStore) List() ([]models.User, error) {
+	var users []models.User
+	err := s.db.Reader.Select(&users, "SELECT * FROM users ORDER BY username")
+	return users, err
+}
+
+func (s *userStore) Get(username string) (*models.User, error) {
+	var u models.User
+	if err := s.db.Reader.Get(&u, "SELECT * FROM users WHERE username = ?", username); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Upsert creates the user or replaces the credential and network grants of an
+// existing user with the same username.
+func (s *userStore) Upsert(u *models.User) error {
+	if u.Networks == "" {
+		u.Networks = "*"
+	}
+	now := time.Now()
+	err := s.db.Get(&u.ID, `INSERT INTO users (username, ha1, networks, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET ha1 = excluded.ha1, networks = excluded.networks, updated_at = excluded.updated_at
+		RETURNING id`,
+		u.Username, u.HA1, u.Networks, now, now)
+	if err != nil {
+		return fmt.Errorf("upsert user: %w", err)
+	}
+	u.UpdatedAt = now
+	return nil
+}
+
+func (s *userStore) Delete(username string) error {
+	res, err := s.db.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IsNotFound reports whether err means the requested row does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/joescharf/fdsn/internal/models"
+)
+
+func TestUserStore(t *testing.T) {
+	s := NewUserStore(setupStationStore(t).db)
+
+	u := &models.User{Username: "alice", HA1: "abc"}
+	if err := s.Upsert(u); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if u.ID == 0 || u.Networks != "*" {
+		t.Errorf("unexpected user after insert: %+v", u)
+	}
+
+	// Upsert again replaces credential and grants
+	if err := s.Upsert(&models.User{Username: "alice", HA1: "def", Networks: "XX,YY"}); err != nil {
+		t.Fatalf("Upsert update: %v", err)
+	}
+	got, err := s.Get("alice")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.HA1 != "def" || got.Networks != "XX,YY" {
+		t.Errorf("got %+v", got)
+	}
+
+	users, _ := s.List()
+	if len(users) != 1 {
+		t.Errorf("expected 1 user, got %d", len(users))
+	}
+
+	if err := s.Delete("alice"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete("alice"); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package traveltime
+
+import "math"
+
+// layer is a depth range of a 1D model in which velocities are
+// polynomials in the normalised radius x = r / EarthRadius.
+type layer struct {
+	top, bottom float64   // depth in km
+	vp, vs      []float64 // polynomial coefficients, lowest order first
+}
+
+// iasp91 is the IASP91 model of Kennett and Engdahl (1991).
+var iasp91 = []layer{
+	{0, 20, []float64{5.8}, []float64{3.36}},
+	{20, 35, []float64{6.5}, []float64{3.75}},
+	{35, 120, []float64{8.78541, -0.74953}, []float64{6.706231, -2.248585}},
+	{120, 210, []float64{25.41389, -17.69722}, []float64{5.75020, -1.27420}},
+	{210, 410, []float64{30.78765, -23.25415}, []float64{15.24213, -11.08552}},
+	{410, 660, []float64{29.38896, -21.40656}, []float64{17.70732, -13.50652}},
+	{660, 760, []float64{25.96984, -16.93412}, []float64{20.76890, -16.53147}},
+	{760, 2740, []float64{25.1486, -41.1538, 51.9932, -26.6083}, []float64{12.9303, -21.2590, 27.8988, -14.1080}},
+	{2740, 2889, []float64{14.49470, -1.47089}, []float64{8.16616, -1.58206}},
+	{2889, 5153.9, []float64{10.03904, 3.75665, -13.67046}, []float64{0}},
+	{5153.9, EarthRadius, []float64{11.24094, 0, -4.09689}, []float64{3.56454, 0, -3.45241}},
+}
+
+// EarthRadius is the radius of the IASP91 Earth in km.
+const EarthRadius = 6371.0
+
+// cmbDepth is the depth of the core-mantle boundary in km.
+const cmbDepth = 2889.0
+
+func polynomial(c []float64, x float64) float64 {
+	v := 0.0
+	for i := len(c) - 1; i >= 0; i-- {
+		v = v*x + c[i]
+	}
+	return v
+}
+
+// shell is a spherical shell of constant velocity, in which rays are
+// straight lines.
+type shell struct {
+	rTop, rBottom float64 // km
+	v             float64 // km/s
+	region        region
+}
+
+type region int
+
+const (
+	mantle region = iota
+	outerCore
+	innerCore
+)
+
+// shellThickness is the thickness in km of the constant-velocity shells the
+// model is divided into.
+const shellThickness = 2.0
+
+// shells divides the model into thin constant-velocity shells for wave type
+// s (P or S), with a boundary at the source depth. S shells stop at the
+// core-mantle boundary.
+func shells(s wave, sourceDepth float64) []shell {
+	var out []shell
+	for i, l := range iasp91 {
+		reg := mantle
+		switch {
+		case i == len(iasp91)-1:
+			reg = innerCore
+		case l.top >= cmbDepth:
+			reg = outerCore
+		}
+		if s == waveS && reg != mantle {
+			break
+		}
+		coef := l.vp
+		if s == waveS {
+			coef = l.vs
+		}
+		bounds := []float64{l.top}
+		if sourceDepth > l.top && sourceDepth < l.bottom {
+			bounds = append(bounds, sourceDepth)
+		}
+		bounds = append(bounds, l.bottom)
+		for j := 0; j+1 < len(bounds); j++ {
+			top, bottom := bounds[j], bounds[j+1]
+			n := int(math.Ceil((bottom - top) / shellThickness))
+			for k := range n {
+				dTop := top + (bottom-top)*float64(k)/float64(n)
+				dBottom := top + (bottom-top)*float64(k+1)/float64(n)
+				mid := EarthRadius - (dTop+dBottom)/2
+				out = append(out, shell{
+					rTop:    EarthRadius - dTop,
+					rBottom: EarthRadius - dBottom,
+					v:       polynomial(coef, mid/EarthRadius),
+					region:  reg,
+				})
+			}
+		}
+	}
+	return out
+}
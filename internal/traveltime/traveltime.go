@@ -0,0 +1,283 @@
+// Package traveltime predicts seismic phase arrival times in the IASP91
+// 1D Earth model. Body waves are traced through the model divided into thin
+// constant-velocity spherical shells, which is accurate to about a second;
+// surface waves travel at fixed group velocities. It is meant for choosing
+// waveform windows and drawing phase markers, not for location.
+package traveltime
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Arrival is a predicted phase arrival. Time is in seconds after the origin
+// time; RayParam is the ray parameter in seconds per degree, zero for
+// surface waves.
+type Arrival struct {
+	Phase    string  `json:"phase"`
+	Time     float64 `json:"time"`
+	RayParam float64 `json:"ray_param"`
+}
+
+// Group velocities in km/s of the Love (LQ) and Rayleigh (LR) surface wave
+// arrivals.
+const (
+	LoveVelocity     = 4.4
+	RayleighVelocity = 3.5
+)
+
+type wave int
+
+const (
+	waveP wave = iota
+	waveS
+)
+
+// ray is one traced ray: its ray parameter in s/rad, epicentral distance in
+// radians and travel time in seconds, and the branch it belongs to.
+type ray struct {
+	p, dist, time float64
+	up            bool // left the source upwards
+	region        region
+}
+
+// branchGap is the largest distance in radians between neighbouring rays
+// of a branch; larger gaps are discontinuities, not a branch to
+// interpolate along.
+var branchGap = 2 * math.Pi / 180
+
+// table holds the rays traced for one wave type and source depth, sorted by
+// ray parameter.
+type table struct {
+	wave  wave
+	rays  []ray
+	graze *ray // deepest mantle ray, continued as the diffracted phase
+}
+
+var (
+	tablesMu sync.Mutex
+	tables   = map[tableKey]*table{}
+)
+
+type tableKey struct {
+	wave  wave
+	depth int64 // 0.1 km
+}
+
+// lookup returns the ray table of w for a source at depth km, tracing it
+// on first use.
+func lookup(w wave, depth float64) *table {
+	depth = math.Min(math.Max(depth, 0), cmbDepth-1)
+	key := tableKey{w, int64(math.Round(depth * 10))}
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+	if t, ok := tables[key]; ok {
+		return t
+	}
+	t := trace(w, float64(key.depth)/10)
+	tables[key] = t
+	return t
+}
+
+// trace shoots rays from a source at depth km over the range of ray
+// parameters: an even grid, plus the values grazing each velocity jump.
+func trace(w wave, depth float64) *table {
+	sh := shells(w, depth)
+	src := 0 // index of the first shell below the source
+	for src < len(sh) && sh[src].rTop > EarthRadius-depth+1e-9 {
+		src++
+	}
+
+	pMax := (EarthRadius - depth) / sh[src].v
+	if src > 0 {
+		pMax = math.Max(pMax, (EarthRadius-depth)/sh[src-1].v)
+	}
+	// Rays grazing a velocity jump bound the branches on either side of it
+	var ps []float64
+	for i := 1; i < len(sh); i++ {
+		if math.Abs(sh[i].v-sh[i-1].v) < 0.01 {
+			continue
+		}
+		for _, p := range []float64{sh[i].rTop / sh[i-1].v, sh[i].rTop / sh[i].v} {
+			if p < pMax {
+				ps = append(ps, p, p*(1-1e-9))
+			}
+		}
+	}
+	const grid = 3000
+	for i := range grid {
+		ps = append(ps, pMax*float64(i)/grid)
+	}
+	// Near-horizontal rays cover the first degrees from the source
+	for k := 4.0; k <= 8; k++ {
+		ps = append(ps, pMax*(1-math.Pow(10, -k)))
+	}
+	sort.Float64s(ps)
+
+	t := &table{wave: w}
+	var up []ray
+	for i, p := range ps {
+		if i > 0 && p == ps[i-1] {
+			continue
+		}
+		above, okAbove := leg(sh[:src], p, false)
+		if !okAbove {
+			continue
+		}
+		if depth > 0 && p < (EarthRadius-depth)/sh[src-1].v {
+			up = append(up, ray{p: p, dist: above.dist, time: above.time, up: true, region: mantle})
+		}
+		if p >= (EarthRadius-depth)/sh[src].v {
+			continue
+		}
+		below, ok := leg(sh[src:], p, true)
+		if !ok {
+			continue
+		}
+		t.rays = append(t.rays, ray{p: p, dist: above.dist + 2*below.dist, time: above.time + 2*below.time, region: below.region})
+	}
+	if depth == 0 {
+		// The horizontal ray, arriving at the source itself
+		t.rays = append(t.rays, ray{p: pMax})
+	}
+	// Keep each branch's rays together, in ray parameter order
+	t.rays = append(t.rays, up...)
+
+	for i := range t.rays {
+		r := &t.rays[i]
+		if !r.up && r.region == mantle && (t.graze == nil || r.dist > t.graze.dist) {
+			t.graze = r
+		}
+	}
+	return t
+}
+
+// legResult is the distance and time a ray covers crossing a run of
+// shells, and the deepest region it reached.
+type legResult struct {
+	dist, time float64
+	region     region
+}
+
+// leg traces a ray of parameter p through shells from the top down. If turn
+// is set, the ray turns (or reflects) within the shells and the result is
+// the one-way path down to the turning point; otherwise it must cross all
+// of them, and ok is false if it cannot.
+func leg(shells []shell, p float64, turn bool) (legResult, bool) {
+	var res legResult
+	for i, s := range shells {
+		b := p * s.v // distance of the straight ray from the centre
+		if b >= s.rTop {
+			// Reflected at the top of this shell
+			return res, turn && i > 0
+		}
+		res.region = s.region
+		if b >= s.rBottom {
+			// Turns within this shell
+			res.dist += math.Acos(b / s.rTop)
+			res.time += math.Sqrt(s.rTop*s.rTop-b*b) / s.v
+			return res, turn
+		}
+		res.dist += math.Acos(b/s.rTop) - math.Acos(b/s.rBottom)
+		res.time += (math.Sqrt(s.rTop*s.rTop-b*b) - math.Sqrt(s.rBottom*s.rBottom-b*b)) / s.v
+	}
+	// Crossed every shell: without turning, only legal for the leg above
+	// the source.
+	return res, !turn || len(shells) == 0
+}
+
+// first returns the earliest arrival at distance dist radians, considering
+// every branch of the table and, past the deepest mantle ray, the phase
+// diffracted along the core-mantle boundary.
+func (t *table) first(dist float64) (Arrival, bool) {
+	var best Arrival
+	found := false
+	consider := func(phase string, time, p float64) {
+		if !found || time < best.Time {
+			best = Arrival{Phase: phase, Time: time, RayParam: p * math.Pi / 180}
+			found = true
+		}
+	}
+	// Rays past 180° arrive from the other side
+	for _, target := range []float64{dist, 2*math.Pi - dist} {
+		for i := 0; i+1 < len(t.rays); i++ {
+			a, b := t.rays[i], t.rays[i+1]
+			if a.up != b.up || a.region != b.region || math.Abs(a.dist-b.dist) > branchGap {
+				continue
+			}
+			if (target < a.dist) == (target < b.dist) && target != a.dist {
+				continue
+			}
+			f := 0.0
+			if b.dist != a.dist {
+				f = (target - a.dist) / (b.dist - a.dist)
+			}
+			consider(t.phase(a), a.time+f*(b.time-a.time), a.p+f*(b.p-a.p))
+		}
+	}
+	if g := t.graze; g != nil && dist > g.dist {
+		name := "Pdiff"
+		if t.wave == waveS {
+			name = "Sdiff"
+		}
+		consider(name, g.time+g.p*(dist-g.dist), g.p)
+	}
+	return best, found
+}
+
+func (t *table) phase(r ray) string {
+	name := "P"
+	if t.wave == waveS {
+		name = "S"
+	}
+	switch {
+	case r.up:
+		return map[string]string{"P": "p", "S": "s"}[name]
+	case r.region == outerCore:
+		return "PKP"
+	case r.region == innerCore:
+		return "PKIKP"
+	}
+	return name
+}
+
+// FirstP returns the first compressional arrival (p, P, Pdiff, PKP or
+// PKIKP) at distance degrees from a source at depth km. Depths are clamped
+// to the mantle; an unknown depth may be given as zero.
+func FirstP(distance, depth float64) (Arrival, bool) {
+	return lookup(waveP, depth).first(radians(distance))
+}
+
+// FirstS returns the first shear arrival (s, S or Sdiff). Shear waves
+// converted in the core (SKS) are not modelled.
+func FirstS(distance, depth float64) (Arrival, bool) {
+	return lookup(waveS, depth).first(radians(distance))
+}
+
+// Surface returns the Love (LQ) and Rayleigh (LR) arrivals at distance
+// degrees, travelling along the surface at their group velocities.
+func Surface(distance float64) []Arrival {
+	km := radians(distance) * EarthRadius
+	return []Arrival{
+		{Phase: "LQ", Time: km / LoveVelocity},
+		{Phase: "LR", Time: km / RayleighVelocity},
+	}
+}
+
+// Arrivals returns the first P and S arrivals and the surface waves at
+// distance degrees from a source at depth km, earliest first.
+func Arrivals(distance, depth float64) []Arrival {
+	var out []Arrival
+	if a, ok := FirstP(distance, depth); ok {
+		out = append(out, a)
+	}
+	if a, ok := FirstS(distance, depth); ok {
+		out = append(out, a)
+	}
+	out = append(out, Surface(distance)...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time < out[j].Time })
+	return out
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
@@ -0,0 +1,71 @@
+package traveltime
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference times are from the IASP91 tables; the shell approximation is
+// good to a few seconds.
+func TestFirstArrivals(t *testing.T) {
+	tests := []struct {
+		name            string
+		distance, depth float64
+		first           func(distance, depth float64) (Arrival, bool)
+		phase           string
+		want            float64
+	}{
+		{"P 30 surface", 30, 0, FirstP, "P", 370},
+		{"P 60 surface", 60, 0, FirstP, "P", 605},
+		{"P 90 surface", 90, 0, FirstP, "P", 780},
+		{"S 60 surface", 60, 0, FirstS, "S", 1100},
+		{"P 30 deep", 30, 600, FirstP, "P", 321},
+		{"up-going p", 1, 600, FirstP, "p", 71},
+		{"Pdiff", 110, 0, FirstP, "Pdiff", 871},
+		{"Sdiff", 110, 0, FirstS, "Sdiff", 1606},
+		{"at the source", 0, 0, FirstP, "P", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := tt.first(tt.distance, tt.depth)
+			if !ok {
+				t.Fatal("no arrival")
+			}
+			if a.Phase != tt.phase {
+				t.Errorf("phase = %q, want %q", a.Phase, tt.phase)
+			}
+			if math.Abs(a.Time-tt.want) > 5 {
+				t.Errorf("time = %.1f, want %.0f ± 5", a.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreBranch(t *testing.T) {
+	tb := lookup(waveP, 0)
+	var antipode float64
+	for _, r := range tb.rays {
+		if r.region == innerCore && r.p == 0 {
+			antipode = r.time
+		}
+	}
+	// PKIKP at 180° arrives after about 20 minutes
+	if math.Abs(antipode-1212) > 5 {
+		t.Errorf("PKIKP(180) = %.1f, want 1212 ± 5", antipode)
+	}
+}
+
+func TestArrivalsSorted(t *testing.T) {
+	as := Arrivals(45, 33)
+	if len(as) != 4 {
+		t.Fatalf("got %d arrivals, want 4: %+v", len(as), as)
+	}
+	for i := 1; i < len(as); i++ {
+		if as[i].Time < as[i-1].Time {
+			t.Errorf("arrivals not sorted: %+v", as)
+		}
+	}
+	if as[0].Phase != "P" || as[3].Phase != "LR" {
+		t.Errorf("order = %s … %s, want P … LR", as[0].Phase, as[3].Phase)
+	}
+}
@@ -0,0 +1,578 @@
+// Package wavecache keeps miniSEED fetched from upstream sources on disk, so
+// repeated dataselect and waveform proxy requests for the same stream and
+// time window are answered without another download.
+//
+// The cache is record-aligned: each upstream fetch of one stream and time
+// window is stored as a segment holding the whole records that overlap it.
+// A later request is cut into the parts its stream's segments already cover,
+// answered from their records, and the parts they do not, which alone are
+// fetched. Segments are evicted least recently used first once the cache
+// grows past its size limit.
+package wavecache
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+)
+
+// MaxWindow is the longest stream request the cache serves; longer ones go
+// straight upstream so one bulk download cannot flush the cache.
+const MaxWindow = 24 * time.Hour
+
+// minSegmentSize is the size a segment is accounted at if smaller, so that
+// many small segments still count towards the limit.
+const minSegmentSize = 512
+
+// upstreamTime is the time format of piece bounds sent upstream.
+const upstreamTime = "2006-01-02T15:04:05.000000"
+
+// Fetcher requests streams from one upstream source with a bulk request,
+// returning nil and no error when the source has no data.
+// fdsnclient.Client.FetchMiniSEEDBulk is a Fetcher.
+type Fetcher func(reqs []fdsnclient.StreamRequest) (io.ReadCloser, error)
+
+// Stats reports cache effectiveness. A hit is a stream request answered from
+// the cache alone, a partial hit one that fetched only the missing parts of
+// its window, a miss one that fetched it all. Bypassed requests (wildcards,
+// windows over MaxWindow, or data the cache cannot parse) go straight
+// upstream.
+type Stats struct {
+	Enabled      bool  `json:"enabled"`
+	Hits         int64 `json:"hits"`
+	PartialHits  int64 `json:"partial_hits"`
+	Misses       int64 `json:"misses"`
+	Bypassed     int64 `json:"bypassed"`
+	BytesServed  int64 `json:"bytes_served"`
+	BytesFetched int64 `json:"bytes_fetched"`
+	Evictions    int64 `json:"evictions"`
+	Segments     int   `json:"segments"`
+	SizeBytes    int64 `json:"size_bytes"`
+	MaxBytes     int64 `json:"max_bytes"`
+}
+
+// Cache is an on-disk miniSEED cache. A nil *Cache is valid and caches
+// nothing: Fetch passes every request upstream.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	minAge   time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	segments map[string][]*segment // by segment key prefix (source/stream), sorted by start
+	lru      *list.List            // of *segment, most recently used first
+	size     int64
+	stats    Stats
+}
+
+// segment is the records of one stream overlapping [start, end], as fetched
+// from one source.
+type segment struct {
+	stream     string // "<source id>/<NET.STA.LOC.CHA>"
+	start, end time.Time
+	size       int64
+	elem       *list.Element
+}
+
+// Open returns a cache storing up to maxBytes of miniSEED under dir, loading
+// the segments already there. Data newer than minAge is never stored, since
+// upstream may still be receiving it.
+func Open(dir string, maxBytes int64, minAge time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create waveform cache directory: %w", err)
+	}
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		minAge:   minAge,
+		now:      time.Now,
+		segments: make(map[string][]*segment),
+		lru:      list.New(),
+	}
+
+	type found struct {
+		seg     *segment
+		touched time.Time
+	}
+	var all []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		seg, ok := c.parsePath(path)
+		if !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seg.size = diskSize(info.Size())
+		all = append(all, found{seg, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load waveform cache: %w", err)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].touched.After(all[j].touched) })
+	for _, f := range all {
+		f.seg.elem = c.lru.PushBack(f.seg)
+		c.insert(f.seg)
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Stats returns the cache's counters and current size.
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Enabled = true
+	s.Segments = c.lru.Len()
+	s.SizeBytes = c.size
+	s.MaxBytes = c.maxBytes
+	return s
+}
+
+// Clear removes every cached segment. The counters are kept.
+func (c *Cache) Clear() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		c.remove(e.Value.(*segment))
+		e = next
+	}
+	return nil
+}
+
+// Fetch writes the miniSEED for reqs from source sourceID to w. Each stream
+// request with literal codes and a window up to MaxWindow is answered from
+// the cache where it can; the remaining pieces and all other requests are
+// fetched upstream with a single call to fetch, and the pieces older than
+// the cache's minimum age are stored. Cached streams are written first, each
+// in time order, followed by anything else upstream returned.
+func (c *Cache) Fetch(sourceID int64, reqs []fdsnclient.StreamRequest, fetch Fetcher, w io.Writer) error {
+	if c == nil {
+		return passthrough(reqs, fetch, w)
+	}
+
+	type wanted struct {
+		req        fdsnclient.StreamRequest
+		stream     string
+		start, end time.Time
+		segs       []*segment // overlapping segments when the request was made
+		records    []Record
+	}
+	var (
+		cached   []*wanted
+		upstream []fdsnclient.StreamRequest
+		pieces   []*wanted // upstream requests the cache will store, parallel to upstream where set
+	)
+	cutoff := c.now().Add(-c.minAge).Truncate(time.Second)
+
+	// Snapshot the segments each request needs under the lock, read them
+	// without it so a slow disk does not hold up other requests, then take
+	// it again to record what was used.
+	c.mu.Lock()
+	for _, r := range reqs {
+		start, end, ok := cacheable(r)
+		if !ok {
+			c.stats.Bypassed++
+			upstream = append(upstream, r)
+			pieces = append(pieces, nil)
+			continue
+		}
+		if r.Location == "--" {
+			r.Location = ""
+		}
+		stream := segmentStream(sourceID, streamID(r.Network, r.Station, r.Location, r.Channel))
+		cached = append(cached, &wanted{req: r, stream: stream, start: start, end: end, segs: c.overlapping(stream, start, end)})
+	}
+	c.mu.Unlock()
+
+	missing := make([][][2]time.Time, len(cached))
+	var used, bad []*segment
+	for i, want := range cached {
+		records, miss, u, b := c.lookup(want.segs, want.start, want.end)
+		want.records, missing[i] = records, miss
+		used, bad = append(used, u...), append(bad, b...)
+	}
+
+	c.mu.Lock()
+	for _, seg := range bad {
+		c.remove(seg)
+	}
+	for _, seg := range used {
+		c.touch(seg)
+	}
+	for i, want := range cached {
+		miss := missing[i]
+		for _, m := range miss {
+			for _, p := range splitAt(m, cutoff) {
+				sr := want.req
+				sr.StartTime, sr.EndTime = p[0].Format(upstreamTime), p[1].Format(upstreamTime)
+				upstream = append(upstream, sr)
+				if p[1].After(cutoff) {
+					pieces = append(pieces, nil)
+				} else {
+					pieces = append(pieces, &wanted{stream: want.stream, start: p[0], end: p[1]})
+				}
+			}
+		}
+		switch {
+		case len(miss) == 0:
+			c.stats.Hits++
+		case len(miss) == 1 && miss[0][0].Equal(want.start) && miss[0][1].Equal(want.end):
+			c.stats.Misses++
+		default:
+			c.stats.PartialHits++
+		}
+		for _, rec := range want.records {
+			c.stats.BytesServed += int64(len(rec.Data))
+		}
+	}
+	c.mu.Unlock()
+
+	var fetched []byte
+	if len(upstream) > 0 {
+		body, err := fetch(upstream)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			fetched, err = io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		c.mu.Lock()
+		c.stats.BytesFetched += int64(len(fetched))
+		c.mu.Unlock()
+	}
+
+	records, err := ParseRecords(fetched)
+	if err != nil {
+		// Nothing can be stored or merged; send what is cached and the
+		// upstream response as it came.
+		log.Warn().Err(err).Int64("source", sourceID).Msg("waveform cache cannot parse upstream data")
+		for _, want := range cached {
+			if err := writeRecords(w, want.records, nil); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(fetched)
+		return err
+	}
+
+	for _, p := range pieces {
+		if p == nil {
+			continue
+		}
+		stream := p.stream[strings.IndexByte(p.stream, '/')+1:]
+		var data bytes.Buffer
+		for _, rec := range records {
+			if rec.Stream() == stream && rec.overlaps(p.start, p.end) {
+				data.Write(rec.Data)
+			}
+		}
+		if data.Len() == 0 {
+			// Upstream may only be missing the data for now; ask again
+			// next time rather than remember the gap.
+			continue
+		}
+		if err := c.store(p.stream, p.start, p.end, data.Bytes()); err != nil {
+			log.Warn().Err(err).Str("stream", p.stream).Msg("waveform cache write failed")
+		}
+	}
+
+	written := make(map[string]bool)
+	for _, want := range cached {
+		stream := want.stream[strings.IndexByte(want.stream, '/')+1:]
+		recs := want.records
+		for _, rec := range records {
+			if rec.Stream() == stream && rec.overlaps(want.start, want.end) {
+				recs = append(recs, rec)
+			}
+		}
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Start.Before(recs[j].Start) })
+		if err := writeRecords(w, recs, written); err != nil {
+			return err
+		}
+	}
+	return writeRecords(w, records, written)
+}
+
+// passthrough fetches reqs upstream and copies the response to w.
+func passthrough(reqs []fdsnclient.StreamRequest, fetch Fetcher, w io.Writer) error {
+	body, err := fetch(reqs)
+	if err != nil || body == nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// writeRecords writes each record not yet in written (if non-nil) to w and
+// adds it there.
+func writeRecords(w io.Writer, recs []Record, written map[string]bool) error {
+	for _, rec := range recs {
+		if written != nil {
+			k := rec.key()
+			if written[k] {
+				continue
+			}
+			written[k] = true
+		}
+		if _, err := w.Write(rec.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheable reports whether r selects one literal stream over a parseable
+// window no longer than MaxWindow, returning the window.
+func cacheable(r fdsnclient.StreamRequest) (start, end time.Time, ok bool) {
+	loc := r.Location
+	if loc == "--" {
+		loc = ""
+	}
+	if r.Network == "" || r.Station == "" || r.Channel == "" ||
+		!validCode(r.Network) || !validCode(r.Station) || !validCode(loc) || !validCode(r.Channel) {
+		return start, end, false
+	}
+	start, err1 := parseTime(r.StartTime)
+	end, err2 := parseTime(r.EndTime)
+	if err1 != nil || err2 != nil || !end.After(start) || end.Sub(start) > MaxWindow {
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// parseTime reads an FDSN time parameter.
+func parseTime(s string) (time.Time, error) {
+	for _, f := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(f, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// splitAt cuts the interval iv at t if t falls inside it.
+func splitAt(iv [2]time.Time, t time.Time) [][2]time.Time {
+	if !t.After(iv[0]) || !t.Before(iv[1]) {
+		return [][2]time.Time{iv}
+	}
+	return [][2]time.Time{{iv[0], t}, {t, iv[1]}}
+}
+
+// overlapping returns the segments of stream overlapping [start, end] in
+// start order. c.mu must be held.
+func (c *Cache) overlapping(stream string, start, end time.Time) []*segment {
+	var segs []*segment
+	for _, seg := range c.segments[stream] {
+		if !seg.end.Before(start) && !seg.start.After(end) {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// lookup reads segs, the segments of one stream overlapping [start, end] in
+// start order, and returns their records overlapping the window, the parts
+// of the window they do not cover, the segments read and those that could
+// not be, which should be removed. It does not need c.mu; a segment evicted
+// meanwhile is simply treated as missing.
+func (c *Cache) lookup(segs []*segment, start, end time.Time) (records []Record, missing [][2]time.Time, used, bad []*segment) {
+	at := start
+	for _, seg := range segs {
+		data, err := os.ReadFile(c.path(seg))
+		if err == nil {
+			var recs []Record
+			if recs, err = ParseRecords(data); err == nil {
+				for _, rec := range recs {
+					if rec.overlaps(start, end) {
+						records = append(records, rec)
+					}
+				}
+			}
+		}
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn().Err(err).Str("stream", seg.stream).Msg("waveform cache read failed")
+			}
+			bad = append(bad, seg)
+			continue
+		}
+		used = append(used, seg)
+		if seg.start.After(at) {
+			missing = append(missing, [2]time.Time{at, seg.start})
+		}
+		if seg.end.After(at) {
+			at = seg.end
+		}
+	}
+	if at.Before(end) {
+		missing = append(missing, [2]time.Time{at, end})
+	}
+	return records, missing, used, bad
+}
+
+// store writes a segment of stream covering [start, end] unless the cache
+// already covers that window, then evicts down to the size limit.
+func (c *Cache) store(stream string, start, end time.Time, data []byte) error {
+	seg := &segment{stream: stream, start: start, end: end, size: diskSize(int64(len(data)))}
+	path := c.path(seg)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.segments[stream] {
+		if !s.start.After(start) && !s.end.Before(end) {
+			if c.path(s) != path {
+				_ = os.Remove(path)
+			}
+			return nil // a concurrent request stored it first
+		}
+	}
+	seg.elem = c.lru.PushFront(seg)
+	c.insert(seg)
+	c.evict()
+	return nil
+}
+
+// insert adds seg to its stream's sorted segment list and the cache size.
+// c.mu must be held (or the cache not yet shared).
+func (c *Cache) insert(seg *segment) {
+	segs := c.segments[seg.stream]
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].start.After(seg.start) })
+	segs = append(segs, nil)
+	copy(segs[i+1:], segs[i:])
+	segs[i] = seg
+	c.segments[seg.stream] = segs
+	c.size += seg.size
+}
+
+// remove deletes seg from the index and the disk, unless it was already
+// removed. c.mu must be held.
+func (c *Cache) remove(seg *segment) {
+	if seg.elem == nil {
+		return
+	}
+	segs := c.segments[seg.stream]
+	for i, s := range segs {
+		if s == seg {
+			c.segments[seg.stream] = append(segs[:i], segs[i+1:]...)
+			break
+		}
+	}
+	if len(c.segments[seg.stream]) == 0 {
+		delete(c.segments, seg.stream)
+	}
+	c.lru.Remove(seg.elem)
+	seg.elem = nil
+	c.size -= seg.size
+	if err := os.Remove(c.path(seg)); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("remove cached waveform segment")
+	}
+}
+
+// touch marks seg most recently used, recording it in the file's
+// modification time so the order survives a restart. Segments removed
+// meanwhile are skipped. c.mu must be held.
+func (c *Cache) touch(seg *segment) {
+	if seg.elem == nil {
+		return
+	}
+	c.lru.MoveToFront(seg.elem)
+	now := c.now()
+	_ = os.Chtimes(c.path(seg), now, now)
+}
+
+// evict removes least recently used segments until the cache fits its size
+// limit. c.mu must be held.
+func (c *Cache) evict() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back().Value.(*segment))
+		c.stats.Evictions++
+	}
+}
+
+// diskSize is the size a segment of n bytes counts towards the limit.
+func diskSize(n int64) int64 {
+	return max(n, minSegmentSize)
+}
+
+// segmentStream is the index key of a stream from one source.
+func segmentStream(sourceID int64, stream string) string {
+	return strconv.FormatInt(sourceID, 10) + "/" + stream
+}
+
+// path is <dir>/<source id>/<NET.STA.LOC.CHA>/<start>_<end>.mseed, with the
+// bounds in Unix nanoseconds.
+func (c *Cache) path(seg *segment) string {
+	name := fmt.Sprintf("%d_%d.mseed", seg.start.UnixNano(), seg.end.UnixNano())
+	return filepath.Join(c.dir, filepath.FromSlash(seg.stream), name)
+}
+
+// parsePath is the inverse of path.
+func (c *Cache) parsePath(path string) (*segment, bool) {
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		return nil, false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".mseed") {
+		return nil, false
+	}
+	if _, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return nil, false
+	}
+	bounds := strings.Split(strings.TrimSuffix(parts[2], ".mseed"), "_")
+	if len(bounds) != 2 {
+		return nil, false
+	}
+	s, err1 := strconv.ParseInt(bounds[0], 10, 64)
+	e, err2 := strconv.ParseInt(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil, false
+	}
+	return &segment{stream: parts[0] + "/" + parts[1], start: time.Unix(0, s).UTC(), end: time.Unix(0, e).UTC()}, true
+}
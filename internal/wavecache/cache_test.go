@@ -0,0 +1,245 @@
+package wavecache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joescharf/fdsn/internal/fdsnclient"
+)
+
+var t0 = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// upstream serves IU.ANMO.00.BHZ as one 10-sample record every 10 s from t0
+// and records the requests it receives.
+type upstream struct {
+	calls [][]fdsnclient.StreamRequest
+}
+
+func (u *upstream) fetch(reqs []fdsnclient.StreamRequest) (io.ReadCloser, error) {
+	u.calls = append(u.calls, reqs)
+	var out bytes.Buffer
+	for _, r := range reqs {
+		if r.Station != "ANMO" && r.Station != "*" {
+			continue
+		}
+		start, _ := parseTime(r.StartTime)
+		end, _ := parseTime(r.EndTime)
+		for i := 0; i < 360; i++ {
+			rs := t0.Add(time.Duration(i) * 10 * time.Second)
+			if !rs.After(end) && !rs.Add(9*time.Second).Before(start) {
+				out.Write(makeRecord(binary.BigEndian, "IU", "ANMO", "00", "BHZ", rs, 10))
+			}
+		}
+	}
+	if out.Len() == 0 {
+		return nil, nil
+	}
+	return io.NopCloser(&out), nil
+}
+
+func req(start, end time.Duration) fdsnclient.StreamRequest {
+	return fdsnclient.StreamRequest{
+		Network: "IU", Station: "ANMO", Location: "00", Channel: "BHZ",
+		StartTime: t0.Add(start).Format(time.RFC3339), EndTime: t0.Add(end).Format(time.RFC3339),
+	}
+}
+
+func openTest(t *testing.T, dir string, maxBytes int64) *Cache {
+	t.Helper()
+	c, err := Open(dir, maxBytes, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	c.now = func() time.Time { return t0.Add(48 * time.Hour) }
+	return c
+}
+
+// fetchRecords runs Fetch and returns the start offsets of the records written.
+func fetchRecords(t *testing.T, c *Cache, u *upstream, reqs ...fdsnclient.StreamRequest) []time.Duration {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.Fetch(1, reqs, u.fetch, &buf); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	recs, err := ParseRecords(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseRecords: %v", err)
+	}
+	var out []time.Duration
+	for _, r := range recs {
+		out = append(out, r.Start.Sub(t0))
+	}
+	return out
+}
+
+func offsets(from, to time.Duration) []time.Duration {
+	var out []time.Duration
+	for d := from; d <= to; d += 10 * time.Second {
+		out = append(out, d)
+	}
+	return out
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFetchHitAndPartial(t *testing.T) {
+	c := openTest(t, t.TempDir(), 1<<20)
+	u := &upstream{}
+
+	want := offsets(0, 60*time.Second)
+	if got := fetchRecords(t, c, u, req(0, time.Minute)); !equalDurations(got, want) {
+		t.Fatalf("miss: records %v, want %v", got, want)
+	}
+	if got := fetchRecords(t, c, u, req(0, time.Minute)); !equalDurations(got, want) {
+		t.Fatalf("hit: records %v, want %v", got, want)
+	}
+	if len(u.calls) != 1 {
+		t.Fatalf("upstream called %d times, want 1", len(u.calls))
+	}
+
+	// Overlapping request: only the uncovered minute goes upstream.
+	want = offsets(30*time.Second, 120*time.Second)
+	if got := fetchRecords(t, c, u, req(35*time.Second, 2*time.Minute)); !equalDurations(got, want) {
+		t.Fatalf("partial: records %v, want %v", got, want)
+	}
+	if len(u.calls) != 2 || len(u.calls[1]) != 1 {
+		t.Fatalf("upstream calls %v, want one more single-stream request", u.calls)
+	}
+	if got := u.calls[1][0]; got.StartTime != "2024-03-01T00:01:00.000000" || got.EndTime != "2024-03-01T00:02:00.000000" {
+		t.Errorf("missing piece requested as %s - %s", got.StartTime, got.EndTime)
+	}
+
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 1 || s.PartialHits != 1 || s.Segments != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+	if s.BytesFetched != int64(7+7)*512 {
+		t.Errorf("bytes fetched = %d", s.BytesFetched)
+	}
+}
+
+func TestFetchRecentDataNotStored(t *testing.T) {
+	c := openTest(t, t.TempDir(), 1<<20)
+	c.now = func() time.Time { return t0.Add(time.Hour + 30*time.Second) }
+	u := &upstream{}
+
+	fetchRecords(t, c, u, req(0, time.Minute))
+	fetchRecords(t, c, u, req(0, time.Minute))
+	if len(u.calls) != 2 {
+		t.Fatalf("upstream called %d times, want 2", len(u.calls))
+	}
+	// The window was split at the cutoff and only the older part stored.
+	if got := len(u.calls[1]); got != 1 {
+		t.Errorf("second fetch sent %d requests, want 1", got)
+	}
+	if s := c.Stats(); s.Segments != 1 {
+		t.Errorf("segments = %d, want 1", s.Segments)
+	}
+}
+
+func TestFetchEmptyNotStored(t *testing.T) {
+	c := openTest(t, t.TempDir(), 1<<20)
+	u := &upstream{}
+
+	// Upstream has nothing after the first hour
+	fetchRecords(t, c, u, req(2*time.Hour, 2*time.Hour+time.Minute))
+	fetchRecords(t, c, u, req(2*time.Hour, 2*time.Hour+time.Minute))
+	if len(u.calls) != 2 {
+		t.Errorf("upstream called %d times, want 2", len(u.calls))
+	}
+	if s := c.Stats(); s.Segments != 0 || s.Misses != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestFetchMissingSegmentFile(t *testing.T) {
+	dir := t.TempDir()
+	c := openTest(t, dir, 1<<20)
+	u := &upstream{}
+
+	fetchRecords(t, c, u, req(0, time.Minute))
+	c.mu.Lock()
+	seg := c.segments["1/IU.ANMO.00.BHZ"][0]
+	c.mu.Unlock()
+	if err := os.Remove(c.path(seg)); err != nil {
+		t.Fatalf("remove segment: %v", err)
+	}
+
+	want := offsets(0, 60*time.Second)
+	if got := fetchRecords(t, c, u, req(0, time.Minute)); !equalDurations(got, want) {
+		t.Fatalf("records %v, want %v", got, want)
+	}
+	if len(u.calls) != 2 {
+		t.Errorf("upstream called %d times, want 2", len(u.calls))
+	}
+	if s := c.Stats(); s.Segments != 1 || s.SizeBytes != 7*512 {
+		t.Errorf("stats = %+v, want the segment stored again", s)
+	}
+}
+
+func TestFetchBypass(t *testing.T) {
+	c := openTest(t, t.TempDir(), 1<<20)
+	u := &upstream{}
+	wild := req(0, time.Minute)
+	wild.Station = "*"
+	long := req(0, 25*time.Hour)
+
+	fetchRecords(t, c, u, wild)
+	fetchRecords(t, c, u, long)
+	if s := c.Stats(); s.Bypassed != 2 || s.Segments != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	var nilCache *Cache
+	if got := fetchRecords(t, nilCache, u, req(0, time.Minute)); len(got) != 7 {
+		t.Errorf("nil cache returned %d records, want 7", len(got))
+	}
+}
+
+func TestEvictionAndReload(t *testing.T) {
+	dir := t.TempDir()
+	// Room for two one-minute segments of 7 records each
+	c := openTest(t, dir, 2*7*512)
+	u := &upstream{}
+
+	fetchRecords(t, c, u, req(0, time.Minute))                 // A
+	fetchRecords(t, c, u, req(10*time.Minute, 11*time.Minute)) // B
+	fetchRecords(t, c, u, req(0, time.Minute))                 // touch A
+	fetchRecords(t, c, u, req(20*time.Minute, 21*time.Minute)) // C evicts B
+	if s := c.Stats(); s.Evictions != 1 || s.Segments != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	c = openTest(t, dir, 2*7*512)
+	calls := len(u.calls)
+	fetchRecords(t, c, u, req(0, time.Minute))
+	fetchRecords(t, c, u, req(20*time.Minute, 21*time.Minute))
+	if len(u.calls) != calls {
+		t.Errorf("reloaded cache went upstream for segments it holds")
+	}
+	fetchRecords(t, c, u, req(10*time.Minute, 11*time.Minute))
+	if len(u.calls) != calls+1 {
+		t.Errorf("evicted segment was served from the cache")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if s := c.Stats(); s.Segments != 0 || s.SizeBytes != 0 {
+		t.Errorf("after Clear: %+v", s)
+	}
+}
@@ -0,0 +1,49 @@
+package wavecache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	requestsDesc = prometheus.NewDesc("fdsn_waveform_cache_requests_total",
+		"Stream requests to the waveform cache, by result: hit, partial (part of the window cached), miss or bypass (not cacheable).",
+		[]string{"result"}, nil)
+	bytesDesc = prometheus.NewDesc("fdsn_waveform_cache_bytes_total",
+		"miniSEED bytes served from the cache and fetched upstream through it.",
+		[]string{"origin"}, nil)
+	evictionsDesc = prometheus.NewDesc("fdsn_waveform_cache_evictions_total",
+		"Segments evicted to keep the cache within its size limit.", nil, nil)
+	sizeDesc = prometheus.NewDesc("fdsn_waveform_cache_size_bytes",
+		"Size of the cached miniSEED.", nil, nil)
+	maxSizeDesc = prometheus.NewDesc("fdsn_waveform_cache_max_bytes",
+		"Size limit of the cache.", nil, nil)
+	segmentsDesc = prometheus.NewDesc("fdsn_waveform_cache_segments",
+		"Cached segments.", nil, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (c *Cache) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{requestsDesc, bytesDesc, evictionsDesc, sizeDesc, maxSizeDesc, segmentsDesc} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector, reporting the counters of
+// Stats. The hit ratio is hits over all non-bypassed requests.
+func (c *Cache) Collect(ch chan<- prometheus.Metric) {
+	s := c.Stats()
+	counter := func(d *prometheus.Desc, v int64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), labels...)
+	}
+	gauge := func(d *prometheus.Desc, v int64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v))
+	}
+	counter(requestsDesc, s.Hits, "hit")
+	counter(requestsDesc, s.PartialHits, "partial")
+	counter(requestsDesc, s.Misses, "miss")
+	counter(requestsDesc, s.Bypassed, "bypass")
+	counter(bytesDesc, s.BytesServed, "cache")
+	counter(bytesDesc, s.BytesFetched, "upstream")
+	counter(evictionsDesc, s.Evictions)
+	gauge(sizeDesc, s.SizeBytes)
+	gauge(maxSizeDesc, s.MaxBytes)
+	gauge(segmentsDesc, int64(s.Segments))
+}
@@ -0,0 +1,106 @@
+package wavecache
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// makeRecord builds a 512-byte miniSEED 2 record at 1 sample/s.
+func makeRecord(order binary.ByteOrder, net, sta, loc, cha string, start time.Time, samples int) []byte {
+	b := make([]byte, 512)
+	copy(b, "000001D ")
+	pad := func(s string, n int) string {
+		for len(s) < n {
+			s += " "
+		}
+		return s
+	}
+	copy(b[8:], pad(sta, 5))
+	copy(b[13:], pad(loc, 2))
+	copy(b[15:], pad(cha, 3))
+	copy(b[18:], pad(net, 2))
+	order.PutUint16(b[20:], uint16(start.Year()))
+	order.PutUint16(b[22:], uint16(start.YearDay()))
+	b[24], b[25], b[26] = byte(start.Hour()), byte(start.Minute()), byte(start.Second())
+	order.PutUint16(b[28:], uint16(start.Nanosecond()/100_000))
+	order.PutUint16(b[30:], uint16(samples))
+	order.PutUint16(b[32:], 1)
+	order.PutUint16(b[34:], 1)
+	b[39] = 1
+	order.PutUint16(b[44:], 64)
+	order.PutUint16(b[46:], 48)
+	// Blockette 1000: Steim-2, big-endian data, 2^9 byte records
+	order.PutUint16(b[48:], 1000)
+	b[52], b[53], b[54] = 11, 1, 9
+	return b
+}
+
+func TestParseRecords(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	data := append(makeRecord(binary.BigEndian, "IU", "ANMO", "00", "BHZ", t0, 10),
+		makeRecord(binary.LittleEndian, "IU", "ANMO", "", "LHZ", t0.Add(10*time.Second), 1)...)
+
+	recs, err := ParseRecords(data)
+	if err != nil {
+		t.Fatalf("ParseRecords: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if got := recs[0].Stream(); got != "IU.ANMO.00.BHZ" {
+		t.Errorf("stream = %q", got)
+	}
+	if !recs[0].Start.Equal(t0) || !recs[0].Last.Equal(t0.Add(9*time.Second)) {
+		t.Errorf("span = %v - %v", recs[0].Start, recs[0].Last)
+	}
+	if len(recs[0].Data) != 512 {
+		t.Errorf("record length = %d", len(recs[0].Data))
+	}
+	if got := recs[1].Stream(); got != "IU.ANMO..LHZ" {
+		t.Errorf("little-endian stream = %q", got)
+	}
+	if !recs[1].Start.Equal(t0.Add(10*time.Second)) || !recs[1].Last.Equal(recs[1].Start) {
+		t.Errorf("little-endian span = %v - %v", recs[1].Start, recs[1].Last)
+	}
+}
+
+func TestParseRecordsErrors(t *testing.T) {
+	rec := makeRecord(binary.BigEndian, "IU", "ANMO", "00", "BHZ", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 10)
+
+	noB1000 := append([]byte(nil), rec...)
+	noB1000[39] = 0
+	notMSEED := append([]byte(nil), rec...)
+	notMSEED[6] = 'X'
+
+	for name, data := range map[string][]byte{
+		"truncated":    rec[:300],
+		"no blockette": noB1000,
+		"not miniSEED": notMSEED,
+		"short header": rec[:20],
+		"miniSEED 3":   append([]byte("MS\x03"), rec[3:]...),
+	} {
+		if _, err := ParseRecords(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	tests := []struct {
+		factor, mult int16
+		want         float64
+	}{
+		{40, 1, 40},
+		{20, 5, 100},
+		{1, -10, 0.1},
+		{-10, 1, 0.1},
+		{-10, -10, 0.01},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := sampleRate(tt.factor, tt.mult); got != tt.want {
+			t.Errorf("sampleRate(%d, %d) = %v, want %v", tt.factor, tt.mult, got, tt.want)
+		}
+	}
+}
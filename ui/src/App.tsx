@@ -9,6 +9,7 @@ import { StationsPage } from "@/components/stations/StationsPage";
 import { StationDetail } from "@/components/stations/StationDetail";
 import { MapPage } from "@/components/map/MapPage";
 import { WaveformsPage } from "@/components/waveforms/WaveformsPage";
+import { EventsPage } from "@/components/events/EventsPage";
 import { FdsnTestPage } from "@/components/fdsn/FdsnTestPage";
 import "./index.css";
 
@@ -34,6 +35,7 @@ export function App() {
             <Route path="stations/:id" element={<StationDetail />} />
             <Route path="map" element={<MapPage />} />
             <Route path="waveforms" element={<WaveformsPage />} />
+            <Route path="events" element={<EventsPage />} />
             <Route path="fdsn" element={<FdsnTestPage />} />
           </Route>
         </Routes>
@@ -23,7 +23,7 @@ export function Dashboard() {
       <div>
         <h2 className="text-2xl font-bold tracking-tight">Dashboard</h2>
         <p className="text-muted-foreground">
-          Overview of imported seismic station data
+          Overview of the station data served from enabled sources
         </p>
       </div>
       <div className="grid gap-4 md:grid-cols-2 lg:grid-cols-4">
@@ -43,6 +43,11 @@ export function Dashboard() {
                   {stats?.[card.key] ?? 0}
                 </div>
               )}
+              {card.key === "sources" && stats && (
+                <p className="text-xs text-muted-foreground">
+                  {stats.enabled_sources} enabled
+                </p>
+              )}
             </CardContent>
           </Card>
         ))}
@@ -0,0 +1,235 @@
+import { useState } from "react";
+import { useExtractions, useCreateExtraction, useDeleteExtraction } from "@/hooks/useEvents";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Badge } from "@/components/ui/badge";
+import { Skeleton } from "@/components/ui/skeleton";
+import {
+  Card,
+  CardContent,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/components/ui/select";
+import { Download, PackageOpen, Trash2, Eye } from "lucide-react";
+import { BASE } from "@/lib/api";
+import { toast } from "sonner";
+import type { Extraction, ExtractionParams } from "@/types";
+
+function formatBytes(n: number): string {
+  if (n < 1024) return `${n} B`;
+  if (n < 1024 * 1024) return `${(n / 1024).toFixed(1)} KiB`;
+  return `${(n / 1024 / 1024).toFixed(1)} MiB`;
+}
+
+const statusVariant: Record<Extraction["status"], "default" | "secondary" | "destructive" | "outline"> = {
+  pending: "outline",
+  running: "secondary",
+  done: "default",
+  failed: "destructive",
+};
+
+// EventExtractions starts waveform extractions for an event and lists them
+// with their bundles.
+export function EventExtractions({ eventId }: { eventId: number }) {
+  const [channel, setChannel] = useState("BH?");
+  const [minRadius, setMinRadius] = useState("0");
+  const [maxRadius, setMaxRadius] = useState("10");
+  const [windowMode, setWindowMode] = useState<"phases" | "offsets">("phases");
+  const [before, setBefore] = useState("60");
+  const [after, setAfter] = useState("600");
+
+  const { data: extractions, isLoading } = useExtractions(eventId);
+  const createMutation = useCreateExtraction();
+  const deleteMutation = useDeleteExtraction();
+
+  const params = (dryRun: boolean): ExtractionParams => ({
+    channel,
+    min_radius: Number(minRadius) || 0,
+    max_radius: Number(maxRadius) || 10,
+    window: windowMode,
+    before: Number(before) || 0,
+    after: Number(after) || 0,
+    dry_run: dryRun,
+  });
+
+  const handleCreate = (dryRun: boolean) => {
+    createMutation.mutate(
+      { eventId, params: params(dryRun) },
+      {
+        onSuccess: (x) => {
+          const stations = new Set((x.requests ?? []).map((r) => `${r.network_code}.${r.station_code}`)).size;
+          if (dryRun) {
+            toast.info(`${x.request_count} channels at ${stations} stations would be fetched`);
+          } else {
+            toast.success(`Extraction ${x.id} started: ${x.request_count} channels at ${stations} stations`);
+          }
+        },
+        onError: (err) => toast.error(`Extraction failed: ${err.message}`),
+      }
+    );
+  };
+
+  const handleDelete = (x: Extraction) => {
+    deleteMutation.mutate(x.id, {
+      onSuccess: () => toast.success("Extraction removed"),
+      onError: (err) => toast.error(`Delete failed: ${err.message}`),
+    });
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle className="text-base">Waveform Extractions</CardTitle>
+      </CardHeader>
+      <CardContent className="space-y-4">
+        <div className="flex flex-wrap items-end gap-4">
+          <div className="space-y-1">
+            <Label htmlFor="extract-channel">Channels</Label>
+            <Input
+              id="extract-channel"
+              value={channel}
+              onChange={(e) => setChannel(e.target.value)}
+              className="w-[120px]"
+            />
+          </div>
+          <div className="space-y-1">
+            <Label htmlFor="extract-minradius">Min distance (°)</Label>
+            <Input
+              id="extract-minradius"
+              value={minRadius}
+              onChange={(e) => setMinRadius(e.target.value)}
+              className="w-[100px]"
+            />
+          </div>
+          <div className="space-y-1">
+            <Label htmlFor="extract-maxradius">Max distance (°)</Label>
+            <Input
+              id="extract-maxradius"
+              value={maxRadius}
+              onChange={(e) => setMaxRadius(e.target.value)}
+              className="w-[100px]"
+            />
+          </div>
+          <div className="space-y-1">
+            <Label>Window</Label>
+            <Select value={windowMode} onValueChange={(v) => setWindowMode(v as "phases" | "offsets")}>
+              <SelectTrigger className="w-[200px]">
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                <SelectItem value="phases">P and S arrivals</SelectItem>
+                <SelectItem value="offsets">Origin time offsets</SelectItem>
+              </SelectContent>
+            </Select>
+          </div>
+          <div className="space-y-1">
+            <Label htmlFor="extract-before">Before (s)</Label>
+            <Input
+              id="extract-before"
+              value={before}
+              onChange={(e) => setBefore(e.target.value)}
+              className="w-[90px]"
+            />
+          </div>
+          <div className="space-y-1">
+            <Label htmlFor="extract-after">After (s)</Label>
+            <Input
+              id="extract-after"
+              value={after}
+              onChange={(e) => setAfter(e.target.value)}
+              className="w-[90px]"
+            />
+          </div>
+          <Button variant="outline" onClick={() => handleCreate(true)} disabled={createMutation.isPending}>
+            <Eye className="h-4 w-4 mr-2" />
+            Preview
+          </Button>
+          <Button onClick={() => handleCreate(false)} disabled={createMutation.isPending}>
+            <PackageOpen className="h-4 w-4 mr-2" />
+            Extract
+          </Button>
+        </div>
+        <p className="text-xs text-muted-foreground">
+          {windowMode === "phases"
+            ? "Each window runs from the seconds before the predicted first P arrival (IASP91) to the seconds after the first S."
+            : "Each window runs from the seconds before the origin time to the seconds after it."}
+        </p>
+
+        {isLoading ? (
+          <Skeleton className="h-24" />
+        ) : (
+          <div className="border rounded-md overflow-auto">
+            <table className="w-full text-sm">
+              <thead>
+                <tr className="bg-muted/50">
+                  <th className="p-2 text-left">Created (UTC)</th>
+                  <th className="p-2 text-left">Status</th>
+                  <th className="p-2 text-left">Selection</th>
+                  <th className="p-2 text-right">Channels</th>
+                  <th className="p-2 text-right">Fetched</th>
+                  <th className="p-2 text-right">No data</th>
+                  <th className="p-2 text-right">Failed</th>
+                  <th className="p-2 text-right">Size</th>
+                  <th className="p-2 text-left">Actions</th>
+                </tr>
+              </thead>
+              <tbody>
+                {(extractions ?? []).map((x) => (
+                  <tr key={x.id} className="border-t">
+                    <td className="p-2 font-mono">{x.created_at.slice(0, 19).replace("T", " ")}</td>
+                    <td className="p-2">
+                      <Badge variant={statusVariant[x.status]} title={x.error}>
+                        {x.status}
+                      </Badge>
+                    </td>
+                    <td className="p-2 font-mono text-xs">
+                      {x.channel} {x.min_radius}–{x.max_radius}° {x.window} −{x.before}s/+{x.after}s
+                    </td>
+                    <td className="p-2 text-right font-mono">{x.request_count}</td>
+                    <td className="p-2 text-right font-mono">{x.done_count}</td>
+                    <td className="p-2 text-right font-mono">{x.nodata_count}</td>
+                    <td className="p-2 text-right font-mono">{x.failed_count}</td>
+                    <td className="p-2 text-right font-mono">{x.status === "done" ? formatBytes(x.bytes) : "-"}</td>
+                    <td className="p-2 flex gap-1">
+                      {x.status === "done" && (
+                        <Button variant="ghost" size="sm" asChild>
+                          <a href={`${BASE}/api/v1/extractions/${x.id}/bundle`}>
+                            <Download className="h-4 w-4 mr-1" />
+                            Bundle
+                          </a>
+                        </Button>
+                      )}
+                      <Button
+                        variant="ghost"
+                        size="sm"
+                        disabled={x.status === "pending" || x.status === "running"}
+                        onClick={() => handleDelete(x)}
+                      >
+                        <Trash2 className="h-4 w-4" />
+                      </Button>
+                    </td>
+                  </tr>
+                ))}
+                {extractions?.length === 0 && (
+                  <tr>
+                    <td colSpan={9} className="p-8 text-center text-muted-foreground">
+                      No extractions for this event yet.
+                    </td>
+                  </tr>
+                )}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
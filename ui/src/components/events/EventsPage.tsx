@@ -0,0 +1,386 @@
+import { useState } from "react";
+import { useNavigate } from "react-router";
+import { useEvents, useEventStations, useImportEvents, useDeleteEvent } from "@/hooks/useEvents";
+import { useSources } from "@/hooks/useSources";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Skeleton } from "@/components/ui/skeleton";
+import {
+  Card,
+  CardContent,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/components/ui/select";
+import { Download, Trash2, Activity, MapPin } from "lucide-react";
+import { EventExtractions } from "./EventExtractions";
+import { BASE } from "@/lib/api";
+import { toast } from "sonner";
+import type { SeismicEvent, EventStation } from "@/types";
+
+const PAGE_SIZE = 50;
+
+function formatTime(iso: string): string {
+  return iso.slice(0, 19).replace("T", " ");
+}
+
+function formatMagnitude(ev: SeismicEvent): string {
+  return ev.magnitude === null ? "-" : `${ev.magnitude.toFixed(1)} ${ev.magnitude_type}`;
+}
+
+export function EventsPage() {
+  const { data: sources } = useSources();
+  const [importSource, setImportSource] = useState("");
+  const [importStart, setImportStart] = useState("");
+  const [importMinMag, setImportMinMag] = useState("5");
+  const [importLimit, setImportLimit] = useState("100");
+  const importMutation = useImportEvents();
+
+  const [minMag, setMinMag] = useState("");
+  const [orderBy, setOrderBy] = useState("time");
+  const [offset, setOffset] = useState(0);
+  const { data, isLoading } = useEvents({ minmag: minMag, orderby: orderBy, limit: PAGE_SIZE, offset });
+  const deleteMutation = useDeleteEvent();
+
+  const [selected, setSelected] = useState(0);
+
+  const handleImport = () => {
+    if (!importSource) return;
+    importMutation.mutate(
+      {
+        source_id: Number(importSource),
+        starttime: importStart || undefined,
+        minmagnitude: importMinMag || undefined,
+        limit: importLimit || undefined,
+      },
+      {
+        onSuccess: (res) => {
+          toast.success(`${res.source_name}: ${res.fetched} events, ${res.inserted} new, ${res.updated} updated`);
+        },
+        onError: (err) => {
+          toast.error(`Import failed: ${err.message}`);
+        },
+      }
+    );
+  };
+
+  const handleDelete = (ev: SeismicEvent) => {
+    deleteMutation.mutate(ev.id, {
+      onSuccess: () => {
+        if (selected === ev.id) setSelected(0);
+        toast.success("Event removed");
+      },
+      onError: (err) => toast.error(`Delete failed: ${err.message}`),
+    });
+  };
+
+  const events = data?.events ?? [];
+  const total = data?.total ?? 0;
+
+  return (
+    <div className="space-y-6">
+      <div>
+        <h2 className="text-2xl font-bold tracking-tight">Events</h2>
+        <p className="text-muted-foreground">
+          Earthquakes imported from FDSN event services, with the stations that recorded them
+        </p>
+      </div>
+
+      <Card>
+        <CardHeader>
+          <CardTitle className="text-base">Import Events</CardTitle>
+        </CardHeader>
+        <CardContent>
+          <div className="flex flex-wrap items-end gap-4">
+            <div className="space-y-1">
+              <Label>Source</Label>
+              <Select value={importSource} onValueChange={setImportSource}>
+                <SelectTrigger className="w-[180px]">
+                  <SelectValue placeholder="Select a source" />
+                </SelectTrigger>
+                <SelectContent>
+                  {(sources ?? [])
+                    .filter((s) => s.enabled)
+                    .map((s) => (
+                      <SelectItem key={s.id} value={String(s.id)}>
+                        {s.name}
+                      </SelectItem>
+                    ))}
+                </SelectContent>
+              </Select>
+            </div>
+            <div className="space-y-1">
+              <Label htmlFor="event-start">Start time</Label>
+              <Input
+                id="event-start"
+                placeholder="2024-01-01"
+                value={importStart}
+                onChange={(e) => setImportStart(e.target.value)}
+                className="w-[180px]"
+              />
+            </div>
+            <div className="space-y-1">
+              <Label htmlFor="event-minmag">Min magnitude</Label>
+              <Input
+                id="event-minmag"
+                value={importMinMag}
+                onChange={(e) => setImportMinMag(e.target.value)}
+                className="w-[120px]"
+              />
+            </div>
+            <div className="space-y-1">
+              <Label htmlFor="event-limit">Limit</Label>
+              <Input
+                id="event-limit"
+                value={importLimit}
+                onChange={(e) => setImportLimit(e.target.value)}
+                className="w-[100px]"
+              />
+            </div>
+            <Button onClick={handleImport} disabled={!importSource || importMutation.isPending}>
+              <Download className="h-4 w-4 mr-2" />
+              {importMutation.isPending ? "Importing..." : "Import"}
+            </Button>
+          </div>
+        </CardContent>
+      </Card>
+
+      <div className="flex flex-wrap items-center gap-4">
+        <Input
+          placeholder="Min magnitude"
+          value={minMag}
+          onChange={(e) => {
+            setMinMag(e.target.value);
+            setOffset(0);
+          }}
+          className="w-[160px]"
+        />
+        <Select
+          value={orderBy}
+          onValueChange={(v) => {
+            setOrderBy(v);
+            setOffset(0);
+          }}
+        >
+          <SelectTrigger className="w-[180px]">
+            <SelectValue />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectItem value="time">Newest first</SelectItem>
+            <SelectItem value="time-asc">Oldest first</SelectItem>
+            <SelectItem value="magnitude">Largest first</SelectItem>
+            <SelectItem value="magnitude-asc">Smallest first</SelectItem>
+          </SelectContent>
+        </Select>
+        <span className="text-sm text-muted-foreground">
+          {total} events
+        </span>
+        <a
+          className="text-sm underline text-muted-foreground"
+          href={`${BASE}/fdsnws/event/1/query?orderby=${orderBy}${minMag ? `&minmag=${encodeURIComponent(minMag)}` : ""}`}
+          target="_blank"
+          rel="noreferrer"
+        >
+          QuakeML
+        </a>
+      </div>
+
+      {isLoading ? (
+        <Skeleton className="h-64" />
+      ) : (
+        <div className="border rounded-md overflow-auto">
+          <table className="w-full text-sm">
+            <thead>
+              <tr className="bg-muted/50">
+                <th className="p-2 text-left">Time (UTC)</th>
+                <th className="p-2 text-right">Lat</th>
+                <th className="p-2 text-right">Lon</th>
+                <th className="p-2 text-right">Depth (km)</th>
+                <th className="p-2 text-left">Magnitude</th>
+                <th className="p-2 text-left">Region</th>
+                <th className="p-2 text-left">Source</th>
+                <th className="p-2 text-left">Actions</th>
+              </tr>
+            </thead>
+            <tbody>
+              {events.map((ev) => (
+                <tr
+                  key={ev.id}
+                  className={`border-t hover:bg-muted/30 cursor-pointer ${ev.id === selected ? "bg-muted/50" : ""}`}
+                  onClick={() => setSelected(ev.id)}
+                >
+                  <td className="p-2 font-mono">{formatTime(ev.time)}</td>
+                  <td className="p-2 text-right font-mono">{ev.latitude.toFixed(3)}</td>
+                  <td className="p-2 text-right font-mono">{ev.longitude.toFixed(3)}</td>
+                  <td className="p-2 text-right font-mono">
+                    {ev.depth_km === null ? "-" : ev.depth_km.toFixed(1)}
+                  </td>
+                  <td className="p-2 font-mono">{formatMagnitude(ev)}</td>
+                  <td className="p-2">{ev.description}</td>
+                  <td className="p-2">{ev.source_name}</td>
+                  <td className="p-2">
+                    <Button
+                      variant="ghost"
+                      size="sm"
+                      onClick={(e) => {
+                        e.stopPropagation();
+                        handleDelete(ev);
+                      }}
+                    >
+                      <Trash2 className="h-4 w-4" />
+                    </Button>
+                  </td>
+                </tr>
+              ))}
+              {events.length === 0 && (
+                <tr>
+                  <td colSpan={8} className="p-8 text-center text-muted-foreground">
+                    No events imported yet. Import them from a source above.
+                  </td>
+                </tr>
+              )}
+            </tbody>
+          </table>
+        </div>
+      )}
+
+      {total > PAGE_SIZE && (
+        <div className="flex items-center gap-2">
+          <Button
+            variant="outline"
+            size="sm"
+            disabled={offset === 0}
+            onClick={() => setOffset(Math.max(0, offset - PAGE_SIZE))}
+          >
+            Previous
+          </Button>
+          <span className="text-sm text-muted-foreground">
+            {offset + 1}–{Math.min(offset + PAGE_SIZE, total)} of {total}
+          </span>
+          <Button
+            variant="outline"
+            size="sm"
+            disabled={offset + PAGE_SIZE >= total}
+            onClick={() => setOffset(offset + PAGE_SIZE)}
+          >
+            Next
+          </Button>
+        </div>
+      )}
+
+      {selected > 0 && <EventStations eventId={selected} />}
+      {selected > 0 && <EventExtractions eventId={selected} />}
+    </div>
+  );
+}
+
+// EventStations lists the stored stations near an event; each row opens the
+// waveform viewer on the window around the event.
+function EventStations({ eventId }: { eventId: number }) {
+  const navigate = useNavigate();
+  const [maxRadius, setMaxRadius] = useState("10");
+  const radius = Number(maxRadius) || 10;
+  const { data, isLoading, isError, error } = useEventStations(eventId, radius);
+
+  const openWaveforms = (s: EventStation) => {
+    const params = new URLSearchParams({
+      source_id: String(s.source_id ?? 0),
+      network: s.network_code ?? "",
+      station_id: String(s.id),
+      station: s.code,
+      event: String(eventId),
+      starttime: s.window_start.slice(0, 19),
+      endtime: s.window_end.slice(0, 19),
+    });
+    navigate(`/waveforms?${params.toString()}`);
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between gap-4">
+          <CardTitle className="text-base">
+            Stations near {data ? `${data.event.description || data.event.public_id} (${formatMagnitude(data.event)})` : "event"}
+          </CardTitle>
+          <div className="flex items-center gap-2">
+            <Label htmlFor="event-radius" className="text-sm">Max distance (°)</Label>
+            <Input
+              id="event-radius"
+              value={maxRadius}
+              onChange={(e) => setMaxRadius(e.target.value)}
+              className="w-[80px]"
+            />
+            <Button variant="outline" size="sm" onClick={() => navigate(`/map?event=${eventId}`)}>
+              <MapPin className="h-4 w-4 mr-1" />
+              Map
+            </Button>
+          </div>
+        </div>
+      </CardHeader>
+      <CardContent>
+        {isLoading ? (
+          <Skeleton className="h-32" />
+        ) : isError ? (
+          <p className="text-sm text-destructive">{error.message}</p>
+        ) : (
+          <div className="border rounded-md overflow-auto">
+            <table className="w-full text-sm">
+              <thead>
+                <tr className="bg-muted/50">
+                  <th className="p-2 text-left">Network</th>
+                  <th className="p-2 text-left">Station</th>
+                  <th className="p-2 text-left">Site Name</th>
+                  <th className="p-2 text-right">Distance (°)</th>
+                  <th className="p-2 text-right">Distance (km)</th>
+                  <th className="p-2 text-right">Azimuth (°)</th>
+                  <th className="p-2 text-left">Window (UTC)</th>
+                  <th className="p-2 text-left">Actions</th>
+                </tr>
+              </thead>
+              <tbody>
+                {(data?.stations ?? []).map((s) => (
+                  <tr key={s.id} className="border-t hover:bg-muted/30">
+                    <td className="p-2 font-mono">{s.network_code}</td>
+                    <td className="p-2 font-mono font-medium">{s.code}</td>
+                    <td className="p-2">{s.site_name}</td>
+                    <td className="p-2 text-right font-mono">{s.distance_deg.toFixed(2)}</td>
+                    <td className="p-2 text-right font-mono">{s.distance_km.toFixed(0)}</td>
+                    <td className="p-2 text-right font-mono" title={`Back-azimuth ${s.back_azimuth.toFixed(0)}°`}>
+                      {s.azimuth.toFixed(0)}
+                    </td>
+                    <td className="p-2 font-mono">
+                      {formatTime(s.window_start)} – {formatTime(s.window_end).slice(11)}
+                    </td>
+                    <td className="p-2 flex gap-1">
+                      <Button variant="ghost" size="sm" onClick={() => openWaveforms(s)}>
+                        <Activity className="h-4 w-4 mr-1" />
+                        View
+                      </Button>
+                      <Button variant="ghost" size="sm" asChild>
+                        <a href={`${BASE}${s.waveform_url}`}>miniSEED</a>
+                      </Button>
+                    </td>
+                  </tr>
+                ))}
+                {data?.stations.length === 0 && (
+                  <tr>
+                    <td colSpan={8} className="p-8 text-center text-muted-foreground">
+                      No imported stations were operating within {radius}° of this event.
+                    </td>
+                  </tr>
+                )}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
@@ -7,6 +7,7 @@ import { StationSearch } from "./StationSearch";
 import { StationResults } from "./StationResults";
 import { ImportDialog } from "./ImportDialog";
 import { SourcePicker } from "./SourcePicker";
+import { NetworkImport } from "./NetworkImport";
 import { Badge } from "@/components/ui/badge";
 
 interface SearchParams {
@@ -95,6 +96,8 @@ export function ExplorerPage() {
         />
       )}
 
+      <NetworkImport />
+
       {sourceId > 0 && <StationSearch onSearch={handleSearch} />}
 
       {sourceId > 0 && importedNetworks && importedNetworks.length > 0 && (
@@ -0,0 +1,52 @@
+import { useState } from "react";
+import { useImportStations } from "@/hooks/useExplorer";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import { Label } from "@/components/ui/label";
+import { Download } from "lucide-react";
+
+// NetworkImport imports a whole network without choosing a source first: the
+// server looks up the data centre serving it (EIDA routing / fedcatalog) and
+// registers it as a source if needed.
+export function NetworkImport() {
+  const [net, setNet] = useState("");
+  const importMutation = useImportStations();
+
+  const handleSubmit = (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!net.trim()) return;
+    importMutation.mutate({ source_id: 0, network: net.trim().toUpperCase(), station: "" });
+  };
+
+  const result = importMutation.data;
+
+  return (
+    <form onSubmit={handleSubmit} className="space-y-2">
+      <div className="flex items-end gap-3">
+        <div className="space-y-2">
+          <Label>Import network by code</Label>
+          <Input
+            value={net}
+            onChange={(e) => setNet(e.target.value)}
+            placeholder="CH"
+            className="w-24"
+          />
+        </div>
+        <Button type="submit" variant="outline" disabled={importMutation.isPending}>
+          <Download className="h-4 w-4 mr-2" />
+          {importMutation.isPending ? "Importing..." : "Import"}
+        </Button>
+      </div>
+      {result && (
+        <p className="text-sm text-muted-foreground">
+          Imported {result.imported} channels from {result.source_name}
+        </p>
+      )}
+      {importMutation.isError && (
+        <p className="text-sm text-destructive">
+          {(importMutation.error as Error).message}
+        </p>
+      )}
+    </form>
+  );
+}
@@ -1,6 +1,7 @@
 import { Moon, Sun } from "lucide-react";
 import { Button } from "@/components/ui/button";
 import { useEffect, useState } from "react";
+import { SearchBox } from "./SearchBox";
 
 export function Header() {
   const [dark, setDark] = useState(() => {
@@ -16,7 +17,7 @@ export function Header() {
 
   return (
     <header className="h-12 border-b flex items-center justify-between px-4">
-      <div />
+      <SearchBox />
       <Button
         variant="ghost"
         size="icon-sm"
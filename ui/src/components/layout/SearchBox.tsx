@@ -0,0 +1,96 @@
+import { useEffect, useRef, useState } from "react";
+import { useNavigate } from "react-router";
+import { Search } from "lucide-react";
+import { Input } from "@/components/ui/input";
+import { Badge } from "@/components/ui/badge";
+import { useSearch } from "@/hooks/useStations";
+import type { SearchResult } from "@/types";
+
+function resultTitle(r: SearchResult): string {
+  switch (r.kind) {
+    case "network":
+      return r.code;
+    case "station":
+      return `${r.network_code}.${r.code}`;
+    case "channel":
+      return `${r.network_code}.${r.station_code}.${r.location_code ?? ""}.${r.code}`;
+  }
+}
+
+export function SearchBox() {
+  const [input, setInput] = useState("");
+  const [query, setQuery] = useState("");
+  const [open, setOpen] = useState(false);
+  const debounceRef = useRef<ReturnType<typeof setTimeout>>();
+  const navigate = useNavigate();
+  const { data, isFetching } = useSearch(query);
+
+  // Debounce typing
+  useEffect(() => {
+    clearTimeout(debounceRef.current);
+    debounceRef.current = setTimeout(() => setQuery(input), 250);
+    return () => clearTimeout(debounceRef.current);
+  }, [input]);
+
+  const select = (r: SearchResult) => {
+    setOpen(false);
+    setInput("");
+    if (r.kind === "network") {
+      navigate(`/stations?network=${encodeURIComponent(r.code)}`);
+    } else if (r.station_id) {
+      navigate(`/stations/${r.station_id}`);
+    }
+  };
+
+  const results = data?.results ?? [];
+
+  return (
+    <div className="relative w-80">
+      <Search className="absolute left-2.5 top-1/2 -translate-y-1/2 h-4 w-4 text-muted-foreground" />
+      <Input
+        placeholder="Search stations, sites, sensors..."
+        value={input}
+        onChange={(e) => {
+          setInput(e.target.value);
+          setOpen(true);
+        }}
+        onFocus={() => setOpen(true)}
+        onBlur={() => setTimeout(() => setOpen(false), 150)}
+        onKeyDown={(e) => {
+          if (e.key === "Enter" && results.length > 0) select(results[0]);
+          if (e.key === "Escape") setOpen(false);
+        }}
+        className="pl-8 h-8"
+      />
+      {open && query.trim() !== "" && (
+        <div className="absolute z-50 mt-1 w-full rounded-md border bg-popover shadow-md max-h-96 overflow-y-auto">
+          {results.length === 0 ? (
+            <p className="p-3 text-sm text-muted-foreground">
+              {isFetching ? "Searching..." : "No matches"}
+            </p>
+          ) : (
+            results.map((r) => (
+              <button
+                key={`${r.kind}-${r.id}`}
+                type="button"
+                className="w-full text-left px-3 py-2 hover:bg-muted flex items-start gap-2"
+                onMouseDown={(e) => e.preventDefault()}
+                onClick={() => select(r)}
+              >
+                <Badge variant="outline" className="shrink-0 capitalize">
+                  {r.kind}
+                </Badge>
+                <span className="min-w-0">
+                  <span className="block font-mono text-sm">{resultTitle(r)}</span>
+                  {r.label && (
+                    <span className="block text-xs text-muted-foreground truncate">{r.label}</span>
+                  )}
+                </span>
+              </button>
+            ))
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -6,6 +6,7 @@ import {
   Map,
   Activity,
   TestTube,
+  Zap,
 } from "lucide-react";
 import { NavLink } from "react-router";
 import { cn } from "@/lib/utils";
@@ -17,6 +18,7 @@ const navItems = [
   { to: "/stations", label: "Stations", icon: Radio },
   { to: "/map", label: "Map", icon: Map },
   { to: "/waveforms", label: "Waveforms", icon: Activity },
+  { to: "/events", label: "Events", icon: Zap },
   { to: "/fdsn", label: "FDSN Test", icon: TestTube },
 ];
 
@@ -0,0 +1,175 @@
+import { useEffect, useState } from "react";
+import { MapContainer, TileLayer, Marker, CircleMarker, Popup, useMap, useMapEvents } from "react-leaflet";
+import L from "leaflet";
+import type { LatLngBounds } from "leaflet";
+import { useNavigate } from "react-router";
+import { useStationClusters } from "@/hooks/useStations";
+import { useTravelTimes } from "@/hooks/useTools";
+import type { SeismicEvent, StationCluster } from "@/types";
+
+// Fix default marker icons in bundled builds
+const defaultIcon = L.icon({
+  iconUrl: "https://unpkg.com/leaflet@1.9.4/dist/images/marker-icon.png",
+  iconRetinaUrl:
+    "https://unpkg.com/leaflet@1.9.4/dist/images/marker-icon-2x.png",
+  shadowUrl: "https://unpkg.com/leaflet@1.9.4/dist/images/marker-shadow.png",
+  iconSize: [25, 41],
+  iconAnchor: [12, 41],
+  popupAnchor: [1, -34],
+  shadowSize: [41, 41],
+});
+
+// Cluster markers grow with the number of stations they hold
+function clusterIcon(count: number) {
+  const size = count < 10 ? 30 : count < 100 ? 36 : count < 1000 ? 44 : 52;
+  return L.divIcon({
+    html: `<div style="width:${size}px;height:${size}px;line-height:${size}px" class="rounded-full bg-blue-600/80 text-white text-xs font-semibold text-center border-2 border-white shadow">${count}</div>`,
+    className: "",
+    iconSize: [size, size],
+  });
+}
+
+interface Viewport {
+  bbox: string;
+  zoom: number;
+}
+
+function viewportOf(bounds: LatLngBounds, zoom: number): Viewport {
+  return { bbox: bounds.toBBoxString(), zoom };
+}
+
+// Tracks the visible area so clusters are fetched for it
+function ViewportTracker({ onChange }: { onChange: (v: Viewport) => void }) {
+  const map = useMapEvents({
+    moveend: () => onChange(viewportOf(map.getBounds(), map.getZoom())),
+  });
+  useEffect(() => {
+    onChange(viewportOf(map.getBounds(), map.getZoom()));
+  }, [map, onChange]);
+  return null;
+}
+
+// Moves the map to an event once it has loaded
+function CenterOnEvent({ event }: { event: SeismicEvent }) {
+  const map = useMap();
+  useEffect(() => {
+    map.setView([event.latitude, event.longitude], Math.max(map.getZoom(), 3));
+  }, [map, event.id]);
+  return null;
+}
+
+// StationPhases shows the distance to an event and the predicted arrivals
+// at a station. Popups render their content only when open, so travel
+// times are fetched for the stations clicked.
+function StationPhases({ eventId, stationId }: { eventId: number; stationId: number }) {
+  const { data, isLoading } = useTravelTimes(eventId, stationId);
+  if (isLoading) return <span className="text-xs text-gray-500">Computing arrivals...</span>;
+  if (!data?.distance) return null;
+  const d = data.distance;
+  return (
+    <span className="text-xs">
+      {d.distance_deg.toFixed(2)}° ({d.distance_km.toFixed(0)} km), az {d.azimuth.toFixed(0)}°, baz{" "}
+      {d.back_azimuth.toFixed(0)}°
+      {data.travel_times[0]?.arrivals.map((a) => (
+        <span key={a.phase} className="block font-mono">
+          {a.phase} +{a.time.toFixed(1)}s{a.arrival_time ? ` (${a.arrival_time.slice(11, 19)})` : ""}
+        </span>
+      ))}
+    </span>
+  );
+}
+
+function ClusterMarker({ cluster, eventId }: { cluster: StationCluster; eventId: number }) {
+  const navigate = useNavigate();
+  const map = useMapEvents({});
+
+  if (cluster.count === 1 && cluster.station_id !== undefined) {
+    return (
+      <Marker position={[cluster.latitude, cluster.longitude]} icon={defaultIcon}>
+        <Popup>
+          <div className="text-sm">
+            <strong className="font-mono">{cluster.code}</strong>
+            <br />
+            <span className="text-xs text-gray-500">
+              {cluster.latitude.toFixed(4)}, {cluster.longitude.toFixed(4)}
+            </span>
+            <br />
+            {eventId > 0 && (
+              <>
+                <StationPhases eventId={eventId} stationId={cluster.station_id} />
+                <br />
+              </>
+            )}
+            <button
+              className="text-blue-600 underline text-xs mt-1"
+              onClick={() => navigate(`/stations/${cluster.station_id}`)}
+            >
+              View details
+            </button>
+          </div>
+        </Popup>
+      </Marker>
+    );
+  }
+
+  return (
+    <Marker
+      position={[cluster.latitude, cluster.longitude]}
+      icon={clusterIcon(cluster.count)}
+      eventHandlers={{
+        click: () => map.setView([cluster.latitude, cluster.longitude], map.getZoom() + 2),
+      }}
+    />
+  );
+}
+
+// ClusterMap draws every imported station, grouped server-side into
+// clusters for the current viewport so large inventories stay responsive.
+// With an event it also marks the epicentre, and single stations show the
+// predicted arrivals of the event.
+export function ClusterMap({ height = "100%", event }: { height?: string; event?: SeismicEvent }) {
+  const [viewport, setViewport] = useState<Viewport>({ bbox: "", zoom: 2 });
+  const { data } = useStationClusters(viewport.bbox, viewport.zoom);
+
+  return (
+    <MapContainer
+      center={[20, 0]}
+      zoom={2}
+      style={{ height, width: "100%" }}
+      scrollWheelZoom={true}
+    >
+      <TileLayer
+        attribution='&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors'
+        url="https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png"
+      />
+      <ViewportTracker onChange={setViewport} />
+      {event && <CenterOnEvent event={event} />}
+      {event && (
+        <CircleMarker
+          center={[event.latitude, event.longitude]}
+          radius={10}
+          pathOptions={{ color: "#dc2626", fillColor: "#dc2626", fillOpacity: 0.6 }}
+        >
+          <Popup>
+            <div className="text-sm">
+              <strong>{event.description || event.public_id}</strong>
+              <br />
+              <span className="text-xs text-gray-500">
+                {event.time.slice(0, 19).replace("T", " ")} UTC
+                {event.magnitude != null && `, M${event.magnitude.toFixed(1)}`}
+                {event.depth_km != null && `, ${event.depth_km.toFixed(0)} km deep`}
+              </span>
+            </div>
+          </Popup>
+        </CircleMarker>
+      )}
+      {data?.clusters.map((c) => (
+        <ClusterMarker
+          key={`${c.station_id ?? "c"}:${c.latitude}:${c.longitude}`}
+          cluster={c}
+          eventId={event?.id ?? 0}
+        />
+      ))}
+    </MapContainer>
+  );
+}
@@ -1,24 +1,26 @@
-import { useStations } from "@/hooks/useStations";
-import { StationMap } from "./StationMap";
-import { Skeleton } from "@/components/ui/skeleton";
+import { useSearchParams } from "react-router";
+import { ClusterMap } from "./ClusterMap";
+import { useEvent } from "@/hooks/useEvents";
 
 export function MapPage() {
-  const { data, isLoading } = useStations({ limit: 1000 });
+  const [searchParams] = useSearchParams();
+  // Set when opened from an event: its epicentre is drawn and station
+  // popups show the predicted arrivals
+  const eventId = Number(searchParams.get("event")) || 0;
+  const { data: event } = useEvent(eventId);
 
   return (
     <div className="space-y-4 h-full flex flex-col">
       <div>
         <h2 className="text-2xl font-bold tracking-tight">Station Map</h2>
         <p className="text-muted-foreground">
-          Geographic view of imported stations
+          {event
+            ? `Imported stations around ${event.description || event.public_id} (${event.time.slice(0, 19).replace("T", " ")} UTC)`
+            : "Geographic view of imported stations"}
         </p>
       </div>
       <div className="flex-1 min-h-0 rounded-md border overflow-hidden">
-        {isLoading ? (
-          <Skeleton className="h-full w-full" />
-        ) : (
-          <StationMap stations={data?.stations ?? []} />
-        )}
+        <ClusterMap event={event} />
       </div>
     </div>
   );
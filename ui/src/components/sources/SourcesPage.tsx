@@ -2,7 +2,9 @@ import { useState } from "react";
 import {
   useSources,
   useCreateSource,
+  useUpdateSource,
   useDeleteSource,
+  previewDeleteSource,
 } from "@/hooks/useSources";
 import {
   Card,
@@ -22,13 +24,16 @@ import {
   DialogTrigger,
 } from "@/components/ui/dialog";
 import { Badge } from "@/components/ui/badge";
+import { Switch } from "@/components/ui/switch";
 import { Plus, Trash2, ExternalLink } from "lucide-react";
 import { Skeleton } from "@/components/ui/skeleton";
 import { toast } from "sonner";
+import type { Source } from "@/types";
 
 export function SourcesPage() {
   const { data: sources, isLoading } = useSources();
   const createSource = useCreateSource();
+  const updateSource = useUpdateSource();
   const deleteSource = useDeleteSource();
   const [open, setOpen] = useState(false);
 
@@ -52,8 +57,33 @@ export function SourcesPage() {
     );
   };
 
-  const handleDelete = (id: number, name: string) => {
-    if (!confirm(`Delete source "${name}"?`)) return;
+  const handleToggle = (src: Source, enabled: boolean) => {
+    updateSource.mutate(
+      { ...src, enabled },
+      {
+        onSuccess: () =>
+          toast.success(`Source ${enabled ? "enabled" : "disabled"}`),
+        onError: (err) => toast.error(err.message),
+      }
+    );
+  };
+
+  const handleDelete = async (id: number, name: string) => {
+    let message = `Delete source "${name}"?`;
+    try {
+      const preview = await previewDeleteSource(id);
+      if (preview.networks > 0 || preview.events > 0) {
+        message +=
+          `\n\nThis also deletes its ${preview.networks} networks, ` +
+          `${preview.stations} stations, ${preview.channels} channels, ` +
+          `${preview.availability} availability extents and ` +
+          `${preview.events} events.`;
+      }
+    } catch (err) {
+      toast.error((err as Error).message);
+      return;
+    }
+    if (!confirm(message)) return;
     deleteSource.mutate(id, {
       onSuccess: () => toast.success("Source deleted"),
       onError: (err) => toast.error(err.message),
@@ -129,9 +159,22 @@ export function SourcesPage() {
                     </p>
                   )}
                 </div>
-                <Badge variant={src.enabled ? "default" : "secondary"}>
-                  {src.enabled ? "Enabled" : "Disabled"}
-                </Badge>
+                <div className="flex gap-2">
+                  {src.managed_by === "config" && (
+                    <Badge variant="outline" title="Defined in config.yaml; edit it there">
+                      Config
+                    </Badge>
+                  )}
+                  <Badge variant={src.enabled ? "default" : "secondary"}>
+                    {src.enabled ? "Enabled" : "Disabled"}
+                  </Badge>
+                  <Switch
+                    checked={src.enabled}
+                    onCheckedChange={(checked) => handleToggle(src, checked)}
+                    disabled={updateSource.isPending}
+                    aria-label={`Enable ${src.name}`}
+                  />
+                </div>
               </CardHeader>
               <CardContent className="flex items-center justify-between">
                 <a
@@ -143,13 +186,15 @@ export function SourcesPage() {
                   {src.base_url}
                   <ExternalLink className="h-3 w-3" />
                 </a>
-                <Button
-                  variant="ghost"
-                  size="icon-sm"
-                  onClick={() => handleDelete(src.id, src.name)}
-                >
-                  <Trash2 className="h-4 w-4 text-destructive" />
-                </Button>
+                {src.managed_by !== "config" && (
+                  <Button
+                    variant="ghost"
+                    size="icon-sm"
+                    onClick={() => handleDelete(src.id, src.name)}
+                  >
+                    <Trash2 className="h-4 w-4 text-destructive" />
+                  </Button>
+                )}
               </CardContent>
             </Card>
           ))}
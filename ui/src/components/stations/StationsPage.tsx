@@ -1,7 +1,7 @@
 import { useState, useEffect, useRef, useMemo } from "react";
 import { useStations, useNetworks } from "@/hooks/useStations";
 import { useRefreshTargets, useRefreshAll } from "@/hooks/useExplorer";
-import { useNavigate } from "react-router";
+import { useNavigate, useSearchParams } from "react-router";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Badge } from "@/components/ui/badge";
@@ -16,10 +16,31 @@ import {
   SelectTrigger,
   SelectValue,
 } from "@/components/ui/select";
-import { MapPin, List, RefreshCw, Circle, ArrowUp, ArrowDown, ArrowUpDown } from "lucide-react";
+import {
+  DropdownMenu,
+  DropdownMenuContent,
+  DropdownMenuItem,
+  DropdownMenuTrigger,
+} from "@/components/ui/dropdown-menu";
+import { MapPin, List, RefreshCw, Circle, ArrowUp, ArrowDown, ArrowUpDown, Download } from "lucide-react";
+import { BASE } from "@/lib/api";
 import { toast } from "sonner";
 import type { Station } from "@/types";
 
+const EXPORT_FORMATS = [
+  { format: "geojson", label: "GeoJSON (QGIS)" },
+  { format: "kml", label: "KML (Google Earth)" },
+  { format: "csv", label: "CSV" },
+];
+
+// Download URL for the stations matching the current filters
+function exportURL(format: string, network: string, station: string): string {
+  const params = new URLSearchParams({ format });
+  if (network) params.set("network", network);
+  if (station) params.set("station", station);
+  return `${BASE}/api/v1/stations?${params}`;
+}
+
 type SortColumn = "network" | "station" | "site_name" | "data";
 type SortDirection = "asc" | "desc";
 
@@ -88,13 +109,20 @@ function sortStations(stations: Station[], column: SortColumn, direction: SortDi
 }
 
 export function StationsPage() {
-  const [networkFilter, setNetworkFilter] = useState("");
+  const [searchParams] = useSearchParams();
+  const [networkFilter, setNetworkFilter] = useState(searchParams.get("network") ?? "");
   const [stationInput, setStationInput] = useState("");
   const [stationFilter, setStationFilter] = useState("");
   const [sortColumn, setSortColumn] = useState<SortColumn>("data");
   const [sortDirection, setSortDirection] = useState<SortDirection>("desc");
   const debounceRef = useRef<ReturnType<typeof setTimeout>>();
 
+  // Follow ?network= links from the search box, also while already on this page
+  useEffect(() => {
+    const network = searchParams.get("network");
+    if (network) setNetworkFilter(network);
+  }, [searchParams]);
+
   // Debounce station filter input
   useEffect(() => {
     clearTimeout(debounceRef.current);
@@ -184,6 +212,23 @@ export function StationsPage() {
           className="w-40"
         />
         <Badge variant="secondary">{data?.total ?? 0} total</Badge>
+        <DropdownMenu>
+          <DropdownMenuTrigger asChild>
+            <Button variant="outline" size="sm">
+              <Download className="h-4 w-4 mr-2" />
+              Export
+            </Button>
+          </DropdownMenuTrigger>
+          <DropdownMenuContent>
+            {EXPORT_FORMATS.map(({ format, label }) => (
+              <DropdownMenuItem key={format} asChild>
+                <a href={exportURL(format, networkFilter, stationFilter)} download>
+                  {label}
+                </a>
+              </DropdownMenuItem>
+            ))}
+          </DropdownMenuContent>
+        </DropdownMenu>
         <Button
           variant="outline"
           size="sm"
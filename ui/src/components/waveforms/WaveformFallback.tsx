@@ -6,11 +6,20 @@ import {
   XAxis,
   YAxis,
   Tooltip,
+  ReferenceLine,
 } from "recharts";
 
+// A vertical line drawn at a time (ms since the epoch), e.g. a predicted
+// phase arrival
+export interface PlotMarker {
+  time: number;
+  label: string;
+}
+
 interface Props {
   data: { time: number; value: number }[];
   label?: string;
+  markers?: PlotMarker[];
 }
 
 /**
@@ -56,7 +65,7 @@ function decimateMinMax(
   return result;
 }
 
-export function WaveformFallback({ data, label }: Props) {
+export function WaveformFallback({ data, label, markers = [] }: Props) {
   const processed = useMemo(() => {
     if (data.length === 0) return null;
 
@@ -94,6 +103,8 @@ export function WaveformFallback({ data, label }: Props) {
         <LineChart data={processed.sampled}>
           <XAxis
             dataKey="time"
+            type="number"
+            domain={["dataMin", "dataMax"]}
             tickFormatter={(v: number) =>
               new Date(v).toISOString().slice(11, 19)
             }
@@ -116,6 +127,16 @@ export function WaveformFallback({ data, label }: Props) {
             strokeWidth={1}
             isAnimationActive={false}
           />
+          {markers.map((m) => (
+            <ReferenceLine
+              key={m.label}
+              x={m.time}
+              stroke="var(--destructive)"
+              strokeDasharray="4 2"
+              ifOverflow="discard"
+              label={{ value: m.label, position: "insideTopLeft", fontSize: 11 }}
+            />
+          ))}
         </LineChart>
       </ResponsiveContainer>
     </div>
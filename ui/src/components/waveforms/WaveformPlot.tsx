@@ -1,9 +1,10 @@
 import { useEffect, useState } from "react";
-import { WaveformFallback } from "./WaveformFallback";
+import { WaveformFallback, type PlotMarker } from "./WaveformFallback";
 
 interface Props {
   data: ArrayBuffer | null;
   label: string;
+  markers?: PlotMarker[];
 }
 
 interface ParsedTrace {
@@ -11,7 +12,7 @@ interface ParsedTrace {
   values: number[];
 }
 
-export function WaveformPlot({ data, label }: Props) {
+export function WaveformPlot({ data, label, markers }: Props) {
   const [trace, setTrace] = useState<ParsedTrace | null>(null);
 
   useEffect(() => {
@@ -76,7 +77,7 @@ export function WaveformPlot({ data, label }: Props) {
 
   return (
     <div className="space-y-2">
-      <WaveformFallback data={chartData} label={label} />
+      <WaveformFallback data={chartData} label={label} markers={markers} />
       <p className="text-xs text-muted-foreground">
         {data.byteLength.toLocaleString()} bytes |{" "}
         {chartData.length.toLocaleString()} samples
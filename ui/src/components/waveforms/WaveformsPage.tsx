@@ -11,6 +11,7 @@ import { ChannelSelector } from "./ChannelSelector";
 import { TimeRangeSelector } from "./TimeRangeSelector";
 import { WaveformPlot } from "./WaveformPlot";
 import { useWaveformData } from "@/hooks/useWaveforms";
+import { useTravelTimes } from "@/hooks/useTools";
 import { Activity } from "lucide-react";
 
 function isoString(date: Date): string {
@@ -26,7 +27,12 @@ export function WaveformsPage() {
   const initialStationId = Number(searchParams.get("station_id")) || 0;
   const initialStation = searchParams.get("station") || "";
   const initialChannel = searchParams.get("channel") || "";
+  const initialStart = searchParams.get("starttime") || "";
+  const initialEnd = searchParams.get("endtime") || "";
+  // Set when opened from an event, to mark its predicted phase arrivals
+  const initialEventId = Number(searchParams.get("event")) || 0;
 
+  const [eventId] = useState(initialEventId);
   const [sourceId, setSourceId] = useState(initialSourceId);
   const [networkCode, setNetworkCode] = useState(initialNetwork);
   const [stationId, setStationId] = useState(initialStationId);
@@ -34,8 +40,8 @@ export function WaveformsPage() {
   const [channelKey, setChannelKey] = useState(initialChannel);
   const now = new Date();
   const hourAgo = new Date(now.getTime() - 3600 * 1000);
-  const [starttime, setStarttime] = useState(isoString(hourAgo));
-  const [endtime, setEndtime] = useState(isoString(now));
+  const [starttime, setStarttime] = useState(initialStart || isoString(hourAgo));
+  const [endtime, setEndtime] = useState(initialEnd || isoString(now));
   const [availableRange, setAvailableRange] = useState<{
     earliest: string;
     latest: string;
@@ -50,6 +56,11 @@ export function WaveformsPage() {
   }, []);
 
   const { data: waveformData, isLoading, isError, error } = useWaveformData(fetchParams);
+  const { data: travelTimes } = useTravelTimes(eventId, stationId);
+  const arrivals = travelTimes?.travel_times[0]?.arrivals ?? [];
+  const markers = arrivals
+    .filter((a) => a.arrival_time)
+    .map((a) => ({ time: Date.parse(a.arrival_time!), label: a.phase }));
 
   const handleFetch = () => {
     if (!sourceId || !networkCode || !stationId || !channelKey) return;
@@ -67,6 +78,8 @@ export function WaveformsPage() {
 
   const handleAvailabilitySelect = (earliest: string, latest: string) => {
     setAvailableRange({ earliest, latest });
+    // Keep a window passed in the URL, e.g. around an event
+    if (initialStart) return;
     // Auto-set time range to last hour of available data
     const latestDate = new Date(latest);
     const oneHourBefore = new Date(latestDate.getTime() - 3600 * 1000);
@@ -130,7 +143,17 @@ export function WaveformsPage() {
           <WaveformPlot
             data={waveformData ?? null}
             label={channelKey ? `${networkCode}.${stationCode}.${channelKey}` : ""}
+            markers={markers}
           />
+          {travelTimes?.distance && (
+            <p className="text-xs text-muted-foreground mt-2">
+              Event at {travelTimes.distance.distance_deg.toFixed(2)}° (
+              {travelTimes.distance.distance_km.toFixed(0)} km), azimuth{" "}
+              {travelTimes.distance.azimuth.toFixed(0)}°, back-azimuth{" "}
+              {travelTimes.distance.back_azimuth.toFixed(0)}°. Predicted arrivals (IASP91):{" "}
+              {arrivals.map((a) => `${a.phase} +${a.time.toFixed(1)}s`).join(", ")}
+            </p>
+          )}
         </CardContent>
       </Card>
     </div>
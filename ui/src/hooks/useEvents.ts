@@ -0,0 +1,101 @@
+import { useQuery, useMutation, useQueryClient, keepPreviousData } from "@tanstack/react-query";
+import { apiFetch } from "@/lib/api";
+import type { SeismicEvent, EventListResponse, EventStationsResponse, EventImportResponse, Extraction, ExtractionParams } from "@/types";
+
+export function useEvents(params?: {
+  source?: number;
+  minmag?: string;
+  starttime?: string;
+  orderby?: string;
+  limit?: number;
+  offset?: number;
+}) {
+  const searchParams = new URLSearchParams();
+  if (params?.source) searchParams.set("source", String(params.source));
+  if (params?.minmag) searchParams.set("minmag", params.minmag);
+  if (params?.starttime) searchParams.set("starttime", params.starttime);
+  if (params?.orderby) searchParams.set("orderby", params.orderby);
+  if (params?.limit) searchParams.set("limit", String(params.limit));
+  if (params?.offset) searchParams.set("offset", String(params.offset));
+
+  const qs = searchParams.toString();
+  return useQuery<EventListResponse>({
+    queryKey: ["events", qs],
+    queryFn: () => apiFetch(`/api/v1/events${qs ? `?${qs}` : ""}`),
+    placeholderData: keepPreviousData,
+  });
+}
+
+export function useEvent(id: number) {
+  return useQuery<SeismicEvent>({
+    queryKey: ["events", id],
+    queryFn: () => apiFetch(`/api/v1/events/${id}`),
+    enabled: id > 0,
+  });
+}
+
+// Stored stations within maxradius degrees of an event, nearest first, with
+// a waveform window around its origin time.
+export function useEventStations(id: number, maxradius: number) {
+  return useQuery<EventStationsResponse>({
+    queryKey: ["events", id, "stations", maxradius],
+    queryFn: () => apiFetch(`/api/v1/events/${id}/stations?maxradius=${maxradius}`),
+    enabled: id > 0,
+  });
+}
+
+export function useImportEvents() {
+  const qc = useQueryClient();
+  return useMutation({
+    mutationFn: (data: { source_id: number; starttime?: string; endtime?: string; minmagnitude?: string; limit?: string }) =>
+      apiFetch<EventImportResponse>("/api/v1/import/events", {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    onSuccess: () => qc.invalidateQueries({ queryKey: ["events"] }),
+  });
+}
+
+export function useDeleteEvent() {
+  const qc = useQueryClient();
+  return useMutation({
+    mutationFn: (id: number) =>
+      apiFetch(`/api/v1/events/${id}`, { method: "DELETE" }),
+    onSuccess: () => qc.invalidateQueries({ queryKey: ["events"] }),
+  });
+}
+
+// Waveform extractions of an event, newest first. Polled while any is
+// still running.
+export function useExtractions(eventId: number) {
+  return useQuery<Extraction[]>({
+    queryKey: ["extractions", eventId],
+    queryFn: () => apiFetch(`/api/v1/extractions?event=${eventId}`),
+    enabled: eventId > 0,
+    refetchInterval: (query) =>
+      query.state.data?.some((x) => x.status === "pending" || x.status === "running") ? 2000 : false,
+  });
+}
+
+export function useCreateExtraction() {
+  const qc = useQueryClient();
+  return useMutation({
+    mutationFn: ({ eventId, params }: { eventId: number; params: ExtractionParams }) =>
+      apiFetch<Extraction>(`/api/v1/events/${eventId}/extractions`, {
+        method: "POST",
+        body: JSON.stringify(params),
+      }),
+    onSuccess: (_, { params }) => {
+      if (!params.dry_run) qc.invalidateQueries({ queryKey: ["extractions"] });
+    },
+  });
+}
+
+export function useDeleteExtraction() {
+  const qc = useQueryClient();
+  return useMutation({
+    mutationFn: (id: number) =>
+      apiFetch(`/api/v1/extractions/${id}`, { method: "DELETE" }),
+    onSuccess: () => qc.invalidateQueries({ queryKey: ["extractions"] }),
+  });
+}
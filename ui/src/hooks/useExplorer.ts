@@ -54,6 +54,7 @@ export function useImportStations() {
         body: JSON.stringify(params),
       }),
     onSuccess: () => {
+      qc.invalidateQueries({ queryKey: ["sources"] });
       qc.invalidateQueries({ queryKey: ["stations"] });
       qc.invalidateQueries({ queryKey: ["stats"] });
       qc.invalidateQueries({ queryKey: ["networks"] });
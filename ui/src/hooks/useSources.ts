@@ -1,6 +1,6 @@
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
 import { apiFetch } from "@/lib/api";
-import type { Source } from "@/types";
+import type { Source, SourceDeletion } from "@/types";
 
 export function useSources() {
   return useQuery<Source[]>({
@@ -41,11 +41,22 @@ export function useUpdateSource() {
   });
 }
 
+// previewDeleteSource reports what deleting a source would remove.
+export function previewDeleteSource(id: number) {
+  return apiFetch<SourceDeletion>(`/api/v1/sources/${id}?dry_run=true`, {
+    method: "DELETE",
+  });
+}
+
 export function useDeleteSource() {
   const qc = useQueryClient();
   return useMutation({
     mutationFn: (id: number) =>
-      apiFetch(`/api/v1/sources/${id}`, { method: "DELETE" }),
-    onSuccess: () => qc.invalidateQueries({ queryKey: ["sources"] }),
+      apiFetch<SourceDeletion>(`/api/v1/sources/${id}`, { method: "DELETE" }),
+    onSuccess: () => {
+      qc.invalidateQueries({ queryKey: ["sources"] });
+      qc.invalidateQueries({ queryKey: ["stats"] });
+      qc.invalidateQueries({ queryKey: ["stations"] });
+    },
   });
 }
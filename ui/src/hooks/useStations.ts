@@ -1,18 +1,28 @@
-import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
+import { useQuery, useMutation, useQueryClient, keepPreviousData } from "@tanstack/react-query";
 import { apiFetch } from "@/lib/api";
-import type { StationDetail, StationListResponse, Stats, Network, ChannelAvailability } from "@/types";
+import type { StationDetail, StationListResponse, StationClusterResponse, Stats, Network, ChannelAvailability, SearchResponse } from "@/types";
 
 export function useStations(params?: {
   network?: string;
   station?: string;
+  channel?: string;
+  sensor?: string;
+  has_availability?: boolean;
+  sort?: string;
+  order?: "asc" | "desc";
   limit?: number;
-  offset?: number;
+  cursor?: string;
 }) {
   const searchParams = new URLSearchParams();
   if (params?.network) searchParams.set("network", params.network);
   if (params?.station) searchParams.set("station", params.station);
+  if (params?.channel) searchParams.set("channel", params.channel);
+  if (params?.sensor) searchParams.set("sensor", params.sensor);
+  if (params?.has_availability !== undefined) searchParams.set("has_availability", String(params.has_availability));
+  if (params?.sort) searchParams.set("sort", params.sort);
+  if (params?.order) searchParams.set("order", params.order);
   if (params?.limit) searchParams.set("limit", String(params.limit));
-  if (params?.offset) searchParams.set("offset", String(params.offset));
+  if (params?.cursor) searchParams.set("cursor", params.cursor);
 
   const qs = searchParams.toString();
   return useQuery<StationListResponse>({
@@ -21,6 +31,18 @@ export function useStations(params?: {
   });
 }
 
+// Station clusters inside a map viewport; bbox is minlon,minlat,maxlon,maxlat.
+// The previous clusters stay on the map while the next viewport loads.
+export function useStationClusters(bbox: string, zoom: number) {
+  return useQuery<StationClusterResponse>({
+    queryKey: ["stations", "clusters", bbox, zoom],
+    queryFn: () =>
+      apiFetch(`/api/v1/stations/clusters?bbox=${encodeURIComponent(bbox)}&zoom=${zoom}`),
+    enabled: bbox !== "",
+    placeholderData: keepPreviousData,
+  });
+}
+
 export function useStation(id: number) {
   return useQuery<StationDetail>({
     queryKey: ["stations", id],
@@ -82,3 +104,12 @@ export function useStationAvailability(stationId: number) {
     enabled: stationId > 0,
   });
 }
+
+export function useSearch(query: string) {
+  const q = query.trim();
+  return useQuery<SearchResponse>({
+    queryKey: ["search", q],
+    queryFn: () => apiFetch(`/api/v1/search?q=${encodeURIComponent(q)}&limit=10`),
+    enabled: q.length > 0,
+  });
+}
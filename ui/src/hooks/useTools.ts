@@ -0,0 +1,15 @@
+import { useQuery } from "@tanstack/react-query";
+import { apiFetch } from "@/lib/api";
+import type { TravelTimeResponse } from "@/types";
+
+// Predicted phase arrivals at a stored station for a stored event, with
+// the distance and azimuths between them, computed by the server from the
+// IASP91 model.
+export function useTravelTimes(eventId: number, stationId: number) {
+  return useQuery<TravelTimeResponse>({
+    queryKey: ["tools", "traveltime", eventId, stationId],
+    queryFn: () => apiFetch(`/api/v1/tools/traveltime?event=${eventId}&station=${stationId}`),
+    enabled: eventId > 0 && stationId > 0,
+    staleTime: 5 * 60 * 1000,
+  });
+}
@@ -4,11 +4,27 @@ export interface Source {
   base_url: string;
   description: string;
   enabled: boolean;
+  managed_by: "" | "config";
   created_at: string;
   updated_at: string;
   network_count?: number;
   station_count?: number;
   availability_count?: number;
+  has_credentials?: boolean;
+  latency_ms?: number | null;
+  last_checked?: string | null;
+  last_success?: string | null;
+  last_failure?: string | null;
+  last_error?: string;
+  services?: SourceService[];
+}
+
+export interface SourceService {
+  service: string;
+  version: string;
+  parameters: string[];
+  checked_at: string;
+  last_error?: string;
 }
 
 export interface Network {
@@ -73,8 +89,34 @@ export interface StationDetail extends Station {
   availability?: ChannelAvailability[];
 }
 
+export interface StationCluster {
+  latitude: number;
+  longitude: number;
+  count: number;
+  station_id?: number;
+  code?: string;
+}
+
+export interface StationClusterResponse {
+  zoom: number;
+  clusters: StationCluster[];
+}
+
+export interface SourceDeletion {
+  source_id: number;
+  source_name: string;
+  reassigned_to?: number;
+  dry_run: boolean;
+  networks: number;
+  stations: number;
+  channels: number;
+  availability: number;
+  events: number;
+}
+
 export interface Stats {
   sources: number;
+  enabled_sources: number;
   networks: number;
   stations: number;
   channels: number;
@@ -94,6 +136,7 @@ export interface ExploreStation {
 export interface StationListResponse {
   stations: Station[];
   total: number;
+  next_cursor?: string;
 }
 
 export interface RefreshTarget {
@@ -103,8 +146,153 @@ export interface RefreshTarget {
 }
 
 export interface ImportResponse {
+  source_id: number;
+  source_name: string;
   imported: number;
   availability_count: number;
   availability_error?: string;
   availability_status?: string;
 }
+
+export type SearchKind = "network" | "station" | "channel";
+
+export interface SearchResult {
+  kind: SearchKind;
+  id: number;
+  station_id?: number;
+  network_code: string;
+  station_code?: string;
+  location_code?: string;
+  code: string;
+  label: string;
+  score: number;
+}
+
+export interface SearchResponse {
+  query: string;
+  results: SearchResult[];
+}
+
+export interface SeismicEvent {
+  id: number;
+  source_id: number;
+  public_id: string;
+  type: string;
+  description: string;
+  time: string;
+  latitude: number;
+  longitude: number;
+  depth_km: number | null;
+  magnitude: number | null;
+  magnitude_type: string;
+  catalog: string;
+  contributor: string;
+  source_name?: string;
+}
+
+export interface EventListResponse {
+  events: SeismicEvent[];
+  total: number;
+}
+
+export interface EventStation extends Station {
+  distance_km: number;
+  distance_deg: number;
+  azimuth: number;
+  back_azimuth: number;
+  window_start: string;
+  window_end: string;
+  waveform_url: string;
+}
+
+export interface EventStationsResponse {
+  event: SeismicEvent;
+  stations: EventStation[];
+}
+
+export interface EventImportResponse {
+  source_id: number;
+  source_name: string;
+  fetched: number;
+  inserted: number;
+  updated: number;
+}
+
+export interface ExtractionRequest {
+  id: number;
+  source_id: number;
+  network_code: string;
+  station_code: string;
+  location_code: string;
+  channel_code: string;
+  distance_deg: number;
+  p_arrival: number | null;
+  s_arrival: number | null;
+  start_time: string;
+  end_time: string;
+  status: "pending" | "done" | "nodata" | "failed";
+  bytes: number;
+  error?: string;
+}
+
+export interface Extraction {
+  id: number;
+  event_id: number;
+  status: "pending" | "running" | "done" | "failed";
+  window: "phases" | "offsets";
+  before: number;
+  after: number;
+  channel: string;
+  min_radius: number;
+  max_radius: number;
+  bytes: number;
+  error?: string;
+  created_at: string;
+  finished_at: string | null;
+  request_count: number;
+  done_count: number;
+  nodata_count: number;
+  failed_count: number;
+  event_description?: string;
+  requests?: ExtractionRequest[];
+}
+
+export interface ExtractionParams {
+  min_radius?: number;
+  max_radius?: number;
+  channel?: string;
+  window?: "phases" | "offsets";
+  before?: number;
+  after?: number;
+  dry_run?: boolean;
+}
+
+export interface ToolDistance {
+  event_id?: number;
+  station_id?: number;
+  network_code?: string;
+  station_code?: string;
+  event_latitude: number;
+  event_longitude: number;
+  station_latitude: number;
+  station_longitude: number;
+  distance_deg: number;
+  distance_km: number;
+  azimuth: number;
+  back_azimuth: number;
+}
+
+export interface PhaseArrival {
+  phase: string;
+  time: number;
+  ray_param: number;
+  arrival_time?: string;
+}
+
+export interface TravelTimeResponse {
+  model: string;
+  depth_km: number;
+  origin_time?: string;
+  distance?: ToolDistance;
+  travel_times: { distance_deg: number; arrivals: PhaseArrival[] }[];
+}